@@ -0,0 +1,577 @@
+package bitmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// parser for .bdf bitmap fonts, the text format
+// from which .pcf files are usually compiled
+
+// See https://adobe-type-tools.github.io/font-tech-notes/pdfs/5005.BDF_Spec.pdf
+// and bdftopcf for the conversion to the structures used by PCF.
+
+const bdfHeader = "STARTFONT"
+
+// maximum length of a line
+const bdfMaxLineSize = 1 << 16
+
+// isBDF returns true if `file` (possibly gzip compressed) starts with the BDF header.
+func isBDF(file fonts.Resource) (bool, error) {
+	r, err := decompressedReader(file)
+	if err != nil {
+		return false, err
+	}
+	var header [len(bdfHeader)]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false, nil // not a BDF file, let the PCF parser report the error
+	}
+	return string(header[:]) == bdfHeader, nil
+}
+
+type bdfParser struct {
+	scanner *bufio.Scanner
+	line    int        // current line number, for errors
+	pending *[2]string // line read but not consumed, returned by the next call to next()
+}
+
+// returns the next non empty line, which is not a comment,
+// split in keyword and (trimmed) arguments
+func (p *bdfParser) next() (keyword, args string, err error) {
+	if p.pending != nil {
+		keyword, args, p.pending = p.pending[0], p.pending[1], nil
+		return keyword, args, nil
+	}
+	for p.scanner.Scan() {
+		p.line++
+		line := strings.TrimSpace(p.scanner.Text())
+		if line == "" {
+			continue
+		}
+		keyword, args = line, ""
+		if i := strings.IndexAny(line, " \t"); i != -1 {
+			keyword, args = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		if keyword == "COMMENT" {
+			continue
+		}
+		return keyword, args, nil
+	}
+	if err := p.scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("invalid BDF file: %s", err)
+	}
+	return "", "", errors.New("invalid BDF file: unexpected end of file")
+}
+
+func (p *bdfParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid BDF file (line %d): %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// parse `count` integers from `args`
+func (p *bdfParser) ints(keyword, args string, count int) ([]int, error) {
+	fields := strings.Fields(args)
+	if len(fields) < count {
+		return nil, p.errorf("expected %d values for %s", count, keyword)
+	}
+	out := make([]int, count)
+	for i := range out {
+		v, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, p.errorf("invalid value for %s: %s", keyword, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// parses a property value, which is either a quoted string or an integer
+func parseBDFPropertyValue(value string) Property {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		// a double quote is escaped by another one
+		return Atom(strings.ReplaceAll(value[1:len(value)-1], `""`, `"`))
+	}
+	if v, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return Int(v)
+	}
+	// be lenient with unquoted strings
+	return Atom(value)
+}
+
+// the global information, used as default for the glyphs
+type bdfHeaderInfo struct {
+	fontName     string
+	size         []int // point size, x and y resolution
+	boundingBox  []int // width, height, x and y offsets
+	dWidth       int
+	sWidth       int
+	hasBBox      bool
+	numberChars  int // as announced by CHARS
+	hasCharCount bool
+}
+
+func (p *bdfParser) properties(args string) (propertiesTable, error) {
+	count, err := strconv.Atoi(args)
+	if err != nil || count < 0 {
+		return nil, p.errorf("invalid number of properties: %s", args)
+	}
+	if count > nbPropertiesMax {
+		return nil, fmt.Errorf("number of properties (%d) exceeds implementation limit (%d)",
+			count, nbPropertiesMax)
+	}
+	out := make(propertiesTable, count)
+	for {
+		keyword, value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if keyword == "ENDPROPERTIES" {
+			break
+		}
+		if len(out) >= nbPropertiesMax {
+			return nil, fmt.Errorf("number of properties exceeds implementation limit (%d)", nbPropertiesMax)
+		}
+		out[keyword] = parseBDFPropertyValue(value)
+	}
+	return out, nil
+}
+
+func (p *bdfParser) header() (info bdfHeaderInfo, props propertiesTable, err error) {
+	keyword, _, err := p.next()
+	if err != nil {
+		return info, nil, err
+	}
+	if keyword != bdfHeader {
+		return info, nil, errors.New("not a BDF file")
+	}
+	for {
+		keyword, args, err := p.next()
+		if err != nil {
+			return info, nil, err
+		}
+		switch keyword {
+		case "FONT":
+			info.fontName = args
+		case "SIZE":
+			info.size, err = p.ints(keyword, args, 3)
+		case "FONTBOUNDINGBOX":
+			info.boundingBox, err = p.ints(keyword, args, 4)
+			info.hasBBox = true
+		case "DWIDTH":
+			var v []int
+			v, err = p.ints(keyword, args, 1)
+			if err == nil {
+				info.dWidth = v[0]
+			}
+		case "SWIDTH":
+			var v []int
+			v, err = p.ints(keyword, args, 1)
+			if err == nil {
+				info.sWidth = v[0]
+			}
+		case "STARTPROPERTIES":
+			props, err = p.properties(args)
+		case "CHARS":
+			var v []int
+			v, err = p.ints(keyword, args, 1)
+			if err == nil {
+				info.numberChars, info.hasCharCount = v[0], true
+			}
+			if info.numberChars > nbMetricsMax || info.numberChars < 0 {
+				return info, nil, fmt.Errorf("number of glyphs (%d) exceeds implementation limit (%d)",
+					info.numberChars, nbMetricsMax)
+			}
+		case "STARTCHAR", "ENDFONT":
+			if !info.hasCharCount {
+				return info, nil, p.errorf("missing CHARS")
+			}
+			p.pending = &[2]string{keyword, args}
+			return info, props, nil
+		default:
+			// other keywords (METRICSSET, vertical metrics, ...) are ignored
+		}
+		if err != nil {
+			return info, nil, err
+		}
+	}
+}
+
+type bdfGlyph struct {
+	name   string
+	code   int    // -1 for unencoded glyphs
+	bbx    []int  // width, height, x and y offsets
+	bitmap []byte // rows padded to a byte boundary
+	metric metric // resolved when reading BITMAP
+
+	sWidth, dWidth int
+	hasBBX         bool
+	hasSW, hasDW   bool
+}
+
+// parse a glyph, after STARTCHAR
+func (p *bdfParser) glyph(name string, info bdfHeaderInfo) (out bdfGlyph, err error) {
+	out.name = name
+	out.code = -1
+	for {
+		keyword, args, err := p.next()
+		if err != nil {
+			return out, err
+		}
+		switch keyword {
+		case "ENCODING":
+			fields := strings.Fields(args)
+			if len(fields) == 0 {
+				return out, p.errorf("missing ENCODING value")
+			}
+			out.code, err = strconv.Atoi(fields[0])
+			if err != nil {
+				return out, p.errorf("invalid ENCODING: %s", err)
+			}
+			// the optional second value is the code in a non standard encoding,
+			// which we ignore, as bdftopcf does
+		case "SWIDTH":
+			var v []int
+			if v, err = p.ints(keyword, args, 1); err != nil {
+				return out, err
+			}
+			out.sWidth, out.hasSW = v[0], true
+		case "DWIDTH":
+			var v []int
+			if v, err = p.ints(keyword, args, 1); err != nil {
+				return out, err
+			}
+			out.dWidth, out.hasDW = v[0], true
+		case "BBX":
+			if out.bbx, err = p.ints(keyword, args, 4); err != nil {
+				return out, err
+			}
+			out.hasBBX = true
+		case "BITMAP":
+			if err = out.resolveMetrics(info); err != nil {
+				return out, p.errorf("%s", err)
+			}
+			if err = p.bitmap(&out); err != nil {
+				return out, err
+			}
+		case "ENDCHAR":
+			if out.bitmap == nil { // glyph without BITMAP
+				if err = out.resolveMetrics(info); err != nil {
+					return out, p.errorf("%s", err)
+				}
+				// use an empty bitmap, as bdftopcf does
+				out.metric.rightSideBearing = out.metric.leftSideBearing
+				out.metric.characterAscent, out.metric.characterDescent = 0, 0
+				out.bitmap = []byte{}
+			}
+			return out, nil
+		default:
+			// other keywords (vertical metrics, ...) are ignored
+		}
+	}
+}
+
+// use the header values as default
+func (g *bdfGlyph) resolveMetrics(info bdfHeaderInfo) error {
+	bbx := g.bbx
+	if !g.hasBBX {
+		if !info.hasBBox {
+			return errors.New("missing BBX")
+		}
+		bbx = info.boundingBox
+	}
+	width, height, xOff, yOff := bbx[0], bbx[1], bbx[2], bbx[3]
+	if width < 0 || height < 0 || width > 0x7FFF || height > 0x7FFF {
+		return fmt.Errorf("invalid glyph size %dx%d", width, height)
+	}
+	dWidth := g.dWidth
+	if !g.hasDW {
+		dWidth = info.dWidth
+	}
+	if !g.hasSW {
+		g.sWidth = info.sWidth
+	}
+	g.metric = metric{
+		leftSideBearing:  int16(xOff),
+		rightSideBearing: int16(xOff + width),
+		characterWidth:   int16(dWidth),
+		characterAscent:  int16(yOff + height),
+		characterDescent: int16(-yOff),
+	}
+	return nil
+}
+
+// read the hexadecimal rows, after BITMAP
+func (p *bdfParser) bitmap(g *bdfGlyph) error {
+	width := int(g.metric.rightSideBearing - g.metric.leftSideBearing)
+	height := int(g.metric.characterAscent + g.metric.characterDescent)
+	stride := (width + 7) / 8
+	// the buffer grows with the rows actually read, so that
+	// a bogus BBX does not trigger a huge allocation
+	g.bitmap = []byte{}
+	for y := 0; y < height; y++ {
+		if !p.scanner.Scan() {
+			return p.errorf("missing bitmap rows for glyph %s", g.name)
+		}
+		p.line++
+		row := strings.TrimSpace(p.scanner.Text())
+		if row == "ENDCHAR" {
+			return p.errorf("missing bitmap rows for glyph %s", g.name)
+		}
+		// rows may be padded with more bytes than needed, or be too short
+		if len(row)%2 == 1 {
+			row += "0"
+		}
+		if len(row) > 2*stride {
+			row = row[:2*stride]
+		}
+		g.bitmap = append(g.bitmap, make([]byte, stride)...)
+		if _, err := hex.Decode(g.bitmap[y*stride:], []byte(row)); err != nil {
+			return p.errorf("invalid bitmap row: %s", err)
+		}
+	}
+	return nil
+}
+
+// ParseBDF parse a .bdf font file (possibly gzip compressed),
+// producing the same structure as a .pcf file.
+func ParseBDF(file fonts.Resource) (*Font, error) {
+	r, err := decompressedReader(file)
+	if err != nil {
+		return nil, err
+	}
+	p := bdfParser{scanner: bufio.NewScanner(r)}
+	p.scanner.Buffer(nil, bdfMaxLineSize)
+
+	info, props, err := p.header()
+	if err != nil {
+		return nil, err
+	}
+	if props == nil {
+		props = make(propertiesTable)
+	}
+
+	var glyphs []bdfGlyph
+	for {
+		keyword, args, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if keyword == "ENDFONT" {
+			break
+		}
+		if keyword != "STARTCHAR" {
+			return nil, p.errorf("unexpected %s", keyword)
+		}
+		if len(glyphs) >= nbMetricsMax {
+			return nil, fmt.Errorf("number of glyphs exceeds implementation limit (%d)", nbMetricsMax)
+		}
+		glyph, err := p.glyph(args, info)
+		if err != nil {
+			return nil, err
+		}
+		glyphs = append(glyphs, glyph)
+	}
+
+	out := Font{
+		properties:     props,
+		metrics:        make(metricsTable, len(glyphs)),
+		scalableWidths: make(scalableWidthsTable, len(glyphs)),
+		names:          make(namesTable, len(glyphs)),
+	}
+	var bitmaps bytes.Buffer
+	out.bitmap.offsets = make([]uint32, len(glyphs))
+	for i, glyph := range glyphs {
+		out.metrics[i] = glyph.metric
+		out.scalableWidths[i] = uint32(glyph.sWidth)
+		out.names[i] = glyph.name
+		out.bitmap.offsets[i] = uint32(bitmaps.Len())
+		bitmaps.Write(glyph.bitmap)
+	}
+	out.bitmap.data = bitmaps.Bytes()
+	out.bitmap.padding = 1 // MSB first
+
+	out.addBDFHeaderProperties(info)
+	out.accelerator = computeAccelerator(out.metrics, out.properties)
+	encoding := buildEncoding(glyphs)
+	if code, ok := out.properties["DEFAULT_CHAR"].(Int); ok {
+		encoding.defaultChar, _ = encoding.lookupGID(rune(code))
+	}
+
+	err = out.concludeParsing(encoding)
+	return &out, err
+}
+
+// lookupGID returns the glyph index for `code`, or 0xFFFF
+func (enc encodingTable) lookupGID(code rune) (gid, bool) {
+	g, ok := enc.Lookup(code)
+	if !ok {
+		return 0xFFFF, false
+	}
+	return gid(g), true
+}
+
+// add the properties stored in the header, as bdftopcf does
+func (f *Font) addBDFHeaderProperties(info bdfHeaderInfo) {
+	props := f.properties
+	if _, has := props["FONT"]; !has && info.fontName != "" {
+		props["FONT"] = Atom(info.fontName)
+	}
+	if len(info.size) == 3 {
+		pointSize, resX, resY := info.size[0], info.size[1], info.size[2]
+		if _, has := props["POINT_SIZE"]; !has {
+			props["POINT_SIZE"] = Int(pointSize * 10) // in decipoints
+		}
+		if _, has := props["PIXEL_SIZE"]; !has {
+			props["PIXEL_SIZE"] = Int((pointSize*resY*10 + 361) / 722) // rounded
+		}
+		if _, has := props["RESOLUTION_X"]; !has {
+			props["RESOLUTION_X"] = Int(resX)
+		}
+		if _, has := props["RESOLUTION_Y"]; !has {
+			props["RESOLUTION_Y"] = Int(resY)
+		}
+	}
+}
+
+// computeAccelerator computes the global metrics of the font,
+// using the FONT_ASCENT and FONT_DESCENT properties if present.
+func computeAccelerator(metrics metricsTable, props propertiesTable) *acceleratorTable {
+	var out acceleratorTable
+	for i, m := range metrics {
+		if i == 0 {
+			out.minbounds, out.maxbounds = m, m
+			continue
+		}
+		out.minbounds = minMetric(out.minbounds, m)
+		out.maxbounds = maxMetric(out.maxbounds, m)
+	}
+	out.inkMinbounds, out.inkMaxbounds = out.minbounds, out.maxbounds
+
+	if ascent, ok := props["FONT_ASCENT"].(Int); ok {
+		out.fontAscent = int32(ascent)
+	} else {
+		out.fontAscent = int32(out.maxbounds.characterAscent)
+	}
+	if descent, ok := props["FONT_DESCENT"].(Int); ok {
+		out.fontDescent = int32(descent)
+	} else {
+		out.fontDescent = int32(out.maxbounds.characterDescent)
+	}
+
+	// adapted from FontComputeInfoAccelerators (libXfont)
+	maxOverlap := int16(-0x8000)
+	for _, m := range metrics {
+		if overlap := m.rightSideBearing - m.characterWidth; overlap > maxOverlap {
+			maxOverlap = overlap
+		}
+	}
+	out.maxOverlap = int32(maxOverlap)
+	out.noOverlap = maxOverlap <= out.minbounds.leftSideBearing
+	out.constantWidth = out.minbounds.characterWidth == out.maxbounds.characterWidth
+	min, max := out.minbounds, out.maxbounds
+	out.constantMetrics = min.leftSideBearing == max.leftSideBearing &&
+		min.rightSideBearing == max.rightSideBearing &&
+		out.constantWidth &&
+		min.characterAscent == max.characterAscent &&
+		min.characterDescent == max.characterDescent
+	out.terminalFont = out.constantMetrics && min.leftSideBearing == 0 &&
+		min.rightSideBearing == min.characterWidth &&
+		int32(min.characterAscent) == out.fontAscent &&
+		int32(min.characterDescent) == out.fontDescent
+	out.inkInside = min.leftSideBearing >= 0 && maxOverlap <= 0 &&
+		int32(max.characterAscent) <= out.fontAscent &&
+		int32(max.characterDescent) <= out.fontDescent
+	return &out
+}
+
+func minMetric(m1, m2 metric) metric {
+	if m2.leftSideBearing < m1.leftSideBearing {
+		m1.leftSideBearing = m2.leftSideBearing
+	}
+	if m2.rightSideBearing < m1.rightSideBearing {
+		m1.rightSideBearing = m2.rightSideBearing
+	}
+	if m2.characterWidth < m1.characterWidth {
+		m1.characterWidth = m2.characterWidth
+	}
+	if m2.characterAscent < m1.characterAscent {
+		m1.characterAscent = m2.characterAscent
+	}
+	if m2.characterDescent < m1.characterDescent {
+		m1.characterDescent = m2.characterDescent
+	}
+	return m1
+}
+
+func maxMetric(m1, m2 metric) metric {
+	if m2.leftSideBearing > m1.leftSideBearing {
+		m1.leftSideBearing = m2.leftSideBearing
+	}
+	if m2.rightSideBearing > m1.rightSideBearing {
+		m1.rightSideBearing = m2.rightSideBearing
+	}
+	if m2.characterWidth > m1.characterWidth {
+		m1.characterWidth = m2.characterWidth
+	}
+	if m2.characterAscent > m1.characterAscent {
+		m1.characterAscent = m2.characterAscent
+	}
+	if m2.characterDescent > m1.characterDescent {
+		m1.characterDescent = m2.characterDescent
+	}
+	return m1
+}
+
+// buildEncoding stores the glyph codes in the PCF encoding table,
+// ignoring unencoded glyphs and codes not fitting in two bytes.
+// When several glyphs have the same code, the first is used.
+func buildEncoding(glyphs []bdfGlyph) encodingTable {
+	out := encodingTable{minChar: 0xFF, minByte: 0xFF}
+	hasCode := false
+	for _, glyph := range glyphs {
+		if glyph.code < 0 || glyph.code > 0xFFFF {
+			continue
+		}
+		hasCode = true
+		enc1, enc2 := byte(glyph.code>>8), byte(glyph.code)
+		if enc1 < out.minByte {
+			out.minByte = enc1
+		}
+		if enc1 > out.maxByte {
+			out.maxByte = enc1
+		}
+		if enc2 < out.minChar {
+			out.minChar = enc2
+		}
+		if enc2 > out.maxChar {
+			out.maxChar = enc2
+		}
+	}
+	if !hasCode {
+		return encodingTable{values: []gid{0xFFFF}}
+	}
+
+	L := int(out.maxChar-out.minChar) + 1
+	out.values = make([]gid, int(out.maxByte-out.minByte+1)*L)
+	for i := range out.values {
+		out.values[i] = 0xFFFF
+	}
+	for i, glyph := range glyphs {
+		if glyph.code < 0 || glyph.code > 0xFFFF {
+			continue
+		}
+		enc1, enc2 := byte(glyph.code>>8), byte(glyph.code)
+		index := int(enc1-out.minByte)*L + int(enc2-out.minChar)
+		if out.values[index] == 0xFFFF {
+			out.values[index] = gid(i)
+		}
+	}
+	return out
+}
@@ -0,0 +1,235 @@
+package bitmap
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func TestParseBDF(t *testing.T) {
+	// test/8x16.bdf.gz has been converted from test/8x16.pcf.gz
+	fi, err := os.Open("test/8x16.pcf.gz")
+	if err != nil {
+		t.Fatal("can't read test file", err)
+	}
+	defer fi.Close()
+	pcf, err := Parse(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi2, err := os.Open("test/8x16.bdf.gz")
+	if err != nil {
+		t.Fatal("can't read test file", err)
+	}
+	defer fi2.Close()
+	bdf, err := ParseBDF(fi2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(pcf.properties, bdf.properties) {
+		t.Fatalf("expected properties %v, got %v", pcf.properties, bdf.properties)
+	}
+	if !reflect.DeepEqual(pcf.metrics, bdf.metrics) {
+		t.Fatal("different metrics")
+	}
+	if !reflect.DeepEqual(pcf.names, bdf.names) || !reflect.DeepEqual(pcf.scalableWidths, bdf.scalableWidths) {
+		t.Fatal("different glyph names or scalable widths")
+	}
+	if pcf.accelerator.fontAscent != bdf.accelerator.fontAscent || pcf.accelerator.fontDescent != bdf.accelerator.fontDescent {
+		t.Fatal("different ascent or descent")
+	}
+	if pcf.accelerator.minbounds != bdf.accelerator.minbounds || pcf.accelerator.maxbounds != bdf.accelerator.maxbounds {
+		t.Fatal("different bounds")
+	}
+	for r := rune(0); r <= 0x10000; r++ {
+		g1, ok1 := pcf.cmap.Lookup(r)
+		g2, ok2 := bdf.cmap.Lookup(r)
+		if ok1 != ok2 || (ok1 && g1 != g2) {
+			t.Fatalf("rune 0x%04x: expected %d %v, got %d %v", r, g1, ok1, g2, ok2)
+		}
+	}
+
+	// check the rows of a known glyph, since both formats could be decoded the same wrong way
+	expRows := []byte{0x00, 0x10, 0x28, 0x28, 0x28, 0x44, 0x44, 0x44, 0x44, 0x7C, 0x82, 0x82, 0x82, 0xC6, 0x00, 0x00}
+	for _, font := range []*Font{pcf, bdf} {
+		gid, _ := font.cmap.Lookup('A')
+		glyph, err := font.GlyphData(gid, 0, 0).(fonts.GlyphBitmap).ByteAligned()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(glyph.Data, expRows) {
+			t.Fatalf("glyph 'A': expected %v, got %v", expRows, glyph.Data)
+		}
+	}
+
+	for gid := range pcf.metrics {
+		img1, err := pcf.GlyphData(fonts.GID(gid), 0, 0).(fonts.GlyphBitmap).Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img2, err := bdf.GlyphData(fonts.GID(gid), 0, 0).(fonts.GlyphBitmap).Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(img1, img2) {
+			t.Fatalf("glyph %d: different images", gid)
+		}
+	}
+}
+
+const bdfSample = `STARTFONT 2.1
+COMMENT a small font
+FONT -Test-Sample-Medium-R-Normal--4-40-75-75-C-40-ISO10646-1
+SIZE 4 75 75
+FONTBOUNDINGBOX 4 4 0 -1
+STARTPROPERTIES 5
+FAMILY_NAME "Sample"
+COPYRIGHT "A ""quoted"" copyright"
+CHARSET_REGISTRY "ISO10646"
+CHARSET_ENCODING "1"
+DEFAULT_CHAR 65
+ENDPROPERTIES
+CHARS 3
+STARTCHAR A
+ENCODING 65
+SWIDTH 750 0
+DWIDTH 4 0
+BBX 3 3 0 0
+BITMAP
+40
+A0
+E000
+ENDCHAR
+COMMENT an unencoded glyph, using the font bounding box
+STARTCHAR unencoded
+ENCODING -1 12
+DWIDTH 4 0
+BITMAP
+F0
+90
+90
+F
+ENDCHAR
+STARTCHAR B
+ENCODING 0x100
+ENDFONT
+`
+
+func TestParseBDFSample(t *testing.T) {
+	// the last glyph has an invalid encoding
+	if _, err := ParseBDF(bytes.NewReader([]byte(bdfSample))); err == nil {
+		t.Fatal("expected error for invalid ENCODING")
+	}
+
+	sample := strings.Replace(bdfSample, "ENCODING 0x100", "ENCODING 256\nDWIDTH 5 0\nBBX 0 0 0 0\nBITMAP\nENDCHAR", 1)
+	font, err := ParseBDF(bytes.NewReader([]byte(sample)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(font.metrics) != 3 {
+		t.Fatalf("expected 3 glyphs, got %d", len(font.metrics))
+	}
+	if exp := Atom(`A "quoted" copyright`); font.properties["COPYRIGHT"] != exp {
+		t.Fatalf("expected %s, got %s", exp, font.properties["COPYRIGHT"])
+	}
+	if font.properties["POINT_SIZE"] != Int(40) || font.properties["PIXEL_SIZE"] != Int(4) {
+		t.Fatalf("unexpected sizes %v %v", font.properties["POINT_SIZE"], font.properties["PIXEL_SIZE"])
+	}
+	if font.accelerator.fontAscent != 3 || font.accelerator.fontDescent != 1 {
+		t.Fatalf("unexpected ascent or descent %d %d", font.accelerator.fontAscent, font.accelerator.fontDescent)
+	}
+	if exp := (metric{0, 4, 4, 3, 1, 0}); font.metrics[1] != exp {
+		t.Fatalf("expected %v, got %v", exp, font.metrics[1])
+	}
+
+	if g, ok := font.cmap.Lookup('A'); !ok || g != 0 {
+		t.Fatalf("unexpected glyph for A: %d", g)
+	}
+	if g, ok := font.cmap.Lookup(0x100); !ok || g != 2 {
+		t.Fatalf("unexpected glyph for 0x100: %d", g)
+	}
+	if _, ok := font.cmap.Lookup(12); ok {
+		t.Fatal("unexpected glyph for 12")
+	}
+	if _, enc := font.Cmap(); enc != fonts.EncUnicode {
+		t.Fatalf("expected Unicode cmap, got %d", enc)
+	}
+
+	data := font.GlyphData(0, 0, 0).(fonts.GlyphBitmap)
+	if exp := []byte{0x40, 0xA0, 0xE0}; !bytes.Equal(data.Data, exp) {
+		t.Fatalf("expected %v, got %v", exp, data.Data)
+	}
+	data = font.GlyphData(1, 0, 0).(fonts.GlyphBitmap)
+	if exp := []byte{0xF0, 0x90, 0x90, 0xF0}; !bytes.Equal(data.Data, exp) {
+		t.Fatalf("expected %v, got %v", exp, data.Data)
+	}
+
+	for _, invalid := range []string{
+		strings.Replace(sample, "E000", "EZ", 1),                         // invalid hexadecimal
+		strings.Replace(sample, "F\nENDCHAR", "ENDCHAR", 1),              // missing row
+		strings.Replace(sample, "CHARS 3\n", "", 1),                      // missing CHARS
+		strings.Replace(sample, "ENDFONT\n", "", 1),                      // truncated
+		strings.Replace(sample, "FONTBOUNDINGBOX 4 4 0 -1\n", "", 1),     // missing BBX
+		strings.Replace(sample, "BBX 0 0 0 0", "BBX 32767 32767 0 0", 1), // oversized BBX, with missing rows
+		"STARTFOND 2.1\n",
+	} {
+		if _, err := ParseBDF(bytes.NewReader([]byte(invalid))); err == nil {
+			t.Fatalf("expected error for invalid BDF file %s", invalid)
+		}
+	}
+}
+
+func TestParseBDFOversized(t *testing.T) {
+	// a glyph without BITMAP is empty, whatever its BBX
+	sample := strings.Replace(bdfSample, "ENCODING 0x100", "ENCODING 256\nDWIDTH 5 0\nBBX 32767 32767 2 0\nENDCHAR", 1)
+	font, err := ParseBDF(bytes.NewReader([]byte(sample)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (metric{2, 2, 5, 0, 0, 0}); font.metrics[2] != exp {
+		t.Fatalf("expected %v, got %v", exp, font.metrics[2])
+	}
+	if data := font.GlyphData(2, 0, 0).(fonts.GlyphBitmap); len(data.Data) != 0 {
+		t.Fatalf("expected empty bitmap, got %d bytes", len(data.Data))
+	}
+}
+
+func TestLoadBDF(t *testing.T) {
+	for _, file := range []string{"test/8x16.bdf.gz", "test/8x16.pcf.gz"} {
+		fi, err := os.Open(file)
+		if err != nil {
+			t.Fatal("can't read test file", err)
+		}
+
+		fs, err := Load(fi)
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		if fs[0].(*Font).properties["FAMILY_NAME"] != Atom("Fixed") {
+			t.Fatalf("unexpected family %v", fs[0].(*Font).properties["FAMILY_NAME"])
+		}
+
+		descriptors, err := ScanFont(fi)
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		if family := descriptors[0].Family(); family != "Sony Fixed" {
+			t.Fatalf("unexpected family %s", family)
+		}
+		cmap, err := descriptors[0].LoadCmap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, ok := cmap.Lookup('a'); !ok || g == 0 {
+			t.Fatalf("unexpected glyph for 'a': %d", g)
+		}
+		fi.Close()
+	}
+}
@@ -1,5 +1,5 @@
 // Pacakge bitmap provides support for bitmap fonts
-// found in .pcf files.
+// found in .pcf and .bdf files.
 package bitmap
 
 import (
@@ -30,7 +30,15 @@ type Int int32
 // Load implements fonts.FontLoader. When the error is `nil`,
 // one (and only one) font is returned.
 func Load(file fonts.Resource) (fonts.Faces, error) {
-	f, err := Parse(file)
+	isBDF, err := isBDF(file)
+	if err != nil {
+		return nil, err
+	}
+	parse := Parse
+	if isBDF {
+		parse = ParseBDF
+	}
+	f, err := parse(file)
 	if err != nil {
 		return nil, err
 	}
@@ -327,8 +335,11 @@ func abs(i int32) int32 {
 	return i
 }
 
-// LoadBitmaps always returns a one element slice.
-func (f *Font) LoadBitmaps() []fonts.BitmapSize { return []fonts.BitmapSize{f.computeBitmapSize()} }
+// LoadBitmaps returns a one element slice, completed
+// by the scaled sizes when scaling is enabled (see SetBitmapRendering).
+func (f *Font) LoadBitmaps() []fonts.BitmapSize {
+	return f.rendering.ScaledSizes([]fonts.BitmapSize{f.computeBitmapSize()})
+}
 
 var _ fonts.FontDescriptor = fontDescriptor{}
 
@@ -336,6 +347,8 @@ type fontDescriptor struct {
 	src          io.Reader
 	cmapTocEntry tocEntry // offset relative to the start of `src`
 
+	cmap *encodingTable // already parsed, for BDF files
+
 	properties propertiesTable // required for Family
 }
 
@@ -358,6 +371,18 @@ func readSection(src io.Reader, offset, size uint32) ([]byte, error) {
 // ScanFont lazily parse `file` to extract the information about the font.
 // If no error occurs, the returned slice has always length 1.
 func ScanFont(file fonts.Resource) ([]fonts.FontDescriptor, error) {
+	isBDF, err := isBDF(file)
+	if err != nil {
+		return nil, err
+	}
+	if isBDF { // the text format has no index, so we parse the whole file
+		font, err := ParseBDF(file)
+		if err != nil {
+			return nil, err
+		}
+		return []fonts.FontDescriptor{fontDescriptor{cmap: &font.cmap, properties: font.properties}}, nil
+	}
+
 	r, tocEntries, err := newParser(file)
 	if err != nil {
 		return nil, err
@@ -514,6 +539,13 @@ func stretchFromBFD(value Int) fonts.Stretch {
 }
 
 func (fd fontDescriptor) LoadCmap() (fonts.Cmap, error) {
+	if fd.cmap != nil {
+		if !fd.properties.isCmapUnicode() {
+			return nil, fmt.Errorf("not a Unicode cmap")
+		}
+		return fd.cmap, nil
+	}
+
 	data, err := readSection(fd.src, fd.cmapTocEntry.offset, fd.cmapTocEntry.size)
 	if err != nil {
 		return nil, err
@@ -33,7 +33,7 @@ func (f *Font) HorizontalAdvance(gid fonts.GID) float32 {
 	if int(gid) >= len(f.metrics) {
 		return 0
 	}
-	return float32(f.metrics[gid].characterWidth)
+	return float32(f.rendering.Advance(int(f.metrics[gid].characterWidth), 1))
 }
 
 // adapted from freetype ft_synthesize_vertical_metrics
@@ -98,18 +98,24 @@ func (m metric) extents() (ext fonts.GlyphExtents) {
 	ext.XBearing = float32(m.leftSideBearing)
 	ext.YBearing = float32(m.characterAscent)
 	ext.Width = float32(m.rightSideBearing - m.leftSideBearing)
-	ext.Height = -(float32(m.characterAscent - m.characterDescent))
+	ext.Height = -(float32(m.characterAscent + m.characterDescent))
 	return ext
 }
 
 // GlyphExtents retrieve the extents for a specified glyph, of false, if not available.
-// `coords` is used by variable fonts, and is specified in normalized coordinates.
-// For bitmap glyphs, the closest resolution to `xPpem` and `yPpem` is selected.
-func (f *Font) GlyphExtents(gid fonts.GID, _, _ uint16) (fonts.GlyphExtents, bool) {
+// The font units are the pixels of the font, so that the extents only depends
+// on `xPpem` and `yPpem` through the rounding of the scaled bitmaps (see SetBitmapRendering).
+func (f *Font) GlyphExtents(gid fonts.GID, xPpem, yPpem uint16) (fonts.GlyphExtents, bool) {
 	if int(gid) >= len(f.metrics) {
 		return fonts.GlyphExtents{}, false
 	}
 
-	// adapted from harfbuzz
-	return f.metrics[gid].extents(), true
+	xScale, yScale := f.scaleFactors(xPpem, yPpem)
+	ext := f.rendering.Extents(f.metrics[gid].extents(), xScale, yScale)
+	// convert back to font units
+	ext.XBearing /= xScale
+	ext.Width /= xScale
+	ext.YBearing /= yScale
+	ext.Height /= yScale
+	return ext, true
 }
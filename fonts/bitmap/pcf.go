@@ -64,6 +64,8 @@ type Font struct {
 	scalableWidths scalableWidthsTable
 	names          namesTable
 	cmap           encodingTable
+
+	rendering fonts.BitmapRendering // scaling and synthetic bold options
 }
 
 func getOrder(format uint32) binary.ByteOrder {
@@ -237,6 +239,9 @@ func (pr *parser) propertiesTable() (propertiesTable, error) {
 type bitmapTable struct {
 	offsets []uint32
 	data    []byte
+
+	padding  int  // rows are padded to a multiple of padding bytes
+	lsbFirst bool // bit order
 }
 
 func (p *parser) bitmap() (bitmapTable, error) {
@@ -292,7 +297,32 @@ func (p *parser) bitmap() (bitmapTable, error) {
 	data := p.data[p.pos : p.pos+bitmapLength]
 	p.pos += bitmapLength
 
-	return bitmapTable{data: data, offsets: offsets}, nil
+	// the bytes are swapped inside each scan unit when the byte order
+	// differs from the bit order: restore the display order
+	if scanUnit := 1 << ((format & scanUnitMask) >> 4); scanUnit > 1 && (format&byteMask != 0) != (format&bitMask != 0) {
+		data = swapScanUnits(data, scanUnit)
+	}
+
+	return bitmapTable{
+		data:     data,
+		offsets:  offsets,
+		padding:  1 << (format & glyphPadMask),
+		lsbFirst: format&bitMask == 0,
+	}, nil
+}
+
+// swapScanUnits returns a copy of `data` where the bytes
+// of each group of `scanUnit` bytes are reversed
+func swapScanUnits(data []byte, scanUnit int) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	for start := 0; start+scanUnit <= len(out); start += scanUnit {
+		unit := out[start : start+scanUnit]
+		for i, j := 0, len(unit)-1; i < j; i, j = i+1, j-1 {
+			unit[i], unit[j] = unit[j], unit[i]
+		}
+	}
+	return out
 }
 
 // we use int16 even for compressed for simplicity
@@ -608,19 +638,28 @@ func (f *Font) validate() error {
 	return nil
 }
 
-func newParser(file fonts.Resource) (io.Reader, []tocEntry, error) {
+// decompressedReader returns a reader on the content of `file`,
+// which may be gzip compressed.
+func decompressedReader(file fonts.Resource) (io.Reader, error) {
 	_, err := file.Seek(0, io.SeekStart) // file might have been used before
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	var r io.Reader
-	// pcf file are often compressed so we try gzip
-	r, err = gzip.NewReader(file)
+	// bitmap files are often compressed so we try gzip
+	r, err := gzip.NewReader(file)
 	if err != nil { // not a gzip file: read from the plain file
 		// gzip has read some bytes
-		_, _ = file.Seek(0, io.SeekStart)
-		r = file
+		_, err = file.Seek(0, io.SeekStart)
+		return file, err
+	}
+	return r, nil
+}
+
+func newParser(file fonts.Resource) (io.Reader, []tocEntry, error) {
+	r, err := decompressedReader(file)
+	if err != nil {
+		return nil, nil, err
 	}
 	// check the start of the file before reading all
 	var headerBuf [4]byte
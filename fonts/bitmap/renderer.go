@@ -6,8 +6,19 @@ import (
 
 var _ fonts.FaceRenderer = (*Font)(nil)
 
+// SetBitmapRendering sets the options used to render the glyphs :
+// see fonts.BitmapRendering. The metrics returned by
+// HorizontalAdvance and GlyphExtents are adjusted accordingly.
+func (f *Font) SetBitmapRendering(rendering fonts.BitmapRendering) { f.rendering = rendering }
+
+// GlyphData returns the glyph bitmap. With the default rendering options,
+// `xPpem` and `yPpem` are ignored since the font only has one strike;
+// see SetBitmapRendering to scale the glyphs.
+// The rows are returned as stored in the font, with the padding and bit order
+// described by the GlyphBitmap fields : use GlyphBitmap.ByteAligned
+// to obtain a canonical layout.
 func (f *Font) GlyphData(gid fonts.GID, xPpem, yPpem uint16) fonts.GlyphData {
-	if int(gid) > len(f.bitmap.offsets) {
+	if int(gid) >= len(f.bitmap.offsets) {
 		return nil
 	}
 
@@ -21,14 +32,34 @@ func (f *Font) GlyphData(gid fonts.GID, xPpem, yPpem uint16) fonts.GlyphData {
 	width := int(met.rightSideBearing - met.leftSideBearing)
 	height := int(met.characterAscent + met.characterDescent)
 
-	// TODO: expose the padding options
-
+	padding := f.bitmap.padding
 	out := fonts.GlyphBitmap{
-		Data:   f.bitmap.data[start:end],
-		Format: fonts.BlackAndWhite,
-		Width:  width,
-		Height: height,
+		Data:     f.bitmap.data[start:end],
+		Format:   fonts.BlackAndWhite,
+		Width:    width,
+		Height:   height,
+		Stride:   (width + 8*padding - 1) / (8 * padding) * padding,
+		Padding:  padding,
+		LSBFirst: f.bitmap.lsbFirst,
+		XBearing: int(met.leftSideBearing),
+		YBearing: int(met.characterAscent),
+		Advance:  int(met.characterWidth),
+	}
+
+	xScale, yScale := f.scaleFactors(xPpem, yPpem)
+	out, err := f.rendering.Render(out, xScale, yScale)
+	if err != nil {
+		return nil
 	}
 
 	return out
 }
+
+// scaleFactors returns the scales to apply to render the glyphs at the given size
+func (f *Font) scaleFactors(xPpem, yPpem uint16) (xScale, yScale float32) {
+	if f.rendering.Scaling == fonts.NoBitmapScaling {
+		return 1, 1
+	}
+	size := f.computeBitmapSize()
+	return f.rendering.ScaleFactors(size.XPpem, size.YPpem, xPpem, yPpem)
+}
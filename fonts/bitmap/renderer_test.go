@@ -1,7 +1,10 @@
 package bitmap
 
 import (
+	"bytes"
+	"math/bits"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/benoitkugler/textlayout/fonts"
@@ -36,3 +39,182 @@ func TestGlyphData(t *testing.T) {
 		}
 	}
 }
+
+func TestGlyphImage(t *testing.T) {
+	for _, file := range []string{
+		"test/4x6.pcf", // LSB first
+		"test/8x16.pcf.gz",
+		"test/charB18.pcf.gz",
+		"test/helvB18.pcf.gz",
+	} {
+		fi, err := os.Open(file)
+		if err != nil {
+			t.Fatal("can't read test file", err)
+		}
+		font, err := Parse(fi)
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		fi.Close()
+
+		gid, _ := font.NominalGlyph('A')
+		data := font.GlyphData(gid, 10, 10).(fonts.GlyphBitmap)
+		if data.Advance != int(font.HorizontalAdvance(gid)) {
+			t.Fatalf("font %s: inconsistent advance %d", file, data.Advance)
+		}
+		img, err := data.Image()
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		if b := img.Bounds(); b.Dx() != data.Width || b.Dy() != data.Height {
+			t.Fatalf("font %s: unexpected image size %v", file, b)
+		}
+		// padding bits are not used
+		var inkPixels, setBits int
+		for y := 0; y < data.Height; y++ {
+			for x := 0; x < data.Width; x++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+					inkPixels++
+				}
+			}
+		}
+		for _, b := range data.Data {
+			setBits += bits.OnesCount8(b)
+		}
+		if inkPixels == 0 || inkPixels != setBits {
+			t.Fatalf("font %s: unexpected number of pixels %d", file, inkPixels)
+		}
+	}
+}
+
+func TestGlyphByteAligned(t *testing.T) {
+	for _, file := range files {
+		fi, err := os.Open(file)
+		if err != nil {
+			t.Fatal("can't read test file", err)
+		}
+		font, err := Parse(fi)
+		if err != nil {
+			t.Fatal(file, err)
+		}
+		fi.Close()
+
+		for gid := range font.metrics {
+			data := font.GlyphData(fonts.GID(gid), 0, 0).(fonts.GlyphBitmap)
+			aligned, err := data.ByteAligned()
+			if err != nil {
+				t.Fatal(file, err)
+			}
+			if stride := (data.Width + 7) / 8; aligned.Stride != stride || aligned.Padding != 1 ||
+				aligned.LSBFirst || len(aligned.Data) != stride*data.Height {
+				t.Fatalf("font %s, glyph %d: unexpected layout %v", file, gid, aligned)
+			}
+			img1, err := data.Image()
+			if err != nil {
+				t.Fatal(file, err)
+			}
+			img2, err := aligned.Image()
+			if err != nil {
+				t.Fatal(file, err)
+			}
+			if !reflect.DeepEqual(img1, img2) {
+				t.Fatalf("font %s, glyph %d: different images", file, gid)
+			}
+		}
+	}
+
+	// check the bit order
+	fi, err := os.Open("test/8x16.pcf.gz")
+	if err != nil {
+		t.Fatal("can't read test file", err)
+	}
+	font, err := Parse(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi.Close()
+	gid, _ := font.NominalGlyph('F')
+	data := font.GlyphData(gid, 0, 0).(fonts.GlyphBitmap)
+	if data.Stride != 4 || data.Padding != 4 || !data.LSBFirst {
+		t.Fatalf("unexpected layout %v", data)
+	}
+	aligned, _ := data.ByteAligned()
+	if exp := []byte{0x00, 0xFE, 0x42, 0x42, 0x40, 0x48, 0x48, 0x78}; !bytes.Equal(aligned.Data[:8], exp) {
+		t.Fatalf("expected %v, got %v", exp, aligned.Data[:8])
+	}
+}
+
+func TestSwapScanUnits(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	if got := swapScanUnits(data, 2); !bytes.Equal(got, []byte{2, 1, 4, 3, 6, 5, 8, 7}) {
+		t.Fatalf("unexpected swap %v", got)
+	}
+	if got := swapScanUnits(data, 4); !bytes.Equal(got, []byte{4, 3, 2, 1, 8, 7, 6, 5}) {
+		t.Fatalf("unexpected swap %v", got)
+	}
+}
+
+func TestGlyphRendering(t *testing.T) {
+	fi, err := os.Open("test/8x16.pcf.gz")
+	if err != nil {
+		t.Fatal("can't read test file", err)
+	}
+	font, err := Parse(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi.Close()
+
+	gid, _ := font.NominalGlyph('A')
+	regular := font.GlyphData(gid, 0, 0).(fonts.GlyphBitmap)
+	regularImg, err := regular.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	font.SetBitmapRendering(fonts.BitmapRendering{Scaling: fonts.IntegerBitmapScaling})
+	if sizes := font.LoadBitmaps(); len(sizes) != 4 || sizes[2].YPpem != 48 {
+		t.Fatalf("unexpected sizes %v", sizes)
+	}
+	data := font.GlyphData(gid, 40, 40).(fonts.GlyphBitmap) // strike is 16 ppem
+	if data.Width != 16 || data.Height != 32 || data.Advance != 16 || data.YBearing != 2*regular.YBearing {
+		t.Fatalf("unexpected scaled bitmap %v", data)
+	}
+	img, err := data.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < data.Height; y++ {
+		for x := 0; x < data.Width; x++ {
+			if img.At(x, y) != regularImg.At(x/2, y/2) {
+				t.Fatalf("unexpected pixel at (%d, %d)", x, y)
+			}
+		}
+	}
+	if ext, _ := font.GlyphExtents(gid, 40, 40); ext.Width != 8 || ext.Height != -16 {
+		t.Fatalf("unexpected extents %v", ext)
+	}
+
+	font.SetBitmapRendering(fonts.BitmapRendering{Bold: true})
+	data = font.GlyphData(gid, 40, 40).(fonts.GlyphBitmap)
+	if data.Width != 9 || data.Advance != 9 || font.HorizontalAdvance(gid) != 9 {
+		t.Fatalf("unexpected bold bitmap %v", data)
+	}
+	if ext, _ := font.GlyphExtents(gid, 40, 40); ext.Width != 9 {
+		t.Fatalf("unexpected extents %v", ext)
+	}
+	img, err = data.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < data.Height; y++ {
+		for x := 0; x < data.Width; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			_, _, _, a1 := regularImg.At(x, y).RGBA()
+			_, _, _, a2 := regularImg.At(x-1, y).RGBA()
+			if (a != 0) != (a1 != 0 || a2 != 0) {
+				t.Fatalf("unexpected pixel at (%d, %d)", x, y)
+			}
+		}
+	}
+}
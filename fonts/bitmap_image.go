@@ -0,0 +1,110 @@
+package fonts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+)
+
+// Image decodes the bitmap content.
+// PNG and JPG data are decoded using the standard library,
+// whereas black and white and grayscale bitmaps are returned as an *image.Alpha,
+// where the ink is opaque, suitable to be used as a mask.
+// The layout of black and white and grayscale rows is given by the Stride
+// and LSBFirst fields. When Stride is zero, rows are bit-aligned.
+// TIFF images are not supported.
+func (bm GlyphBitmap) Image() (image.Image, error) {
+	switch bm.Format {
+	case PNG:
+		return png.Decode(bytes.NewReader(bm.Data))
+	case JPG:
+		return jpeg.Decode(bytes.NewReader(bm.Data))
+	}
+
+	depth := bm.Format.BitDepth()
+	strideBits, err := bm.strideBits()
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.NewAlpha(image.Rect(0, 0, bm.Width, bm.Height))
+	maxValue := 1<<depth - 1
+	for y := 0; y < bm.Height; y++ {
+		for x := 0; x < bm.Width; x++ {
+			value := int(bm.pixel(x, y, strideBits, depth))
+			out.Pix[y*out.Stride+x] = uint8(value * 0xFF / maxValue)
+		}
+	}
+	return out, nil
+}
+
+// strideBits returns the number of bits between the start of two rows,
+// for black and white and grayscale formats.
+func (bm GlyphBitmap) strideBits() (int, error) {
+	depth := bm.Format.BitDepth()
+	if depth == 0 {
+		return 0, fmt.Errorf("unsupported bitmap format %d", bm.Format)
+	}
+	if bm.Width < 0 || bm.Height < 0 {
+		return 0, fmt.Errorf("invalid bitmap size %dx%d", bm.Width, bm.Height)
+	}
+
+	rowBits := bm.Width * depth
+	if bm.Stride != 0 {
+		if bm.Stride*8 < rowBits || len(bm.Data) < bm.Stride*bm.Height {
+			return 0, errors.New("invalid bitmap data (EOF)")
+		}
+		return 8 * bm.Stride, nil
+	}
+
+	// bit-aligned rows
+	if len(bm.Data)*8 < rowBits*bm.Height {
+		return 0, errors.New("invalid bitmap data (EOF)")
+	}
+	return rowBits, nil
+}
+
+// pixel returns the value of the pixel at (x, y), with `depth` bits per pixel
+func (bm GlyphBitmap) pixel(x, y, strideBits, depth int) byte {
+	bit := y*strideBits + x*depth
+	shift := 8 - depth - bit%8
+	if bm.LSBFirst {
+		shift = bit % 8
+	}
+	return bm.Data[bit/8] >> shift & (1<<depth - 1)
+}
+
+// ByteAligned returns the bitmap with a canonical layout : each row
+// starts on a byte boundary (without additional padding), with the leftmost pixel
+// in the most significant bits.
+// Bitmaps using other formats than black and white and grayscale are returned unchanged.
+func (bm GlyphBitmap) ByteAligned() (GlyphBitmap, error) {
+	depth := bm.Format.BitDepth()
+	if depth == 0 {
+		return bm, nil
+	}
+	strideBits, err := bm.strideBits()
+	if err != nil {
+		return bm, err
+	}
+	stride := (bm.Width*depth + 7) / 8
+	if strideBits == 8*stride && !bm.LSBFirst { // nothing to do
+		bm.Data = bm.Data[:stride*bm.Height]
+		bm.Stride, bm.Padding = stride, 1
+		return bm, nil
+	}
+
+	out := bm
+	out.Data = make([]byte, stride*bm.Height)
+	out.Stride, out.Padding, out.LSBFirst = stride, 1, false
+	for y := 0; y < bm.Height; y++ {
+		for x := 0; x < bm.Width; x++ {
+			bit := y*8*stride + x*depth
+			out.Data[bit/8] |= bm.pixel(x, y, strideBits, depth) << (8 - depth - bit%8)
+		}
+	}
+	return out, nil
+}
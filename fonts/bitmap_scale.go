@@ -0,0 +1,292 @@
+package fonts
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// BitmapScaling selects how bitmap glyphs are adapted
+// when no strike matches the requested size.
+type BitmapScaling uint8
+
+const (
+	// NoBitmapScaling uses the closest strike, as it is (default).
+	NoBitmapScaling BitmapScaling = iota
+	// IntegerBitmapScaling enlarges the closest strike by the largest integer
+	// factor fitting in the requested size, duplicating pixels,
+	// which keeps the rendering crisp.
+	IntegerBitmapScaling
+	// NearestBitmapScaling resamples the closest strike to the requested
+	// size, using the nearest neighbour.
+	NearestBitmapScaling
+)
+
+// maxBitmapScale is the largest factor applied by BitmapRendering.ScaleFactors,
+// and the largest integer factor reported by BitmapRendering.ScaledSizes.
+const maxBitmapScale = 4
+
+// BitmapRendering groups the options used to render bitmap glyphs,
+// which are applied on top of the strike selection.
+// The zero value renders the strikes as they are.
+type BitmapRendering struct {
+	Scaling BitmapScaling
+
+	// Bold synthesizes a bold variant by smearing the pixels
+	// of the strike one pixel to the right (before scaling), so that
+	// bitmaps and advances are one pixel (of the strike) wider.
+	Bold bool
+}
+
+// ScaleFactors returns the horizontal and vertical scales to apply
+// to a strike of size (`strikeXPpem`, `strikeYPpem`) to render it at (`xPpem`, `yPpem`).
+// A zero requested size on one axis is replaced by the other one; when both are zero,
+// the strike is not scaled. The scales are at most 4, so that tiny strikes
+// are not blown up to huge bitmaps.
+func (r BitmapRendering) ScaleFactors(strikeXPpem, strikeYPpem, xPpem, yPpem uint16) (xScale, yScale float32) {
+	if xPpem == 0 {
+		xPpem = yPpem
+	} else if yPpem == 0 {
+		yPpem = xPpem
+	}
+	if r.Scaling == NoBitmapScaling || xPpem == 0 || strikeXPpem == 0 || strikeYPpem == 0 {
+		return 1, 1
+	}
+	xScale = float32(xPpem) / float32(strikeXPpem)
+	yScale = float32(yPpem) / float32(strikeYPpem)
+	if r.Scaling == IntegerBitmapScaling {
+		// use the same factor on both axis, never shrinking
+		scale := float32(math.Floor(float64(xScale)))
+		if ys := float32(math.Floor(float64(yScale))); ys < scale {
+			scale = ys
+		}
+		if scale < 1 {
+			scale = 1
+		}
+		xScale, yScale = scale, scale
+	}
+	if xScale > maxBitmapScale {
+		xScale = maxBitmapScale
+	}
+	if yScale > maxBitmapScale {
+		yScale = maxBitmapScale
+	}
+	return xScale, yScale
+}
+
+// ScaledSizes returns `sizes` completed, when scaling is enabled,
+// with the sizes obtained by enlarging them by integer factors (up to 4).
+func (r BitmapRendering) ScaledSizes(sizes []BitmapSize) []BitmapSize {
+	if r.Scaling == NoBitmapScaling {
+		return sizes
+	}
+	has := make(map[[2]uint16]bool, len(sizes))
+	for _, size := range sizes {
+		has[[2]uint16{size.XPpem, size.YPpem}] = true
+	}
+	out := append([]BitmapSize(nil), sizes...)
+	for _, size := range sizes {
+		for factor := uint32(2); factor <= maxBitmapScale; factor++ {
+			scaled := BitmapSize{
+				Height: clampUint16(uint32(size.Height) * factor),
+				Width:  clampUint16(uint32(size.Width) * factor),
+				XPpem:  clampUint16(uint32(size.XPpem) * factor),
+				YPpem:  clampUint16(uint32(size.YPpem) * factor),
+			}
+			if key := [2]uint16{scaled.XPpem, scaled.YPpem}; !has[key] {
+				has[key] = true
+				out = append(out, scaled)
+			}
+		}
+	}
+	return out
+}
+
+func clampUint16(v uint32) uint16 {
+	if v > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(v)
+}
+
+// Extents applies the synthetic bold and the scales to `extents`,
+// expressed in pixels of the strike. The returned extents are in pixels
+// of the rendered bitmap, and match the ones of the bitmap returned by Render.
+func (r BitmapRendering) Extents(extents GlyphExtents, xScale, yScale float32) GlyphExtents {
+	if r.Bold && extents.Width != 0 && extents.Height != 0 {
+		extents.Width++
+	}
+	x0, x1 := scaleCoord(int(extents.XBearing), xScale), scaleCoord(int(extents.XBearing+extents.Width), xScale)
+	y0, y1 := scaleCoord(int(extents.YBearing), yScale), scaleCoord(int(extents.YBearing+extents.Height), yScale)
+	return GlyphExtents{
+		XBearing: float32(x0),
+		YBearing: float32(y0),
+		Width:    float32(x1 - x0),
+		Height:   float32(y1 - y0),
+	}
+}
+
+// Advance applies the synthetic bold and the scale
+// to `advance`, expressed in pixels of the strike.
+// Note that the synthetic bold always enlarges the advance.
+func (r BitmapRendering) Advance(advance int, xScale float32) int {
+	if r.Bold {
+		advance++
+	}
+	return scaleCoord(advance, xScale)
+}
+
+// Render applies the synthetic bold and the scales to `bm`.
+// When `r` has no effect, `bm` is returned unchanged.
+// See GlyphBitmap.Embolden and GlyphBitmap.Scale for the supported formats.
+func (r BitmapRendering) Render(bm GlyphBitmap, xScale, yScale float32) (GlyphBitmap, error) {
+	var err error
+	if r.Bold {
+		bm, err = bm.Embolden()
+		if err != nil {
+			return bm, err
+		}
+	}
+	if xScale != 1 || yScale != 1 {
+		bm, err = bm.Scale(xScale, yScale)
+	}
+	return bm, err
+}
+
+// scaleCoord scales a pixel coordinate, rounding to the nearest pixel,
+// so that adjacent edges stay adjacent.
+func scaleCoord(v int, scale float32) int {
+	return int(math.Round(float64(v) * float64(scale)))
+}
+
+// Embolden returns a synthetic bold version of the bitmap, where
+// each pixel is smeared one pixel to the right: the bitmap and its advance
+// (if not zero) are one pixel wider. The bearings are unchanged.
+// Black and white and grayscale bitmaps are returned with bit-aligned rows,
+// whereas PNG and JPG images are decoded and returned as PNG images.
+// TIFF images are not supported.
+func (bm GlyphBitmap) Embolden() (GlyphBitmap, error) {
+	if bm.Width == 0 || bm.Height == 0 {
+		return bm, nil
+	}
+	width := bm.Width + 1
+	out := bm
+	out.Width = width
+	out.Stride, out.Padding, out.LSBFirst = 0, 0, false
+	if bm.Advance != 0 {
+		out.Advance++
+	}
+
+	if depth := bm.Format.BitDepth(); depth != 0 {
+		strideBits, err := bm.strideBits()
+		if err != nil {
+			return bm, err
+		}
+		out.Data = make([]byte, (width*bm.Height*depth+7)/8)
+		for y := 0; y < bm.Height; y++ {
+			var previous byte // value of the source pixel on the left
+			for x := 0; x < width; x++ {
+				var value byte
+				if x < bm.Width {
+					value = bm.pixel(x, y, strideBits, depth)
+				}
+				if previous > value { // keep the darkest pixel
+					out.setPixel(y*width+x, depth, previous)
+				} else {
+					out.setPixel(y*width+x, depth, value)
+				}
+				previous = value
+			}
+		}
+		return out, nil
+	}
+
+	img, err := bm.Image()
+	if err != nil {
+		return bm, err
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, bm.Height))
+	for y := 0; y < bm.Height; y++ {
+		var previous color.NRGBA
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			if x < bm.Width {
+				c = color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			}
+			current := c
+			if previous.A > c.A { // keep the most opaque pixel
+				c = previous
+			}
+			previous = current
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return out.encodePNG(dst)
+}
+
+// Scale returns the bitmap resampled with the nearest neighbour,
+// using the given factors. The bearings and the advance are scaled
+// and rounded to the nearest pixel.
+// Black and white and grayscale bitmaps are returned with bit-aligned rows,
+// whereas PNG and JPG images are decoded and returned as PNG images.
+// TIFF images are not supported.
+func (bm GlyphBitmap) Scale(xScale, yScale float32) (GlyphBitmap, error) {
+	out := bm
+	out.Stride, out.Padding, out.LSBFirst = 0, 0, false
+	x0, x1 := scaleCoord(bm.XBearing, xScale), scaleCoord(bm.XBearing+bm.Width, xScale)
+	y0, y1 := scaleCoord(bm.YBearing, yScale), scaleCoord(bm.YBearing-bm.Height, yScale)
+	out.XBearing, out.Width = x0, x1-x0
+	out.YBearing, out.Height = y0, y0-y1
+	out.Advance = scaleCoord(bm.Advance, xScale)
+	if out.Width < 0 || out.Height < 0 {
+		out.Width, out.Height = 0, 0
+	}
+	// the source pixel of (x, y) in the scaled bitmap
+	srcX := func(x int) int { return x * bm.Width / out.Width }
+	srcY := func(y int) int { return y * bm.Height / out.Height }
+
+	if depth := bm.Format.BitDepth(); depth != 0 {
+		strideBits, err := bm.strideBits()
+		if err != nil {
+			return bm, err
+		}
+		out.Data = make([]byte, (out.Width*out.Height*depth+7)/8)
+		for y := 0; y < out.Height; y++ {
+			for x := 0; x < out.Width; x++ {
+				out.setPixel(y*out.Width+x, depth, bm.pixel(srcX(x), srcY(y), strideBits, depth))
+			}
+		}
+		return out, nil
+	}
+
+	img, err := bm.Image()
+	if err != nil {
+		return bm, err
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, out.Width, out.Height))
+	for y := 0; y < out.Height; y++ {
+		for x := 0; x < out.Width; x++ {
+			dst.Set(x, y, img.At(bounds.Min.X+srcX(x), bounds.Min.Y+srcY(y)))
+		}
+	}
+	return out.encodePNG(dst)
+}
+
+// set the pixel at `index` in bit-aligned data, which is assumed to be zero
+func (bm GlyphBitmap) setPixel(index, depth int, value byte) {
+	bit := index * depth
+	bm.Data[bit/8] |= value << (8 - depth - bit%8)
+}
+
+func (bm GlyphBitmap) encodePNG(img image.Image) (GlyphBitmap, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return bm, err
+	}
+	bm.Data, bm.Format = buf.Bytes(), PNG
+	return bm, nil
+}
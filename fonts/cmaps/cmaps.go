@@ -0,0 +1,222 @@
+// Package cmaps provides a parser for PostScript CMap files,
+// as described in "Adobe CMap and CIDFont Files Specification, Adobe Systems (1996)".
+//
+// CMaps are used by CID-keyed fonts (such as CID-keyed Type 1 fonts or CFF CIDFonts),
+// whose glyphs are indexed by CID, either to map character codes to CIDs,
+// or to map CIDs to Unicode (such as the predefined Adobe-Japan1-UCS2 CMap).
+package cmaps
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// CID is a character identifier, used to index
+// the glyphs of CID-keyed fonts.
+type CID uint16
+
+// CharCode is a character code, made of one to four bytes
+// (stored in big-endian order).
+type CharCode uint32
+
+// CIDSystemInfo identifies a character collection,
+// such as Adobe-Japan1-6.
+type CIDSystemInfo struct {
+	Registry   string
+	Ordering   string
+	Supplement int
+}
+
+// Codespace is a range of valid character codes of the same length.
+type Codespace struct {
+	Low, High CharCode
+	NumBytes  int
+}
+
+// CIDRange maps the codes from Low to High (included)
+// to consecutive CIDs, starting at CID.
+type CIDRange struct {
+	Low, High CharCode
+	CID       CID
+}
+
+// CMap is a parsed CMap resource. It is also used
+// to store the predefined CMaps, which are shared and should not be modified.
+type CMap struct {
+	Name          string
+	CIDSystemInfo CIDSystemInfo
+	Type          int
+	WMode         int // 0 for horizontal, 1 for vertical writing
+
+	Codespaces []Codespace
+
+	// CIDs stores the cidrange and cidchar mappings,
+	// in their order of definition (the CMaps referenced by usecmap come first).
+	CIDs []CIDRange
+
+	// Unicodes stores the bfrange and bfchar mappings, from
+	// codes to (UTF-8 encoded) text.
+	Unicodes map[CharCode]string
+}
+
+// CID returns the CID for `code`, using the last matching range,
+// so that mappings override the ones inherited from usecmap.
+func (cm *CMap) CID(code CharCode) (CID, bool) {
+	for i := len(cm.CIDs) - 1; i >= 0; i-- {
+		if r := cm.CIDs[i]; r.Low <= code && code <= r.High {
+			return r.CID + CID(code-r.Low), true
+		}
+	}
+	return 0, false
+}
+
+// Unicode returns the text associated to `code`.
+func (cm *CMap) Unicode(code CharCode) (string, bool) {
+	text, ok := cm.Unicodes[code]
+	return text, ok
+}
+
+// UnicodeCmap builds a Unicode cmap for a CID-keyed font from `cidToUnicode`, a CMap
+// mapping CIDs (as codes) to Unicode, such as the ones returned by `PredefinedToUnicode`.
+// `cidToGID` maps the CIDs to the glyphs of the font: it may be nil when GIDs are equal to CIDs,
+// as for CID-keyed Type 1 fonts.
+// CIDs mapped to several runes (such as ligatures) are ignored, and
+// when several CIDs are mapped to the same rune, the smallest one is used.
+func UnicodeCmap(cidToUnicode *CMap, cidToGID map[CID]fonts.GID) fonts.CmapSimple {
+	out := fonts.CmapSimple{}
+	cids := map[rune]CID{}
+	for code, text := range cidToUnicode.Unicodes {
+		runes := []rune(text)
+		if len(runes) != 1 || code > 0xFFFF {
+			continue
+		}
+		r, cid := runes[0], CID(code)
+		gid := fonts.GID(cid)
+		if cidToGID != nil {
+			var ok bool
+			if gid, ok = cidToGID[cid]; !ok {
+				continue
+			}
+		}
+		if previous, has := cids[r]; has && previous < cid {
+			continue
+		}
+		cids[r] = cid
+		out[r] = gid
+	}
+	return out
+}
+
+// ResourceDirs lists the directories searched for predefined CMaps, such as the ones
+// published at https://github.com/adobe-type-tools/cmap-resources
+// or distributed with poppler-data.
+// A CMap is looked up in the directory itself, in its sub-directories (as in poppler-data),
+// and in the CMap folder of its sub-directories (as in the Adobe repository).
+// The predefined CMaps are not bundled with this package: except for Identity-H and Identity-V,
+// they are only available when poppler-data or the Adobe resources are installed
+// (and the default directories may be adjusted accordingly).
+var ResourceDirs = []string{
+	"/usr/share/poppler/cMap",
+	"/usr/local/share/poppler/cMap",
+}
+
+// maximum depth of usecmap references
+const maxUseCMapDepth = 8
+
+var (
+	builtin = map[string]*CMap{
+		"Identity-H": identity("Identity-H", 0),
+		"Identity-V": identity("Identity-V", 1),
+	}
+
+	// the CMaps loaded from files, keyed by path,
+	// so that modifying ResourceDirs is supported
+	predefinedLock sync.Mutex
+	predefined     = map[string]*CMap{}
+)
+
+func identity(name string, wMode int) *CMap {
+	return &CMap{
+		Name:          name,
+		CIDSystemInfo: CIDSystemInfo{Registry: "Adobe", Ordering: "Identity"},
+		Type:          1,
+		WMode:         wMode,
+		Codespaces:    []Codespace{{Low: 0, High: 0xFFFF, NumBytes: 2}},
+		CIDs:          []CIDRange{{Low: 0, High: 0xFFFF, CID: 0}},
+		Unicodes:      map[CharCode]string{},
+	}
+}
+
+// Predefined returns the predefined CMap `name`. The Identity-H and Identity-V
+// CMaps are builtin, the others are loaded from `ResourceDirs` and cached (by file).
+func Predefined(name string) (*CMap, error) {
+	return loadPredefined(name, 0)
+}
+
+// PredefinedToUnicode returns the predefined CMap mapping the CIDs of the character
+// collection `ros` to Unicode, such as Adobe-Japan1-UCS2 for Adobe-Japan1.
+func PredefinedToUnicode(ros CIDSystemInfo) (*CMap, error) {
+	return Predefined(ros.Registry + "-" + ros.Ordering + "-UCS2")
+}
+
+func loadPredefined(name string, depth int) (*CMap, error) {
+	if depth > maxUseCMapDepth {
+		return nil, errors.New("too many nested usecmap")
+	}
+
+	if cmap, ok := builtin[name]; ok {
+		return cmap, nil
+	}
+
+	file, err := findResource(name)
+	if err != nil {
+		return nil, err
+	}
+
+	predefinedLock.Lock()
+	cmap, ok := predefined[file]
+	predefinedLock.Unlock()
+	if ok {
+		return cmap, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cmap, err = parse(data, depth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid predefined CMap %s: %s", name, err)
+	}
+
+	predefinedLock.Lock()
+	predefined[file] = cmap
+	predefinedLock.Unlock()
+	return cmap, nil
+}
+
+// findResource returns the path of the predefined CMap `name`
+func findResource(name string) (string, error) {
+	// the name comes from the CMaps or the fonts: reject path and pattern characters
+	if name == "" || strings.ContainsAny(name, `/\*?[`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid CMap name %s", name)
+	}
+	for _, dir := range ResourceDirs {
+		for _, pattern := range [...]string{
+			filepath.Join(dir, name),
+			filepath.Join(dir, "*", name),
+			filepath.Join(dir, "*", "CMap", name),
+		} {
+			if matches, _ := filepath.Glob(pattern); len(matches) != 0 {
+				return matches[0], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("predefined CMap %s not found", name)
+}
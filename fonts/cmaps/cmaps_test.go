@@ -0,0 +1,228 @@
+package cmaps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+const ucs2CMap = `%!PS-Adobe-3.0 Resource-CMap
+%%DocumentNeededResources: ProcSet (CIDInit)
+%%IncludeResource: ProcSet (CIDInit)
+%%BeginResource: CMap (Test-Japan1-UCS2)
+/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CIDSystemInfo 3 dict dup begin
+  /Registry (Test) def
+  /Ordering (Japan1) def
+  /Supplement 6 def
+end def
+/CMapName /Test-Japan1-UCS2 def
+/CMapVersion 1.0 def
+/CMapType 2 def
+/XUID [1 10 25335 1212] def
+/WMode 0 def
+1 begincodespacerange
+  <0000> <FFFF>
+endcodespacerange
+3 beginbfchar
+<0001> <0020>
+<0010> <D840DC0B>
+<0011> /eacute
+endbfchar
+3 beginbfrange
+<0022> <0024> <0041>
+<0030> <0031> [<0066006C> <3042>]
+<0040> <0041> <0041>
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end
+%%EndResource
+%%EOF
+`
+
+const unicodeCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CIDSystemInfo << /Registry (Test) /Ordering (Japan1) /Supplement 6 >> def
+/CMapName /Test-UTF16-V def
+/CMapType 1 def
+/WMode 1 def
+/Test-UTF16-H usecmap
+2 begincidchar
+<0041> 10
+<3042> 20
+endcidchar
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end
+`
+
+func setupResources(t *testing.T) {
+	dir := t.TempDir()
+
+	// use the layout of the Adobe repository
+	collection := filepath.Join(dir, "Test-Japan1-6", "CMap")
+	if err := os.MkdirAll(collection, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"Test-Japan1-UCS2": ucs2CMap,
+		"Test-UTF16-H": `/CMapName /Test-UTF16-H def
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 begincidrange
+<0020> <007E> 1
+<3041> <3096> 842
+endcidrange`,
+		"Test-Cycle": "/Test-Cycle usecmap",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(collection, name), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := ResourceDirs
+	ResourceDirs = []string{dir}
+	t.Cleanup(func() { ResourceDirs = saved })
+}
+
+func TestParseBf(t *testing.T) {
+	cmap, err := Parse([]byte(ucs2CMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmap.Name != "Test-Japan1-UCS2" || cmap.Type != 2 || cmap.WMode != 0 {
+		t.Fatalf("unexpected properties %s %d %d", cmap.Name, cmap.Type, cmap.WMode)
+	}
+	if exp := (CIDSystemInfo{"Test", "Japan1", 6}); cmap.CIDSystemInfo != exp {
+		t.Fatalf("expected %v, got %v", exp, cmap.CIDSystemInfo)
+	}
+	if exp := []Codespace{{0, 0xFFFF, 2}}; !reflect.DeepEqual(cmap.Codespaces, exp) {
+		t.Fatalf("expected %v, got %v", exp, cmap.Codespaces)
+	}
+	exp := map[CharCode]string{
+		1:    " ",
+		0x10: "\U0002000B",
+		0x11: "é",
+		0x22: "A", 0x23: "B", 0x24: "C",
+		0x30: "fl", 0x31: "あ",
+		0x40: "A", 0x41: "B",
+	}
+	if !reflect.DeepEqual(cmap.Unicodes, exp) {
+		t.Fatalf("expected %v, got %v", exp, cmap.Unicodes)
+	}
+	if text, ok := cmap.Unicode(0x31); !ok || text != "あ" {
+		t.Fatalf("unexpected text %s", text)
+	}
+
+	cmapUnicode := UnicodeCmap(cmap, nil)
+	if gid, _ := cmapUnicode.Lookup('A'); gid != 0x22 { // smallest CID
+		t.Fatalf("unexpected glyph for 'A': %d", gid)
+	}
+	if _, ok := cmapUnicode.Lookup('f'); ok { // ligatures are ignored
+		t.Fatal("unexpected glyph for 'f'")
+	}
+	cmapUnicode = UnicodeCmap(cmap, map[CID]fonts.GID{0x31: 3, 0x40: 4})
+	if exp := (fonts.CmapSimple{'あ': 3, 'A': 4}); !reflect.DeepEqual(cmapUnicode, exp) {
+		t.Fatalf("expected %v, got %v", exp, cmapUnicode)
+	}
+}
+
+func TestParseCID(t *testing.T) {
+	setupResources(t)
+
+	cmap, err := Parse([]byte(unicodeCMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmap.Name != "Test-UTF16-V" || cmap.WMode != 1 || cmap.CIDSystemInfo.Ordering != "Japan1" {
+		t.Fatalf("unexpected properties %s %d %v", cmap.Name, cmap.WMode, cmap.CIDSystemInfo)
+	}
+	if len(cmap.Codespaces) != 1 || len(cmap.CIDs) != 4 {
+		t.Fatalf("unexpected mappings %v %v", cmap.Codespaces, cmap.CIDs)
+	}
+	for code, exp := range map[CharCode]CID{
+		0x20:   1,
+		0x40:   33,
+		0x41:   10, // overriden
+		0x3041: 842,
+		0x3042: 20, // overriden
+		0x3043: 844,
+	} {
+		if cid, ok := cmap.CID(code); !ok || cid != exp {
+			t.Fatalf("code %x: expected %d, got %d", code, exp, cid)
+		}
+	}
+	if _, ok := cmap.CID(0x10); ok {
+		t.Fatal("unexpected CID for 0x10")
+	}
+}
+
+func TestPredefined(t *testing.T) {
+	setupResources(t)
+
+	for _, name := range []string{"Identity-H", "Identity-V"} {
+		cmap, err := Predefined(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid, _ := cmap.CID(0x1234); cid != 0x1234 {
+			t.Fatalf("unexpected CID %d", cid)
+		}
+	}
+
+	cmap, err := PredefinedToUnicode(CIDSystemInfo{"Test", "Japan1", 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmap.Name != "Test-Japan1-UCS2" {
+		t.Fatalf("unexpected name %s", cmap.Name)
+	}
+	if cached, _ := Predefined("Test-Japan1-UCS2"); cached != cmap {
+		t.Fatal("expected cached CMap")
+	}
+
+	for _, name := range []string{"Test-Korea1-UCS2", "Test-Cycle", "../Test-Japan1-6", "Test-*"} {
+		if _, err = Predefined(name); err == nil {
+			t.Fatalf("expected error for %s", name)
+		}
+	}
+
+	// the cache is not shared between resource directories
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "Test-Japan1-UCS2"), []byte("/CMapName /Other-UCS2 def"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	ResourceDirs = []string{dir}
+	if other, err := Predefined("Test-Japan1-UCS2"); err != nil || other.Name != "Other-UCS2" {
+		t.Fatalf("unexpected CMap %v (%v)", other, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		strings.Replace(ucs2CMap, "endbfchar", "", 1),
+		strings.Replace(ucs2CMap, "<0010> <D840DC0B>", "<0010>", 1),
+		strings.Replace(ucs2CMap, "<0022> <0024>", "<0024> <0022>", 1),
+		strings.Replace(ucs2CMap, "<0022> <0024>", "<0022> <00FF0000>", 1),
+		strings.Replace(ucs2CMap, "<0022> <0024>", "<0000000000> <0024>", 1),
+		strings.Replace(ucs2CMap, "[<0066006C> <3042>]", "[<0066006C>]", 1),
+		strings.Replace(ucs2CMap, "/eacute", "/unknownglyph", 1),
+		strings.Replace(unicodeCMap, "<0041> 10", "<0041> 70000", 1),
+		strings.Replace(unicodeCMap, "/Test-UTF16-H", "/Unknown-UTF16-H", 1),
+		"/Registry (Test) /Ordering << /Test 1",
+	} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Fatalf("expected error for invalid CMap %s", invalid)
+		}
+	}
+}
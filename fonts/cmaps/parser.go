@@ -0,0 +1,394 @@
+package cmaps
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	tk "github.com/benoitkugler/pstokenizer"
+	"github.com/benoitkugler/textlayout/fonts/glyphsnames"
+)
+
+// maximum number of codes in a bfrange
+const maxBfRangeSize = 0xFFFF
+
+// Parse parses a PostScript CMap file.
+// The CMaps referenced by usecmap are resolved with `Predefined`.
+// The notdefrange and notdefchar mappings are ignored.
+func Parse(data []byte) (*CMap, error) {
+	return parse(data, 0)
+}
+
+// operand of a CMap block, which may be an array (for bfrange)
+type operand struct {
+	token tk.Token
+	array []tk.Token
+}
+
+type parser struct {
+	tokenizer *tk.Tokenizer
+	stack     []tk.Token // operands of the current operator
+	out       *CMap
+	depth     int // usecmap nesting
+}
+
+// parse interprets the (small) subset of PostScript used by CMap files:
+// definitions of the CMap properties and mapping blocks.
+func parse(data []byte, depth int) (*CMap, error) {
+	p := parser{
+		tokenizer: tk.NewTokenizer(data),
+		out:       &CMap{Unicodes: map[CharCode]string{}},
+		depth:     depth,
+	}
+	for {
+		token, err := p.tokenizer.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		switch token.Kind {
+		case tk.EOF:
+			return p.out, nil
+		case tk.StartDic:
+			err = p.readDict()
+		case tk.StartArray, tk.StartProc: // such as XUID, not needed
+			_, err = p.readArray(token.Kind)
+		case tk.Other:
+			err = p.apply(string(token.Value))
+		default:
+			p.stack = append(p.stack, token)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) apply(operator string) error {
+	var err error
+	switch operator {
+	case "def":
+		if L := len(p.stack); L >= 2 && p.stack[L-2].Kind == tk.Name {
+			err = p.define(string(p.stack[L-2].Value), p.stack[L-1])
+		}
+	case "usecmap":
+		if L := len(p.stack); L >= 1 && p.stack[L-1].Kind == tk.Name {
+			err = p.useCMap(string(p.stack[L-1].Value))
+		} else {
+			err = errors.New("missing CMap name for usecmap")
+		}
+	case "begincodespacerange":
+		err = p.readBlock("endcodespacerange", 2, p.codespace)
+	case "begincidrange":
+		err = p.readBlock("endcidrange", 3, p.cidRange)
+	case "begincidchar":
+		err = p.readBlock("endcidchar", 2, p.cidChar)
+	case "beginbfrange":
+		err = p.readBlock("endbfrange", 3, p.bfRange)
+	case "beginbfchar":
+		err = p.readBlock("endbfchar", 2, p.bfChar)
+	case "beginnotdefrange":
+		err = p.readBlock("endnotdefrange", 3, func([]operand) error { return nil })
+	case "beginnotdefchar":
+		err = p.readBlock("endnotdefchar", 2, func([]operand) error { return nil })
+	}
+	// other operators (dict, begin, end, defineresource, ...) are ignored
+	p.stack = p.stack[:0]
+	return err
+}
+
+// define handles the (simple) values of the CMap dict,
+// and of the CIDSystemInfo dict
+func (p *parser) define(key string, value tk.Token) error {
+	var err error
+	switch key {
+	case "CMapName":
+		p.out.Name = string(value.Value)
+	case "CMapType":
+		p.out.Type, err = value.Int()
+	case "WMode":
+		p.out.WMode, err = value.Int()
+	case "Registry":
+		p.out.CIDSystemInfo.Registry = string(value.Value)
+	case "Ordering":
+		p.out.CIDSystemInfo.Ordering = string(value.Value)
+	case "Supplement":
+		p.out.CIDSystemInfo.Supplement, err = value.Int()
+	}
+	return err
+}
+
+// reads an inline dict, such as << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >>,
+// which is replaced by a placeholder on the stack
+func (p *parser) readDict() error {
+	for {
+		key, err := p.tokenizer.NextToken()
+		if err != nil {
+			return err
+		}
+		switch key.Kind {
+		case tk.EndDic:
+			p.stack = append(p.stack, tk.Token{Kind: tk.StartDic})
+			return nil
+		case tk.EOF:
+			return errors.New("unexpected end of dict")
+		case tk.Name:
+		default:
+			return fmt.Errorf("invalid dict key %s", key.Value)
+		}
+
+		value, err := p.tokenizer.NextToken()
+		if err != nil {
+			return err
+		}
+		switch value.Kind {
+		case tk.StartDic:
+			if err = p.readDict(); err == nil { // nested dicts are not needed
+				p.stack = p.stack[:len(p.stack)-1]
+			}
+		case tk.StartArray, tk.StartProc:
+			_, err = p.readArray(value.Kind)
+		default:
+			err = p.define(string(key.Value), value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reads the (flat) elements of an array or a procedure, up to the closing delimiter
+func (p *parser) readArray(start tk.Kind) ([]tk.Token, error) {
+	end := tk.EndArray
+	if start == tk.StartProc {
+		end = tk.EndProc
+	}
+	var out []tk.Token
+	for level := 1; ; {
+		token, err := p.tokenizer.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		switch token.Kind {
+		case tk.EOF:
+			return nil, errors.New("unexpected end of array")
+		case start:
+			level++
+		case end:
+			level--
+			if level == 0 {
+				return out, nil
+			}
+		}
+		out = append(out, token)
+	}
+}
+
+// readBlock reads the entries of a begin.../end... block, made of `size` operands,
+// and calls `fn` for each of them
+func (p *parser) readBlock(end string, size int, fn func([]operand) error) error {
+	entry := make([]operand, 0, size)
+	for {
+		token, err := p.tokenizer.NextToken()
+		if err != nil {
+			return err
+		}
+		switch {
+		case token.Kind == tk.EOF:
+			return fmt.Errorf("missing %s", end)
+		case token.IsOther(end):
+			if len(entry) != 0 {
+				return fmt.Errorf("incomplete entry before %s", end)
+			}
+			return nil
+		case token.Kind == tk.StartArray:
+			array, err := p.readArray(tk.StartArray)
+			if err != nil {
+				return err
+			}
+			entry = append(entry, operand{token: token, array: array})
+		default:
+			entry = append(entry, operand{token: token})
+		}
+
+		if len(entry) == size {
+			if err = fn(entry); err != nil {
+				return err
+			}
+			entry = entry[:0]
+		}
+	}
+}
+
+// returns the code and its number of bytes
+func parseCode(token tk.Token) (CharCode, int, error) {
+	if token.Kind != tk.StringHex && token.Kind != tk.String {
+		return 0, 0, fmt.Errorf("expected string for character code, got %s", token.Kind)
+	}
+	if len(token.Value) == 0 || len(token.Value) > 4 {
+		return 0, 0, fmt.Errorf("invalid character code length %d", len(token.Value))
+	}
+	var code CharCode
+	for _, b := range token.Value {
+		code = code<<8 | CharCode(b)
+	}
+	return code, len(token.Value), nil
+}
+
+// parses a code range, checking that low <= high
+func parseCodeRange(low, high operand) (CharCode, CharCode, error) {
+	lowCode, _, err := parseCode(low.token)
+	if err != nil {
+		return 0, 0, err
+	}
+	highCode, _, err := parseCode(high.token)
+	if err != nil {
+		return 0, 0, err
+	}
+	if highCode < lowCode {
+		return 0, 0, fmt.Errorf("invalid code range %d-%d", lowCode, highCode)
+	}
+	return lowCode, highCode, nil
+}
+
+func parseCID(token tk.Token) (CID, error) {
+	if token.Kind != tk.Integer {
+		return 0, fmt.Errorf("expected integer for CID, got %s", token.Kind)
+	}
+	cid, err := token.Int()
+	if err != nil {
+		return 0, err
+	}
+	if cid < 0 || cid > 0xFFFF {
+		return 0, fmt.Errorf("invalid CID %d", cid)
+	}
+	return CID(cid), nil
+}
+
+// parses the destination of a bf mapping, either
+// a string of UTF-16BE code units, or a glyph name
+func parseUnicode(token tk.Token) ([]uint16, error) {
+	switch token.Kind {
+	case tk.StringHex, tk.String:
+		if len(token.Value) == 1 { // tolerate single byte values
+			return []uint16{uint16(token.Value[0])}, nil
+		}
+		units := make([]uint16, len(token.Value)/2)
+		for i := range units {
+			units[i] = uint16(token.Value[2*i])<<8 | uint16(token.Value[2*i+1])
+		}
+		return units, nil
+	case tk.Name:
+		r, ok := glyphsnames.GlyphToRune(string(token.Value))
+		if !ok {
+			return nil, fmt.Errorf("unknown glyph name %s", token.Value)
+		}
+		return utf16.Encode([]rune{r}), nil
+	default:
+		return nil, fmt.Errorf("invalid destination %s", token.Kind)
+	}
+}
+
+func (p *parser) codespace(entry []operand) error {
+	low, size, err := parseCode(entry[0].token)
+	if err != nil {
+		return err
+	}
+	high, _, err := parseCode(entry[1].token)
+	if err != nil {
+		return err
+	}
+	p.out.Codespaces = append(p.out.Codespaces, Codespace{Low: low, High: high, NumBytes: size})
+	return nil
+}
+
+func (p *parser) cidRange(entry []operand) error {
+	low, high, err := parseCodeRange(entry[0], entry[1])
+	if err != nil {
+		return err
+	}
+	cid, err := parseCID(entry[2].token)
+	if err != nil {
+		return err
+	}
+	p.out.CIDs = append(p.out.CIDs, CIDRange{Low: low, High: high, CID: cid})
+	return nil
+}
+
+func (p *parser) cidChar(entry []operand) error {
+	code, _, err := parseCode(entry[0].token)
+	if err != nil {
+		return err
+	}
+	cid, err := parseCID(entry[1].token)
+	if err != nil {
+		return err
+	}
+	p.out.CIDs = append(p.out.CIDs, CIDRange{Low: code, High: code, CID: cid})
+	return nil
+}
+
+func (p *parser) bfChar(entry []operand) error {
+	code, _, err := parseCode(entry[0].token)
+	if err != nil {
+		return err
+	}
+	units, err := parseUnicode(entry[1].token)
+	if err != nil {
+		return err
+	}
+	p.out.Unicodes[code] = string(utf16.Decode(units))
+	return nil
+}
+
+func (p *parser) bfRange(entry []operand) error {
+	low, high, err := parseCodeRange(entry[0], entry[1])
+	if err != nil {
+		return err
+	}
+	if high-low > maxBfRangeSize {
+		return fmt.Errorf("too large bfrange %d-%d", low, high)
+	}
+
+	// array of destinations
+	if entry[2].token.Kind == tk.StartArray {
+		if len(entry[2].array) < int(high-low)+1 {
+			return fmt.Errorf("missing destinations for bfrange %d-%d", low, high)
+		}
+		for i, token := range entry[2].array[:high-low+1] {
+			units, err := parseUnicode(token)
+			if err != nil {
+				return err
+			}
+			p.out.Unicodes[low+CharCode(i)] = string(utf16.Decode(units))
+		}
+		return nil
+	}
+
+	// the last code unit of the destination is incremented
+	units, err := parseUnicode(entry[2].token)
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return errors.New("empty bfrange destination")
+	}
+	for i := CharCode(0); i <= high-low; i++ {
+		p.out.Unicodes[low+i] = string(utf16.Decode(units))
+		units[len(units)-1]++
+	}
+	return nil
+}
+
+// useCMap copies the mappings of the predefined CMap `name`
+func (p *parser) useCMap(name string) error {
+	base, err := loadPredefined(name, p.depth+1)
+	if err != nil {
+		return err
+	}
+	p.out.Codespaces = append(p.out.Codespaces, base.Codespaces...)
+	p.out.CIDs = append(p.out.CIDs, base.CIDs...)
+	for code, text := range base.Unicodes {
+		p.out.Unicodes[code] = text
+	}
+	return nil
+}
@@ -2,7 +2,8 @@
 // several font formats (postscript, bitmap and truetype)
 // and provides a common API, inspired by freetype.
 //
-// It does not currently support CIDType1 fonts.
+// CID-keyed Type 1 fonts (CIDFontType 0) are supported,
+// with glyphs indexed by CID.
 package fonts
 
 import "math"
@@ -272,7 +273,7 @@ type FaceRenderer interface {
 }
 
 // GlyphData describe how to graw a glyph.
-// It is either an GlyphOutline, GlyphSVG or GlyphBitmap.
+// It is either an GlyphOutline, GlyphSVG, GlyphBitmap, GlyphLayers or GlyphColor.
 type GlyphData interface {
 	isGlyphData()
 }
@@ -280,6 +281,7 @@ type GlyphData interface {
 func (GlyphOutline) isGlyphData() {}
 func (GlyphSVG) isGlyphData()     {}
 func (GlyphBitmap) isGlyphData()  {}
+func (GlyphLayers) isGlyphData()  {}
 
 // GlyphOutline exposes the path to draw for
 // vector glyph.
@@ -350,21 +352,82 @@ type GlyphBitmap struct {
 	Data          []byte
 	Format        BitmapFormat
 	Width, Height int // number of columns and rows
+
+	// Layout of the rows of black and white and grayscale bitmaps.
+	// The zero values describe bit-aligned rows, each one directly following
+	// the previous one, with the leftmost pixel in the most significant bits.
+	// Stride is the number of bytes between the start of two rows,
+	// which are padded to a multiple of Padding bytes (1, 2, 4 or 8).
+	// LSBFirst is true when the leftmost pixel is stored in the least significant bits,
+	// as found in some PCF fonts.
+	Stride, Padding int
+	LSBFirst        bool
+
+	// Metrics of the bitmap, in pixels, when provided by the font (zero otherwise) :
+	// the offsets from the glyph origin to the left and top edges of the bitmap
+	// (with Y going up), and the horizontal advance.
+	XBearing, YBearing int
+	Advance            int
+}
+
+// GlyphLayers is a color glyph made of superposed layers,
+// as found in Opentype COLR table (version 0).
+type GlyphLayers struct {
+	// The layers, to be drawn in order (from bottom to top).
+	Layers []GlyphLayer
+}
+
+// PaletteIndexForeground is a special palette index
+// indicating that the text foreground color should be used.
+const PaletteIndexForeground = 0xFFFF
+
+// GlyphLayer is one layer of a color glyph : the outline
+// of the glyph `GID` should be filled with a color from
+// the palette in use (see PaletteIndexForeground).
+type GlyphLayer struct {
+	GID          GID
+	PaletteIndex uint16
 }
 
 // BitmapFormat identifies the format on the glyph
 // raw data. Across the various font files, many formats
-// may be encountered : black and white bitmaps, PNG, TIFF, JPG.
+// may be encountered : black and white or grayscale bitmaps, PNG, TIFF, JPG.
+//
+// For black and white and grayscale bitmaps, the pixels are stored
+// row by row, from top to bottom, using the layout described by
+// the Stride, Padding and LSBFirst fields of GlyphBitmap.
+// A value of 0 is the background, and the maximum value is the ink.
 type BitmapFormat uint8
 
 const (
-	_ BitmapFormat = iota
-	BlackAndWhite
+	_             BitmapFormat = iota
+	BlackAndWhite              // 1 bit per pixel
 	PNG
 	JPG
 	TIFF
+	Gray2 // 2 bits per pixel
+	Gray4 // 4 bits per pixel
+	Gray8 // 8 bits per pixel
 )
 
+// BitDepth returns the number of bits per pixel for
+// black and white and grayscale formats, or 0 for
+// other formats.
+func (f BitmapFormat) BitDepth() int {
+	switch f {
+	case BlackAndWhite:
+		return 1
+	case Gray2:
+		return 2
+	case Gray4:
+		return 4
+	case Gray8:
+		return 8
+	default:
+		return 0
+	}
+}
+
 // BitmapSize expose the size of bitmap glyphs.
 // One font may contain several sizes.
 type BitmapSize struct {
@@ -0,0 +1,201 @@
+package fonts
+
+// GlyphColor is a color glyph described by a graph of paint
+// operations, as found in Opentype COLR table (version 1).
+// For variable fonts, the variations are already applied.
+type GlyphColor struct {
+	// Paint is the root of the paint graph.
+	Paint Paint
+
+	// Clip is an optional clip box for the glyph,
+	// which may be used to pre-allocate a drawing surface.
+	Clip *ClipBox
+}
+
+func (GlyphColor) isGlyphData() {}
+
+// ClipBox is a rectangle in font units.
+type ClipBox struct {
+	XMin, YMin, XMax, YMax float32
+}
+
+// Paint is a node of the paint graph of a color glyph. It is one of
+// PaintColrLayers, PaintSolid, PaintLinearGradient, PaintRadialGradient,
+// PaintSweepGradient, PaintGlyph, PaintColrGlyph, PaintTransform,
+// PaintTranslate, PaintScale, PaintRotate, PaintSkew or PaintComposite.
+//
+// Coordinates are expressed in font units, and angles
+// in counter-clockwise degrees.
+type Paint interface {
+	isPaint()
+}
+
+func (PaintColrLayers) isPaint()     {}
+func (PaintSolid) isPaint()          {}
+func (PaintLinearGradient) isPaint() {}
+func (PaintRadialGradient) isPaint() {}
+func (PaintSweepGradient) isPaint()  {}
+func (PaintGlyph) isPaint()          {}
+func (PaintColrGlyph) isPaint()      {}
+func (PaintTransform) isPaint()      {}
+func (PaintTranslate) isPaint()      {}
+func (PaintScale) isPaint()          {}
+func (PaintRotate) isPaint()         {}
+func (PaintSkew) isPaint()           {}
+func (PaintComposite) isPaint()      {}
+
+// PaintColrLayers draws the layers in order (from bottom to top).
+type PaintColrLayers struct {
+	Layers []Paint
+}
+
+// PaintSolid fills with a color from the palette in use
+// (see PaletteIndexForeground), whose alpha channel
+// must be multiplied by `Alpha`.
+type PaintSolid struct {
+	PaletteIndex uint16
+	Alpha        float32
+}
+
+// Extend defines how a color line is extended
+// outside of its [0, 1] interval.
+type Extend uint8
+
+const (
+	ExtendPad Extend = iota
+	ExtendRepeat
+	ExtendReflect
+)
+
+// ColorStop is a color at a given position of a color line.
+type ColorStop struct {
+	Offset       float32
+	PaletteIndex uint16
+	Alpha        float32 // to multiply with the palette color alpha
+}
+
+// ColorLine defines the colors of a gradient.
+type ColorLine struct {
+	Extend Extend
+	Stops  []ColorStop
+}
+
+// PaintLinearGradient is a linear gradient, going from (X0, Y0) to (X1, Y1),
+// and rotated by the point (X2, Y2).
+type PaintLinearGradient struct {
+	ColorLine              ColorLine
+	X0, Y0, X1, Y1, X2, Y2 float32
+}
+
+// PaintRadialGradient is a radial gradient between two circles.
+type PaintRadialGradient struct {
+	ColorLine       ColorLine
+	X0, Y0, Radius0 float32
+	X1, Y1, Radius1 float32
+}
+
+// PaintSweepGradient is a sweep (conic) gradient around a center,
+// between two angles.
+type PaintSweepGradient struct {
+	ColorLine            ColorLine
+	CenterX, CenterY     float32
+	StartAngle, EndAngle float32
+}
+
+// PaintGlyph uses the outline of the glyph `GID` as a clip mask
+// for `Paint`.
+type PaintGlyph struct {
+	Paint Paint
+	GID   GID
+}
+
+// PaintColrGlyph reuses the paint graph of the color glyph `GID`,
+// which may be retrieved using the same method used to access
+// the current glyph.
+type PaintColrGlyph struct {
+	GID GID
+}
+
+// Affine2x3 is an affine transformation, mapping (x, y) to
+// (XX*x + XY*y + DX, YX*x + YY*y + DY).
+type Affine2x3 struct {
+	XX, YX, XY, YY, DX, DY float32
+}
+
+// PaintTransform applies a transformation to `Paint`.
+type PaintTransform struct {
+	Paint     Paint
+	Transform Affine2x3
+}
+
+// PaintTranslate applies a translation to `Paint`.
+type PaintTranslate struct {
+	Paint  Paint
+	DX, DY float32
+}
+
+// PaintScale applies a scale transformation to `Paint`,
+// around the center (CenterX, CenterY), which defaults to the origin.
+type PaintScale struct {
+	Paint            Paint
+	ScaleX, ScaleY   float32
+	CenterX, CenterY float32
+}
+
+// PaintRotate applies a rotation to `Paint`, around the
+// center (CenterX, CenterY), which defaults to the origin.
+type PaintRotate struct {
+	Paint            Paint
+	Angle            float32
+	CenterX, CenterY float32
+}
+
+// PaintSkew applies a skew transformation to `Paint`,
+// around the center (CenterX, CenterY), which defaults to the origin.
+type PaintSkew struct {
+	Paint                  Paint
+	XSkewAngle, YSkewAngle float32
+	CenterX, CenterY       float32
+}
+
+// CompositeMode defines how the source and backdrop of a
+// PaintComposite are combined.
+// See https://www.w3.org/TR/compositing-1/ for the definitions.
+type CompositeMode uint8
+
+const (
+	CompositeClear CompositeMode = iota
+	CompositeSrc
+	CompositeDest
+	CompositeSrcOver
+	CompositeDestOver
+	CompositeSrcIn
+	CompositeDestIn
+	CompositeSrcOut
+	CompositeDestOut
+	CompositeSrcAtop
+	CompositeDestAtop
+	CompositeXor
+	CompositePlus
+	CompositeScreen
+	CompositeOverlay
+	CompositeDarken
+	CompositeLighten
+	CompositeColorDodge
+	CompositeColorBurn
+	CompositeHardLight
+	CompositeSoftLight
+	CompositeDifference
+	CompositeExclusion
+	CompositeMultiply
+	CompositeHslHue
+	CompositeHslSaturation
+	CompositeHslColor
+	CompositeHslLuminosity
+)
+
+// PaintComposite combines `Source` and `Backdrop`, using `Mode`.
+type PaintComposite struct {
+	Source, Backdrop Paint
+	Mode             CompositeMode
+}
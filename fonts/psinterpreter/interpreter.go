@@ -35,6 +35,10 @@ const (
 	// T1_SPEC.pdf 6.1 Encoding as a limitation of 24.
 	psArgStackSize = 48
 
+	// psArgStackSizeCFF2 is the argument stack size for CFF2 charstrings and
+	// Private DICTs, raised by the CFF2 specification to accommodate the 'blend' operator arguments.
+	psArgStackSizeCFF2 = 513
+
 	// Similarly, Appendix B says "Subr nesting, stack limit 10".
 	psCallStackSize = 10
 
@@ -49,10 +53,23 @@ const (
 	PrivateDict                      // Private dict in CFF files
 	Type2Charstring                  // Charstring in CFF files
 	Type1Charstring                  // Charstring in Type1 font files
+	CFF2PrivateDict                  // Private dict in CFF2 tables
+	CFF2Charstring                   // Charstring in CFF2 tables
 )
 
+// isType2 returns true for the charstrings found in CFF and CFF2 tables.
+func (ctx PsContext) isType2() bool { return ctx == Type2Charstring || ctx == CFF2Charstring }
+
+// argStackSize returns the maximum number of operands
+func (ctx PsContext) argStackSize() int32 {
+	if ctx == CFF2PrivateDict || ctx == CFF2Charstring {
+		return psArgStackSizeCFF2
+	}
+	return psArgStackSize
+}
+
 type ArgStack struct {
-	Vals [psArgStackSize]int32
+	Vals [psArgStackSizeCFF2]int32
 	// Effecive size currently in use. The first value to
 	// pop is at index Top-1
 	Top int32
@@ -146,7 +163,19 @@ func (p *Machine) Run(instructions []byte, localSubrs, globalSubrs [][]byte, han
 	p.ArgStack.Top = 0
 	p.callStack.top = 0
 
-	for len(p.instructions) > 0 {
+	for {
+		if len(p.instructions) == 0 {
+			// CFF2 charstrings have no 'return' operator:
+			// reaching the end of a subroutine implicitly returns to the caller
+			if p.ctx != CFF2Charstring || p.callStack.top == 0 {
+				break
+			}
+			if err := p.Return(); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Push a numeric operand on the stack, if applicable.
 		if hasResult, err := p.parseNumber(); hasResult {
 			if err != nil {
@@ -192,14 +221,14 @@ func (p *Machine) parseNumber() (hasResult bool, err error) {
 		number, hasResult = int32(int16(be.Uint16(p.instructions[1:]))), true
 		p.instructions = p.instructions[3:]
 
-	case b == 29 && p.ctx != Type2Charstring:
+	case b == 29 && !p.ctx.isType2():
 		if len(p.instructions) < 5 {
 			return true, errInvalidCFFTable
 		}
 		number, hasResult = int32(be.Uint32(p.instructions[1:])), true
 		p.instructions = p.instructions[5:]
 
-	case b == 30 && p.ctx != Type2Charstring && p.ctx != Type1Charstring:
+	case b == 30 && !p.ctx.isType2() && p.ctx != Type1Charstring:
 		// Parse a real number. This isn't listed in 5176.CFF.pdf Table 3
 		// "Operand Encoding" but that table lists integer encodings. Further
 		// down the page it says "A real number operand is provided in addition
@@ -256,7 +285,7 @@ func (p *Machine) parseNumber() (hasResult bool, err error) {
 		b1 := p.instructions[1]
 		p.instructions = p.instructions[2:]
 		number, hasResult = -int32(b-251)*256-int32(b1)-108, true
-	case b == 255 && (p.ctx == Type2Charstring || p.ctx == Type1Charstring):
+	case b == 255 && (p.ctx.isType2() || p.ctx == Type1Charstring):
 		if len(p.instructions) < 5 {
 			return true, errInvalidCFFTable
 		}
@@ -265,7 +294,7 @@ func (p *Machine) parseNumber() (hasResult bool, err error) {
 	}
 
 	if hasResult {
-		if p.ArgStack.Top == psArgStackSize {
+		if p.ArgStack.Top == p.ctx.argStackSize() {
 			return true, errInvalidCFFTable
 		}
 		p.ArgStack.Vals[p.ArgStack.Top] = number
@@ -319,7 +348,7 @@ func (p *Machine) CallSubroutine(index int32, isLocal bool) error {
 	}
 
 	// no bias in type1 fonts
-	if p.ctx == Type2Charstring {
+	if p.ctx.isType2() {
 		index += subrBias(len(subrs))
 	}
 
@@ -0,0 +1,62 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TableLcar is the AAT 'lcar' table, which stores the caret positions
+// in ligature glyphs.
+// See https://developer.apple.com/fonts/TrueType-Reference-Manual/RM06/Chap6lcar.html
+type TableLcar struct {
+	class  Class // offset of the carets, from the start of the table
+	carets []byte
+	// If true, the carets are indices of control points,
+	// otherwise they are distances (in font units) from the glyph origin.
+	IsControlPoints bool
+}
+
+// GetCarets returns the caret positions (or the control point indices) of
+// the ligature `glyph`, or nil if not found.
+func (t TableLcar) GetCarets(glyph GID) []int16 {
+	if t.class == nil {
+		return nil
+	}
+	offset, ok := t.class.ClassID(glyph)
+	if !ok || len(t.carets) < int(offset)+2 {
+		return nil
+	}
+	data := t.carets[offset:]
+	count := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+2*count {
+		return nil // invalid table
+	}
+	out := make([]int16, count)
+	for i := range out {
+		out[i] = int16(binary.BigEndian.Uint16(data[2+2*i:]))
+	}
+	return out
+}
+
+func parseTableLcar(data []byte, numGlyphs int) (out TableLcar, err error) {
+	if len(data) < 8 {
+		return out, errors.New("invalid 'lcar' table (EOF)")
+	}
+	switch format := binary.BigEndian.Uint16(data[4:]); format {
+	case 0:
+	case 1:
+		out.IsControlPoints = true
+	default:
+		return out, fmt.Errorf("unsupported 'lcar' table format: %d", format)
+	}
+	out.class, err = parseAATLookupTable(data, 6, numGlyphs, false)
+	if err != nil {
+		return out, fmt.Errorf("invalid 'lcar' table: %s", err)
+	}
+	out.carets = data
+	if e := out.class.Extent(); e-1+2 > len(out.carets) {
+		return out, errors.New("invalid 'lcar' table (EOF)")
+	}
+	return out, nil
+}
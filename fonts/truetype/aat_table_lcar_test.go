@@ -0,0 +1,34 @@
+package truetype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTableLcar(t *testing.T) {
+	data := deHexStr(
+		"0001 0000 0001 " + // version 1.0, format 1 (control points)
+			"0006 0004 0001 0004 0000 0000 " + // lookup format 6, 1 unit of 4 bytes
+			"0014 0016 " + // glyph 20 -> 22
+			"0002 0003 0007") // 2 carets
+
+	lcar, err := parseTableLcar(data, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lcar.IsControlPoints {
+		t.Fatal("expected control points format")
+	}
+	if carets := lcar.GetCarets(20); !reflect.DeepEqual(carets, []int16{3, 7}) {
+		t.Fatalf("unexpected carets %v", carets)
+	}
+	if carets := lcar.GetCarets(21); carets != nil {
+		t.Fatalf("unexpected carets %v", carets)
+	}
+
+	// invalid count
+	data[23] = 3
+	if carets := lcar.GetCarets(20); carets != nil {
+		t.Fatalf("unexpected carets %v", carets)
+	}
+}
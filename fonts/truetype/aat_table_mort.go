@@ -0,0 +1,275 @@
+package truetype
+
+// parser of the deprecated 'mort' table
+// See https://developer.apple.com/fonts/TrueType-Reference-Manual/RM06/Chap6mort.html
+// The subtables are converted to their 'morx' equivalent, so that the same
+// shaping logic applies for both tables.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// parseMortChain parses a chain of a 'mort' table (version 1).
+func parseMortChain(data []byte, numGlyphs int) (out MorxChain, size int, err error) {
+	if len(data) < 12 {
+		return out, 0, errors.New("invalid mort table (EOF)")
+	}
+	out.DefaultFlags = binary.BigEndian.Uint32(data)
+	size = int(binary.BigEndian.Uint32(data[4:]))
+	nFeatures := int(binary.BigEndian.Uint16(data[8:]))
+	nSubtables := int(binary.BigEndian.Uint16(data[10:]))
+
+	if len(data) < 12+12*nFeatures {
+		return out, 0, errors.New("invalid mort table (EOF)")
+	}
+	out.Features = make([]AATFeature, nFeatures)
+	for i := range out.Features {
+		out.Features[i].Type = binary.BigEndian.Uint16(data[12+12*i:])
+		out.Features[i].Setting = binary.BigEndian.Uint16(data[12+12*i+2:])
+		out.Features[i].EnableFlags = binary.BigEndian.Uint32(data[12+12*i+4:])
+		out.Features[i].DisableFlags = binary.BigEndian.Uint32(data[12+12*i+8:])
+	}
+
+	// "sanitize" before allocating
+	currentOffset := 12 + 12*nFeatures
+	if len(data) < currentOffset+8*nSubtables { // at least
+		return out, 0, errors.New("invalid mort table (EOF)")
+	}
+	out.Subtables = make([]MortxSubtable, nSubtables)
+	var subtableLength int
+	for i := range out.Subtables {
+		if len(data) < currentOffset {
+			return out, 0, errors.New("invalid mort table (EOF)")
+		}
+		out.Subtables[i], subtableLength, err = parseMortSubtable(data[currentOffset:], numGlyphs)
+		if err != nil {
+			return out, 0, err
+		}
+		currentOffset += subtableLength
+	}
+	return out, size, nil
+}
+
+// also returns the length of the subtable (in bytes)
+func parseMortSubtable(data []byte, numGlyphs int) (out MortxSubtable, length int, err error) {
+	if len(data) < 8 {
+		return out, 0, errors.New("invalid mort subtable (EOF)")
+	}
+	length = int(binary.BigEndian.Uint16(data))
+	if length < 8 || len(data) < length {
+		return out, 0, errors.New("invalid mort subtable (EOF)")
+	}
+	// the coverage flags use the same bits as in 'morx', on 16 bits
+	out.Coverage = data[2]
+	kind := MorxSubtableType(data[3] & 0x07)
+	out.Flags = binary.BigEndian.Uint32(data[4:])
+	data = data[8:length]
+	switch kind {
+	case MorxRearrangement:
+		var s AATStateTable
+		s, err = parseStateTable(data, 0, false, numGlyphs)
+		out.Data = MorxRearrangementSubtable(s)
+	case MorxContextual:
+		out.Data, err = parseMortContextualSubtable(data, numGlyphs)
+	case MorxLigature:
+		out.Data, err = parseMortLigatureSubtable(data, numGlyphs)
+	case MorxNonContextual:
+		out.Data, err = parseNonContextualSubtable(data, numGlyphs)
+	case MorxInsertion:
+		out.Data, err = parseMortInsertionSubtable(data, numGlyphs)
+	default:
+		return out, 0, fmt.Errorf("invalid mort subtable type: %d", kind)
+	}
+	return out, length, err
+}
+
+// mortSubstitution is a view into the substitution table
+// of a 'mort' contextual subtable: the replacement of a glyph is found
+// by adding the glyph to the (word) offset stored in the entry.
+// Null values are ignored.
+type mortSubstitution struct {
+	substitutions []uint16
+	firstGlyph    int // glyph mapped to the first substitution, may be negative
+}
+
+func (m mortSubstitution) ClassID(gid GID) (uint32, bool) {
+	index := int(gid) - m.firstGlyph
+	if index < 0 || index >= len(m.substitutions) || m.substitutions[index] == 0 {
+		return 0, false
+	}
+	return uint32(m.substitutions[index]), true
+}
+
+func (m mortSubstitution) GlyphSize() int {
+	count := 0
+	for i, glyph := range m.substitutions {
+		if gid := m.firstGlyph + i; gid >= 0 && gid <= 0xFFFF && glyph != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (m mortSubstitution) Extent() int {
+	max := uint16(0)
+	for i, glyph := range m.substitutions {
+		if gid := m.firstGlyph + i; gid >= 0 && gid <= 0xFFFF && glyph > max {
+			max = glyph
+		}
+	}
+	return int(max) + 1
+}
+
+func parseMortContextualSubtable(data []byte, numGlyphs int) (out MorxContextualSubtable, err error) {
+	// we need the offset to the data following the stateTable
+	if len(data) < aatStateHeaderSize+2 {
+		return out, errors.New("invalid mort contextual subtable (EOF)")
+	}
+	subsOffset := int(binary.BigEndian.Uint16(data[aatStateHeaderSize:]))
+	if len(data) < subsOffset {
+		return out, errors.New("invalid mort contextual subtable (EOF)")
+	}
+	out.Machine, err = parseStateTable(data[:subsOffset], 4, false, numGlyphs)
+	if err != nil {
+		return out, err
+	}
+
+	// the substitution table extends up to the end of the subtable
+	substitutions, _ := parseUint16s(data[subsOffset:], (len(data)-subsOffset)/2) // length already checked
+
+	// entries store word offsets from the start of the subtable, which are
+	// resolved to indices into Substitutions; a zero offset means no substitution
+	indices := make(map[uint16]uint16)
+	resolve := func(offset uint16) uint16 {
+		if offset == 0 {
+			return 0xFFFF
+		}
+		if index, has := indices[offset]; has {
+			return index
+		}
+		index := uint16(len(out.Substitutions))
+		out.Substitutions = append(out.Substitutions, mortSubstitution{
+			substitutions: substitutions,
+			firstGlyph:    subsOffset/2 - int(offset),
+		})
+		indices[offset] = index
+		return index
+	}
+	for i := range out.Machine.entries {
+		entry := &out.Machine.entries[i]
+		markOffset, currentOffset := entry.AsMorxContextual()
+		binary.BigEndian.PutUint16(entry.data[:], resolve(markOffset))
+		binary.BigEndian.PutUint16(entry.data[2:], resolve(currentOffset))
+	}
+	return out, nil
+}
+
+func parseMortLigatureSubtable(data []byte, numGlyphs int) (out MorxLigatureSubtable, err error) {
+	if len(data) < aatStateHeaderSize+6 {
+		return out, errors.New("invalid mort ligature subtable (EOF)")
+	}
+	ligActionOffset := int(binary.BigEndian.Uint16(data[aatStateHeaderSize:]))
+	componentOffset := int(binary.BigEndian.Uint16(data[aatStateHeaderSize+2:]))
+	ligatureOffset := int(binary.BigEndian.Uint16(data[aatStateHeaderSize+4:]))
+	// we need the offset to the data following the stateTable
+	// for now, we assume the offsets are actually sorted
+	if ligActionOffset > componentOffset || componentOffset > ligatureOffset || len(data) < ligatureOffset {
+		return out, errors.New("invalid mort ligature subtable (EOF)")
+	}
+	out.Machine, err = parseStateTable(data[:ligActionOffset], 0, false, numGlyphs)
+	if err != nil {
+		return out, err
+	}
+
+	// In 'mort', the action offset added to the glyph is a word offset
+	// from the start of the subtable: convert it to an index into Component.
+	for offset := ligActionOffset; offset+4 <= componentOffset; offset += 4 {
+		action := binary.BigEndian.Uint32(data[offset:])
+		uoffset := action & MLActionOffset
+		if uoffset&0x20000000 != 0 {
+			uoffset |= 0xC0000000 // sign-extend
+		}
+		index := int32(uoffset) - int32(componentOffset/2)
+		action = action&^MLActionOffset | uint32(index)&MLActionOffset
+		out.LigatureAction = append(out.LigatureAction, action)
+	}
+
+	// the entries store the byte offset of the action list in their flags
+	for i := range out.Machine.entries {
+		entry := &out.Machine.entries[i]
+		offset := int(entry.Flags & MLOffset)
+		entry.Flags &= MLSetComponent | MLDontAdvance
+		if offset == 0 {
+			continue
+		}
+		index := (offset - ligActionOffset) / 4
+		if offset < ligActionOffset || (offset-ligActionOffset)%4 != 0 || index >= len(out.LigatureAction) {
+			return out, fmt.Errorf("invalid mort ligature action offset: %d", offset)
+		}
+		entry.Flags |= MLPerformAction
+		binary.BigEndian.PutUint16(entry.data[:], uint16(index))
+	}
+
+	// The components accumulate byte offsets from the start of the subtable to the
+	// ligature glyph: they are stored as word offsets, and the ligatures are shifted
+	// accordingly, so that the sum of the components is an index into Ligatures.
+	out.Component = make([]uint16, (ligatureOffset-componentOffset)/2)
+	for i := range out.Component {
+		out.Component[i] = binary.BigEndian.Uint16(data[componentOffset+2*i:]) / 2
+	}
+	ligatureCount := (len(data) - ligatureOffset) / 2
+	out.Ligatures = make([]GID, ligatureOffset/2+ligatureCount)
+	for i := 0; i < ligatureCount; i++ {
+		out.Ligatures[ligatureOffset/2+i] = GID(binary.BigEndian.Uint16(data[ligatureOffset+2*i:]))
+	}
+	return out, nil
+}
+
+func parseMortInsertionSubtable(data []byte, numGlyphs int) (out MorxInsertionSubtable, err error) {
+	out.Machine, err = parseStateTable(data, 4, false, numGlyphs)
+	if err != nil {
+		return out, err
+	}
+
+	// entries store the byte offsets (from the start of the subtable)
+	// of the glyph lists to insert, or zero: the lists are copied into Insertions
+	type insertionList struct{ offset, count int }
+	indices := make(map[insertionList]uint16)
+	resolve := func(offset uint16, count int) (uint16, error) {
+		if offset == 0 || count == 0 {
+			return 0xFFFF, nil
+		}
+		list := insertionList{int(offset), count}
+		if index, has := indices[list]; has {
+			return index, nil
+		}
+		if len(data) < list.offset+2*count {
+			return 0, errors.New("invalid mort insertion subtable (EOF)")
+		}
+		// the number of entries is bounded by the state array,
+		// so that the index fits in an uint16
+		index := uint16(len(out.Insertions))
+		for i := 0; i < count; i++ {
+			out.Insertions = append(out.Insertions, GID(binary.BigEndian.Uint16(data[list.offset+2*i:])))
+		}
+		indices[list] = index
+		return index, nil
+	}
+	for i := range out.Machine.entries {
+		entry := &out.Machine.entries[i]
+		currentOffset, markedOffset := entry.AsMorxInsertion()
+		currentIndex, err := resolve(currentOffset, int(entry.Flags&MICurrentInsertCount)>>5)
+		if err != nil {
+			return out, err
+		}
+		markedIndex, err := resolve(markedOffset, int(entry.Flags&MIMarkedInsertCount))
+		if err != nil {
+			return out, err
+		}
+		binary.BigEndian.PutUint16(entry.data[:], currentIndex)
+		binary.BigEndian.PutUint16(entry.data[2:], markedIndex)
+	}
+	return out, nil
+}
@@ -0,0 +1,191 @@
+package truetype
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// return the only subtable of the only chain of `data`
+func parseSingleMortSubtable(t *testing.T, data []byte) MortxSubtable {
+	out, err := parseTableMorx(data, 0xFFFF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || len(out[0].Subtables) != 1 {
+		t.Fatalf("expected one chain with one subtable, got %v", out)
+	}
+	return out[0].Subtables[0]
+}
+
+func TestParseMortLigature(t *testing.T) {
+	// f (10) + i (11) -> fi (100)
+	mortLigatureData := deHexStr(
+		"0001 0000 " + //  0: Version=1.0
+			"0000 0001 " + //  4: MorphChainCount=1
+			"0000 0001 " + //  8: DefaultFlags=1
+			"0000 005A " + // 12: ChainLength=90
+			"0001 0001 " + // 16: MorphFeatureCount=1, MorphSubtableCount=1
+			"0001 0000 0000 0001 FFFF FFFE " + // 20: Feature[0]: type=1, setting=0, enable=1, disable=~1
+			"0042 2002 " + // 32: Subtable[0].Length=66, .Coverage=AllDirections|LigatureMorph
+			"0000 0001 " + // 36: Subtable[0].SubFeatureFlags=0x1
+
+			// State table header (offsets from 40).
+			"0006 000E 0014 0020 " + // 40: ClassCount=6, ClassTableOffset=14, StateArrayOffset=20, EntryTableOffset=32
+			"002C 0034 0038 " + // 48: LigActionsOffset=44, ComponentOffset=52, LigatureOffset=56
+			"000A 0002 04 05 " + // 54: FirstGlyph=10, NGlyphs=2, classes
+			"00 00 00 00 01 00 " + // 60: State[0][0..5]
+			"00 00 00 00 01 02 " + // 66: State[1][0..5]
+			"0014 0000 " + // 72: Entries[0].NewState=0, .Flags=0
+			"001A 8000 " + // 76: Entries[1].NewState=1, .Flags=SetComponent
+			"0014 802C " + // 80: Entries[2].NewState=0, .Flags=SetComponent, action at 44
+			"0000 0010 " + // 84: Action[0]: word offset 16 + glyph
+			"8000 0010 " + // 88: Action[1]: last, word offset 16 + glyph
+			"0038 0000 " + // 92: Component[f]=56, Component[i]=0
+			"0064") // 96: Ligature[0]=100
+
+	if len(mortLigatureData) != 98 {
+		t.Fatal()
+	}
+
+	out, err := parseTableMorx(mortLigatureData, 0xFFFF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected one chain, got %d", len(out))
+	}
+	chain := out[0]
+	if exp := []AATFeature{{Type: 1, Setting: 0, EnableFlags: 1, DisableFlags: 0xFFFFFFFE}}; !reflect.DeepEqual(chain.Features, exp) {
+		t.Fatalf("expected %v, got %v", exp, chain.Features)
+	}
+
+	subtable := chain.Subtables[0]
+	if subtable.Coverage != 0x20 || subtable.Flags != 1 {
+		t.Fatalf("unexpected coverage or flags: %d %d", subtable.Coverage, subtable.Flags)
+	}
+	lig, ok := subtable.Data.(MorxLigatureSubtable)
+	if !ok {
+		t.Fatalf("expected MorxLigatureSubtable, got %T", subtable.Data)
+	}
+	if exp, got := [][]uint16{{0, 0, 0, 0, 1, 0}, {0, 0, 0, 0, 1, 2}}, lig.Machine.states; !reflect.DeepEqual(exp, got) {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+	expEntries := []AATStateEntry{
+		{NewState: 0, Flags: 0},
+		{NewState: 1, Flags: MLSetComponent},
+		{NewState: 0, Flags: MLSetComponent | MLPerformAction},
+	}
+	if got := lig.Machine.entries; !reflect.DeepEqual(expEntries, got) {
+		t.Fatalf("expected %v, got %v", expEntries, got)
+	}
+
+	// resolve the ligature as the shaper does, popping i then f
+	var ligatureIdx int
+	for i, glyph := range []GID{11, 10} {
+		action := lig.LigatureAction[int(lig.Machine.entries[2].AsMorxLigature())+i]
+		uoffset := action & MLActionOffset
+		if uoffset&0x20000000 != 0 {
+			uoffset |= 0xC0000000
+		}
+		componentIdx := int32(glyph) + int32(uoffset)
+		if componentIdx < 0 || int(componentIdx) >= len(lig.Component) {
+			t.Fatalf("invalid component index %d", componentIdx)
+		}
+		ligatureIdx += int(lig.Component[componentIdx])
+	}
+	if ligatureIdx >= len(lig.Ligatures) || lig.Ligatures[ligatureIdx] != 100 {
+		t.Fatalf("invalid ligature index %d", ligatureIdx)
+	}
+
+	// invalid action offset
+	mortLigatureData[83] = 0x2E
+	if _, err := parseTableMorx(mortLigatureData, 0xFFFF); err == nil {
+		t.Fatal("expected error for invalid action offset")
+	}
+}
+
+func TestParseMortContextual(t *testing.T) {
+	// 10 followed by 11 -> replace 11 by 200
+	data := deHexStr(
+		"0001 0000 0000 0001 " + //  0: Version=1.0, MorphChainCount=1
+			"0000 0001 0000 004A 0000 0001 " + //  8: DefaultFlags=1, ChainLength=74, no feature, one subtable
+			"003E 0001 0000 0001 " + // 20: Length=62, Coverage=ContextualMorph, SubFeatureFlags=1
+			"0006 000A 0010 001C 0034 " + // 28: ClassCount=6, ClassTableOffset=10, StateArrayOffset=16, EntryTableOffset=28, SubstitutionTable=52
+			"000A 0002 04 05 " + // FirstGlyph=10, NGlyphs=2, classes
+			"00 00 00 00 01 00 " + // State[0][0..5]
+			"00 00 00 00 01 02 " + // State[1][0..5]
+			"0010 0000 0000 0000 " + // Entries[0]: no substitution
+			"0016 8000 0000 0000 " + // Entries[1]: NewState=1, SetMark
+			"0010 0000 0000 000F " + // Entries[2]: current substitution at word offset 15
+			"00C8") // Substitution[0]=200
+
+	subtable := parseSingleMortSubtable(t, data)
+	ctx, ok := subtable.Data.(MorxContextualSubtable)
+	if !ok {
+		t.Fatalf("expected MorxContextualSubtable, got %T", subtable.Data)
+	}
+	expEntries := []AATStateEntry{
+		{NewState: 0, Flags: 0, data: [4]byte{0xFF, 0xFF, 0xFF, 0xFF}},
+		{NewState: 1, Flags: MCSetMark, data: [4]byte{0xFF, 0xFF, 0xFF, 0xFF}},
+		{NewState: 0, Flags: 0, data: [4]byte{0xFF, 0xFF, 0, 0}},
+	}
+	if got := ctx.Machine.entries; !reflect.DeepEqual(expEntries, got) {
+		t.Fatalf("expected %v, got %v", expEntries, got)
+	}
+	if len(ctx.Substitutions) != 1 {
+		t.Fatalf("expected one substitution, got %d", len(ctx.Substitutions))
+	}
+	subs := ctx.Substitutions[0]
+	if rep, ok := subs.ClassID(11); !ok || rep != 200 {
+		t.Fatalf("expected substitution 200 for glyph 11, got %d %v", rep, ok)
+	}
+	if _, ok := subs.ClassID(10); ok {
+		t.Fatal("unexpected substitution for glyph 10")
+	}
+	if subs.GlyphSize() != 1 || subs.Extent() != 201 {
+		t.Fatalf("unexpected substitution size %d %d", subs.GlyphSize(), subs.Extent())
+	}
+}
+
+func TestParseMortInsertion(t *testing.T) {
+	// insert 300, 301 after 10
+	data := deHexStr(
+		"0001 0000 0000 0001 " + //  0: Version=1.0, MorphChainCount=1
+			"0000 0001 0000 0040 0000 0001 " + //  8: DefaultFlags=1, ChainLength=64, no feature, one subtable
+			"0034 8005 0000 0001 " + // 20: Length=52, Coverage=Vertical|InsertionMorph, SubFeatureFlags=1
+			"0005 0008 000E 0018 " + // 28: ClassCount=5, ClassTableOffset=8, StateArrayOffset=14, EntryTableOffset=24
+			"000A 0001 04 00 " + // FirstGlyph=10, NGlyphs=1, classes, padding
+			"00 00 00 00 01 " + // State[0][0..4]
+			"00 00 00 00 01 " + // State[1][0..4]
+			"000E 0000 0000 0000 " + // Entries[0]: no insertion
+			"000E 0040 0028 0000 " + // Entries[1]: CurrentInsertCount=2, CurrentInsertList at 40
+			"012C 012D") // glyphs to insert
+
+	subtable := parseSingleMortSubtable(t, data)
+	if subtable.Coverage != 0x80 {
+		t.Fatalf("unexpected coverage %d", subtable.Coverage)
+	}
+	ins, ok := subtable.Data.(MorxInsertionSubtable)
+	if !ok {
+		t.Fatalf("expected MorxInsertionSubtable, got %T", subtable.Data)
+	}
+	expEntries := []AATStateEntry{
+		{NewState: 0, Flags: 0, data: [4]byte{0xFF, 0xFF, 0xFF, 0xFF}},
+		{NewState: 0, Flags: 0x40, data: [4]byte{0, 0, 0xFF, 0xFF}},
+	}
+	if got := ins.Machine.entries; !reflect.DeepEqual(expEntries, got) {
+		t.Fatalf("expected %v, got %v", expEntries, got)
+	}
+	if exp := []fonts.GID{300, 301}; !reflect.DeepEqual(ins.Insertions, exp) {
+		t.Fatalf("expected %v, got %v", exp, ins.Insertions)
+	}
+
+	// truncated insertion list
+	data = data[:len(data)-2]
+	data[21] = 0x32 // subtable length
+	if _, err := parseTableMorx(data, 0xFFFF); err == nil {
+		t.Fatal("expected error for truncated insertion list")
+	}
+}
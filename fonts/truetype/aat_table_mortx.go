@@ -1,7 +1,7 @@
 package truetype
 
 // parser of Apple AAT layout tables
-// We dont support the deprecated 'mort' tables
+// The deprecated 'mort' tables are handled in aat_table_mort.go
 
 import (
 	"encoding/binary"
@@ -9,6 +9,8 @@ import (
 	"fmt"
 )
 
+// TableMorx is either a 'morx' table, or a deprecated 'mort' table
+// converted to the 'morx' structures.
 type TableMorx []MorxChain
 
 func parseTableMorx(data []byte, numGlyphs int) (TableMorx, error) {
@@ -51,7 +53,7 @@ type MorxChain struct {
 func parseMorxChain(version uint16, data []byte, numGlyphs int) (out MorxChain, size int, err error) {
 	switch version {
 	case 1:
-		return out, 0, fmt.Errorf("deprecated mort tables are not supported")
+		return parseMortChain(data, numGlyphs)
 	case 2, 3:
 		return parseMorxChain23(data, numGlyphs)
 	default:
@@ -0,0 +1,64 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TableOpbd is the AAT 'opbd' table, which stores the optical bounds of glyphs.
+// See https://developer.apple.com/fonts/TrueType-Reference-Manual/RM06/Chap6opbd.html
+type TableOpbd struct {
+	class  Class // offset of the bounds, from the start of the table
+	bounds []byte
+	// If true, the bounds are indices of control points,
+	// otherwise they are distances in font units.
+	IsControlPoints bool
+}
+
+// AATOpticalBounds stores the optical bounds of a glyph, which is
+// either the distances (in font units) to add to the edges of the advance box to
+// obtain the optical edges, or the indices of the control points defining the
+// optical edges (with -1 meaning no point, and no adjustment).
+type AATOpticalBounds struct {
+	Left, Top, Right, Bottom int16
+}
+
+// GetBounds returns the optical bounds of `glyph`, or false if not found.
+func (t TableOpbd) GetBounds(glyph GID) (bounds AATOpticalBounds, ok bool) {
+	if t.class == nil {
+		return bounds, false
+	}
+	offset, ok := t.class.ClassID(glyph)
+	if !ok || len(t.bounds) < int(offset)+8 {
+		return bounds, false
+	}
+	data := t.bounds[offset:]
+	bounds.Left = int16(binary.BigEndian.Uint16(data))
+	bounds.Top = int16(binary.BigEndian.Uint16(data[2:]))
+	bounds.Right = int16(binary.BigEndian.Uint16(data[4:]))
+	bounds.Bottom = int16(binary.BigEndian.Uint16(data[6:]))
+	return bounds, true
+}
+
+func parseTableOpbd(data []byte, numGlyphs int) (out TableOpbd, err error) {
+	if len(data) < 8 {
+		return out, errors.New("invalid 'opbd' table (EOF)")
+	}
+	switch format := binary.BigEndian.Uint16(data[4:]); format {
+	case 0:
+	case 1:
+		out.IsControlPoints = true
+	default:
+		return out, fmt.Errorf("unsupported 'opbd' table format: %d", format)
+	}
+	out.class, err = parseAATLookupTable(data, 6, numGlyphs, false)
+	if err != nil {
+		return out, fmt.Errorf("invalid 'opbd' table: %s", err)
+	}
+	out.bounds = data
+	if e := out.class.Extent(); e-1+8 > len(out.bounds) {
+		return out, errors.New("invalid 'opbd' table (EOF)")
+	}
+	return out, nil
+}
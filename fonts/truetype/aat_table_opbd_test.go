@@ -0,0 +1,48 @@
+package truetype
+
+import "testing"
+
+func TestParseTableOpbd(t *testing.T) {
+	data := deHexStr(
+		"0001 0000 0000 " + // version 1.0, format 0 (distances)
+			"0006 0004 0002 0008 0001 0000 " + // lookup format 6, 2 units of 4 bytes
+			"0005 001A 0007 0022 " + // glyph 5 -> 26, glyph 7 -> 34
+			"FFF6 0000 000F 0000 " + // bounds for glyph 5
+			"0000 0005 0000 FFFB") // bounds for glyph 7
+
+	opbd, err := parseTableOpbd(data, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opbd.IsControlPoints {
+		t.Fatal("expected distance format")
+	}
+	expecteds := []struct {
+		glyph  GID
+		bounds AATOpticalBounds
+		ok     bool
+	}{
+		{5, AATOpticalBounds{Left: -10, Right: 15}, true},
+		{7, AATOpticalBounds{Top: 5, Bottom: -5}, true},
+		{6, AATOpticalBounds{}, false},
+	}
+	for _, exp := range expecteds {
+		bounds, ok := opbd.GetBounds(exp.glyph)
+		if bounds != exp.bounds || ok != exp.ok {
+			t.Fatalf("invalid bounds for %d: expected %v, got %v", exp.glyph, exp.bounds, bounds)
+		}
+	}
+
+	if _, err := parseTableOpbd(data[:38], 10); err == nil {
+		t.Fatal("expected error for truncated table")
+	}
+	data[5] = 2
+	if _, err := parseTableOpbd(data, 10); err == nil {
+		t.Fatal("expected error for invalid format")
+	}
+
+	var empty TableOpbd
+	if _, ok := empty.GetBounds(5); ok {
+		t.Fatal("unexpected bounds in empty table")
+	}
+}
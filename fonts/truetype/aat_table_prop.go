@@ -0,0 +1,112 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TableProp is the AAT 'prop' table, which stores glyph properties.
+// See https://developer.apple.com/fonts/TrueType-Reference-Manual/RM06/Chap6prop.html
+type TableProp struct {
+	class    Class // may be nil when all glyphs have the default properties
+	Default  AATGlyphProperties
+	hasTable bool
+}
+
+// AATGlyphProperties is a bit field describing properties of a glyph.
+// See the constants and methods for its content.
+type AATGlyphProperties uint16
+
+const (
+	// The glyph floats above or below the surrounding glyphs
+	// (such as a diacritic).
+	AATFloater AATGlyphProperties = 0x8000
+	// The glyph may hang off the left (or top) edge of the line.
+	AATHangLeft AATGlyphProperties = 0x4000
+	// The glyph may hang off the right (or bottom) edge of the line.
+	AATHangRight AATGlyphProperties = 0x2000
+	// The glyph attaches on its right side (version 3.0).
+	AATAttachRight AATGlyphProperties = 0x0080
+
+	aatHasBracket     AATGlyphProperties = 0x1000
+	aatBracketOffset  AATGlyphProperties = 0x0F00
+	aatDirectionality AATGlyphProperties = 0x001F
+)
+
+// AATDirectionality is the directionality class of a glyph,
+// used by the bidirectional algorithm.
+type AATDirectionality uint8
+
+const (
+	AATStrongLeftToRight        AATDirectionality = iota // L
+	AATStrongRightToLeft                                 // R
+	AATArabicLetter                                      // AL
+	AATEuropeanNumber                                    // EN
+	AATEuropeanNumberSeparator                           // ES
+	AATEuropeanNumberTerminator                          // ET
+	AATArabicNumber                                      // AN
+	AATCommonNumberSeparator                             // CS
+	AATParagraphSeparator                                // B
+	AATSegmentSeparator                                  // S
+	AATWhitespace                                        // WS
+	AATOtherNeutral                                      // ON
+	AATLeftToRightEmbedding                              // LRE
+	AATLeftToRightOverride                               // LRO
+	AATRightToLeftEmbedding                              // RLE
+	AATRightToLeftOverride                               // RLO
+	AATPopDirectionalFormat                              // PDF
+	AATNonSpacingMark                                    // NSM
+	AATBoundaryNeutral                                   // BN
+)
+
+// Directionality returns the directionality class of the glyph.
+func (p AATGlyphProperties) Directionality() AATDirectionality {
+	return AATDirectionality(p & aatDirectionality)
+}
+
+// ComplementaryBracketOffset returns the offset to add to the glyph
+// to obtain its complementary bracket (such as the closing parenthesis for
+// an opening one), or 0 if the glyph is not a bracket.
+func (p AATGlyphProperties) ComplementaryBracketOffset() int {
+	if p&aatHasBracket == 0 {
+		return 0
+	}
+	offset := int(p&aatBracketOffset) >> 8
+	if offset >= 8 { // signed 4 bits value
+		offset -= 16
+	}
+	return offset
+}
+
+// IsEmpty return `true` it the table is absent.
+func (t TableProp) IsEmpty() bool { return !t.hasTable }
+
+// GetProperties returns the properties of `glyph`.
+func (t TableProp) GetProperties(glyph GID) AATGlyphProperties {
+	if t.class != nil {
+		if props, ok := t.class.ClassID(glyph); ok {
+			return AATGlyphProperties(props)
+		}
+	}
+	return t.Default
+}
+
+func parseTableProp(data []byte, numGlyphs int) (out TableProp, err error) {
+	if len(data) < 8 {
+		return out, errors.New("invalid 'prop' table (EOF)")
+	}
+	out.hasTable = true
+	out.Default = AATGlyphProperties(binary.BigEndian.Uint16(data[6:]))
+	switch format := binary.BigEndian.Uint16(data[4:]); format {
+	case 0: // no lookup table
+	case 1:
+		out.class, err = parseAATLookupTable(data, 8, numGlyphs, false)
+		if err != nil {
+			return out, fmt.Errorf("invalid 'prop' table: %s", err)
+		}
+	default:
+		return out, fmt.Errorf("unsupported 'prop' table format: %d", format)
+	}
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package truetype
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseTableProp(t *testing.T) {
+	f, err := os.Open("testdata/ToyKern1.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	pr, err := NewFontParser(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop, err := pr.PropTable(195)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop.IsEmpty() {
+		t.Fatal("expected non empty table")
+	}
+
+	expecteds := []struct {
+		glyph     GID
+		direction AATDirectionality
+		bracket   int
+		floater   bool
+	}{
+		{3, AATWhitespace, 0, false},
+		{11, AATOtherNeutral, 1, false},  // (
+		{12, AATOtherNeutral, -1, false}, // )
+		{31, AATOtherNeutral, 2, false},
+		{20, AATEuropeanNumber, 0, false},
+		{188, AATOtherNeutral, 0, true},
+		{1, AATStrongLeftToRight, 0, false}, // default
+	}
+	for _, exp := range expecteds {
+		props := prop.GetProperties(exp.glyph)
+		if got := props.Directionality(); got != exp.direction {
+			t.Errorf("glyph %d: expected direction %d, got %d", exp.glyph, exp.direction, got)
+		}
+		if got := props.ComplementaryBracketOffset(); got != exp.bracket {
+			t.Errorf("glyph %d: expected bracket offset %d, got %d", exp.glyph, exp.bracket, got)
+		}
+		if got := props&AATFloater != 0; got != exp.floater {
+			t.Errorf("glyph %d: expected floater %v, got %v", exp.glyph, exp.floater, got)
+		}
+	}
+
+	// format 0 applies the default properties to all glyphs
+	prop, err = parseTableProp(deHexStr("0002 0000 0000 4001"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props := prop.GetProperties(5); props&AATHangLeft == 0 || props.Directionality() != AATStrongRightToLeft {
+		t.Fatalf("unexpected properties %x", props)
+	}
+}
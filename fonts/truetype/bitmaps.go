@@ -54,9 +54,21 @@ func (pr *FontParser) selectBitmapTable() bitmapTable {
 	return nil
 }
 
+// SetBitmapRendering sets the options used to render the bitmap glyphs
+// (see fonts.BitmapRendering), which also apply to the metrics returned
+// by HorizontalAdvance and GlyphExtents.
+func (font *Font) SetBitmapRendering(rendering fonts.BitmapRendering) {
+	font.bitmapRendering = rendering
+}
+
 // LoadBitmaps checks for the various bitmaps table and returns
-// the first valid
+// the first valid. When scaling is enabled (see SetBitmapRendering),
+// the sizes of the strikes are completed by their scaled versions.
 func (font *Font) LoadBitmaps() []fonts.BitmapSize {
+	return font.bitmapRendering.ScaledSizes(font.loadBitmaps())
+}
+
+func (font *Font) loadBitmaps() []fonts.BitmapSize {
 	upem := font.Head.UnitsPerEm
 
 	avgWidth := font.OS2.XAvgCharWidth
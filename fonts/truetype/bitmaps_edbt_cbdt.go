@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 
 	"github.com/benoitkugler/textlayout/fonts"
@@ -310,6 +311,7 @@ func parseIndexSubTable3(firstGlyph, lastGlyph GID, imageFormat uint16, imageDat
 		return out, err
 	}
 	out.firstGlyph, out.lastGlyph = firstGlyph, lastGlyph
+	out.format = imageFormat
 	out.glyphs = make([]*bitmapDataMetrics, numGlyphs)
 	for i := range out.glyphs {
 		if offsets[i] == offsets[i+1] {
@@ -416,7 +418,7 @@ func parseIndexSubTable5(firstGlyph, lastGlyph GID, imageFormat uint16, imageDat
 	out.glyphs = make([]bitmapDataStandalone, numGlyphs)
 	for i := range out.glyphs {
 		out.glyphIndexes[i] = GID(binary.BigEndian.Uint16(data[2*i:]))
-		out.glyphs[i], err = parseBitmapDataStandalone(imageData, imageSize*uint32(i), (imageSize+1)*uint32(i), imageFormat)
+		out.glyphs[i], err = parseBitmapDataStandalone(imageData, imageSize*uint32(i), imageSize*uint32(i+1), imageFormat)
 		if err != nil {
 			return out, fmt.Errorf("invalid bitmap index format 5: %s", err)
 		}
@@ -476,10 +478,20 @@ func parseBigGlyphMetrics(data []byte) (out bigGlyphMetrics) {
 // for now, we simplify the implementation to two cases:
 //	- data, metrics (small)
 //  - data only
+// The image data is stored as found in the font, and decoded
+// when requested (see bitmapSize.decodeGlyph).
 
 type bitmapDataMetrics struct {
 	image   []byte
 	metrics smallGlyphMetrics
+	// for composite formats 8 and 9, image is empty
+	components []bitmapComponent
+}
+
+// bitmapComponent is a glyph used in a composite bitmap
+type bitmapComponent struct {
+	glyph            GID
+	xOffset, yOffset int8 // position of the top left corner of the component
 }
 
 type bitmapDataStandalone []byte
@@ -490,10 +502,14 @@ func parseBitmapDataMetrics(imageData []byte, start, end uint32, format uint16)
 	}
 	imageData = imageData[start:end]
 	switch format {
-	case 1, 6, 7, 8, 9:
-		return nil, fmt.Errorf("valid but currently not implemented bitmap image format: %d", format)
-	case 2:
-		return parseBitmapDataFormat2(imageData)
+	case 1, 2:
+		return parseBitmapDataFormat1And2(imageData, format)
+	case 6, 7:
+		return parseBitmapDataFormat6And7(imageData, format)
+	case 8:
+		return parseBitmapDataFormat8(imageData)
+	case 9:
+		return parseBitmapDataFormat9(imageData)
 	case 17:
 		return parseBitmapDataFormat17(imageData)
 	case 18:
@@ -510,7 +526,7 @@ func parseBitmapDataStandalone(imageData []byte, start, end uint32, format uint1
 	imageData = imageData[start:end]
 	switch format {
 	case 4:
-		return nil, fmt.Errorf("valid but currently not implemented bitmap image format: %d", format)
+		return parseBitmapDataFormat4(imageData)
 	case 5:
 		return parseBitmapDataFormat5(imageData)
 	case 19:
@@ -520,11 +536,11 @@ func parseBitmapDataStandalone(imageData []byte, start, end uint32, format uint1
 	}
 }
 
-// small metrics, bit-aligned data
+// small metrics, byte-aligned (format 1) or bit-aligned (format 2) data
 // data start at the image data
-func parseBitmapDataFormat2(data []byte) (*bitmapDataMetrics, error) {
+func parseBitmapDataFormat1And2(data []byte, format uint16) (*bitmapDataMetrics, error) {
 	if len(data) < smallGlyphMetricsSize {
-		return nil, errors.New("invalid bitmap data format 2 (EOF)")
+		return nil, fmt.Errorf("invalid bitmap data format %d (EOF)", format)
 	}
 	return &bitmapDataMetrics{
 		metrics: parseSmallGlyphMetrics(data),
@@ -532,6 +548,82 @@ func parseBitmapDataFormat2(data []byte) (*bitmapDataMetrics, error) {
 	}, nil
 }
 
+// Format 4: metrics in the location table, Huffman compressed data
+// data start at the image data
+func parseBitmapDataFormat4(data []byte) (out bitmapDataStandalone, err error) {
+	if len(data) < 12 {
+		return out, errors.New("invalid bitmap data format 4 (EOF)")
+	}
+	// the offsets are checked when decoding
+	return data, nil
+}
+
+// big metrics, byte-aligned (format 6) or bit-aligned (format 7) data
+// data start at the image data
+func parseBitmapDataFormat6And7(data []byte, format uint16) (*bitmapDataMetrics, error) {
+	if len(data) < bigGlyphMetricsSize {
+		return nil, fmt.Errorf("invalid bitmap data format %d (EOF)", format)
+	}
+	// for now, we only use the first metrics
+	return &bitmapDataMetrics{
+		metrics: parseBigGlyphMetrics(data).smallGlyphMetrics,
+		image:   data[bigGlyphMetricsSize:],
+	}, nil
+}
+
+// small metrics, component data
+// data start at the image data
+func parseBitmapDataFormat8(data []byte) (*bitmapDataMetrics, error) {
+	if len(data) < smallGlyphMetricsSize+3 {
+		return nil, errors.New("invalid bitmap data format 8 (EOF)")
+	}
+	var (
+		out bitmapDataMetrics
+		err error
+	)
+	out.metrics = parseSmallGlyphMetrics(data)
+	// skip the padding byte
+	out.components, err = parseBitmapComponents(data[smallGlyphMetricsSize+1:])
+	if err != nil {
+		return nil, errors.New("invalid bitmap data format 8 (EOF)")
+	}
+	return &out, nil
+}
+
+// big metrics, component data
+// data start at the image data
+func parseBitmapDataFormat9(data []byte) (*bitmapDataMetrics, error) {
+	if len(data) < bigGlyphMetricsSize+2 {
+		return nil, errors.New("invalid bitmap data format 9 (EOF)")
+	}
+	var (
+		out bitmapDataMetrics
+		err error
+	)
+	// for now, we only use the first metrics
+	out.metrics = parseBigGlyphMetrics(data).smallGlyphMetrics
+	out.components, err = parseBitmapComponents(data[bigGlyphMetricsSize:])
+	if err != nil {
+		return nil, errors.New("invalid bitmap data format 9 (EOF)")
+	}
+	return &out, nil
+}
+
+// data starts at the number of components
+func parseBitmapComponents(data []byte) ([]bitmapComponent, error) {
+	count := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+4*count {
+		return nil, io.ErrUnexpectedEOF
+	}
+	out := make([]bitmapComponent, count)
+	for i := range out {
+		out[i].glyph = GID(binary.BigEndian.Uint16(data[2+4*i:]))
+		out[i].xOffset = int8(data[2+4*i+2])
+		out[i].yOffset = int8(data[2+4*i+3])
+	}
+	return out, nil
+}
+
 // Format 5: metrics in CBLC table, bit-aligned image data only
 // data start at the image data
 func parseBitmapDataFormat5(data []byte) (out bitmapDataStandalone, err error) {
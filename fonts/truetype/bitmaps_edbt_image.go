@@ -0,0 +1,244 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+const (
+	// maximum nesting level of composite bitmaps
+	maxBitmapCompositeDepth = 8
+	// maximum number of components (nested ones included)
+	// decoded for one glyph
+	maxBitmapComponents = 1024
+)
+
+// pixelDepth returns the number of bits per pixel
+// of the non PNG formats.
+func (b *bitmapSize) pixelDepth() int {
+	switch b.bitDepth {
+	case 2, 4, 8:
+		return int(b.bitDepth)
+	default:
+		return 1
+	}
+}
+
+// bitmapFormat returns the format of the non PNG images
+func (b *bitmapSize) bitmapFormat() fonts.BitmapFormat {
+	switch b.pixelDepth() {
+	case 2:
+		return fonts.Gray2
+	case 4:
+		return fonts.Gray4
+	case 8:
+		return fonts.Gray8
+	default:
+		return fonts.BlackAndWhite
+	}
+}
+
+// decodeGlyph returns the metrics and the image of `gid` together with its format.
+// Except for PNG formats, the image is decoded as bit-aligned rows, with `pixelDepth` bits
+// per pixel: byte-aligned data are repacked, compressed data are expanded and composite
+// glyphs are resolved.
+func (b *bitmapSize) decodeGlyph(gid GID) (image []byte, metrics smallGlyphMetrics, format uint16, err error) {
+	var state compositeState
+	return b.decodeGlyphLevel(gid, &state)
+}
+
+// compositeState bounds the work needed to resolve composite glyphs
+type compositeState struct {
+	path       []GID // composite glyphs being decoded, used to detect cycles
+	components int   // number of components already decoded
+}
+
+func (b *bitmapSize) decodeGlyphLevel(gid GID, state *compositeState) (image []byte, metrics smallGlyphMetrics, format uint16, err error) {
+	subtable := b.findTable(gid)
+	if subtable == nil {
+		return nil, metrics, 0, fmt.Errorf("no glyph %d in bitmap strike", gid)
+	}
+	glyph := subtable.getImage(gid)
+	if glyph == nil {
+		return nil, metrics, 0, fmt.Errorf("no glyph %d in bitmap strike", gid)
+	}
+	metrics, format = glyph.metrics, subtable.imageFormat()
+	depth := b.pixelDepth()
+	width, height := int(metrics.width), int(metrics.height)
+	switch format {
+	case 2, 5, 7, 17, 18, 19:
+		image = glyph.image
+	case 1, 6:
+		image, err = repackByteAligned(glyph.image, width, height, depth)
+	case 4:
+		image, err = decodeBitmapHuffman(glyph.image, width, height)
+	case 8, 9:
+		if len(state.path) >= maxBitmapCompositeDepth {
+			return nil, metrics, format, errors.New("invalid composite bitmap (too many nested components)")
+		}
+		state.path = append(state.path, gid)
+		image, err = b.decodeComposite(glyph.components, width, height, state)
+		state.path = state.path[:len(state.path)-1]
+	default:
+		err = fmt.Errorf("unsupported bitmap image format: %d", format)
+	}
+	return image, metrics, format, err
+}
+
+// decodeComposite draws the components into a bit-aligned image of the given size
+func (b *bitmapSize) decodeComposite(components []bitmapComponent, width, height int, state *compositeState) ([]byte, error) {
+	depth := b.pixelDepth()
+	out := make([]byte, (width*height*depth+7)/8)
+	for _, comp := range components {
+		state.components++
+		if state.components > maxBitmapComponents {
+			return nil, errors.New("invalid composite bitmap (too many components)")
+		}
+		for _, parent := range state.path {
+			if parent == comp.glyph {
+				return nil, fmt.Errorf("invalid composite bitmap (cyclic reference to glyph %d)", comp.glyph)
+			}
+		}
+		image, metrics, format, err := b.decodeGlyphLevel(comp.glyph, state)
+		if err != nil {
+			return nil, err
+		}
+		switch format {
+		case 17, 18, 19:
+			return nil, fmt.Errorf("invalid composite bitmap (PNG component %d)", comp.glyph)
+		}
+		compWidth, compHeight := int(metrics.width), int(metrics.height)
+		if len(image)*8 < compWidth*compHeight*depth {
+			return nil, errors.New("invalid bitmap data (EOF)")
+		}
+		for y := 0; y < compHeight; y++ {
+			dstY := y + int(comp.yOffset)
+			if dstY < 0 || dstY >= height {
+				continue
+			}
+			for x := 0; x < compWidth; x++ {
+				dstX := x + int(comp.xOffset)
+				if dstX < 0 || dstX >= width {
+					continue
+				}
+				value := getPixel(image, y*compWidth+x, depth)
+				// components are combined, keeping the darkest pixel
+				if dstIndex := dstY*width + dstX; value > getPixel(out, dstIndex, depth) {
+					setPixel(out, dstIndex, depth, value)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// returns the value of the pixel at `index`, in bit-aligned data
+// with `depth` bits per pixel (1, 2, 4 or 8)
+func getPixel(data []byte, index, depth int) byte {
+	bit := index * depth
+	shift := 8 - depth - bit%8
+	return data[bit/8] >> shift & (1<<depth - 1)
+}
+
+// set the pixel at `index`, which is assumed to be zero
+func setPixel(data []byte, index, depth int, value byte) {
+	bit := index * depth
+	shift := 8 - depth - bit%8
+	data[bit/8] = data[bit/8]&^((1<<depth-1)<<shift) | value<<shift
+}
+
+// repackByteAligned converts rows padded to a byte boundary
+// to bit-aligned rows
+func repackByteAligned(data []byte, width, height, depth int) ([]byte, error) {
+	rowBits := width * depth
+	stride := (rowBits + 7) / 8
+	if len(data) < stride*height {
+		return nil, errors.New("invalid byte-aligned bitmap data (EOF)")
+	}
+	if rowBits%8 == 0 { // nothing to do
+		return data[:stride*height], nil
+	}
+	out := make([]byte, (rowBits*height+7)/8)
+	for y := 0; y < height; y++ {
+		row := data[y*stride:]
+		for b := 0; b < rowBits; b++ {
+			if row[b/8]&(0x80>>(b%8)) != 0 {
+				dst := y*rowBits + b
+				out[dst/8] |= 0x80 >> (dst % 8)
+			}
+		}
+	}
+	return out, nil
+}
+
+// decodeBitmapHuffman decodes the image format 4, used in 'bdat' tables.
+// The data starts with the offsets (from the start of the data) of two
+// trees, for white and black runs, and of the compressed bit stream.
+// Each tree is an array of nodes, made of two uint16 children for the
+// 0 and 1 bits, starting at the root. A child with the high bit set is a leaf,
+// storing a run length; otherwise it is the index of the next node.
+// The runs alternate white and black, starting with white, and cover the image
+// in row-major order. The decoded image is bit-aligned, with 1 bit per pixel.
+func decodeBitmapHuffman(data []byte, width, height int) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("invalid bitmap data format 4 (EOF)")
+	}
+	whiteTree := binary.BigEndian.Uint32(data)
+	blackTree := binary.BigEndian.Uint32(data[4:])
+	streamOffset := binary.BigEndian.Uint32(data[8:])
+	if int(whiteTree) > len(data) || int(blackTree) > len(data) || int(streamOffset) > len(data) {
+		return nil, errors.New("invalid bitmap data format 4 (EOF)")
+	}
+	trees := [2][]byte{data[whiteTree:], data[blackTree:]}
+	stream := data[streamOffset:]
+
+	total := width * height
+	out := make([]byte, (total+7)/8)
+	var (
+		streamBit int  // position in the stream
+		pos       int  // current pixel
+		black     bool // color of the current run
+	)
+	for pos < total {
+		tree := trees[0]
+		if black {
+			tree = trees[1]
+		}
+		// walk the tree until a leaf is found
+		node := 0
+		for {
+			if streamBit >= 8*len(stream) {
+				return nil, errors.New("invalid bitmap data format 4 (EOF in stream)")
+			}
+			bit := int(stream[streamBit/8]>>(7-streamBit%8)) & 1
+			streamBit++
+			if len(tree) < 4*node+4 {
+				return nil, errors.New("invalid bitmap data format 4 (EOF in tree)")
+			}
+			child := binary.BigEndian.Uint16(tree[4*node+2*bit:])
+			if child&0x8000 != 0 {
+				node = int(child & 0x7FFF)
+				break
+			}
+			if int(child) <= node { // avoid infinite loops
+				return nil, errors.New("invalid bitmap data format 4 (invalid tree)")
+			}
+			node = int(child)
+		}
+		run := node
+		if pos+run > total {
+			run = total - pos
+		}
+		if black {
+			for i := pos; i < pos+run; i++ {
+				out[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+		pos += run
+		black = !black
+	}
+	return out, nil
+}
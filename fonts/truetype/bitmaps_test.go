@@ -2,6 +2,7 @@ package truetype
 
 import (
 	"fmt"
+	"image"
 	"os"
 	"reflect"
 	"testing"
@@ -211,3 +212,270 @@ func TestSize(t *testing.T) {
 		file.Close()
 	}
 }
+
+// buildEbdt returns 'EBLC' and 'EBDT' tables with one strike,
+// where each glyph is stored in its own index subtable.
+// `images` are the image data, including the metrics, and
+// `formats` the index and image format of each glyph (starting at 1).
+func buildEbdt(images [][]byte, formats [][2]uint16, metrics []byte, bitDepth byte) (eblc, ebdt []byte) {
+	u16 := func(b []byte, v uint16) []byte { return append(b, byte(v>>8), byte(v)) }
+	u32 := func(b []byte, v uint32) []byte { return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)) }
+
+	ebdt = []byte{0, 2, 0, 0}
+	n := len(images)
+	arrayOffset := 8 + bitmapSizeLength
+	eblc = u32(nil, 0x00020000)
+	eblc = u32(eblc, 1)
+	eblc = u32(eblc, uint32(arrayOffset))
+	eblc = u32(eblc, 0)
+	eblc = u32(eblc, uint32(n))
+	eblc = u32(eblc, 0)
+	eblc = append(eblc, make([]byte, 2*sbitLineMetricsLength)...)
+	eblc = u16(eblc, 1)
+	eblc = u16(eblc, uint16(n))
+	eblc = append(eblc, 8, 8, bitDepth, 1)
+
+	var subtables []byte
+	for i, image := range images {
+		eblc = u16(eblc, uint16(i+1))
+		eblc = u16(eblc, uint16(i+1))
+		eblc = u32(eblc, uint32(8*n+len(subtables)))
+
+		subtables = u16(subtables, formats[i][0])
+		subtables = u16(subtables, formats[i][1])
+		subtables = u32(subtables, uint32(len(ebdt)))
+		if formats[i][0] == 1 {
+			subtables = u32(subtables, 0)
+			subtables = u32(subtables, uint32(len(image)))
+		} else { // format 2
+			subtables = u32(subtables, uint32(len(image)))
+			subtables = append(subtables, metrics...)
+		}
+		ebdt = append(ebdt, image...)
+	}
+	return append(eblc, subtables...), ebdt
+}
+
+func TestEbdtFormats(t *testing.T) {
+	images := [][]byte{
+		// format 1 : 3x2, byte-aligned
+		{2, 3, 0, 2, 4, 0b10100000, 0b01000000},
+		// format 6 : 10x1, byte-aligned
+		{1, 10, 0, 1, 11, 0, 0, 0, 0xFF, 0xC0},
+		// format 7 : 3x2, bit-aligned
+		{2, 3, 0, 2, 4, 0, 0, 0, 0b10101000},
+		// format 8 : 6x2, glyphs 1 and 3
+		{2, 6, 0, 2, 7, 0, 0, 2, 0, 1, 0, 0, 0, 3, 3, 0},
+		// format 9 : 6x3, glyphs 4 and 2 (clipped)
+		{3, 6, 0, 3, 7, 0, 0, 0, 0, 2, 0, 4, 0, 1, 0, 2, 0, 0},
+		// format 4 : 4x2, with white runs 2, 2 and black run 4
+		{0, 0, 0, 12, 0, 0, 0, 16, 0, 0, 0, 20, 0x80, 2, 0x80, 0, 0x80, 4, 0x80, 1, 0},
+	}
+	formats := [][2]uint16{{1, 1}, {1, 6}, {1, 7}, {1, 8}, {1, 9}, {2, 4}}
+	eblc, ebdt := buildEbdt(images, formats, []byte{2, 4, 0, 2, 5, 0, 0, 0}, 1)
+
+	table, err := parseTableBitmap(eblc, ebdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for gid, expected := range map[GID]fonts.GlyphBitmap{
+		1: {Data: []byte{0b10101000}, Width: 3, Height: 2, YBearing: 2, Advance: 4},
+		2: {Data: []byte{0xFF, 0xC0}, Width: 10, Height: 1, YBearing: 1, Advance: 11},
+		3: {Data: []byte{0b10101000}, Width: 3, Height: 2, YBearing: 2, Advance: 4},
+		4: {Data: []byte{0b10110101, 0b00100000}, Width: 6, Height: 2, YBearing: 2, Advance: 7},
+		5: {Data: []byte{0b11111110, 0b11010100, 0b10000000}, Width: 6, Height: 3, YBearing: 3, Advance: 7},
+		6: {Data: []byte{0b00111100}, Width: 4, Height: 2, YBearing: 2, Advance: 5},
+	} {
+		expected.Format = fonts.BlackAndWhite
+		got, err := table.glyphData(gid, 8, 8, fonts.BitmapRendering{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("glyph %d: expected %v, got %v", gid, expected, got)
+		}
+	}
+
+	// recursive composite
+	images[3] = []byte{2, 6, 0, 2, 7, 0, 0, 1, 0, 4, 0, 0}
+	eblc, ebdt = buildEbdt(images, formats, []byte{2, 4, 0, 2, 5, 0, 0, 0}, 1)
+	table, err = parseTableBitmap(eblc, ebdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.glyphData(4, 8, 8, fonts.BitmapRendering{}); err == nil {
+		t.Fatal("expected error for recursive composite")
+	}
+
+	// too many components
+	numComponents := 2 * maxBitmapComponents
+	images[3] = []byte{2, 6, 0, 2, 7, 0, byte(numComponents >> 8), byte(numComponents)}
+	for i := 0; i < numComponents; i++ {
+		images[3] = append(images[3], 0, 1, 0, 0)
+	}
+	eblc, ebdt = buildEbdt(images, formats, []byte{2, 4, 0, 2, 5, 0, 0, 0}, 1)
+	table, err = parseTableBitmap(eblc, ebdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.glyphData(4, 8, 8, fonts.BitmapRendering{}); err == nil {
+		t.Fatal("expected error for too many components")
+	}
+
+	// invalid Huffman stream
+	images[5] = images[5][:20]
+	eblc, ebdt = buildEbdt(images, formats, []byte{2, 4, 0, 2, 5, 0, 0, 0}, 1)
+	table, err = parseTableBitmap(eblc, ebdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.glyphData(6, 8, 8, fonts.BitmapRendering{}); err == nil {
+		t.Fatal("expected error for truncated stream")
+	}
+}
+
+func TestEbdtGrayscale(t *testing.T) {
+	// 3x2 image, with 2 bits per pixel : 3, 1, 2 and 0, 3, 0
+	images := [][]byte{{2, 3, 1, 2, 4, 0b11011000, 0b00110000}}
+	eblc, ebdt := buildEbdt(images, [][2]uint16{{1, 1}}, nil, 2)
+	table, err := parseTableBitmap(eblc, ebdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := table.glyphData(1, 8, 8, fonts.BitmapRendering{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fonts.GlyphBitmap{
+		Data: []byte{0b11011000, 0b11000000}, Format: fonts.Gray2,
+		Width: 3, Height: 2, XBearing: 1, YBearing: 2, Advance: 4,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	img, err := got.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alpha, ok := img.(*image.Alpha)
+	if !ok {
+		t.Fatalf("unexpected image type %T", img)
+	}
+	if exp := []uint8{0xFF, 0x55, 0xAA, 0, 0xFF, 0}; !reflect.DeepEqual(alpha.Pix, exp) {
+		t.Fatalf("expected %v, got %v", exp, alpha.Pix)
+	}
+
+	// trailing bytes do not change the (bit-aligned) layout
+	got.Data = append(got.Data, 0xFF, 0xFF)
+	img, err = got.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []uint8{0xFF, 0x55, 0xAA, 0, 0xFF, 0}; !reflect.DeepEqual(img.(*image.Alpha).Pix, exp) {
+		t.Fatalf("expected %v, got %v", exp, img.(*image.Alpha).Pix)
+	}
+}
+
+func TestBitmapRendering(t *testing.T) {
+	// 3x2 image : 101 and 010
+	images := [][]byte{{2, 3, 0, 2, 4, 0, 0, 0, 0b10101000}}
+	eblc, ebdt := buildEbdt(images, [][2]uint16{{1, 7}}, nil, 1)
+	table, err := parseTableBitmap(eblc, ebdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendering := fonts.BitmapRendering{Scaling: fonts.IntegerBitmapScaling, Bold: true}
+	got, err := table.glyphData(1, 20, 20, rendering) // strike is 8 ppem
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fonts.GlyphBitmap{
+		Data: []byte{0xFF, 0xFF, 0x3C, 0x3C}, Format: fonts.BlackAndWhite,
+		Width: 8, Height: 4, YBearing: 4, Advance: 10,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	rendering = fonts.BitmapRendering{Scaling: fonts.NearestBitmapScaling}
+	if xScale, yScale := rendering.ScaleFactors(8, 8, 0xFFFF, 0xFFFF); xScale != 4 || yScale != 4 {
+		t.Fatalf("expected clamped scales, got %g %g", xScale, yScale)
+	}
+	got, err = table.glyphData(1, 12, 12, rendering)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = fonts.GlyphBitmap{
+		Data: []byte{0b11001110, 0b01001100}, Format: fonts.BlackAndWhite,
+		Width: 5, Height: 3, YBearing: 3, Advance: 6,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestBitmapRenderingMetrics(t *testing.T) {
+	font := loadFont(t, "testdata/IBM3161-bitmap.otb") // one strike at 16 ppem
+	gid, _ := font.NominalGlyph('A')
+	regularAdvance := font.HorizontalAdvance(gid)
+
+	font.SetBitmapRendering(fonts.BitmapRendering{Scaling: fonts.IntegerBitmapScaling, Bold: true})
+	if sizes := font.LoadBitmaps(); len(sizes) != 4 || sizes[1].XPpem != 32 || sizes[3].YPpem != 64 {
+		t.Fatalf("unexpected bitmap sizes %v", sizes)
+	}
+	if adv := font.HorizontalAdvance(gid); adv != regularAdvance+1000./16 {
+		t.Fatalf("unexpected bold advance %g (regular %g)", adv, regularAdvance)
+	}
+	// the synthetic bold also applies to variable fonts (here with a trivial 'HVAR' table)
+	font.fvar.Axis, font.varCoords, font.hvar = make([]VarAxis, 1), []float32{0}, &tableHVvar{}
+	if adv := font.HorizontalAdvance(gid); adv != regularAdvance+1000./16 {
+		t.Fatalf("unexpected bold advance %g (regular %g)", adv, regularAdvance)
+	}
+	font.fvar.Axis, font.varCoords, font.hvar = nil, nil, nil
+
+	for _, ppem := range []uint16{16, 32, 40} {
+		scale := float32(ppem / 16) // integer scaling
+		data := font.GlyphData(gid, ppem, ppem).(fonts.GlyphBitmap)
+		extents, ok := font.GlyphExtents(gid, ppem, ppem)
+		if !ok {
+			t.Fatal()
+		}
+		// convert the bitmap metrics to font units
+		toUnits := func(v int) float32 { return float32(v) * 1000 / (16 * scale) }
+		exp := fonts.GlyphExtents{
+			XBearing: toUnits(data.XBearing), YBearing: toUnits(data.YBearing),
+			Width: toUnits(data.Width), Height: -toUnits(data.Height),
+		}
+		if extents != exp {
+			t.Fatalf("ppem %d: expected %v, got %v", ppem, exp, extents)
+		}
+		if adv := font.HorizontalAdvance(gid); toUnits(data.Advance) != adv {
+			t.Fatalf("ppem %d: expected advance %g, got %g", ppem, toUnits(data.Advance), adv)
+		}
+	}
+
+	// PNG images are resampled
+	font = loadFont(t, "testdata/ToyCBLC1.ttf") // one strike at 109 ppem
+	font.SetBitmapRendering(fonts.BitmapRendering{Scaling: fonts.IntegerBitmapScaling})
+	nbImages := 0
+	for gid := GID(0); gid < GID(font.NumGlyphs); gid++ {
+		regular, err := font.bitmap.glyphData(gid, 109, 109, fonts.BitmapRendering{})
+		if err != nil {
+			continue
+		}
+		nbImages++
+		data := font.GlyphData(gid, 218, 218).(fonts.GlyphBitmap)
+		img, err := data.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); data.Format != fonts.PNG || b.Dx() != 2*regular.Width || b.Dy() != 2*regular.Height {
+			t.Fatalf("glyph %d: unexpected image size %v", gid, b)
+		}
+	}
+	if nbImages == 0 {
+		t.Fatal("no bitmap glyphs")
+	}
+}
@@ -0,0 +1,45 @@
+package truetype
+
+// FontBuilder assembles font tables into a font file, computing
+// the table directory, the checksums of the tables and the
+// checksum adjustment stored in the 'head' table.
+//
+// The tables may be encoded with the Serialize methods of the
+// parsed tables, such as TableName.Serialize.
+type FontBuilder struct {
+	tables     map[Tag][]byte
+	scalerType Tag
+}
+
+// NewFontBuilder returns an empty builder for a font whose
+// type is `scalerType`, usually TypeTrueType or TypeOpenType.
+func NewFontBuilder(scalerType Tag) *FontBuilder {
+	return &FontBuilder{scalerType: scalerType, tables: make(map[Tag][]byte)}
+}
+
+// Builder returns a builder initialized with the tables of the font,
+// which may then be replaced or removed.
+func (pr *FontParser) Builder() (*FontBuilder, error) {
+	out := NewFontBuilder(pr.Type)
+	for tag := range pr.tables {
+		table, err := pr.GetRawTable(tag)
+		if err != nil {
+			return nil, err
+		}
+		out.tables[tag] = table
+	}
+	return out, nil
+}
+
+// AddTable adds the table `tag`, replacing the existing one if any.
+func (fb *FontBuilder) AddTable(tag Tag, table []byte) { fb.tables[tag] = table }
+
+// RemoveTable removes the table `tag`, if present.
+func (fb *FontBuilder) RemoveTable(tag Tag) { delete(fb.tables, tag) }
+
+// Table returns the content of the table `tag`, or nil if absent.
+func (fb *FontBuilder) Table(tag Tag) []byte { return fb.tables[tag] }
+
+// Build returns the font file. The table directory and the table data
+// are both sorted by tag, and each table is four bytes aligned.
+func (fb *FontBuilder) Build() []byte { return writeOTF(fb.scalerType, fb.tables) }
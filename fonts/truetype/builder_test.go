@@ -0,0 +1,214 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func loadParser(t *testing.T, filename string) *FontParser {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := NewFontParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func cmapMappings(cmap Cmap) map[rune]GID {
+	out := make(map[rune]GID)
+	for iter := cmap.Iter(); iter.Next(); {
+		r, g := iter.Char()
+		out[r] = g
+	}
+	return out
+}
+
+func sortedNames(names TableName) TableName {
+	key := func(n NameEntry) uint64 {
+		return uint64(n.PlatformID)<<48 | uint64(n.EncodingID)<<32 | uint64(n.LanguageID)<<16 | uint64(n.NameID)
+	}
+	out := append(TableName(nil), names...)
+	sort.SliceStable(out, func(i, j int) bool { return key(out[i]) < key(out[j]) })
+	return out
+}
+
+func TestSerializeTables(t *testing.T) {
+	for _, filename := range []string{
+		"testdata/DejaVuSerif.ttf",
+		"testdata/FreeSerif.ttf",
+		"testdata/Roboto-BoldItalic.ttf",
+		"testdata/SelawikVar.ttf",
+		"testdata/CFFTest.otf",
+		"testdata/Castoro-Regular.ttf",
+		"testdata/ToyCMAP12.otf",
+		"testdata/ToyCMAP14.otf",
+		"testdata/mry_KacstQurn.ttf",
+	} {
+		pr := loadParser(t, filename)
+
+		head, err := pr.loadHeadTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseTableHead(head.Serialize()); err != nil || got != head {
+			t.Fatalf("%s: 'head' not preserved (%v): %v != %v", filename, err, got, head)
+		}
+
+		maxp, err := pr.MaxpTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseTableMaxp(maxp.Serialize()); err != nil || got != maxp {
+			t.Fatalf("%s: 'maxp' not preserved (%v): %v != %v", filename, err, got, maxp)
+		}
+
+		hhea, err := pr.HheaTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hmtx, err := pr.HtmxTable(int(maxp.NumGlyphs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hmtxData, numOfLongMetrics := hmtx.Serialize()
+		if got, err := parseTableHVhea(hhea.Serialize(numOfLongMetrics)); err != nil ||
+			got.numOfLongMetrics != numOfLongMetrics || got.Ascent != hhea.Ascent || got.MaxExtent != hhea.MaxExtent {
+			t.Fatalf("%s: 'hhea' not preserved (%v): %v != %v", filename, err, got, hhea)
+		}
+		if got, err := parseHVmtxTable(hmtxData, numOfLongMetrics, maxp.NumGlyphs); err != nil || !reflect.DeepEqual(got, hmtx) {
+			t.Fatalf("%s: 'hmtx' not preserved (%v)", filename, err)
+		}
+
+		os2, err := pr.OS2Table()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseTableOS2(os2.Serialize()); err != nil || *got != *os2 {
+			t.Fatalf("%s: 'OS/2' not preserved (%v): %v != %v", filename, err, got, os2)
+		}
+
+		names, err := pr.tryAndLoadNameTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseTableName(names.Serialize()); err != nil || !reflect.DeepEqual(sortedNames(got), sortedNames(names)) {
+			t.Fatalf("%s: 'name' not preserved (%v)", filename, err)
+		}
+
+		post, err := pr.PostTable(int(maxp.NumGlyphs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseTablePost(post.Serialize(int(maxp.NumGlyphs)), maxp.NumGlyphs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != post.Version || got.ItalicAngle != post.ItalicAngle || got.UnderlinePosition != post.UnderlinePosition ||
+			got.UnderlineThickness != post.UnderlineThickness || got.IsFixedPitch != post.IsFixedPitch {
+			t.Fatalf("%s: 'post' not preserved: %v != %v", filename, got, post)
+		}
+		if post.Names != nil {
+			for g := 0; g < int(maxp.NumGlyphs); g++ {
+				if a, b := post.Names.GlyphName(GID(g)), got.Names.GlyphName(GID(g)); a != b {
+					t.Fatalf("%s: 'post' glyph %d: %s != %s", filename, g, a, b)
+				}
+			}
+		}
+
+		cmap, err := pr.CmapTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotCmap, err := parseTableCmap(cmap.Serialize())
+		if err != nil {
+			t.Fatalf("%s: invalid 'cmap': %s", filename, err)
+		}
+		if len(gotCmap.Cmaps) != len(cmap.Cmaps) {
+			t.Fatalf("%s: 'cmap' not preserved", filename)
+		}
+		for _, subtable := range cmap.Cmaps {
+			got := gotCmap.FindSubtable(subtable.ID)
+			if got == nil || !reflect.DeepEqual(cmapMappings(got), cmapMappings(subtable.Cmap)) {
+				t.Fatalf("%s: 'cmap' subtable %v not preserved", filename, subtable.ID)
+			}
+		}
+		if !reflect.DeepEqual(gotCmap.unicodeVariation, cmap.unicodeVariation) {
+			t.Fatalf("%s: 'cmap' variations not preserved", filename)
+		}
+	}
+}
+
+func TestFontBuilder(t *testing.T) {
+	pr := loadParser(t, "testdata/DejaVuSerif.ttf")
+	names, err := pr.tryAndLoadNameTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		if name.NameID == NameFontFamily && name.isWindows() {
+			names[i].Value = []byte{0, 'T', 0, 'e', 0, 's', 0, 't'}
+		}
+	}
+
+	builder, err := pr.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddTable(tagName, names.Serialize())
+	builder.RemoveTable(MustNewTag("DSIG"))
+	out := builder.Build()
+
+	// the output is deterministic
+	for range [5]int{} {
+		if !bytes.Equal(builder.Build(), out) {
+			t.Fatal("different outputs for the same input")
+		}
+	}
+
+	// the checksum of the whole font is fixed by the 'head' table
+	if sum := tableChecksum(out); sum != 0xB1B0AFBA {
+		t.Fatalf("invalid font checksum %x", sum)
+	}
+	// the table checksums are valid
+	numTables := int(binary.BigEndian.Uint16(out[4:]))
+	var lastOffset uint32
+	for i := 0; i < numTables; i++ {
+		entry := out[otfHeaderLength+directoryEntryLength*i:]
+		tag := Tag(binary.BigEndian.Uint32(entry))
+		offset, length := binary.BigEndian.Uint32(entry[8:]), binary.BigEndian.Uint32(entry[12:])
+		if offset%4 != 0 {
+			t.Fatalf("table %s is not aligned", tag)
+		}
+		if offset < lastOffset {
+			t.Fatalf("table %s is not stored in the directory order", tag)
+		}
+		lastOffset = offset
+		table := append([]byte(nil), out[offset:offset+length]...)
+		if tag == tagHead {
+			binary.BigEndian.PutUint32(table[8:], 0)
+		}
+		if checksum := binary.BigEndian.Uint32(entry[4:]); checksum != tableChecksum(table) {
+			t.Fatalf("invalid checksum for table %s", tag)
+		}
+	}
+
+	font, err := Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if family := font.Names.getName(NameFontFamily); family != "Test" {
+		t.Fatalf("expected patched family name, got %s", family)
+	}
+	if font.NumGlyphs != loadFont(t, "testdata/DejaVuSerif.ttf").NumGlyphs {
+		t.Fatal("glyphs not preserved")
+	}
+}
@@ -215,6 +215,10 @@ type fontDescriptor struct {
 	os2   *TableOS2
 	names TableName
 	head  TableHead
+
+	// only used for variable fonts
+	fvar TableFvar
+	stat *TableStat
 }
 
 func newFontDescriptor(pr *FontParser) *fontDescriptor {
@@ -223,6 +227,12 @@ func newFontDescriptor(pr *FontParser) *fontDescriptor {
 	out.os2, _ = pr.OS2Table()
 	out.names, _ = pr.tryAndLoadNameTable()
 	out.head, _ = pr.loadHeadTable()
+	out.fvar, _ = pr.tryAndLoadFvarTable(out.names)
+	if len(out.fvar.Axis) != 0 {
+		if stat, err := pr.StatTable(); err == nil {
+			out.stat = &stat
+		}
+	}
 	return &out
 }
 
@@ -246,6 +256,13 @@ func (fd *fontDescriptor) Family() string {
 }
 
 func (fd *fontDescriptor) AdditionalStyle() string {
+	// variable fonts describe the style of their default instance in 'STAT'
+	if fd.stat != nil {
+		if style := fd.stat.StyleName(fd.stat.location(fd.fvar, nil), fd.names); style != "" {
+			return style
+		}
+	}
+
 	var style string
 	if fd.os2 != nil && fd.os2.FsSelection&256 != 0 {
 		style = fd.names.getName(NamePreferredSubfamily)
@@ -307,6 +324,24 @@ func (fd *fontDescriptor) Aspect() (style fonts.Style, weight fonts.Weight, stre
 		}
 	}
 
+	// for variable fonts, prefer the default values of the registered axes
+	for _, axis := range fd.fvar.Axis {
+		switch axis.Tag {
+		case MustNewTag("wght"):
+			weight = fonts.Weight(axis.Default)
+		case MustNewTag("wdth"):
+			stretch = fonts.Stretch(axis.Default / 100)
+		case MustNewTag("ital"):
+			if axis.Default >= 1 {
+				style = fonts.StyleItalic
+			}
+		case MustNewTag("slnt"):
+			if axis.Default != 0 && style != fonts.StyleItalic {
+				style = fonts.StyleOblique
+			}
+		}
+	}
+
 	return
 }
 
@@ -54,8 +54,11 @@ type Font struct {
 	hhea, vhea *TableHVhea
 	vorg       *tableVorg // optional
 	cff        *type1c.Font
-	post       TablePost // optional
-	svg        tableSVG  // optional
+	cff2       *tableCFF2 // optional
+	post       TablePost  // optional
+	svg        tableSVG   // optional
+	colr       tableCOLR  // optional
+	cpal       TableCPAL  // optional
 
 	// Optionnal, only present in variable fonts
 
@@ -65,14 +68,21 @@ type Font struct {
 	mvar       TableMvar
 	gvar       tableGvar
 	fvar       TableFvar
+	cvar       tableCvar
+
+	hinting tableHinting // optional, for TrueType outlines
 
 	Glyf       TableGlyf
 	vmtx, Hmtx TableHVmtx
 	bitmap     bitmapTable // CBDT or EBLC or BLOC
 	sbix       tableSbix
 
+	bitmapRendering fonts.BitmapRendering // scaling and synthetic bold options
+
 	OS2 *TableOS2 // optional
 
+	STAT *TableStat // optional
+
 	// graphite font, optionnal
 	Graphite *GraphiteTables
 
@@ -104,11 +114,17 @@ type LayoutTables struct {
 	Trak TableTrak
 	Ankr TableAnkr
 	Feat TableFeat
-	Morx TableMorx
+	Opbd TableOpbd
+	Prop TableProp
+	Lcar TableLcar
+	Morx TableMorx // may be built from a deprecated 'mort' table
 	Kern TableKernx
 	Kerx TableKernx
 	GSUB TableGSUB // An absent table has a nil slice of lookups
 	GPOS TableGPOS // An absent table has a nil slice of lookups
+	MATH TableMath // An absent table has nil coverages
+	BASE TableBase // An absent table has nil scripts
+	JSTF TableJstf // An absent table has nil scripts
 }
 
 // LayoutTables returns the valid advanced layout tables.
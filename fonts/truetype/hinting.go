@@ -0,0 +1,402 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// this file implements the loading of hinted glyphs,
+// using the TrueType bytecode interpreter defined in hinting_interpreter.go
+//
+// it is adapted from FreeType (src/truetype/ttgload.c),
+// emulating the 'classic' (version 35) interpreter
+
+var (
+	tagFpgm = MustNewTag("fpgm")
+	tagCvt  = MustNewTag("cvt ")
+	tagCvar = MustNewTag("cvar")
+)
+
+// tableHinting stores the programs and data
+// used by the TrueType instructions.
+type tableHinting struct {
+	fpgm, prep []byte  // optional
+	cvt        []int16 // optional, in font units
+	maxp       maxpProfile
+}
+
+// maxpProfile stores the limits found
+// in a version 1.0 'maxp' table.
+type maxpProfile struct {
+	maxTwilightPoints  uint16
+	maxStorage         uint16
+	maxFunctionDefs    uint16
+	maxInstructionDefs uint16
+	maxStackElements   uint16
+}
+
+// return an error for version 0.5 tables
+func parseMaxpProfile(data []byte) (out maxpProfile, err error) {
+	if len(data) < 32 {
+		return out, errInvalidMaxpTable
+	}
+	out.maxTwilightPoints = binary.BigEndian.Uint16(data[16:])
+	out.maxStorage = binary.BigEndian.Uint16(data[18:])
+	out.maxFunctionDefs = binary.BigEndian.Uint16(data[20:])
+	out.maxInstructionDefs = binary.BigEndian.Uint16(data[22:])
+	out.maxStackElements = binary.BigEndian.Uint16(data[24:])
+	return out, nil
+}
+
+func parseTableCvt(data []byte) ([]int16, error) {
+	values, err := parseUint16s(data, len(data)/2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'cvt ' table: %s", err)
+	}
+	out := make([]int16, len(values))
+	for i, v := range values {
+		out[i] = int16(v)
+	}
+	return out, nil
+}
+
+// tableCvar stores the variations of the control values.
+type tableCvar glyphVariationData
+
+func parseTableCvar(data []byte, axisCount, cvtCount int) (tableCvar, error) {
+	out, err := parseOneGlyphVariationData(data, 0, true, axisCount, cvtCount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'cvar' table: %s", err)
+	}
+	return tableCvar(out), nil
+}
+
+// applyDeltas updates `cvt` in place
+func (t tableCvar) applyDeltas(coords []float32, cvt []float32) {
+	for _, tuple := range t {
+		scalar := tuple.calculateScalar(coords, nil)
+		if scalar == 0 {
+			continue
+		}
+		for i, delta := range tuple.deltas {
+			index := i
+			if tuple.pointNumbers != nil {
+				index = int(tuple.pointNumbers[i])
+			}
+			if index < len(cvt) {
+				cvt[index] += float32(delta) * scalar
+			}
+		}
+	}
+}
+
+// ControlValues returns the values of the 'cvt ' table, in font units,
+// with the 'cvar' variations applied for the current variation coordinates.
+func (f *Font) ControlValues() []float32 {
+	out := make([]float32, len(f.hinting.cvt))
+	for i, v := range f.hinting.cvt {
+		out[i] = float32(v)
+	}
+	if f.isVar() {
+		f.cvar.applyDeltas(f.varCoords, out)
+	}
+	return out
+}
+
+// Hinter executes the TrueType instructions found in a font
+// to produce grid-fitted outlines from the 'glyf' table.
+//
+// The font program ('fpgm') is run once, and the control value program
+// ('prep') each time the size or the variation coordinates of the font change,
+// so that a Hinter should be reused when loading several glyphs.
+// A Hinter is not safe for concurrent use.
+type Hinter struct {
+	font *Font
+
+	ppem   uint16    // the size at which 'prep' was run, 0 if not run yet
+	coords []float32 // the variation coordinates used by 'prep'
+
+	machine interpreter
+
+	// state saved after running 'prep'
+	gs       graphicsState
+	cvt      []int32
+	storage  []int32
+	twilight zone
+}
+
+// NewHinter returns a hinter for `font`.
+// An error is returned if `font` has no 'glyf' table.
+func NewHinter(font *Font) (*Hinter, error) {
+	if len(font.Glyf) == 0 {
+		return nil, errors.New("hinting requires TrueType outlines")
+	}
+	h := &Hinter{font: font}
+	if err := h.machine.init(font); err != nil {
+		return nil, fmt.Errorf("invalid font program: %s", err)
+	}
+	return h, nil
+}
+
+func (h *Hinter) isUpToDate(ppem uint16) bool {
+	if h.ppem != ppem || len(h.coords) != len(h.font.varCoords) {
+		return false
+	}
+	for i, c := range h.coords {
+		if h.font.varCoords[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// setup runs 'prep', if needed
+func (h *Hinter) setup(ppem uint16) error {
+	if h.isUpToDate(ppem) {
+		return nil
+	}
+	h.ppem = 0 // invalidate the state in case of error
+
+	if err := h.machine.runPrep(ppem); err != nil {
+		return fmt.Errorf("invalid control value program: %s", err)
+	}
+
+	h.gs = h.machine.gs
+	h.cvt = append(h.cvt[:0], h.machine.cvt...)
+	h.storage = append(h.storage[:0], h.machine.storage...)
+	h.twilight = h.machine.zones[0].clone()
+
+	h.ppem = ppem
+	h.coords = append(h.coords[:0], h.font.varCoords...)
+	return nil
+}
+
+// GlyphOutline returns the outline of `gid`, grid-fitted for a size of `ppem` pixels per em,
+// using the current variation coordinates of the font.
+// The coordinates of the returned outline are expressed in pixels, with the
+// origin of the glyph at (0, 0), so that integer values fall on the pixel grid.
+//
+// An error is returned if `gid` is invalid or if the instructions could not be executed,
+// in which case the unhinted outline, as returned by Font.GlyphData, may be used instead.
+func (h *Hinter) GlyphOutline(gid GID, ppem uint16) (fonts.GlyphOutline, error) {
+	if int(gid) >= len(h.font.Glyf) {
+		return fonts.GlyphOutline{}, fmt.Errorf("out of range glyph %d", gid)
+	}
+	if ppem == 0 {
+		return fonts.GlyphOutline{}, errors.New("invalid size 0")
+	}
+	if err := h.setup(ppem); err != nil {
+		return fonts.GlyphOutline{}, err
+	}
+
+	g, err := h.loadGlyph(gid, 0)
+	if err != nil {
+		return fonts.GlyphOutline{}, fmt.Errorf("invalid instructions for glyph %d: %s", gid, err)
+	}
+
+	// use the left phantom point as origin
+	originX := g.cur[len(g.cur)-phantomCount+phantomLeft].x
+	points := make([]contourPoint, len(g.cur)-phantomCount)
+	for i := range points {
+		p := g.cur[i]
+		points[i].X = float32(p.x-originX) / 64
+		points[i].Y = float32(p.y) / 64
+		points[i].isOnCurve = g.flags[i]&flagOnCurve != 0
+	}
+	for _, end := range g.ends {
+		points[end].isEndPoint = true
+	}
+
+	return fonts.GlyphOutline{Segments: buildSegments(points)}, nil
+}
+
+// hintedGlyph stores the points of a glyph, phantom points included,
+// with the contours, once its instructions have been run
+type hintedGlyph struct {
+	cur   []vector // in 26.6 pixels
+	orus  []vector // in font units
+	flags []uint8  // flagOnCurve
+	ends  []int    // the last point of each contour, phantom points excluded
+}
+
+// loadGlyph recursively loads and hints `gid`, which must be
+// a valid index into the 'glyf' table.
+func (h *Hinter) loadGlyph(gid GID, depth int) (out hintedGlyph, err error) {
+	if depth > maxCompositeNesting {
+		return out, errors.New("too many nested composite glyphs")
+	}
+	font := h.font
+	ownPoints := font.getOwnPoints(gid)
+	m := &h.machine
+
+	switch data := font.Glyf[gid].data.(type) {
+	case compositeGlyphData:
+		var phantoms [phantomCount]vector
+		for i, p := range ownPoints[len(ownPoints)-phantomCount:] {
+			phantoms[i] = vector{m.scaleValue(p.X), m.scaleValue(p.Y)}
+		}
+
+		for compIndex, item := range data.glyphs {
+			if int(item.glyphIndex) >= len(font.Glyf) {
+				return out, fmt.Errorf("out of range component glyph %d", item.glyphIndex)
+			}
+			comp, err := h.loadGlyph(item.glyphIndex, depth+1)
+			if err != nil {
+				return out, err
+			}
+			if item.hasUseMyMetrics() {
+				copy(phantoms[:], comp.cur[len(comp.cur)-phantomCount:])
+			}
+			h.placeComponent(&out, comp, item, ownPoints[compIndex])
+		}
+
+		// phantom points are added at the end
+		for i, p := range ownPoints[len(ownPoints)-phantomCount:] {
+			out.cur = append(out.cur, phantoms[i])
+			out.orus = append(out.orus, vector{roundF(p.X), roundF(p.Y)})
+			out.flags = append(out.flags, 0)
+		}
+
+		// contrary to simple glyphs, the phantom points
+		// are not rounded when there is no instructions
+		if len(data.instructions) == 0 {
+			return out, nil
+		}
+
+		// the instructions of a composite glyph
+		// refer to the hinted components
+		z := zone{
+			cur:       out.cur,
+			org:       append([]vector(nil), out.cur...),
+			orus:      append([]vector(nil), out.cur...),
+			flags:     out.flags,
+			ends:      out.ends,
+			orusScale: 1 << 16,
+		}
+		for i := range z.flags {
+			z.flags[i] &^= flagTouchedX | flagTouchedY
+		}
+		err = h.hint(&z, data.instructions)
+	default:
+		// simple or empty glyph
+		out.cur = make([]vector, len(ownPoints))
+		out.orus = make([]vector, len(ownPoints))
+		out.flags = make([]uint8, len(ownPoints))
+		scale := m.scaleValue
+		if font.isVar() {
+			scale = m.scaleFractional
+		}
+		for i, p := range ownPoints {
+			out.cur[i] = vector{scale(p.X), scale(p.Y)}
+			out.orus[i] = vector{roundF(p.X), roundF(p.Y)}
+			if p.isOnCurve {
+				out.flags[i] = flagOnCurve
+			}
+		}
+		var instructions []byte
+		if simple, ok := data.(simpleGlyphData); ok {
+			for _, end := range simple.endPtsOfContours {
+				out.ends = append(out.ends, int(end))
+			}
+			instructions = simple.instructions
+		}
+
+		z := zone{
+			cur:       out.cur,
+			org:       append([]vector(nil), out.cur...),
+			orus:      out.orus,
+			flags:     out.flags,
+			ends:      out.ends,
+			orusScale: h.machine.scaleFix,
+		}
+		err = h.hint(&z, instructions)
+	}
+
+	return out, err
+}
+
+// placeComponent transforms and translates the hinted points of
+// `comp`, and appends them to `parent`, phantom points excluded.
+// `offset` contains the variations of the component offset.
+func (h *Hinter) placeComponent(parent *hintedGlyph, comp hintedGlyph, item compositeGlyphPart, offset contourPoint) {
+	L := len(comp.cur) - phantomCount
+	cur, orus := comp.cur[:L], comp.orus[:L]
+
+	if item.scale != [4]float32{1, 0, 0, 1} {
+		// use 16.16 fixed point arithmetic, as FreeType does
+		xx, yx := int32(item.scale[0]*0x10000), int32(item.scale[1]*0x10000)
+		xy, yy := int32(item.scale[2]*0x10000), int32(item.scale[3]*0x10000)
+		transform := func(p vector) vector {
+			return vector{mulFix(p.x, xx) + mulFix(p.y, xy), mulFix(p.x, yx) + mulFix(p.y, yy)}
+		}
+		for i := range cur {
+			cur[i] = transform(cur[i])
+		}
+		for i := range orus {
+			orus[i] = transform(orus[i])
+		}
+	}
+
+	var dx, dy, dxUnits, dyUnits int32
+	if item.isAnchored() {
+		p1, p2 := item.argsAsIndices()
+		if p1 < len(parent.cur) && p2 < L {
+			dx, dy = parent.cur[p1].x-cur[p2].x, parent.cur[p1].y-cur[p2].y
+			dxUnits, dyUnits = parent.orus[p1].x-orus[p2].x, parent.orus[p1].y-orus[p2].y
+		}
+	} else {
+		arg1, arg2 := item.argsAsTranslation()
+		x, y := float32(arg1)+offset.X, float32(arg2)+offset.Y
+		if item.isScaledOffsets() {
+			m := item.scale
+			x, y = x*m[0]+y*m[2], x*m[1]+y*m[3]
+		}
+		dxUnits, dyUnits = roundF(x), roundF(y)
+		dx, dy = h.machine.scaleValue(x), h.machine.scaleValue(y)
+		const roundXYToGrid = 0x0004
+		if item.flags&roundXYToGrid != 0 {
+			dx, dy = pixRound(dx), pixRound(dy)
+		}
+	}
+
+	start := len(parent.cur)
+	for i := range cur {
+		parent.cur = append(parent.cur, vector{cur[i].x + dx, cur[i].y + dy})
+		parent.orus = append(parent.orus, vector{orus[i].x + dxUnits, orus[i].y + dyUnits})
+	}
+	parent.flags = append(parent.flags, comp.flags[:L]...)
+	for _, end := range comp.ends {
+		parent.ends = append(parent.ends, start+end)
+	}
+}
+
+// hint rounds the phantom points and runs the glyph program
+func (h *Hinter) hint(glyph *zone, instructions []byte) error {
+	n := len(glyph.cur)
+	glyph.cur[n-4].x = pixRound(glyph.cur[n-4].x)
+	glyph.cur[n-3].x = pixRound(glyph.cur[n-3].x)
+	glyph.cur[n-2].y = pixRound(glyph.cur[n-2].y)
+	glyph.cur[n-1].y = pixRound(glyph.cur[n-1].y)
+
+	if len(instructions) == 0 || h.gs.instructControl&1 != 0 {
+		return nil
+	}
+
+	m := &h.machine
+	// restore the state defined by 'prep'
+	m.gs = h.gs
+	copy(m.cvt, h.cvt)
+	copy(m.storage, h.storage)
+	m.zones[0].copyFrom(h.twilight)
+	m.zones[1] = *glyph
+
+	return m.runGlyph(instructions)
+}
+
+// roundF rounds `v` to the nearest integer, rounding half values up,
+// as FreeType does for fixed point numbers
+func roundF(v float32) int32 { return int32(math.Floor(float64(v) + 0.5)) }
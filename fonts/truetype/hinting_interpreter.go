@@ -0,0 +1,1783 @@
+package truetype
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// this file implements the TrueType bytecode interpreter,
+// following the behavior of FreeType (src/truetype/ttinterp.c)
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/tt_instructions
+
+const (
+	// protect against malicious fonts (infinite loops, deep recursion)
+	maxHintingInstructions = 1 << 20
+	maxHintingCallDepth    = 64
+
+	// additional room given to fonts underestimating
+	// their stack usage in the 'maxp' table
+	hintingStackMargin = 32
+)
+
+const (
+	flagTouchedX uint8 = 1 << (iota + 1) // flagOnCurve is 1
+	flagTouchedY
+)
+
+// vector is a point or a direction; its unit
+// depends on the context (26.6 fixed point for coordinates,
+// 2.14 fixed point for unit vectors).
+type vector struct{ x, y int32 }
+
+// zone is a set of points referenced by the instructions:
+// the twilight zone (0) or the glyph zone (1).
+type zone struct {
+	cur, org []vector // current (hinted) and original positions, in 26.6 pixels
+	orus     []vector // original positions, in units scaled by orusScale, nil for the twilight zone
+	flags    []uint8
+	ends     []int // last point of each contour (glyph zone only)
+
+	orusScale int32 // from orus units to 26.6 pixels, in 16.16
+}
+
+func newTwilightZone(size int) zone {
+	return zone{
+		cur:   make([]vector, size),
+		org:   make([]vector, size),
+		flags: make([]uint8, size),
+	}
+}
+
+func (z zone) clone() zone {
+	return zone{
+		cur:   append([]vector(nil), z.cur...),
+		org:   append([]vector(nil), z.org...),
+		flags: append([]uint8(nil), z.flags...),
+	}
+}
+
+// copyFrom overwrites the points of the twilight zone `z`,
+// which must have the same size as `src`.
+func (z *zone) copyFrom(src zone) {
+	copy(z.cur, src.cur)
+	copy(z.org, src.org)
+	copy(z.flags, src.flags)
+}
+
+type roundMode uint8
+
+const (
+	roundToHalfGrid roundMode = iota
+	roundToGrid
+	roundToDoubleGrid
+	roundDownToGrid
+	roundUpToGrid
+	roundOff
+	roundSuper
+	roundSuper45
+)
+
+// graphicsState is the state modified by the instructions
+type graphicsState struct {
+	pv, fv, dv vector // projection, freedom and dual projection vectors, in 2.14
+
+	rp [3]int32 // reference points
+	zp [3]int32 // zone pointers: 0 for the twilight zone, 1 for the glyph zone
+
+	controlValueCutIn int32
+	singleWidthCutIn  int32
+	singleWidth       int32
+	minDistance       int32
+	deltaBase         int32
+	deltaShift        int32
+	loop              int32
+
+	roundMode                roundMode
+	period, phase, threshold int32 // used by roundSuper and roundSuper45
+
+	autoFlip        bool
+	instructControl int32
+}
+
+var defaultGraphicsState = graphicsState{
+	pv:                vector{0x4000, 0},
+	fv:                vector{0x4000, 0},
+	dv:                vector{0x4000, 0},
+	zp:                [3]int32{1, 1, 1},
+	controlValueCutIn: 68, // 17/16 pixels
+	minDistance:       64,
+	deltaBase:         9,
+	deltaShift:        3,
+	loop:              1,
+	roundMode:         roundToGrid,
+	autoFlip:          true,
+}
+
+// resetForGlyph applies the changes to the state
+// performed before running a glyph program
+func (gs *graphicsState) resetForGlyph() {
+	gs.pv = vector{0x4000, 0}
+	gs.fv = gs.pv
+	gs.dv = gs.pv
+	gs.rp = [3]int32{}
+	gs.zp = [3]int32{1, 1, 1}
+	gs.roundMode = roundToGrid
+	gs.loop = 1
+}
+
+type programKind uint8
+
+const (
+	programFpgm programKind = iota
+	programPrep
+	programGlyph
+)
+
+type funcDef struct {
+	code  []byte // the program containing the definition
+	start int    // first instruction of the body
+	ok    bool
+}
+
+type callFrame struct {
+	code  []byte // the caller code
+	retPC int    // the caller instruction following the call
+	fn    funcDef
+	count int32 // remaining executions (for LOOPCALL)
+}
+
+type interpreter struct {
+	font *Font
+
+	scaleFix int32 // from font units to 26.6 pixels, in 16.16
+	ppem     int32
+
+	gs    graphicsState
+	fDotP int32 // dot product of the freedom and projection vectors, in 2.14
+
+	stack    []int32
+	maxStack int
+	storage  []int32
+	cvt      []int32 // in 26.6 pixels
+	zones    [2]zone
+
+	functions    []funcDef
+	instructions map[byte]funcDef // user defined instructions
+
+	kind   programKind
+	budget int
+}
+
+// init runs the font program
+func (m *interpreter) init(font *Font) error {
+	m.font = font
+	maxp := font.hinting.maxp
+	m.maxStack = int(maxp.maxStackElements) + hintingStackMargin
+	m.stack = make([]int32, 0, m.maxStack)
+	m.storage = make([]int32, maxp.maxStorage)
+	m.functions = make([]funcDef, maxp.maxFunctionDefs)
+	m.instructions = make(map[byte]funcDef)
+	m.zones[0] = newTwilightZone(int(maxp.maxTwilightPoints))
+
+	// the font program does not use the size
+	m.scaleFix, m.ppem = 0, 0
+	m.cvt = make([]int32, len(font.hinting.cvt))
+	m.gs = defaultGraphicsState
+	return m.run(font.hinting.fpgm, programFpgm)
+}
+
+// runPrep resets the state and runs the control value program,
+// scaling the control values to `ppem`.
+func (m *interpreter) runPrep(ppem uint16) error {
+	m.ppem = int32(ppem)
+	m.scaleFix = divFix(int32(ppem)*64, int32(m.font.upem))
+	for i, v := range m.font.ControlValues() {
+		// as FreeType, scale from 26.6 font units
+		m.cvt[i] = mulFix(roundF(v*64), m.scaleFix>>6)
+	}
+	for i := range m.storage {
+		m.storage[i] = 0
+	}
+	twilight := m.zones[0]
+	for i := range twilight.cur {
+		twilight.cur[i], twilight.org[i], twilight.flags[i] = vector{}, vector{}, 0
+	}
+	m.zones[1] = zone{}
+
+	m.gs = defaultGraphicsState
+	if err := m.run(m.font.hinting.prep, programPrep); err != nil {
+		return err
+	}
+	// the following variables are not modifiable by 'prep'
+	m.gs.resetForGlyph()
+	return nil
+}
+
+// runGlyph runs the glyph instructions on the glyph zone
+func (m *interpreter) runGlyph(instructions []byte) error {
+	m.gs.resetForGlyph()
+	return m.run(instructions, programGlyph)
+}
+
+var errStackUnderflow = errors.New("stack underflow")
+
+// popCounts is the number of arguments required by each instruction,
+// not including the ones depending on the loop variable.
+var popCounts = [256]uint8{
+	// 0x00
+	0, 0, 0, 0, 0, 0, 2, 2, 2, 2, 2, 2, 0, 0, 0, 5,
+	// 0x10
+	1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 0, 1, 1, 1, 1,
+	// 0x20
+	1, 1, 0, 2, 0, 1, 1, 2, 0, 1, 2, 1, 1, 0, 1, 1,
+	// 0x30
+	0, 0, 0, 0, 1, 1, 1, 1, 1, 0, 2, 2, 0, 0, 2, 2,
+	// 0x40
+	0, 0, 2, 1, 2, 1, 1, 1, 2, 2, 2, 0, 0, 0, 0, 1,
+	// 0x50
+	2, 2, 2, 2, 2, 2, 1, 1, 1, 0, 2, 2, 1, 1, 1, 1,
+	// 0x60
+	2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	// 0x70
+	2, 1, 1, 1, 1, 1, 1, 1, 2, 2, 0, 0, 0, 0, 1, 1,
+	// 0x80
+	0, 2, 2, 0, 0, 1, 2, 2, 1, 1, 3, 2, 2, 1, 2, 0,
+	// 0x90 - 0xBF : no arguments
+	0xC0: 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+}
+
+// isUndefinedOpcode returns true for the opcodes
+// not defined by the specification
+func (m *interpreter) isUndefinedOpcode(op byte) bool {
+	switch {
+	case op == 0x28, op == 0x7B, op == 0x83, op == 0x84, op == 0x8F, op == 0x90:
+		return true
+	case op == 0x91: // GETVARIATION
+		return !m.font.isVar()
+	case 0x92 <= op && op < 0xB0:
+		return true
+	}
+	return false
+}
+
+// instructionLength returns the length of the instruction at `pc`,
+// including its inline arguments.
+func instructionLength(code []byte, pc int) (int, error) {
+	op := code[pc]
+	var length int
+	switch {
+	case op == 0x40: // NPUSHB
+		if pc+1 >= len(code) {
+			return 0, errors.New("invalid NPUSHB instruction (EOF)")
+		}
+		length = 2 + int(code[pc+1])
+	case op == 0x41: // NPUSHW
+		if pc+1 >= len(code) {
+			return 0, errors.New("invalid NPUSHW instruction (EOF)")
+		}
+		length = 2 + 2*int(code[pc+1])
+	case 0xB0 <= op && op <= 0xB7: // PUSHB
+		length = 1 + int(op-0xAF)
+	case 0xB8 <= op && op <= 0xBF: // PUSHW
+		length = 1 + 2*int(op-0xB7)
+	default:
+		length = 1
+	}
+	if pc+length > len(code) {
+		return 0, errors.New("invalid push instruction (EOF)")
+	}
+	return length, nil
+}
+
+// skipConditional returns the position after the ELSE (if `stopAtElse` is true)
+// or the EIF matching the IF or ELSE at `pc`
+func skipConditional(code []byte, pc int, stopAtElse bool) (int, error) {
+	nested := 1
+	for {
+		length, err := instructionLength(code, pc)
+		if err != nil {
+			return 0, err
+		}
+		pc += length
+		if pc >= len(code) {
+			return 0, errors.New("unterminated IF instruction")
+		}
+		switch code[pc] {
+		case 0x58: // IF
+			nested++
+		case 0x1B: // ELSE
+			if nested == 1 && stopAtElse {
+				return pc + 1, nil
+			}
+		case 0x59: // EIF
+			nested--
+			if nested == 0 {
+				return pc + 1, nil
+			}
+		}
+	}
+}
+
+// skipDefinition returns the position of the ENDF
+// matching the FDEF or IDEF at `pc`
+func skipDefinition(code []byte, pc int) (int, error) {
+	for {
+		length, err := instructionLength(code, pc)
+		if err != nil {
+			return 0, err
+		}
+		pc += length
+		if pc >= len(code) {
+			return 0, errors.New("unterminated function definition")
+		}
+		switch code[pc] {
+		case 0x2C, 0x89: // FDEF, IDEF
+			return 0, errors.New("nested function definition")
+		case 0x2D: // ENDF
+			return pc, nil
+		}
+	}
+}
+
+func (m *interpreter) push(v int32) { m.stack = append(m.stack, v) }
+
+// pop assumes the stack is not empty
+func (m *interpreter) pop() int32 {
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return v
+}
+
+func (m *interpreter) run(code []byte, kind programKind) error {
+	m.kind = kind
+	m.stack = m.stack[:0]
+	m.budget = maxHintingInstructions
+	m.updateFDotP()
+
+	var calls []callFrame
+	for pc := 0; ; {
+		if pc >= len(code) {
+			if len(calls) != 0 {
+				return errors.New("missing ENDF instruction")
+			}
+			return nil
+		}
+
+		m.budget--
+		if m.budget < 0 {
+			return errors.New("too many instructions")
+		}
+
+		op := code[pc]
+		if int(popCounts[op]) > len(m.stack) {
+			return errStackUnderflow
+		}
+
+		if m.isUndefinedOpcode(op) {
+			def, ok := m.instructions[op]
+			if !ok {
+				return fmt.Errorf("invalid opcode 0x%x", op)
+			}
+			if len(calls) >= maxHintingCallDepth {
+				return errors.New("call stack overflow")
+			}
+			calls = append(calls, callFrame{code: code, retPC: pc + 1, fn: def, count: 1})
+			code, pc = def.code, def.start
+			continue
+		}
+
+		nextPC := pc + 1
+		var err error
+		switch op {
+		case 0x58: // IF
+			if m.pop() == 0 {
+				nextPC, err = skipConditional(code, pc, true)
+			}
+		case 0x1B: // ELSE
+			nextPC, err = skipConditional(code, pc, false)
+		case 0x59: // EIF
+		case 0x1C: // JMPR
+			nextPC, err = jump(code, pc, m.pop())
+		case 0x78, 0x79: // JROT, JROF
+			cond, offset := m.pop(), m.pop()
+			if (cond != 0) == (op == 0x78) {
+				nextPC, err = jump(code, pc, offset)
+			}
+		case 0x2C, 0x89: // FDEF, IDEF
+			if m.kind == programGlyph {
+				return errors.New("function definition in glyph program")
+			}
+			n := m.pop()
+			var end int
+			end, err = skipDefinition(code, pc)
+			if err != nil {
+				return err
+			}
+			def := funcDef{code: code, start: pc + 1, ok: true}
+			if op == 0x2C {
+				if n < 0 || n > 0xFFFF {
+					return fmt.Errorf("invalid function number %d", n)
+				}
+				if int(n) >= len(m.functions) { // be lenient with fonts underestimating maxFunctionDefs
+					m.functions = append(m.functions, make([]funcDef, int(n)+1-len(m.functions))...)
+				}
+				m.functions[n] = def
+			} else {
+				if n < 0 || n > 0xFF {
+					return fmt.Errorf("invalid instruction definition %d", n)
+				}
+				m.instructions[byte(n)] = def
+			}
+			nextPC = end + 1
+		case 0x2D: // ENDF
+			if len(calls) == 0 {
+				return errors.New("unexpected ENDF instruction")
+			}
+			frame := &calls[len(calls)-1]
+			frame.count--
+			if frame.count > 0 {
+				nextPC = frame.fn.start
+			} else {
+				code, nextPC = frame.code, frame.retPC
+				calls = calls[:len(calls)-1]
+			}
+		case 0x2B, 0x2A: // CALL, LOOPCALL
+			n := m.pop()
+			count := int32(1)
+			if op == 0x2A {
+				count = m.pop()
+			}
+			if n < 0 || int(n) >= len(m.functions) || !m.functions[n].ok {
+				return fmt.Errorf("invalid function call %d", n)
+			}
+			if count <= 0 {
+				break
+			}
+			if len(calls) >= maxHintingCallDepth {
+				return errors.New("call stack overflow")
+			}
+			fn := m.functions[n]
+			calls = append(calls, callFrame{code: code, retPC: pc + 1, fn: fn, count: count})
+			code, nextPC = fn.code, fn.start
+		case 0x40, 0x41, 0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7,
+			0xB8, 0xB9, 0xBA, 0xBB, 0xBC, 0xBD, 0xBE, 0xBF: // push instructions
+			var length int
+			length, err = instructionLength(code, pc)
+			if err != nil {
+				return err
+			}
+			m.runPush(code[pc : pc+length])
+			nextPC = pc + length
+		default:
+			err = m.exec(op)
+		}
+		if err != nil {
+			return err
+		}
+		if len(m.stack) > m.maxStack {
+			return errors.New("stack overflow")
+		}
+		pc = nextPC
+	}
+}
+
+// jump returns the target of a relative jump from `pc`
+func jump(code []byte, pc int, offset int32) (int, error) {
+	target := pc + int(offset)
+	if offset == 0 || target < 0 || target > len(code) {
+		return 0, fmt.Errorf("invalid jump offset %d", offset)
+	}
+	return target, nil
+}
+
+// instruction is the complete push instruction
+func (m *interpreter) runPush(instruction []byte) {
+	op := instruction[0]
+	args := instruction[1:]
+	if op == 0x40 || op == 0x41 { // skip the count
+		args = args[1:]
+	}
+	if op == 0x40 || (0xB0 <= op && op <= 0xB7) { // bytes
+		for _, b := range args {
+			m.push(int32(b))
+		}
+	} else { // words
+		for i := 0; i+1 < len(args); i += 2 {
+			m.push(int32(int16(uint16(args[i])<<8 | uint16(args[i+1]))))
+		}
+	}
+}
+
+// exec executes the instructions not related to
+// the control flow.
+func (m *interpreter) exec(op byte) error {
+	gs := &m.gs
+	switch op {
+	case 0x00, 0x01, 0x02, 0x03, 0x04, 0x05: // SVTCA, SPVTCA, SFVTCA
+		v := vector{0, 0x4000}
+		if op&1 != 0 {
+			v = vector{0x4000, 0}
+		}
+		if op <= 0x03 {
+			gs.pv, gs.dv = v, v
+		}
+		if op <= 0x01 || op >= 0x04 {
+			gs.fv = v
+		}
+		m.updateFDotP()
+	case 0x06, 0x07: // SPVTL
+		p2, p1 := m.pop(), m.pop()
+		v, err := m.lineVector(p1, p2, op&1 != 0, false)
+		if err != nil {
+			return err
+		}
+		gs.pv, gs.dv = v, v
+		m.updateFDotP()
+	case 0x08, 0x09: // SFVTL
+		p2, p1 := m.pop(), m.pop()
+		v, err := m.lineVector(p1, p2, op&1 != 0, false)
+		if err != nil {
+			return err
+		}
+		gs.fv = v
+		m.updateFDotP()
+	case 0x86, 0x87: // SDPVTL
+		p2, p1 := m.pop(), m.pop()
+		v, err := m.lineVector(p1, p2, op&1 != 0, true)
+		if err != nil {
+			return err
+		}
+		gs.dv = v
+		gs.pv, _ = m.lineVector(p1, p2, op&1 != 0, false)
+		m.updateFDotP()
+	case 0x0A, 0x0B: // SPVFS, SFVFS
+		y, x := m.pop(), m.pop()
+		if x == 0 && y == 0 { // the vector is not modified
+			break
+		}
+		v := normalize(x, y)
+		if op == 0x0A {
+			gs.pv, gs.dv = v, v
+		} else {
+			gs.fv = v
+		}
+		m.updateFDotP()
+	case 0x0C: // GPV
+		m.push(gs.pv.x)
+		m.push(gs.pv.y)
+	case 0x0D: // GFV
+		m.push(gs.fv.x)
+		m.push(gs.fv.y)
+	case 0x0E: // SFVTPV
+		gs.fv = gs.pv
+		m.updateFDotP()
+	case 0x0F: // ISECT
+		return m.isect()
+	case 0x10, 0x11, 0x12: // SRP0, SRP1, SRP2
+		gs.rp[op-0x10] = m.pop()
+	case 0x13, 0x14, 0x15: // SZP0, SZP1, SZP2
+		z := m.pop()
+		if z != 0 && z != 1 {
+			return fmt.Errorf("invalid zone %d", z)
+		}
+		gs.zp[op-0x13] = z
+	case 0x16: // SZPS
+		z := m.pop()
+		if z != 0 && z != 1 {
+			return fmt.Errorf("invalid zone %d", z)
+		}
+		gs.zp = [3]int32{z, z, z}
+	case 0x17: // SLOOP
+		n := m.pop()
+		if n < 0 {
+			return fmt.Errorf("invalid loop counter %d", n)
+		}
+		gs.loop = n
+	case 0x18: // RTG
+		gs.roundMode = roundToGrid
+	case 0x19: // RTHG
+		gs.roundMode = roundToHalfGrid
+	case 0x3D: // RTDG
+		gs.roundMode = roundToDoubleGrid
+	case 0x7A: // ROFF
+		gs.roundMode = roundOff
+	case 0x7C: // RUTG
+		gs.roundMode = roundUpToGrid
+	case 0x7D: // RDTG
+		gs.roundMode = roundDownToGrid
+	case 0x76: // SROUND
+		gs.roundMode = roundSuper
+		gs.setSuperRound(64, m.pop())
+	case 0x77: // S45ROUND
+		gs.roundMode = roundSuper45
+		gs.setSuperRound(45, m.pop()) // 64 / sqrt(2)
+	case 0x1A: // SMD
+		gs.minDistance = m.pop()
+	case 0x1D: // SCVTCI
+		gs.controlValueCutIn = m.pop()
+	case 0x1E: // SSWCI
+		gs.singleWidthCutIn = m.pop()
+	case 0x1F: // SSW
+		gs.singleWidth = mulFix(m.pop(), m.scaleFix)
+	case 0x4D: // FLIPON
+		gs.autoFlip = true
+	case 0x4E: // FLIPOFF
+		gs.autoFlip = false
+	case 0x5E: // SDB
+		gs.deltaBase = m.pop()
+	case 0x5F: // SDS
+		n := m.pop()
+		if n < 0 || n > 6 {
+			return fmt.Errorf("invalid delta shift %d", n)
+		}
+		gs.deltaShift = n
+	case 0x4F, 0x7E, 0x7F, 0x85, 0x8D: // DEBUG, SANGW, AA, SCANCTRL, SCANTYPE
+		m.pop()
+	case 0x8E: // INSTCTRL
+		selector, value := m.pop(), m.pop()
+		if m.kind == programPrep && 1 <= selector && selector <= 3 {
+			flag := int32(1) << (selector - 1)
+			if value != 0 {
+				gs.instructControl |= flag
+			} else {
+				gs.instructControl &^= flag
+			}
+		}
+
+	// stack management
+
+	case 0x20: // DUP
+		v := m.pop()
+		m.push(v)
+		m.push(v)
+	case 0x21: // POP
+		m.pop()
+	case 0x22: // CLEAR
+		m.stack = m.stack[:0]
+	case 0x23: // SWAP
+		L := len(m.stack)
+		m.stack[L-1], m.stack[L-2] = m.stack[L-2], m.stack[L-1]
+	case 0x24: // DEPTH
+		m.push(int32(len(m.stack)))
+	case 0x25, 0x26: // CINDEX, MINDEX
+		k := m.pop()
+		if k <= 0 || int(k) > len(m.stack) {
+			return fmt.Errorf("invalid stack index %d", k)
+		}
+		index := len(m.stack) - int(k)
+		v := m.stack[index]
+		if op == 0x26 {
+			copy(m.stack[index:], m.stack[index+1:])
+			m.stack = m.stack[:len(m.stack)-1]
+		}
+		m.push(v)
+	case 0x8A: // ROLL
+		L := len(m.stack)
+		a, b, c := m.stack[L-3], m.stack[L-2], m.stack[L-1]
+		m.stack[L-3], m.stack[L-2], m.stack[L-1] = b, c, a
+
+	// storage and control values
+
+	case 0x42: // WS
+		v, i := m.pop(), m.pop()
+		if 0 <= i && int(i) < len(m.storage) {
+			m.storage[i] = v
+		}
+	case 0x43: // RS
+		i := m.pop()
+		var v int32
+		if 0 <= i && int(i) < len(m.storage) {
+			v = m.storage[i]
+		}
+		m.push(v)
+	case 0x44, 0x70: // WCVTP, WCVTF
+		v, i := m.pop(), m.pop()
+		if op == 0x70 {
+			v = mulFix(v, m.scaleFix)
+		}
+		if 0 <= i && int(i) < len(m.cvt) {
+			m.cvt[i] = v
+		}
+	case 0x45: // RCVT
+		m.push(m.readCvt(m.pop()))
+
+	// information
+
+	case 0x4B, 0x4C: // MPPEM, MPS
+		m.push(m.ppem)
+	case 0x88: // GETINFO
+		selector := m.pop()
+		var result int32
+		if selector&1 != 0 {
+			result = 35 // interpreter version
+		}
+		if selector&8 != 0 && m.font.isVar() {
+			result |= 1 << 10
+		}
+		m.push(result)
+	case 0x91: // GETVARIATION
+		coords := m.font.varCoords
+		for i := range m.font.fvar.Axis {
+			var c float32
+			if i < len(coords) {
+				c = coords[i]
+			}
+			m.push(roundF(c*0x10000) >> 2) // 16.16 to 2.14, as FreeType
+		}
+
+	// arithmetic and logic
+
+	case 0x50, 0x51, 0x52, 0x53, 0x54, 0x55: // LT, LTEQ, GT, GTEQ, EQ, NEQ
+		b, a := m.pop(), m.pop()
+		var cond bool
+		switch op {
+		case 0x50:
+			cond = a < b
+		case 0x51:
+			cond = a <= b
+		case 0x52:
+			cond = a > b
+		case 0x53:
+			cond = a >= b
+		case 0x54:
+			cond = a == b
+		case 0x55:
+			cond = a != b
+		}
+		m.push(boolToInt32(cond))
+	case 0x56, 0x57: // ODD, EVEN
+		v := m.round(m.pop()) & 127
+		if op == 0x56 {
+			m.push(boolToInt32(v == 64))
+		} else {
+			m.push(boolToInt32(v == 0))
+		}
+	case 0x5A: // AND
+		b, a := m.pop(), m.pop()
+		m.push(boolToInt32(a != 0 && b != 0))
+	case 0x5B: // OR
+		b, a := m.pop(), m.pop()
+		m.push(boolToInt32(a != 0 || b != 0))
+	case 0x5C: // NOT
+		m.push(boolToInt32(m.pop() == 0))
+	case 0x60: // ADD
+		b, a := m.pop(), m.pop()
+		m.push(a + b)
+	case 0x61: // SUB
+		b, a := m.pop(), m.pop()
+		m.push(a - b)
+	case 0x62: // DIV
+		b, a := m.pop(), m.pop()
+		if b == 0 {
+			return errors.New("division by zero")
+		}
+		m.push(mulDivNoRound(a, 64, b))
+	case 0x63: // MUL
+		b, a := m.pop(), m.pop()
+		m.push(mulDivRound(a, b, 64))
+	case 0x64: // ABS
+		v := m.pop()
+		if v < 0 {
+			v = -v
+		}
+		m.push(v)
+	case 0x65: // NEG
+		m.push(-m.pop())
+	case 0x66: // FLOOR
+		m.push(m.pop() &^ 63)
+	case 0x67: // CEILING
+		m.push((m.pop() + 63) &^ 63)
+	case 0x68, 0x69, 0x6A, 0x6B: // ROUND
+		m.push(m.round(m.pop()))
+	case 0x6C, 0x6D, 0x6E, 0x6F: // NROUND
+	case 0x8B: // MAX
+		b, a := m.pop(), m.pop()
+		if b > a {
+			a = b
+		}
+		m.push(a)
+	case 0x8C: // MIN
+		b, a := m.pop(), m.pop()
+		if b < a {
+			a = b
+		}
+		m.push(a)
+
+	// points
+
+	case 0x2E, 0x2F: // MDAP
+		return m.mdap(op&1 != 0)
+	case 0x3E, 0x3F: // MIAP
+		return m.miap(op&1 != 0)
+	case 0x3A, 0x3B: // MSIRP
+		return m.msirp(op&1 != 0)
+	case 0x3C: // ALIGNRP
+		return m.alignrp()
+	case 0x27: // ALIGNPTS
+		return m.alignpts()
+	case 0x29: // UTP
+		z := m.zp(0)
+		p := m.pop()
+		if !z.has(p) {
+			return nil
+		}
+		if gs.fv.x != 0 {
+			z.flags[p] &^= flagTouchedX
+		}
+		if gs.fv.y != 0 {
+			z.flags[p] &^= flagTouchedY
+		}
+	case 0x30, 0x31: // IUP
+		m.iup(op&1 != 0)
+	case 0x32, 0x33: // SHP
+		return m.shp(op&1 != 0)
+	case 0x34, 0x35: // SHC
+		return m.shc(op&1 != 0)
+	case 0x36, 0x37: // SHZ
+		return m.shz(op&1 != 0)
+	case 0x38: // SHPIX
+		return m.shpix()
+	case 0x39: // IP
+		return m.ip()
+	case 0x46, 0x47: // GC
+		z := m.zp(2)
+		p := m.pop()
+		var v int32
+		if z.has(p) {
+			if op == 0x47 {
+				v = m.dualProject(z.org[p])
+			} else {
+				v = m.project(z.cur[p])
+			}
+		}
+		m.push(v)
+	case 0x48: // SCFS
+		value, p := m.pop(), m.pop()
+		z := m.zp(2)
+		if !z.has(p) {
+			return nil
+		}
+		m.move(z, p, value-m.project(z.cur[p]), true)
+		if gs.zp[2] == 0 {
+			z.org[p] = z.cur[p]
+		}
+	case 0x49, 0x4A: // MD
+		k, l := m.pop(), m.pop()
+		z0, z1 := m.zp(0), m.zp(1)
+		var d int32
+		if z0.has(l) && z1.has(k) {
+			if op == 0x49 {
+				d = m.project(sub(z0.cur[l], z1.cur[k]))
+			} else {
+				d = m.origDistance(z0, l, z1, k)
+			}
+		}
+		m.push(d)
+	case 0x80: // FLIPPT
+		z := &m.zones[1]
+		for ; gs.loop > 0; gs.loop-- {
+			if len(m.stack) == 0 {
+				return errStackUnderflow
+			}
+			p := m.pop()
+			if z.has(p) {
+				z.flags[p] ^= flagOnCurve
+			}
+		}
+		gs.loop = 1
+	case 0x81, 0x82: // FLIPRGON, FLIPRGOFF
+		hi, lo := m.pop(), m.pop()
+		z := &m.zones[1]
+		if !z.has(lo) || !z.has(hi) {
+			return nil
+		}
+		for p := lo; p <= hi; p++ {
+			if op == 0x81 {
+				z.flags[p] |= flagOnCurve
+			} else {
+				z.flags[p] &^= flagOnCurve
+			}
+		}
+	case 0x5D, 0x71, 0x72: // DELTAP1, DELTAP2, DELTAP3
+		return m.deltap(op)
+	case 0x73, 0x74, 0x75: // DELTAC1, DELTAC2, DELTAC3
+		return m.deltac(op)
+	default:
+		switch {
+		case 0xC0 <= op && op < 0xE0:
+			return m.mdrp(op)
+		case 0xE0 <= op:
+			return m.mirp(op)
+		}
+		return fmt.Errorf("invalid opcode 0x%x", op)
+	}
+	return nil
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ------------------------------ arithmetic ------------------------------
+
+// mulDivRound returns a*b/c, rounded
+func mulDivRound(a, b, c int32) int32 {
+	if c == 0 {
+		return math.MaxInt32
+	}
+	p := int64(a) * int64(b)
+	d := int64(c)
+	if (p < 0) != (d < 0) {
+		return int32((p - d/2) / d)
+	}
+	return int32((p + d/2) / d)
+}
+
+// mulFix returns a*b, where b is a 16.16 fixed point number
+func mulFix(a, b int32) int32 {
+	ab := int64(a) * int64(b)
+	if ab < 0 {
+		ab--
+	}
+	return int32((ab + 0x8000) >> 16)
+}
+
+// divFix returns a/b, as a 16.16 fixed point number
+func divFix(a, b int32) int32 { return mulDivRound(a, 0x10000, b) }
+
+// scaleValue converts from font units to 26.6 pixels,
+// first rounding `v` to an integer
+func (m *interpreter) scaleValue(v float32) int32 {
+	return mulFix(roundF(v), m.scaleFix)
+}
+
+// scaleFractional converts from font units to 26.6 pixels,
+// preserving the fractional part of `v` (as found in varied outlines)
+func (m *interpreter) scaleFractional(v float32) int32 {
+	return (mulFix(roundF(v*64), m.scaleFix) + 32) >> 6
+}
+
+// mulDivNoRound returns a*b/c, truncated
+func mulDivNoRound(a, b, c int32) int32 {
+	if c == 0 {
+		return math.MaxInt32
+	}
+	return int32(int64(a) * int64(b) / int64(c))
+}
+
+// dotProduct returns the 2.14 dot product of `v` with the unit vector `u`
+func dotProduct(v, u vector) int32 {
+	p := int64(v.x)*int64(u.x) + int64(v.y)*int64(u.y)
+	if p < 0 {
+		p--
+	}
+	return int32((p + 0x2000) >> 14)
+}
+
+func sub(a, b vector) vector { return vector{a.x - b.x, a.y - b.y} }
+
+// normalize returns the 2.14 unit vector in the direction (x, y),
+// which must not be null
+func normalize(x, y int32) vector {
+	// as FreeType, compute a 16.16 unit vector using
+	// Newton iterations, and truncate it
+	var sx, sy int32 = 1, 1
+	ux, uy := uint32(x), uint32(y)
+	if x < 0 {
+		ux, sx = uint32(-x), -1
+	}
+	if y < 0 {
+		uy, sy = uint32(-y), -1
+	}
+	if ux == 0 {
+		return vector{0, sy * 0x4000}
+	} else if uy == 0 {
+		return vector{sx * 0x4000, 0}
+	}
+
+	// estimate the length and pre-normalize so that
+	// it is between 2/3 and 4/3 (in 16.16)
+	l := ux + uy>>1
+	if uy > ux {
+		l = uy + ux>>1
+	}
+	shift := 31 - (bits.Len32(l) - 1)
+	if l >= 0xAAAAAAAA>>shift {
+		shift -= 16
+	} else {
+		shift -= 15
+	}
+	if shift > 0 {
+		ux <<= shift
+		uy <<= shift
+		l = ux + uy>>1
+		if uy > ux {
+			l = uy + ux>>1
+		}
+	} else {
+		ux >>= -shift
+		uy >>= -shift
+		l >>= -shift
+	}
+
+	b := 0x10000 - int32(l)
+	x_, y_ := int32(ux), int32(uy)
+	var u, v uint32
+	for {
+		u = uint32(x_ + (x_ * b >> 16))
+		v = uint32(y_ + (y_ * b >> 16))
+		z := -int32(u*u+v*v) / 0x200
+		z = z * ((0x10000 + b) >> 8) / 0x10000
+		b += z
+		if z <= 0 {
+			break
+		}
+	}
+	return vector{sx * int32(u) / 4, sy * int32(v) / 4}
+}
+
+// pixRound rounds the 26.6 value `v` to the nearest integer,
+// rounding half values up (contrary to the RTG instruction,
+// which is symmetric)
+func pixRound(v int32) int32 { return (v + 32) &^ 63 }
+
+// setSuperRound decodes the SROUND and S45ROUND argument, for the given grid period
+func (gs *graphicsState) setSuperRound(gridPeriod int32, selector int32) {
+	switch selector & 0xC0 {
+	case 0x00:
+		gs.period = gridPeriod / 2
+	case 0x80:
+		gs.period = gridPeriod * 2
+	default:
+		gs.period = gridPeriod
+	}
+	switch selector & 0x30 {
+	case 0x00:
+		gs.phase = 0
+	case 0x10:
+		gs.phase = gs.period / 4
+	case 0x20:
+		gs.phase = gs.period / 2
+	case 0x30:
+		gs.phase = gs.period * 3 / 4
+	}
+	if selector&0x0F == 0 {
+		gs.threshold = gs.period - 1
+	} else {
+		gs.threshold = (selector&0x0F - 4) * gs.period / 8
+	}
+}
+
+// round applies the current round state to the 26.6 distance `d`
+func (m *interpreter) round(d int32) int32 {
+	gs := &m.gs
+	var v int32
+	switch gs.roundMode {
+	case roundOff:
+		return d
+	case roundToGrid:
+		if d >= 0 {
+			return (d + 32) &^ 63
+		}
+		return -((-d + 32) &^ 63)
+	case roundToHalfGrid:
+		if d >= 0 {
+			return d&^63 + 32
+		}
+		return -((-d)&^63 + 32)
+	case roundToDoubleGrid:
+		if d >= 0 {
+			return (d + 16) &^ 31
+		}
+		return -((-d + 16) &^ 31)
+	case roundDownToGrid:
+		if d >= 0 {
+			return d &^ 63
+		}
+		return -((-d) &^ 63)
+	case roundUpToGrid:
+		if d >= 0 {
+			return (d + 63) &^ 63
+		}
+		return -((-d + 63) &^ 63)
+	case roundSuper:
+		if gs.period <= 0 {
+			return d
+		}
+		if d >= 0 {
+			v = (d-gs.phase+gs.threshold)&-gs.period + gs.phase
+			if v < 0 {
+				v = gs.phase
+			}
+		} else {
+			v = -((gs.threshold - gs.phase - d) & -gs.period) - gs.phase
+			if v > 0 {
+				v = -gs.phase
+			}
+		}
+	case roundSuper45:
+		if gs.period <= 0 {
+			return d
+		}
+		if d >= 0 {
+			v = (d-gs.phase+gs.threshold)/gs.period*gs.period + gs.phase
+			if v < 0 {
+				v = gs.phase
+			}
+		} else {
+			v = -((gs.threshold - gs.phase - d) / gs.period * gs.period) - gs.phase
+			if v > 0 {
+				v = -gs.phase
+			}
+		}
+	}
+	return v
+}
+
+// ------------------------------ geometry ------------------------------
+
+// zp returns the zone pointed by zp0, zp1 or zp2
+func (m *interpreter) zp(i int) *zone { return &m.zones[m.gs.zp[i]] }
+
+func (z *zone) has(p int32) bool { return 0 <= p && int(p) < len(z.cur) }
+
+func (m *interpreter) updateFDotP() {
+	gs := &m.gs
+	m.fDotP = int32((int64(gs.pv.x)*int64(gs.fv.x) + int64(gs.pv.y)*int64(gs.fv.y)) >> 14)
+	if m.fDotP > -0x400 && m.fDotP < 0x400 {
+		m.fDotP = 0x4000
+	}
+}
+
+func (m *interpreter) project(v vector) int32     { return dotProduct(v, m.gs.pv) }
+func (m *interpreter) dualProject(v vector) int32 { return dotProduct(v, m.gs.dv) }
+
+// origDistance returns the projection on the dual vector
+// of the original distance between the points p1 and p2.
+func (m *interpreter) origDistance(z1 *zone, p1 int32, z2 *zone, p2 int32) int32 {
+	if z1.orus == nil || z2.orus == nil { // twilight zone
+		return m.dualProject(sub(z1.org[p1], z2.org[p2]))
+	}
+	d := m.dualProject(sub(z1.orus[p1], z2.orus[p2]))
+	return mulFix(d, z1.orusScale)
+}
+
+// move moves the point `p` by `distance`, along the freedom vector
+func (m *interpreter) move(z *zone, p int32, distance int32, touch bool) {
+	fv := m.gs.fv
+	if fv.x != 0 {
+		z.cur[p].x += mulDivRound(distance, fv.x, m.fDotP)
+		if touch {
+			z.flags[p] |= flagTouchedX
+		}
+	}
+	if fv.y != 0 {
+		z.cur[p].y += mulDivRound(distance, fv.y, m.fDotP)
+		if touch {
+			z.flags[p] |= flagTouchedY
+		}
+	}
+}
+
+// moveOrig moves the original position of the point `p` by `distance`, along the freedom vector
+func (m *interpreter) moveOrig(z *zone, p int32, distance int32) {
+	fv := m.gs.fv
+	if fv.x != 0 {
+		z.org[p].x += mulDivRound(distance, fv.x, m.fDotP)
+	}
+	if fv.y != 0 {
+		z.org[p].y += mulDivRound(distance, fv.y, m.fDotP)
+	}
+}
+
+// lineVector returns the unit vector parallel (or perpendicular)
+// to the line from p2 (in zp2) to p1 (in zp1)
+func (m *interpreter) lineVector(p1, p2 int32, perpendicular, original bool) (vector, error) {
+	z1, z2 := m.zp(1), m.zp(2)
+	if !z1.has(p1) || !z2.has(p2) {
+		return vector{}, errors.New("invalid point reference")
+	}
+	var d vector
+	if original {
+		d = sub(z1.org[p1], z2.org[p2])
+	} else {
+		d = sub(z1.cur[p1], z2.cur[p2])
+	}
+	if d.x == 0 && d.y == 0 {
+		d.x, perpendicular = 0x4000, false
+	}
+	if perpendicular {
+		d.x, d.y = -d.y, d.x
+	}
+	return normalize(d.x, d.y), nil
+}
+
+func (m *interpreter) readCvt(i int32) int32 {
+	if 0 <= i && int(i) < len(m.cvt) {
+		return m.cvt[i]
+	}
+	return 0
+}
+
+func (m *interpreter) mdap(round bool) error {
+	z := m.zp(0)
+	p := m.pop()
+	if !z.has(p) {
+		return nil
+	}
+	var distance int32
+	if round {
+		d := m.project(z.cur[p])
+		distance = m.round(d) - d
+	}
+	m.move(z, p, distance, true)
+	m.gs.rp[0], m.gs.rp[1] = p, p
+	return nil
+}
+
+func (m *interpreter) miap(round bool) error {
+	gs := &m.gs
+	cvtIndex, p := m.pop(), m.pop()
+	z := m.zp(0)
+	if !z.has(p) {
+		return nil
+	}
+	distance := m.readCvt(cvtIndex)
+	if gs.zp[0] == 0 { // twilight zone
+		z.org[p] = vector{mulDivRound(distance, gs.fv.x, 0x4000), mulDivRound(distance, gs.fv.y, 0x4000)}
+		z.cur[p] = z.org[p]
+	}
+	origDist := m.project(z.cur[p])
+	if round {
+		if abs32(distance-origDist) > gs.controlValueCutIn {
+			distance = origDist
+		}
+		distance = m.round(distance)
+	}
+	m.move(z, p, distance-origDist, true)
+	gs.rp[0], gs.rp[1] = p, p
+	return nil
+}
+
+func (m *interpreter) msirp(setRP0 bool) error {
+	gs := &m.gs
+	distance, p := m.pop(), m.pop()
+	z0, z1 := m.zp(0), m.zp(1)
+	if !z1.has(p) || !z0.has(gs.rp[0]) {
+		return nil
+	}
+	if gs.zp[1] == 0 { // twilight zone
+		z1.org[p] = z0.org[gs.rp[0]]
+		m.moveOrig(z1, p, distance)
+		z1.cur[p] = z1.org[p]
+	}
+	d := m.project(sub(z1.cur[p], z0.cur[gs.rp[0]]))
+	m.move(z1, p, distance-d, true)
+	gs.rp[1], gs.rp[2] = gs.rp[0], p
+	if setRP0 {
+		gs.rp[0] = p
+	}
+	return nil
+}
+
+func (m *interpreter) mdrp(op byte) error {
+	gs := &m.gs
+	p := m.pop()
+	z0, z1 := m.zp(0), m.zp(1)
+	rp0 := gs.rp[0]
+	if !z1.has(p) || !z0.has(rp0) {
+		gs.rp[1], gs.rp[2] = rp0, p
+		return nil
+	}
+
+	origDist := m.origDistance(z1, p, z0, rp0)
+
+	// single width cut-in test
+	if abs32(origDist-gs.singleWidth) < gs.singleWidthCutIn {
+		if origDist >= 0 {
+			origDist = gs.singleWidth
+		} else {
+			origDist = -gs.singleWidth
+		}
+	}
+
+	distance := origDist
+	if op&4 != 0 {
+		distance = m.round(origDist)
+	}
+
+	if op&8 != 0 { // minimum distance
+		if origDist >= 0 {
+			if distance < gs.minDistance {
+				distance = gs.minDistance
+			}
+		} else if distance > -gs.minDistance {
+			distance = -gs.minDistance
+		}
+	}
+
+	curDist := m.project(sub(z1.cur[p], z0.cur[rp0]))
+	m.move(z1, p, distance-curDist, true)
+
+	gs.rp[1], gs.rp[2] = rp0, p
+	if op&16 != 0 {
+		gs.rp[0] = p
+	}
+	return nil
+}
+
+func (m *interpreter) mirp(op byte) error {
+	gs := &m.gs
+	cvtIndex, p := m.pop(), m.pop()
+	z0, z1 := m.zp(0), m.zp(1)
+	rp0 := gs.rp[0]
+	if !z1.has(p) || !z0.has(rp0) {
+		gs.rp[1], gs.rp[2] = rp0, p
+		if op&16 != 0 {
+			gs.rp[0] = p
+		}
+		return nil
+	}
+
+	cvtDist := m.readCvt(cvtIndex)
+
+	// single width cut-in test
+	if abs32(cvtDist-gs.singleWidth) < gs.singleWidthCutIn {
+		if cvtDist >= 0 {
+			cvtDist = gs.singleWidth
+		} else {
+			cvtDist = -gs.singleWidth
+		}
+	}
+
+	if gs.zp[1] == 0 { // twilight zone
+		z1.org[p] = vector{
+			z0.org[rp0].x + mulDivRound(cvtDist, gs.fv.x, 0x4000),
+			z0.org[rp0].y + mulDivRound(cvtDist, gs.fv.y, 0x4000),
+		}
+		z1.cur[p] = z1.org[p]
+	}
+
+	origDist := m.dualProject(sub(z1.org[p], z0.org[rp0]))
+	curDist := m.project(sub(z1.cur[p], z0.cur[rp0]))
+
+	if gs.autoFlip && (origDist^cvtDist) < 0 {
+		cvtDist = -cvtDist
+	}
+
+	distance := cvtDist
+	if op&4 != 0 {
+		// the cut-in test is only performed when both points refer to the same zone
+		if gs.zp[0] == gs.zp[1] && abs32(cvtDist-origDist) > gs.controlValueCutIn {
+			distance = origDist
+		}
+		distance = m.round(distance)
+	}
+
+	if op&8 != 0 { // minimum distance
+		if origDist >= 0 {
+			if distance < gs.minDistance {
+				distance = gs.minDistance
+			}
+		} else if distance > -gs.minDistance {
+			distance = -gs.minDistance
+		}
+	}
+
+	m.move(z1, p, distance-curDist, true)
+
+	gs.rp[1], gs.rp[2] = rp0, p
+	if op&16 != 0 {
+		gs.rp[0] = p
+	}
+	return nil
+}
+
+func (m *interpreter) alignrp() error {
+	gs := &m.gs
+	z0, z1 := m.zp(0), m.zp(1)
+	for ; gs.loop > 0; gs.loop-- {
+		if len(m.stack) == 0 {
+			return errStackUnderflow
+		}
+		p := m.pop()
+		if !z1.has(p) || !z0.has(gs.rp[0]) {
+			continue
+		}
+		d := m.project(sub(z1.cur[p], z0.cur[gs.rp[0]]))
+		m.move(z1, p, -d, true)
+	}
+	gs.loop = 1
+	return nil
+}
+
+func (m *interpreter) alignpts() error {
+	p2, p1 := m.pop(), m.pop()
+	z0, z1 := m.zp(0), m.zp(1)
+	if !z1.has(p1) || !z0.has(p2) {
+		return nil
+	}
+	d := m.project(sub(z0.cur[p2], z1.cur[p1])) / 2
+	m.move(z1, p1, d, true)
+	m.move(z0, p2, -d, true)
+	return nil
+}
+
+func (m *interpreter) isect() error {
+	b1, b0, a1, a0, p := m.pop(), m.pop(), m.pop(), m.pop(), m.pop()
+	z0, z1, z2 := m.zp(0), m.zp(1), m.zp(2)
+	if !z0.has(b0) || !z0.has(b1) || !z1.has(a0) || !z1.has(a1) || !z2.has(p) {
+		return nil
+	}
+	db := sub(z0.cur[b1], z0.cur[b0])
+	da := sub(z1.cur[a1], z1.cur[a0])
+	d := sub(z0.cur[b0], z1.cur[a0])
+
+	discriminant := mulDivRound(da.x, -db.y, 0x40) + mulDivRound(da.y, db.x, 0x40)
+	dotProduct := mulDivRound(da.x, db.x, 0x40) + mulDivRound(da.y, db.y, 0x40)
+
+	// reject grazing intersections (angle below 3 degrees)
+	if 19*abs32(discriminant) > abs32(dotProduct) {
+		v := mulDivRound(d.x, -db.y, 0x40) + mulDivRound(d.y, db.x, 0x40)
+		z2.cur[p] = vector{
+			z1.cur[a0].x + mulDivRound(v, da.x, discriminant),
+			z1.cur[a0].y + mulDivRound(v, da.y, discriminant),
+		}
+	} else {
+		// use the middle of the middles of A and B
+		z2.cur[p] = vector{
+			(z1.cur[a0].x + z1.cur[a1].x + z0.cur[b0].x + z0.cur[b1].x) / 4,
+			(z1.cur[a0].y + z1.cur[a1].y + z0.cur[b0].y + z0.cur[b1].y) / 4,
+		}
+	}
+	z2.flags[p] |= flagTouchedX | flagTouchedY
+	return nil
+}
+
+// pointDisplacement returns the displacement of the reference point
+// used by SHP, SHC and SHZ
+func (m *interpreter) pointDisplacement(useRP1 bool) (dx, dy int32, z *zone, ref int32, ok bool) {
+	gs := &m.gs
+	if useRP1 {
+		z, ref = m.zp(0), gs.rp[1]
+	} else {
+		z, ref = m.zp(1), gs.rp[2]
+	}
+	if !z.has(ref) {
+		return 0, 0, nil, 0, false
+	}
+	d := m.project(sub(z.cur[ref], z.org[ref]))
+	dx = mulDivRound(d, gs.fv.x, m.fDotP)
+	dy = mulDivRound(d, gs.fv.y, m.fDotP)
+	return dx, dy, z, ref, true
+}
+
+// shift moves the point `p` of `z` by (dx, dy)
+func (m *interpreter) shift(z *zone, p int32, dx, dy int32, touch bool) {
+	if m.gs.fv.x != 0 {
+		z.cur[p].x += dx
+		if touch {
+			z.flags[p] |= flagTouchedX
+		}
+	}
+	if m.gs.fv.y != 0 {
+		z.cur[p].y += dy
+		if touch {
+			z.flags[p] |= flagTouchedY
+		}
+	}
+}
+
+func (m *interpreter) shp(useRP1 bool) error {
+	gs := &m.gs
+	dx, dy, _, _, ok := m.pointDisplacement(useRP1)
+	z := m.zp(2)
+	for ; gs.loop > 0; gs.loop-- {
+		if len(m.stack) == 0 {
+			return errStackUnderflow
+		}
+		p := m.pop()
+		if ok && z.has(p) {
+			m.shift(z, p, dx, dy, true)
+		}
+	}
+	gs.loop = 1
+	return nil
+}
+
+func (m *interpreter) shc(useRP1 bool) error {
+	contour := m.pop()
+	dx, dy, refZone, ref, ok := m.pointDisplacement(useRP1)
+	z := m.zp(2)
+	if !ok || contour < 0 || int(contour) >= len(z.ends) {
+		return nil
+	}
+	start := int32(0)
+	if contour > 0 {
+		start = int32(z.ends[contour-1]) + 1
+	}
+	end := int32(z.ends[contour])
+	for p := start; p <= end && z.has(p); p++ {
+		if refZone != z || p != ref {
+			m.shift(z, p, dx, dy, true)
+		}
+	}
+	return nil
+}
+
+func (m *interpreter) shz(useRP1 bool) error {
+	e := m.pop()
+	if e != 0 && e != 1 {
+		return fmt.Errorf("invalid zone %d", e)
+	}
+	dx, dy, refZone, ref, ok := m.pointDisplacement(useRP1)
+	if !ok {
+		return nil
+	}
+	z := &m.zones[e]
+	// phantom points are not moved
+	limit := int32(len(z.cur))
+	if e == 1 {
+		limit = 0
+		if len(z.ends) != 0 {
+			limit = int32(z.ends[len(z.ends)-1]) + 1
+		}
+	}
+	for p := int32(0); p < limit; p++ {
+		if refZone != z || p != ref {
+			m.shift(z, p, dx, dy, false)
+		}
+	}
+	return nil
+}
+
+func (m *interpreter) shpix() error {
+	gs := &m.gs
+	amount := m.pop()
+	dx := mulDivRound(amount, gs.fv.x, 0x4000)
+	dy := mulDivRound(amount, gs.fv.y, 0x4000)
+	z := m.zp(2)
+	for ; gs.loop > 0; gs.loop-- {
+		if len(m.stack) == 0 {
+			return errStackUnderflow
+		}
+		p := m.pop()
+		if z.has(p) {
+			m.shift(z, p, dx, dy, true)
+		}
+	}
+	gs.loop = 1
+	return nil
+}
+
+func (m *interpreter) ip() error {
+	gs := &m.gs
+	z0, z1, z2 := m.zp(0), m.zp(1), m.zp(2)
+	rp1, rp2 := gs.rp[1], gs.rp[2]
+
+	// the ratio of the original distances does not depend on the scale
+	twilight := z0.orus == nil || z1.orus == nil || z2.orus == nil
+	origDistance := func(z *zone, p int32) int32 {
+		if twilight {
+			return m.dualProject(sub(z.org[p], z0.org[rp1]))
+		}
+		return m.dualProject(sub(z.orus[p], z0.orus[rp1]))
+	}
+
+	var oldRange, curRange int32
+	valid := z0.has(rp1) && z1.has(rp2)
+	if valid {
+		oldRange = origDistance(z1, rp2)
+		curRange = m.project(sub(z1.cur[rp2], z0.cur[rp1]))
+	}
+
+	for ; gs.loop > 0; gs.loop-- {
+		if len(m.stack) == 0 {
+			return errStackUnderflow
+		}
+		p := m.pop()
+		if !valid || !z2.has(p) {
+			continue
+		}
+		origDist := origDistance(z2, p)
+		curDist := m.project(sub(z2.cur[p], z0.cur[rp1]))
+		var newDist int32
+		if origDist != 0 {
+			if oldRange != 0 {
+				newDist = mulDivRound(origDist, curRange, oldRange)
+			} else {
+				newDist = origDist
+			}
+		}
+		m.move(z2, p, newDist-curDist, true)
+	}
+	gs.loop = 1
+	return nil
+}
+
+func (m *interpreter) iup(isX bool) {
+	z := &m.zones[1]
+	mask := flagTouchedY
+	if isX {
+		mask = flagTouchedX
+	}
+	coord := func(v *vector) *int32 {
+		if isX {
+			return &v.x
+		}
+		return &v.y
+	}
+
+	// shift the untouched points between p1 and p2, by the displacement of ref
+	shift := func(p1, p2, ref int) {
+		delta := *coord(&z.cur[ref]) - *coord(&z.org[ref])
+		if delta == 0 {
+			return
+		}
+		for i := p1; i <= p2; i++ {
+			if i != ref {
+				*coord(&z.cur[i]) += delta
+			}
+		}
+	}
+
+	// interpolate the untouched points between p1 and p2, using ref1 and ref2
+	interpolate := func(p1, p2, ref1, ref2 int) {
+		if p1 > p2 {
+			return
+		}
+		orus1, orus2 := *coord(&z.orus[ref1]), *coord(&z.orus[ref2])
+		if orus1 > orus2 {
+			orus1, orus2 = orus2, orus1
+			ref1, ref2 = ref2, ref1
+		}
+		org1, org2 := *coord(&z.org[ref1]), *coord(&z.org[ref2])
+		cur1, cur2 := *coord(&z.cur[ref1]), *coord(&z.cur[ref2])
+		delta1, delta2 := cur1-org1, cur2-org2
+
+		if cur1 == cur2 || orus1 == orus2 {
+			for i := p1; i <= p2; i++ {
+				x := *coord(&z.org[i])
+				if x <= org1 {
+					x += delta1
+				} else if x >= org2 {
+					x += delta2
+				} else {
+					x = cur1
+				}
+				*coord(&z.cur[i]) = x
+			}
+			return
+		}
+
+		scale := divFix(cur2-cur1, orus2-orus1)
+		for i := p1; i <= p2; i++ {
+			x := *coord(&z.org[i])
+			if x <= org1 {
+				x += delta1
+			} else if x >= org2 {
+				x += delta2
+			} else {
+				x = cur1 + mulFix(*coord(&z.orus[i])-orus1, scale)
+			}
+			*coord(&z.cur[i]) = x
+		}
+	}
+
+	point := 0
+	for _, end := range z.ends {
+		if end >= len(z.cur) {
+			end = len(z.cur) - 1
+		}
+		first := point
+		for point <= end && z.flags[point]&mask == 0 {
+			point++
+		}
+		if point <= end {
+			firstTouched, curTouched := point, point
+			point++
+			for ; point <= end; point++ {
+				if z.flags[point]&mask != 0 {
+					interpolate(curTouched+1, point-1, curTouched, point)
+					curTouched = point
+				}
+			}
+			if curTouched == firstTouched {
+				shift(first, end, curTouched)
+			} else {
+				interpolate(curTouched+1, end, curTouched, firstTouched)
+				if firstTouched > 0 {
+					interpolate(first, firstTouched-1, curTouched, firstTouched)
+				}
+			}
+		}
+		point = end + 1
+	}
+}
+
+// deltaPPEM returns the ppem and the magnitude encoded in `arg`
+func (m *interpreter) deltaValue(op byte, arg int32) (ppem int32, amount int32) {
+	ppem = (arg&0xF0)>>4 + m.gs.deltaBase
+	switch op {
+	case 0x71, 0x74:
+		ppem += 16
+	case 0x72, 0x75:
+		ppem += 32
+	}
+	amount = arg&0xF - 8
+	if amount >= 0 {
+		amount++
+	}
+	amount *= 1 << (6 - m.gs.deltaShift)
+	return ppem, amount
+}
+
+func (m *interpreter) deltap(op byte) error {
+	n := m.pop()
+	if n < 0 || int(2*n) > len(m.stack) {
+		return errStackUnderflow
+	}
+	z := m.zp(0)
+	for ; n > 0; n-- {
+		p, arg := m.pop(), m.pop()
+		if !z.has(p) {
+			continue
+		}
+		if ppem, amount := m.deltaValue(op, arg); ppem == m.ppem {
+			m.move(z, p, amount, true)
+		}
+	}
+	return nil
+}
+
+func (m *interpreter) deltac(op byte) error {
+	n := m.pop()
+	if n < 0 || int(2*n) > len(m.stack) {
+		return errStackUnderflow
+	}
+	for ; n > 0; n-- {
+		index, arg := m.pop(), m.pop()
+		if index < 0 || int(index) >= len(m.cvt) {
+			continue
+		}
+		if ppem, amount := m.deltaValue(op, arg); ppem == m.ppem {
+			m.cvt[index] += amount
+		}
+	}
+	return nil
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,187 @@
+package truetype
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// hintedPoints returns the hinted points of `gid` (phantom points excluded),
+// in 26.6 pixels, formatted as FreeType would output them
+func hintedPoints(t *testing.T, h *Hinter, gid GID, ppem uint16) string {
+	t.Helper()
+	if err := h.setup(ppem); err != nil {
+		t.Fatal(err)
+	}
+	g, err := h.loadGlyph(gid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originX := g.cur[len(g.cur)-phantomCount+phantomLeft].x
+	var chunks []string
+	for _, p := range g.cur[:len(g.cur)-phantomCount] {
+		chunks = append(chunks, fmt.Sprintf("%d,%d", p.x-originX, p.y))
+	}
+	return strings.Join(chunks, " ")
+}
+
+func TestHinterFreeType(t *testing.T) {
+	// reference values are obtained from FreeType, with the version 35 interpreter,
+	// using FT_LOAD_NO_AUTOHINT | FT_LOAD_TARGET_MONO
+	for _, test := range []struct {
+		file     string
+		coords   []float32 // user space
+		ppem     uint16
+		gid      GID
+		expected string
+	}{
+		{
+			"testdata/DejaVuSerif.ttf", nil, 12, 68,
+			"320,128 320,192 230,192 178,192 128,161 128,128 128,99 179,64 223,64 267,64 320,100 384,255 384,64 448,64 448,0 320,0 320,67 298,33 241,0 202,0 139,0 64,69 64,128 64,189 147,256 222,256 320,256 320,292 320,336 267,384 220,384 180,384 134,352 128,320 64,320 64,384 102,416 173,448 207,448 293,448 384,349",
+		},
+		{ // composite glyph with rotated components
+			"testdata/FreeSerif.ttf", nil, 13, 4163,
+			"659,197 645,157 489,214 503,254 211,389 197,349 41,406 55,446 627,467 641,427 485,370 471,410 179,211 193,171 37,114 23,154 656,293 656,251 490,251 490,293 208,293 208,251 42,251 42,293 212,245 212,254 216,271 227,286 237,297 256,310 269,318 296,329 310,335 340,347 347,350 347,383 347,428 306,428 290,428 267,412 267,401 267,397 270,382 270,380 270,371 255,357 245,357 236,357 223,371 223,380 223,406 275,441 313,441 358,441 391,398 391,354 391,249 391,231 398,217 407,217 419,217 431,228 431,213 417,198 396,187 382,187 366,187 350,205 348,226 301,187 269,187 244,187 212,219 347,258 347,337 299,319 260,286 260,262 260,239 281,218 293,218 312,218 333,230 341,236 347,246",
+		},
+		{ // ttfautohint instructions
+			"testdata/Castoro-Regular.ttf", nil, 20, 5,
+			"-35,0 -37,20 -35,40 1,44 45,54 76,81 104,135 123,185 399,886 413,891 453,896 467,896 742,173 760,128 783,76 812,53 855,44 893,40 896,20 893,0 849,0 754,0 707,0 658,0 559,0 511,0 507,18 511,39 573,45 622,56 622,79 622,89 614,114 608,133 535,328 247,328 183,155 174,131 165,100 165,88 165,60 218,46 278,40 280,19 278,0 234,0 150,0 106,0 70,0 4,0 266,376 516,376 390,717",
+		},
+		{
+			"testdata/SelawikVar.ttf", nil, 16, 5,
+			"12,0 287,704 374,704 650,0 557,0 341,572 340,576 332,611 330,619 328,619 326,611 318,576 316,572 106,0 121,128 121,192 532,192 532,128 241,768 216,768 180,807 180,833 180,861 214,896 240,896 265,896 301,859 301,833 301,806 265,768 433,768 409,768 374,805 374,833 374,861 408,896 432,896 458,896 493,859 493,833 493,806 458,768",
+		},
+		{ // with 'cvar' and 'gvar' applied
+			"testdata/SelawikVar.ttf", []float32{700}, 16, 5,
+			"5,0 266,704 457,704 713,0 540,0 370,527 368,531 360,566 358,574 356,574 355,567 346,532 344,527 174,0 173,128 173,256 584,256 584,128 248,768 208,768 164,802 164,832 164,863 208,896 248,896 288,896 332,863 332,832 332,802 288,768 477,768 437,768 393,802 393,832 393,863 437,896 477,896 518,896 562,863 562,832 562,802 518,768",
+		},
+	} {
+		font := loadFont(t, test.file)
+		if test.coords != nil {
+			font.SetVarCoordinates(font.NormalizeVariations(test.coords))
+		}
+		h, err := NewHinter(font)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hintedPoints(t, h, test.gid, test.ppem); got != test.expected {
+			t.Errorf("%s, glyph %d at %d ppem: expected\n%s\ngot\n%s", test.file, test.gid, test.ppem, test.expected, got)
+		}
+	}
+}
+
+func TestHinterAllGlyphs(t *testing.T) {
+	for _, file := range []string{
+		"testdata/04B_30.ttf",
+		"testdata/Castoro-Italic.ttf",
+		"testdata/DejaVuSerif.ttf",
+		"testdata/LateefGR-Regular.ttf",
+		"testdata/SelawikVar.ttf",
+	} {
+		font := loadFont(t, file)
+		h, err := NewHinter(font)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ppem := range []uint16{9, 16, 33} {
+			for gid := range font.Glyf {
+				hinted, err := h.GlyphOutline(GID(gid), ppem)
+				if err != nil {
+					t.Fatalf("%s, glyph %d at %d ppem: %s", file, gid, ppem, err)
+				}
+				unhinted := font.GlyphData(GID(gid), ppem, ppem).(fonts.GlyphOutline)
+				if len(hinted.Segments) != len(unhinted.Segments) {
+					t.Fatalf("%s, glyph %d: expected %d segments, got %d", file, gid, len(unhinted.Segments), len(hinted.Segments))
+				}
+			}
+		}
+	}
+}
+
+func TestHinterCache(t *testing.T) {
+	font := loadFont(t, "testdata/SelawikVar.ttf")
+	h, err := NewHinter(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regular1 := hintedPoints(t, h, 5, 16)
+	small := hintedPoints(t, h, 5, 9)
+	font.SetVarCoordinates(font.NormalizeVariations([]float32{700}))
+	bold := hintedPoints(t, h, 5, 16)
+	font.SetVarCoordinates(nil)
+	regular2 := hintedPoints(t, h, 5, 16)
+
+	if regular1 == small || regular1 == bold {
+		t.Fatal("size or variations not taken into account")
+	}
+	if regular1 != regular2 {
+		t.Fatalf("inconsistent hinting: %s and %s", regular1, regular2)
+	}
+}
+
+func TestCvar(t *testing.T) {
+	font := loadFont(t, "testdata/SelawikVar.ttf")
+	if len(font.cvar) == 0 {
+		t.Fatal("missing 'cvar' table")
+	}
+
+	regular := font.ControlValues()
+	for i, v := range font.hinting.cvt {
+		if regular[i] != float32(v) {
+			t.Fatalf("unexpected variation at default coordinates for control value %d", i)
+		}
+	}
+
+	font.SetVarCoordinates(font.NormalizeVariations([]float32{700}))
+	bold := font.ControlValues()
+	varied := 0
+	for i := range bold {
+		if bold[i] != regular[i] {
+			varied++
+		}
+	}
+	if varied == 0 {
+		t.Fatal("control values not varied")
+	}
+}
+
+func TestHinterInvalid(t *testing.T) {
+	font := loadFont(t, "testdata/CFFTest.otf")
+	if _, err := NewHinter(font); err == nil {
+		t.Fatal("expected error for CFF outlines")
+	}
+
+	font = loadFont(t, "testdata/DejaVuSerif.ttf")
+	h, err := NewHinter(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.GlyphOutline(GID(len(font.Glyf)), 12); err == nil {
+		t.Fatal("expected error for invalid glyph")
+	}
+	if _, err = h.GlyphOutline(0, 0); err == nil {
+		t.Fatal("expected error for invalid size")
+	}
+
+	// corrupted instructions must not crash
+	if err := h.setup(12); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range [][]byte{
+		{0x2B},                         // CALL on empty stack
+		{0xB0, 0xFF, 0x2B},             // CALL undefined function
+		{0xB0, 0x01, 0x2C},             // FDEF without ENDF
+		{0x58},                         // IF on empty stack
+		{0xB0, 0x00, 0x1C},             // infinite JMPR
+		{0xB0, 0x05, 0xB0, 0x00, 0x62}, // DIV by zero
+		{0xB0, 0x05, 0x13},             // SZP0 invalid zone
+	} {
+		glyph := zone{cur: make([]vector, 8), org: make([]vector, 8), orus: make([]vector, 8), flags: make([]uint8, 8), ends: []int{3}}
+		if err := h.hint(&glyph, code); err == nil {
+			t.Errorf("expected error for instructions %x", code)
+		}
+	}
+}
@@ -0,0 +1,716 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// tables storing the variations, which are dropped from the instances
+var instancerDroppedTables = []Tag{
+	tagFvar, tagGvar, tagAvar, tagHvar, tagVvar, tagMvar, MustNewTag("cvar"), MustNewTag("DSIG"),
+}
+
+// Instantiate returns a static font file, where the variations of the font
+// have been applied at the position given by `variations`.
+// The axis not specified in `variations` use their default value.
+//
+// The deltas are applied to the 'glyf' points, the 'hmtx' and 'vmtx' metrics,
+// the 'OS/2', 'hhea', 'vhea' and 'post' values described in the 'MVAR' table,
+// the control values and the 'GDEF' and 'GPOS' device tables. The feature variations
+// of the 'GSUB' and 'GPOS' tables are resolved.
+// The 'fvar', 'gvar', 'avar', 'HVAR', 'VVAR', 'MVAR' and 'cvar' tables are dropped.
+// Other tables are copied as they are (in particular, the 'name' table is not updated).
+//
+// Only fonts with 'glyf' outlines are supported.
+func (pr *FontParser) Instantiate(variations []Variation) ([]byte, error) {
+	font, err := pr.loadTables()
+	if err != nil {
+		return nil, err
+	}
+	if len(font.fvar.Axis) == 0 {
+		return nil, errors.New("instancing requires a variable font")
+	}
+	return pr.instantiate(font, font.fvar.GetDesignCoordsDefault(variations))
+}
+
+// InstantiateNamed is the same as Instantiate, using the
+// coordinates of the named `instance`, usually
+// found in the `Instances` field of the table returned by `Variations`.
+func (pr *FontParser) InstantiateNamed(instance VarInstance) ([]byte, error) {
+	font, err := pr.loadTables()
+	if err != nil {
+		return nil, err
+	}
+	if len(font.fvar.Axis) == 0 {
+		return nil, errors.New("instancing requires a variable font")
+	}
+	if len(instance.Coords) != len(font.fvar.Axis) {
+		return nil, fmt.Errorf("invalid instance: expected %d coordinates, got %d", len(font.fvar.Axis), len(instance.Coords))
+	}
+	return pr.instantiate(font, instance.Coords)
+}
+
+func (pr *FontParser) instantiate(font *Font, designCoords []float32) ([]byte, error) {
+	if !pr.HasTable(tagGlyf) {
+		return nil, errors.New("instancing requires 'glyf' outlines")
+	}
+	font.SetVarCoordinates(font.NormalizeVariations(designCoords))
+	coords := font.varCoords
+
+	tables := make(map[Tag][]byte, len(pr.tables))
+	for tag := range pr.tables {
+		tables[tag], _ = pr.GetRawTable(tag)
+	}
+	for _, tag := range instancerDroppedTables {
+		delete(tables, tag)
+	}
+
+	// outlines and metrics
+	glyf, hmtx, vmtx := font.instantiateGlyphs()
+	var locaFormat int16
+	tables[tagGlyf], tables[tagLoca], locaFormat = glyf.writeTo()
+
+	head := tables[tagHead]
+	if len(head) < 54 {
+		return nil, errors.New("invalid 'head' table (EOF)")
+	}
+	xMin, yMin, xMax, yMax := glyf.bounds()
+	binary.BigEndian.PutUint16(head[36:], uint16(xMin))
+	binary.BigEndian.PutUint16(head[38:], uint16(yMin))
+	binary.BigEndian.PutUint16(head[40:], uint16(xMax))
+	binary.BigEndian.PutUint16(head[42:], uint16(yMax))
+	binary.BigEndian.PutUint16(head[50:], uint16(locaFormat))
+
+	var err error
+	allGlyphs := make(glyphSet, len(glyf))
+	for gid := range glyf {
+		allGlyphs[GID(gid)] = true
+	}
+	identity := newGlyphMapping(allGlyphs, false)
+	tables[tagHhea], tables[tagHmtx], err = pr.subsetMetrics(tagHhea, hmtx, identity)
+	if err != nil {
+		return nil, err
+	}
+	updateHheaExtents(tables[tagHhea], glyf, hmtx)
+	if vmtx != nil {
+		tables[tagVhea], tables[tagVmtx], err = pr.subsetMetrics(tagVhea, vmtx, identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// global metrics
+	font.mvar.instantiateMetrics(tables, coords)
+	if os2 := tables[tagOS2]; len(os2) >= 8 {
+		for i, axis := range font.fvar.Axis {
+			switch axis.Tag {
+			case MustNewTag("wght"):
+				binary.BigEndian.PutUint16(os2[4:], uint16(weightClass(designCoords[i])))
+			case MustNewTag("wdth"):
+				binary.BigEndian.PutUint16(os2[6:], uint16(widthClass(designCoords[i])))
+			}
+		}
+	}
+
+	if cvt := font.ControlValues(); len(cvt) != 0 {
+		out := make([]byte, 2*len(cvt))
+		for i, v := range cvt {
+			binary.BigEndian.PutUint16(out[2*i:], uint16(roundF(v)))
+		}
+		tables[MustNewTag("cvt ")] = out
+	}
+
+	// layout tables
+	layout := font.LayoutTables()
+	if len(layout.GSUB.FeatureVariations) != 0 {
+		layout.GSUB.TableLayout = layout.GSUB.instantiate(coords)
+		tables[TagGsub], err = layout.GSUB.subset(identity).writeTo()
+		if err != nil {
+			return nil, err
+		}
+	}
+	store := layout.GDEF.VariationStore
+	hasStore := len(store.Regions) != 0 || len(store.Datas) != 0
+	if len(layout.GPOS.FeatureVariations) != 0 || hasStore {
+		layout.GPOS.TableLayout = layout.GPOS.instantiate(coords)
+		layout.GPOS.instantiateDevices(store, coords)
+		tables[TagGpos], err = layout.GPOS.subset(identity).writeTo()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hasStore {
+		layout.GDEF.LigatureCaretList.instantiateDevices(store, coords)
+		layout.GDEF.VariationStore = VariationStore{}
+		tables[TagGdef], err = layout.GDEF.subset(identity).writeTo()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return writeOTF(pr.Type, tables), nil
+}
+
+// weightClass returns the 'OS/2' usWeightClass for the 'wght' axis value.
+func weightClass(wght float32) int {
+	v := int(roundF(wght))
+	if v < 1 {
+		return 1
+	} else if v > 1000 {
+		return 1000
+	}
+	return v
+}
+
+// widthClass returns the 'OS/2' usWidthClass for the 'wdth' axis value,
+// a percentage of the normal width.
+func widthClass(wdth float32) int {
+	percentages := [...]float32{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+	class := 1
+	for i, p := range percentages {
+		if absF(wdth-p) < absF(wdth-percentages[class-1]) {
+			class = i + 1
+		}
+	}
+	return class
+}
+
+func absF(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// instantiateGlyphs returns the glyphs with the variations applied and rounded,
+// and the new horizontal and vertical metrics (nil if the font has no 'vmtx' table).
+func (f *Font) instantiateGlyphs() (glyf TableGlyf, hmtx, vmtx TableHVmtx) {
+	glyf = make(TableGlyf, len(f.Glyf))
+	for gid, g := range f.Glyf {
+		if g.data == nil {
+			continue
+		}
+		points := f.getOwnPoints(GID(gid))
+		switch data := g.data.(type) {
+		case simpleGlyphData:
+			newData := simpleGlyphData{
+				endPtsOfContours: data.endPtsOfContours,
+				instructions:     data.instructions,
+				points:           make([]glyphContourPoint, len(data.points)),
+			}
+			for i, p := range data.points {
+				newData.points[i] = glyphContourPoint{
+					flag: p.flag & (flagOnCurve | overlapSimple),
+					x:    int16(roundF(points[i].X)),
+					y:    int16(roundF(points[i].Y)),
+				}
+			}
+			glyf[gid].data = newData
+		case compositeGlyphData:
+			newData := compositeGlyphData{
+				instructions: data.instructions,
+				glyphs:       append([]compositeGlyphPart(nil), data.glyphs...),
+			}
+			for i, part := range newData.glyphs {
+				if part.isAnchored() { // no offset to vary
+					continue
+				}
+				dx, dy := part.argsAsTranslation()
+				dx += int16(roundF(points[i].X))
+				dy += int16(roundF(points[i].Y))
+				if dx < -0x80 || dx > 0x7F || dy < -0x80 || dy > 0x7F {
+					newData.glyphs[i].flags |= arg1And2AreWords
+				}
+				if newData.glyphs[i].flags&arg1And2AreWords != 0 {
+					newData.glyphs[i].arg1, newData.glyphs[i].arg2 = uint16(dx), uint16(dy)
+				} else {
+					newData.glyphs[i].arg1, newData.glyphs[i].arg2 = uint16(uint8(dx)), uint16(uint8(dy))
+				}
+			}
+			glyf[gid].data = newData
+		}
+	}
+
+	// the bounding boxes are computed from the rounded points
+	static := Font{Glyf: glyf}
+	for gid := range glyf {
+		var points []contourPoint
+		static.getPointsForGlyph(GID(gid), 1, &points) // avoid the shift done at the top level
+		if len(points) <= phantomCount {
+			continue
+		}
+		ext := extentsFromPoints(points)
+		glyf[gid].Xmin = int16(roundF(ext.XBearing))
+		glyf[gid].Xmax = int16(roundF(ext.XBearing + ext.Width))
+		glyf[gid].Ymax = int16(roundF(ext.YBearing))
+		glyf[gid].Ymin = int16(roundF(ext.YBearing + ext.Height))
+	}
+
+	// the side bearings are computed from the phantom points of the varied font
+	hmtx = make(TableHVmtx, len(f.Glyf))
+	if f.vmtx != nil {
+		vmtx = make(TableHVmtx, len(f.Glyf))
+	}
+	for gid := range glyf {
+		var points []contourPoint
+		f.getPointsForGlyph(GID(gid), 1, &points)
+		if len(points) < phantomCount {
+			continue
+		}
+		phantoms := points[len(points)-phantomCount:]
+		hmtx[gid].Advance = int16(roundF(f.HorizontalAdvance(GID(gid))))
+		hmtx[gid].SideBearing = glyf[gid].Xmin - int16(roundF(phantoms[phantomLeft].X))
+		if vmtx != nil {
+			vmtx[gid].Advance = int16(roundF(-f.VerticalAdvance(GID(gid))))
+			vmtx[gid].SideBearing = int16(roundF(phantoms[phantomTop].Y)) - glyf[gid].Ymax
+		}
+	}
+	return glyf, hmtx, vmtx
+}
+
+// bounds returns the bounding box of all the glyphs
+func (t TableGlyf) bounds() (xMin, yMin, xMax, yMax int16) {
+	first := true
+	for _, g := range t {
+		if g.data == nil {
+			continue
+		}
+		if first {
+			xMin, yMin, xMax, yMax = g.Xmin, g.Ymin, g.Xmax, g.Ymax
+			first = false
+			continue
+		}
+		if g.Xmin < xMin {
+			xMin = g.Xmin
+		}
+		if g.Ymin < yMin {
+			yMin = g.Ymin
+		}
+		if g.Xmax > xMax {
+			xMax = g.Xmax
+		}
+		if g.Ymax > yMax {
+			yMax = g.Ymax
+		}
+	}
+	return
+}
+
+// updateHheaExtents updates in place the advanceWidthMax, minLeftSideBearing,
+// minRightSideBearing and xMaxExtent fields of the 'hhea' table.
+func updateHheaExtents(hhea []byte, glyf TableGlyf, hmtx TableHVmtx) {
+	var (
+		advanceMax                uint16
+		minLsb, minRsb, maxExtent int16 = 0x7FFF, 0x7FFF, -0x8000
+	)
+	for gid, metric := range hmtx {
+		if uint16(metric.Advance) > advanceMax {
+			advanceMax = uint16(metric.Advance)
+		}
+		g := glyf[gid]
+		if g.data == nil { // empty glyphs are ignored
+			continue
+		}
+		extent := metric.SideBearing + g.Xmax - g.Xmin
+		if metric.SideBearing < minLsb {
+			minLsb = metric.SideBearing
+		}
+		if rsb := metric.Advance - extent; rsb < minRsb {
+			minRsb = rsb
+		}
+		if extent > maxExtent {
+			maxExtent = extent
+		}
+	}
+	if maxExtent < minLsb { // no glyph with outlines
+		minLsb, minRsb, maxExtent = 0, 0, 0
+	}
+	binary.BigEndian.PutUint16(hhea[10:], advanceMax)
+	binary.BigEndian.PutUint16(hhea[12:], uint16(minLsb))
+	binary.BigEndian.PutUint16(hhea[14:], uint16(minRsb))
+	binary.BigEndian.PutUint16(hhea[16:], uint16(maxExtent))
+}
+
+// fields varied by the 'MVAR' table, as (table, offset) pairs
+var mvarFields = map[Tag][]struct {
+	table  Tag
+	offset int
+}{
+	metricsTagHorizontalAscender:  {{tagOS2, 68}, {tagHhea, 4}},
+	metricsTagHorizontalDescender: {{tagOS2, 70}, {tagHhea, 6}},
+	metricsTagHorizontalLineGap:   {{tagOS2, 72}, {tagHhea, 8}},
+	MustNewTag("hcla"):            {{tagOS2, 74}},
+	MustNewTag("hcld"):            {{tagOS2, 76}},
+	metricsTagVerticalAscender:    {{tagVhea, 4}},
+	metricsTagVerticalDescender:   {{tagVhea, 6}},
+	metricsTagVerticalLineGap:     {{tagVhea, 8}},
+	MustNewTag("hcrs"):            {{tagHhea, 18}},
+	MustNewTag("hcrn"):            {{tagHhea, 20}},
+	MustNewTag("hcof"):            {{tagHhea, 22}},
+	MustNewTag("vcrs"):            {{tagVhea, 18}},
+	MustNewTag("vcrn"):            {{tagVhea, 20}},
+	MustNewTag("vcof"):            {{tagVhea, 22}},
+	MustNewTag("sbxs"):            {{tagOS2, 10}},
+	tagSubscriptYSize:             {{tagOS2, 12}},
+	tagSubscriptXOffset:           {{tagOS2, 14}},
+	tagSubscriptYOffset:           {{tagOS2, 16}},
+	tagSuperscriptXSize:           {{tagOS2, 18}},
+	tagSuperscriptYSize:           {{tagOS2, 20}},
+	tagSuperscriptXOffset:         {{tagOS2, 22}},
+	tagSuperscriptYOffset:         {{tagOS2, 24}},
+	tagStrikeoutSize:              {{tagOS2, 26}},
+	tagStrikeoutOffset:            {{tagOS2, 28}},
+	tagXHeight:                    {{tagOS2, 86}},
+	tagCapHeight:                  {{tagOS2, 88}},
+	tagUnderlineOffset:            {{tagPost, 8}},
+	tagUnderlineSize:              {{tagPost, 10}},
+}
+
+// instantiateMetrics applies in place the variations to `tables`.
+// Unknown tags and missing tables are ignored.
+func (t TableMvar) instantiateMetrics(tables map[Tag][]byte, coords []float32) {
+	for _, record := range t.Values {
+		fields := mvarFields[record.Tag]
+		if len(fields) == 0 {
+			continue
+		}
+		delta := int16(roundF(t.Store.GetDelta(record.Index, coords)))
+		for _, field := range fields {
+			table := tables[field.table]
+			if len(table) < field.offset+2 {
+				continue
+			}
+			v := int16(binary.BigEndian.Uint16(table[field.offset:]))
+			binary.BigEndian.PutUint16(table[field.offset:], uint16(v+delta))
+		}
+	}
+}
+
+// instantiate returns a copy of the layout where the feature variations
+// matching `coords` have been applied and removed.
+func (t TableLayout) instantiate(coords []float32) TableLayout {
+	index := t.FindVariationIndex(coords)
+	features := append([]FeatureRecord(nil), t.Features...)
+	if index != -1 {
+		for _, subs := range t.FeatureVariations[index].FeatureSubstitutions {
+			if int(subs.FeatureIndex) < len(features) {
+				features[subs.FeatureIndex].Feature = subs.AlternateFeature
+			}
+		}
+	}
+	t.Features = features
+	t.FeatureVariations = nil
+	return t
+}
+
+// deltaInstancer applies the variations stored in 'GDEF'.
+type deltaInstancer struct {
+	store  VariationStore
+	coords []float32
+}
+
+// apply returns the value with the delta of `device` applied, and
+// the device, or nil if it has been applied.
+func (inst deltaInstancer) apply(value int16, device DeviceTable) (int16, DeviceTable) {
+	if index, ok := device.(DeviceVariation); ok {
+		delta := inst.store.GetDelta(VariationStoreIndex(index), inst.coords)
+		return value + int16(roundF(delta)), nil
+	}
+	return value, device
+}
+
+func (inst deltaInstancer) valueRecord(record *GPOSValueRecord) {
+	record.XPlacement, record.XPlaDevice = inst.apply(record.XPlacement, record.XPlaDevice)
+	record.YPlacement, record.YPlaDevice = inst.apply(record.YPlacement, record.YPlaDevice)
+	record.XAdvance, record.XAdvDevice = inst.apply(record.XAdvance, record.XAdvDevice)
+	record.YAdvance, record.YAdvDevice = inst.apply(record.YAdvance, record.YAdvDevice)
+}
+
+func (inst deltaInstancer) anchor(anchor GPOSAnchor) GPOSAnchor {
+	a, ok := anchor.(GPOSAnchorFormat3)
+	if !ok {
+		return anchor
+	}
+	a.X, a.XDevice = inst.apply(a.X, a.XDevice)
+	a.Y, a.YDevice = inst.apply(a.Y, a.YDevice)
+	if a.XDevice == nil && a.YDevice == nil {
+		return a.GPOSAnchorFormat1
+	}
+	return a
+}
+
+func (inst deltaInstancer) anchors(anchors []GPOSAnchor) {
+	for i, a := range anchors {
+		anchors[i] = inst.anchor(a)
+	}
+}
+
+func (inst deltaInstancer) marks(marks []GPOSMark) {
+	for i, m := range marks {
+		marks[i].Anchor = inst.anchor(m.Anchor)
+	}
+}
+
+// valueFormat returns the format to use once the variations are applied
+// on `records`: the values of variable devices are included, and the unused
+// devices are removed.
+func valueFormat(format GPOSValueFormat, records []GPOSValueRecord) GPOSValueFormat {
+	devices := format & Devices
+	format &^= Devices
+	if devices&XPlaDevice != 0 {
+		format |= XPlacement
+	}
+	if devices&YPlaDevice != 0 {
+		format |= YPlacement
+	}
+	if devices&XAdvDevice != 0 {
+		format |= XAdvance
+	}
+	if devices&YAdvDevice != 0 {
+		format |= YAdvance
+	}
+	for _, record := range records {
+		if record.XPlaDevice != nil {
+			format |= XPlaDevice
+		}
+		if record.YPlaDevice != nil {
+			format |= YPlaDevice
+		}
+		if record.XAdvDevice != nil {
+			format |= XAdvDevice
+		}
+		if record.YAdvDevice != nil {
+			format |= YAdvDevice
+		}
+	}
+	return format
+}
+
+// instantiateDevices applies in place the variations of the device tables,
+// which are then removed.
+func (t TableGPOS) instantiateDevices(store VariationStore, coords []float32) {
+	inst := deltaInstancer{store: store, coords: coords}
+	for _, lookup := range t.Lookups {
+		for j, subtable := range lookup.Subtables {
+			switch data := subtable.Data.(type) {
+			case GPOSSingle1:
+				inst.valueRecord(&data.Value)
+				data.Format = valueFormat(data.Format, []GPOSValueRecord{data.Value})
+				lookup.Subtables[j].Data = data
+			case GPOSSingle2:
+				for i := range data.Values {
+					inst.valueRecord(&data.Values[i])
+				}
+				data.Format = valueFormat(data.Format, data.Values)
+				lookup.Subtables[j].Data = data
+			case GPOSPair1:
+				var firsts, seconds []GPOSValueRecord
+				for _, set := range data.Values {
+					for i := range set {
+						inst.valueRecord(&set[i].Pos[0])
+						inst.valueRecord(&set[i].Pos[1])
+						firsts = append(firsts, set[i].Pos[0])
+						seconds = append(seconds, set[i].Pos[1])
+					}
+				}
+				data.Formats = [2]GPOSValueFormat{valueFormat(data.Formats[0], firsts), valueFormat(data.Formats[1], seconds)}
+				lookup.Subtables[j].Data = data
+			case GPOSPair2:
+				var firsts, seconds []GPOSValueRecord
+				for _, row := range data.Values {
+					for i := range row {
+						inst.valueRecord(&row[i][0])
+						inst.valueRecord(&row[i][1])
+						firsts = append(firsts, row[i][0])
+						seconds = append(seconds, row[i][1])
+					}
+				}
+				data.Formats = [2]GPOSValueFormat{valueFormat(data.Formats[0], firsts), valueFormat(data.Formats[1], seconds)}
+				lookup.Subtables[j].Data = data
+			case GPOSCursive1:
+				for i := range data {
+					data[i][0] = inst.anchor(data[i][0])
+					data[i][1] = inst.anchor(data[i][1])
+				}
+			case GPOSMarkToBase1:
+				inst.marks(data.Marks)
+				for _, anchors := range data.Bases {
+					inst.anchors(anchors)
+				}
+			case GPOSMarkToLigature1:
+				inst.marks(data.Marks)
+				for _, ligature := range data.Ligatures {
+					for _, anchors := range ligature {
+						inst.anchors(anchors)
+					}
+				}
+			case GPOSMarkToMark1:
+				inst.marks(data.Marks1)
+				for _, anchors := range data.Marks2 {
+					inst.anchors(anchors)
+				}
+			}
+		}
+	}
+}
+
+// instantiateDevices applies in place the variations of the caret values.
+func (t LigatureCaretList) instantiateDevices(store VariationStore, coords []float32) {
+	inst := deltaInstancer{store: store, coords: coords}
+	for _, carets := range t.LigCarets {
+		for i, caret := range carets {
+			if c, ok := caret.(CaretValueFormat3); ok {
+				c.Coordinate, c.Device = inst.apply(c.Coordinate, c.Device)
+				if c.Device == nil {
+					carets[i] = CaretValueFormat1(c.Coordinate)
+				} else {
+					carets[i] = c
+				}
+			}
+		}
+	}
+}
+
+// writeTo returns the 'glyf' and 'loca' tables, with the format of the 'loca' table.
+func (t TableGlyf) writeTo() (glyf, loca []byte, format int16) {
+	offsets := make([]uint32, len(t)+1)
+	for i, g := range t {
+		offsets[i] = uint32(len(glyf))
+		glyf = g.appendTo(glyf)
+		for len(glyf)%4 != 0 { // padding
+			glyf = append(glyf, 0)
+		}
+	}
+	offsets[len(t)] = uint32(len(glyf))
+
+	if len(glyf)/2 <= 0xFFFF { // short offsets
+		loca = make([]byte, 2*len(offsets))
+		for i, offset := range offsets {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(offset/2))
+		}
+		return glyf, loca, 0
+	}
+	loca = make([]byte, 4*len(offsets))
+	for i, offset := range offsets {
+		binary.BigEndian.PutUint32(loca[4*i:], offset)
+	}
+	return glyf, loca, 1
+}
+
+// appendTo encodes the glyph, which is empty for absent glyphs.
+func (g GlyphData) appendTo(out []byte) []byte {
+	var numberOfContours int16
+	switch data := g.data.(type) {
+	case simpleGlyphData:
+		numberOfContours = int16(len(data.endPtsOfContours))
+	case compositeGlyphData:
+		numberOfContours = -1
+	default:
+		return out
+	}
+	for _, v := range [...]int16{numberOfContours, g.Xmin, g.Ymin, g.Xmax, g.Ymax} {
+		out = append(out, byte(uint16(v)>>8), byte(v))
+	}
+	switch data := g.data.(type) {
+	case simpleGlyphData:
+		out = data.appendTo(out)
+	case compositeGlyphData:
+		out = data.appendTo(out)
+	}
+	return out
+}
+
+func (sg simpleGlyphData) appendTo(out []byte) []byte {
+	const repeatFlag = 0x08
+	for _, end := range sg.endPtsOfContours {
+		out = append(out, byte(end>>8), byte(end))
+	}
+	out = append(out, byte(len(sg.instructions)>>8), byte(len(sg.instructions)))
+	out = append(out, sg.instructions...)
+
+	var (
+		xs, ys           []byte
+		prevX, prevY     int16
+		lastFlag, repeat int = -1, 0
+	)
+	encode := func(delta int16, shortFlag, sameFlag uint8, coords []byte) (uint8, []byte) {
+		switch {
+		case delta == 0:
+			return sameFlag, coords
+		case -0xFF <= delta && delta <= 0xFF:
+			if delta > 0 {
+				return shortFlag | sameFlag, append(coords, byte(delta))
+			}
+			return shortFlag, append(coords, byte(-delta))
+		default:
+			return 0, append(coords, byte(uint16(delta)>>8), byte(delta))
+		}
+	}
+	for _, p := range sg.points {
+		flag := p.flag & (flagOnCurve | overlapSimple)
+		var f uint8
+		f, xs = encode(p.x-prevX, xShortVector, xIsSameOrPositiveXShortVector, xs)
+		flag |= f
+		f, ys = encode(p.y-prevY, yShortVector, yIsSameOrPositiveYShortVector, ys)
+		flag |= f
+		prevX, prevY = p.x, p.y
+
+		if int(flag) == lastFlag && repeat < 0xFF {
+			repeat++
+			if repeat == 1 { // mark the previous flag as repeated
+				out[len(out)-1] |= repeatFlag
+				out = append(out, 1)
+			} else {
+				out[len(out)-1] = byte(repeat)
+			}
+			continue
+		}
+		out = append(out, flag)
+		lastFlag, repeat = int(flag), 0
+	}
+	out = append(out, xs...)
+	return append(out, ys...)
+}
+
+func (cg compositeGlyphData) appendTo(out []byte) []byte {
+	const (
+		weHaveAScale       = 1 << 3
+		moreComponents     = 1 << 5
+		weHaveAnXAndYScale = 1 << 6
+		weHaveATwoByTwo    = 1 << 7
+		weHaveInstructions = 1 << 8
+	)
+	for i, part := range cg.glyphs {
+		flags := part.flags &^ (moreComponents | weHaveInstructions)
+		if i != len(cg.glyphs)-1 {
+			flags |= moreComponents
+		} else if len(cg.instructions) != 0 {
+			flags |= weHaveInstructions
+		}
+		out = append(out, byte(flags>>8), byte(flags), byte(part.glyphIndex>>8), byte(part.glyphIndex))
+		if flags&arg1And2AreWords != 0 {
+			out = append(out, byte(part.arg1>>8), byte(part.arg1), byte(part.arg2>>8), byte(part.arg2))
+		} else {
+			out = append(out, byte(part.arg1), byte(part.arg2))
+		}
+		var scales []float32
+		switch {
+		case flags&weHaveAScale != 0:
+			scales = part.scale[:1]
+		case flags&weHaveAnXAndYScale != 0:
+			scales = []float32{part.scale[0], part.scale[3]}
+		case flags&weHaveATwoByTwo != 0:
+			scales = part.scale[:]
+		}
+		for _, s := range scales {
+			v := floatToFixed214(s)
+			out = append(out, byte(v>>8), byte(v))
+		}
+	}
+	if len(cg.instructions) != 0 {
+		out = append(out, byte(len(cg.instructions)>>8), byte(len(cg.instructions)))
+		out = append(out, cg.instructions...)
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package truetype
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func instantiateFont(t *testing.T, filename string, variations []Variation) (varied, static *Font) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := NewFontParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := pr.Instantiate(variations)
+	if err != nil {
+		t.Fatalf("instancing %s: %s", filename, err)
+	}
+
+	varied, err = Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetVariations(varied, variations)
+	static, err = Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("parsing instance of %s: %s", filename, err)
+	}
+	return varied, static
+}
+
+func closeOutlines(a, b fonts.GlyphOutline, tolerance float32) bool {
+	if len(a.Segments) != len(b.Segments) {
+		return false
+	}
+	for i, s := range a.Segments {
+		if s.Op != b.Segments[i].Op {
+			return false
+		}
+		for j, p := range s.Args {
+			q := b.Segments[i].Args[j]
+			if math.Abs(float64(p.X-q.X)) > float64(tolerance) || math.Abs(float64(p.Y-q.Y)) > float64(tolerance) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestInstantiate(t *testing.T) {
+	for _, test := range []struct {
+		filename   string
+		variations []Variation
+	}{
+		{"testdata/SelawikVar.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 700}}},
+		{"testdata/SelawikVar.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 250}}},
+		{"testdata/SelawikVar.ttf", nil}, // default instance
+		{"testdata/Commissioner-VF.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 800}, {Tag: MustNewTag("slnt"), Value: -12}}},
+		{"testdata/Estedad-VF.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 200}}},
+		{"testdata/Mada-VF.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 900}}},
+		{"testdata/SourceSansVariable-Roman.modcomp.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 900}}},
+		{"testdata/ToyVar1.ttf", []Variation{{Tag: MustNewTag("wght"), Value: 700}}},
+	} {
+		varied, static := instantiateFont(t, test.filename, test.variations)
+
+		if len(static.Variations().Axis) != 0 || static.hvar != nil || len(static.mvar.Values) != 0 {
+			t.Fatalf("%s: variable tables not removed", test.filename)
+		}
+		if static.NumGlyphs != varied.NumGlyphs {
+			t.Fatalf("%s: different number of glyphs", test.filename)
+		}
+		for gid := 0; gid < varied.NumGlyphs; gid++ {
+			expected, ok1 := varied.GlyphData(GID(gid), 0, 0).(fonts.GlyphOutline)
+			got, ok2 := static.GlyphData(GID(gid), 0, 0).(fonts.GlyphOutline)
+			if ok1 != ok2 {
+				t.Fatalf("%s: glyph %d: invalid outlines", test.filename, gid)
+			}
+			// composite glyphs may cumulate rounding errors
+			if !closeOutlines(expected, got, 2) {
+				t.Fatalf("%s: glyph %d: different outlines", test.filename, gid)
+			}
+			if a, b := varied.HorizontalAdvance(GID(gid)), static.HorizontalAdvance(GID(gid)); math.Abs(float64(a-b)) > 0.5 {
+				t.Fatalf("%s: glyph %d: different advances: %f != %f", test.filename, gid, a, b)
+			}
+		}
+
+		expectedExtents, _ := varied.FontHExtents()
+		gotExtents, _ := static.FontHExtents()
+		if math.Abs(float64(expectedExtents.Ascender-gotExtents.Ascender)) > 0.5 ||
+			math.Abs(float64(expectedExtents.Descender-gotExtents.Descender)) > 0.5 {
+			t.Fatalf("%s: different extents: %v != %v", test.filename, expectedExtents, gotExtents)
+		}
+
+		layout := static.LayoutTables()
+		if len(layout.GSUB.FeatureVariations) != 0 || len(layout.GPOS.FeatureVariations) != 0 ||
+			len(layout.GDEF.VariationStore.Datas) != 0 {
+			t.Fatalf("%s: variations not removed from layout tables", test.filename)
+		}
+		if len(layout.GPOS.Lookups) != len(varied.LayoutTables().GPOS.Lookups) {
+			t.Fatalf("%s: GPOS lookups not preserved", test.filename)
+		}
+	}
+}
+
+func TestInstantiateDevices(t *testing.T) {
+	variations := []Variation{{Tag: MustNewTag("wght"), Value: 900}}
+	varied, static := instantiateFont(t, "testdata/Mada-VF.ttf", variations)
+
+	store := varied.LayoutTables().GDEF.VariationStore
+	var devices int
+	for i, lookup := range varied.LayoutTables().GPOS.Lookups {
+		for j, subtable := range lookup.Subtables {
+			pairs, ok := subtable.Data.(GPOSPair2)
+			if !ok {
+				continue
+			}
+			got := static.LayoutTables().GPOS.Lookups[i].Subtables[j].Data.(GPOSPair2)
+			for k, row := range pairs.Values {
+				for l, values := range row {
+					expected := values[0].XAdvance
+					if dev, ok := values[0].XAdvDevice.(DeviceVariation); ok {
+						expected += int16(roundF(store.GetDelta(VariationStoreIndex(dev), varied.VarCoordinates())))
+						devices++
+					}
+					if value := got.Values[k][l][0]; value.XAdvance != expected || value.XAdvDevice != nil {
+						t.Fatalf("unexpected value record %v", value)
+					}
+				}
+			}
+		}
+	}
+	if devices == 0 {
+		t.Fatal("expected variable device tables")
+	}
+}
+
+func TestEncodeGlyphs(t *testing.T) {
+	for _, filename := range []string{"testdata/FreeSerif.ttf", "testdata/Roboto-BoldItalic.ttf"} {
+		font := loadFont(t, filename)
+		data, loca, format := font.Glyf.writeTo()
+		offsets, err := parseTableLoca(loca, len(font.Glyf), format == 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		glyf, err := parseTableGlyf(data, offsets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only the on-curve and overlap flags are relevant
+		maskFlags := func(g GlyphData) {
+			if simple, ok := g.data.(simpleGlyphData); ok {
+				for i := range simple.points {
+					simple.points[i].flag &= flagOnCurve | overlapSimple
+				}
+			}
+		}
+		for gid, g := range font.Glyf {
+			got := glyf[gid]
+			maskFlags(g)
+			maskFlags(got)
+			if !reflect.DeepEqual(got, g) {
+				t.Fatalf("%s: glyph %d: expected %v, got %v", filename, gid, g, got)
+			}
+		}
+	}
+}
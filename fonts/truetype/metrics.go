@@ -194,15 +194,12 @@ const (
 	phantomCount
 )
 
-// use the `glyf` table to fetch the contour points,
-// applying variation if needed.
-// for composite, recursively calls itself; allPoints includes phantom points and will be at least of length 4
-func (f *Font) getPointsForGlyph(gid GID, currentDepth int, allPoints *[]contourPoint /* OUT */) {
-	// adapted from harfbuzz/src/hb-ot-glyf-table.hh
-
-	if currentDepth > maxCompositeNesting || int(gid) >= len(f.Glyf) {
-		return
-	}
+// getOwnPoints returns the points defined by the glyph `gid`,
+// followed by the phantom points, applying variation if needed.
+// For composite glyphs, there is one point per component, storing
+// the variation of its offset.
+// `gid` must be a valid index into the 'glyf' table.
+func (f *Font) getOwnPoints(gid GID) []contourPoint {
 	g := f.Glyf[gid]
 
 	var points []contourPoint
@@ -229,6 +226,23 @@ func (f *Font) getPointsForGlyph(gid GID, currentDepth int, allPoints *[]contour
 		f.gvar.applyDeltasToPoints(gid, f.varCoords, points)
 	}
 
+	return points
+}
+
+// use the `glyf` table to fetch the contour points,
+// applying variation if needed.
+// for composite, recursively calls itself; allPoints includes phantom points and will be at least of length 4
+func (f *Font) getPointsForGlyph(gid GID, currentDepth int, allPoints *[]contourPoint /* OUT */) {
+	// adapted from harfbuzz/src/hb-ot-glyf-table.hh
+
+	if currentDepth > maxCompositeNesting || int(gid) >= len(f.Glyf) {
+		return
+	}
+	g := f.Glyf[gid]
+
+	points := f.getOwnPoints(gid)
+	phantoms := points[len(points)-phantomCount:]
+
 	switch data := g.data.(type) {
 	case simpleGlyphData:
 		*allPoints = append(*allPoints, points...)
@@ -326,6 +340,39 @@ func (f *Font) getGlyfPoints(gid GID, computeExtents bool) (ext fonts.GlyphExten
 	return ext, ph
 }
 
+// GlyphPoint is a point of a glyph outline, in font units.
+type GlyphPoint struct {
+	fonts.SegmentPoint
+	OnCurve      bool
+	EndOfContour bool // this point is the last of its contour
+}
+
+// GlyphPoints returns the points of the glyph `gid` from the 'glyf' table, with
+// composite glyphs resolved and the 'gvar' variations applied for the current
+// variation coordinates.
+// The phantom points (left, right, top and bottom) are also returned: they
+// describe the varied horizontal and vertical metrics of the glyph.
+// All coordinates are expressed in font units, and shifted so that the
+// left phantom point is at x = 0, as done by rasterizers.
+// `ok` is false if `gid` is not in the 'glyf' table.
+func (f *Font) GlyphPoints(gid GID) (points []GlyphPoint, phantoms [4]fonts.SegmentPoint, ok bool) {
+	if int(gid) >= len(f.Glyf) {
+		return nil, phantoms, false
+	}
+	var allPoints []contourPoint
+	f.getPointsForGlyph(gid, 0, &allPoints)
+
+	L := len(allPoints) - phantomCount
+	points = make([]GlyphPoint, L)
+	for i, p := range allPoints[:L] {
+		points[i] = GlyphPoint{SegmentPoint: p.SegmentPoint, OnCurve: p.isOnCurve, EndOfContour: p.isEndPoint}
+	}
+	for i, p := range allPoints[L:] {
+		phantoms[i] = p.SegmentPoint
+	}
+	return points, phantoms, true
+}
+
 func clamp(v float32) float32 {
 	if v < 0 {
 		v = 0
@@ -347,13 +394,31 @@ func (f *Font) getGlyphAdvanceVar(gid GID, isVertical bool) float32 {
 
 func (f *Font) HorizontalAdvance(gid GID) float32 {
 	advance := f.getBaseAdvance(gid, f.Hmtx)
+	bold := f.boldBitmapAdvance(gid)
 	if !f.isVar() {
-		return float32(advance)
+		return float32(advance) + bold
 	}
 	if f.hvar != nil {
-		return float32(advance) + f.hvar.getAdvanceVar(gid, f.varCoords)
+		return float32(advance) + f.hvar.getAdvanceVar(gid, f.varCoords) + bold
+	}
+	return f.getGlyphAdvanceVar(gid, false) + bold
+}
+
+// boldBitmapAdvance returns the advance (in font units) added by the synthetic bold to
+// bitmap glyphs, which is one pixel of the largest strike, or 0 if the glyph is not in it.
+func (f *Font) boldBitmapAdvance(gid GID) float32 {
+	if !f.bitmapRendering.Bold {
+		return 0
+	}
+	if strike := f.sbix.chooseStrike(0, 0); strike != nil && strike.ppem != 0 && !strike.getGlyph(gid, 0).isNil() {
+		return float32(f.upem) / float32(strike.ppem)
+	}
+	if strike := f.bitmap.chooseStrike(0, 0); strike != nil && strike.ppemX != 0 {
+		if subtable := strike.findTable(gid); subtable != nil && subtable.getImage(gid) != nil {
+			return float32(f.upem) / float32(strike.ppemX)
+		}
 	}
-	return f.getGlyphAdvanceVar(gid, false)
+	return 0
 }
 
 // return `true` is the font is variable and `varCoords` is valid
@@ -457,11 +522,12 @@ func (f *Font) getExtentsFromCBDT(glyph GID, xPpem, yPpem uint16) (fonts.GlyphEx
 	if image == nil {
 		return fonts.GlyphExtents{}, false
 	}
-	extents := image.metrics.glyphExtents()
+	xPixelScale, yPixelScale := f.bitmapRendering.ScaleFactors(strike.ppemX, strike.ppemY, xPpem, yPpem)
+	extents := f.bitmapRendering.Extents(image.metrics.glyphExtents(), xPixelScale, yPixelScale)
 
 	/* convert to font units. */
-	xScale := float32(f.upem) / float32(strike.ppemX)
-	yScale := float32(f.upem) / float32(strike.ppemY)
+	xScale := float32(f.upem) / (float32(strike.ppemX) * xPixelScale)
+	yScale := float32(f.upem) / (float32(strike.ppemY) * yPixelScale)
 	extents.XBearing *= xScale
 	extents.YBearing *= yScale
 	extents.Width *= xScale
@@ -479,13 +545,16 @@ func (f *Font) getExtentsFromSbix(glyph GID, xPpem, yPpem uint16) (fonts.GlyphEx
 		return fonts.GlyphExtents{}, false
 	}
 	extents, ok := data.glyphExtents()
+	xPixelScale, yPixelScale := f.bitmapRendering.ScaleFactors(strike.ppem, strike.ppem, xPpem, yPpem)
+	extents = f.bitmapRendering.Extents(extents, xPixelScale, yPixelScale)
 
 	/* convert to font units. */
-	scale := float32(f.upem) / float32(strike.ppem)
-	extents.XBearing *= scale
-	extents.YBearing *= scale
-	extents.Width *= scale
-	extents.Height *= scale
+	xScale := float32(f.upem) / (float32(strike.ppem) * xPixelScale)
+	yScale := float32(f.upem) / (float32(strike.ppem) * yPixelScale)
+	extents.XBearing *= xScale
+	extents.YBearing *= yScale
+	extents.Width *= xScale
+	extents.Height *= yScale
 	return extents, ok
 }
 
@@ -500,8 +569,16 @@ func (f *Font) getExtentsFromCff1(glyph GID) (fonts.GlyphExtents, bool) {
 	return bounds.ToExtents(), true
 }
 
-// func (f *fontMetrics) getExtentsFromCff2(glyph , coords []float32) (fonts.GlyphExtents, bool) {
-// }
+func (f *Font) getExtentsFromCff2(glyph GID) (fonts.GlyphExtents, bool) {
+	if f.cff2 == nil {
+		return fonts.GlyphExtents{}, false
+	}
+	_, bounds, err := f.cff2.loadGlyph(glyph, f.varCoords)
+	if err != nil {
+		return fonts.GlyphExtents{}, false
+	}
+	return bounds.ToExtents(), true
+}
 
 func (f *Font) GlyphExtents(glyph GID, xPpem, yPpem uint16) (fonts.GlyphExtents, bool) {
 	out, ok := f.getExtentsFromSbix(glyph, xPpem, yPpem)
@@ -516,6 +593,10 @@ func (f *Font) GlyphExtents(glyph GID, xPpem, yPpem uint16) (fonts.GlyphExtents,
 	if ok {
 		return out, ok
 	}
+	out, ok = f.getExtentsFromCff2(glyph)
+	if ok {
+		return out, ok
+	}
 	out, ok = f.getExtentsFromCBDT(glyph, xPpem, yPpem)
 	return out, ok
 }
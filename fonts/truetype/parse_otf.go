@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/benoitkugler/textlayout/fonts"
 )
@@ -85,9 +86,11 @@ func parseOTF(file fonts.Resource, offset uint32, relativeOffset bool) (*FontPar
 	}
 
 	fontParser := &FontParser{
-		file:   file,
-		tables: make(map[Tag]tableSection, header.NumTables),
-		Type:   header.ScalerType,
+		file:       file,
+		tables:     make(map[Tag]tableSection, header.NumTables),
+		Type:       header.ScalerType,
+		directory:  tableSection{offset: offset, length: otfHeaderLength + directoryEntryLength*uint32(header.NumTables)},
+		standalone: offset == 0,
 	}
 
 	for i := 0; i < int(header.NumTables); i++ {
@@ -96,16 +99,18 @@ func parseOTF(file fonts.Resource, offset uint32, relativeOffset bool) (*FontPar
 			return nil, err
 		}
 
-		// TODO Check the checksum.
+		// the checksums are only verified by Validate
 
 		if _, found := fontParser.tables[entry.Tag]; found {
 			// ignore duplicate tables – the first one wins
+			fontParser.duplicates = append(fontParser.duplicates, entry.Tag)
 			continue
 		}
 
 		sec := tableSection{
-			offset: entry.Offset,
-			length: entry.Length,
+			offset:   entry.Offset,
+			length:   entry.Length,
+			checksum: entry.CheckSum,
 		}
 		// adapt the relative offsets
 		if relativeOffset {
@@ -119,3 +124,111 @@ func parseOTF(file fonts.Resource, offset uint32, relativeOffset bool) (*FontPar
 
 	return fontParser, nil
 }
+
+func (header otfHeader) writeTo(data []byte) {
+	_ = data[11] // early bound checking
+	binary.BigEndian.PutUint32(data, uint32(header.ScalerType))
+	binary.BigEndian.PutUint16(data[4:], header.NumTables)
+	binary.BigEndian.PutUint16(data[6:], header.SearchRange)
+	binary.BigEndian.PutUint16(data[8:], header.EntrySelector)
+	binary.BigEndian.PutUint16(data[10:], header.RangeShift)
+}
+
+func (entry directoryEntry) writeTo(data []byte) {
+	_ = data[15] // early bound checking
+	binary.BigEndian.PutUint32(data, uint32(entry.Tag))
+	binary.BigEndian.PutUint32(data[4:], entry.CheckSum)
+	binary.BigEndian.PutUint32(data[8:], entry.Offset)
+	binary.BigEndian.PutUint32(data[12:], entry.Length)
+}
+
+// binarySearchParams returns the searchRange, entrySelector and rangeShift
+// fields of a table directory with `numTables` entries.
+func binarySearchParams(numTables uint16) (searchRange, entrySelector, rangeShift uint16) {
+	entrySelector = 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange = (1 << entrySelector) * directoryEntryLength
+	rangeShift = numTables*directoryEntryLength - searchRange
+	return searchRange, entrySelector, rangeShift
+}
+
+// tableChecksum returns the sum of the content of `table`,
+// interpreted as uint32 values, padded with zeros.
+func tableChecksum(table []byte) uint32 {
+	var sum uint32
+	for len(table) >= 4 {
+		sum += binary.BigEndian.Uint32(table)
+		table = table[4:]
+	}
+	if len(table) != 0 {
+		var last [4]byte
+		copy(last[:], table)
+		sum += binary.BigEndian.Uint32(last[:])
+	}
+	return sum
+}
+
+// writeOTF assembles `tables` into an OpenType file, with the given `scalerType`.
+// The 'head' table, if present, is updated with the checksum adjustment.
+func writeOTF(scalerType Tag, tables map[Tag][]byte) []byte {
+	// the table data is layed out in the order of the directory,
+	// so that the output is deterministic
+	tags := make([]Tag, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	offset := uint32(otfHeaderLength + directoryEntryLength*len(tables))
+	size := offset
+	for _, table := range tables {
+		size += (uint32(len(table)) + 3) &^ 3 // padding
+	}
+	out := make([]byte, size)
+
+	entries := make([]directoryEntry, 0, len(tables))
+	headOffset := -1
+	for _, tag := range tags {
+		table := tables[tag]
+		copy(out[offset:], table)
+		if tag == tagHead && len(table) >= 12 {
+			headOffset = int(offset)
+			binary.BigEndian.PutUint32(out[offset+8:], 0)
+		}
+		entries = append(entries, directoryEntry{
+			Tag:      tag,
+			CheckSum: tableChecksum(out[offset : offset+uint32(len(table))]),
+			Offset:   offset,
+			Length:   uint32(len(table)),
+		})
+		offset += (uint32(len(table)) + 3) &^ 3
+	}
+
+	fontChecksum := writeTableDirectory(out, scalerType, entries)
+	if headOffset != -1 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-fontChecksum)
+	}
+	return out
+}
+
+// writeTableDirectory sorts `entries` by tag, and writes the offset table
+// followed by the table directory at the start of `out`.
+// It returns the checksum of the whole font, computed from the offset table,
+// the directory entries and the table checksums stored in `entries`.
+func writeTableDirectory(out []byte, scalerType Tag, entries []directoryEntry) uint32 {
+	// the table directory must be sorted by tag
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Tag < entries[j].Tag })
+
+	header := otfHeader{ScalerType: scalerType, NumTables: uint16(len(entries))}
+	header.SearchRange, header.EntrySelector, header.RangeShift = binarySearchParams(header.NumTables)
+	header.writeTo(out)
+
+	fontChecksum := header.checkSum()
+	for i, entry := range entries {
+		entry.writeTo(out[otfHeaderLength+directoryEntryLength*i:])
+		fontChecksum += entry.checkSum() + entry.CheckSum
+	}
+	return fontChecksum
+}
@@ -91,9 +91,10 @@ func parseWOFF(file fonts.Resource, offset uint32, relativeOffset bool) (*FontPa
 	}
 
 	fontParser := &FontParser{
-		file:   file,
-		tables: make(map[Tag]tableSection, header.NumTables),
-		Type:   header.Flavor,
+		file:      file,
+		tables:    make(map[Tag]tableSection, header.NumTables),
+		Type:      header.Flavor,
+		directory: tableSection{offset: offset, length: woffHeaderSize + woffEntrySize*uint32(header.NumTables)},
 	}
 	for i := 0; i < int(header.NumTables); i++ {
 		entry, err := readWOFFEntry(file)
@@ -101,17 +102,19 @@ func parseWOFF(file fonts.Resource, offset uint32, relativeOffset bool) (*FontPa
 			return nil, err
 		}
 
-		// TODO Check the checksum.
+		// the checksums are only verified by Validate
 
 		if _, found := fontParser.tables[entry.Tag]; found {
 			// ignore duplicate tables – the first one wins
+			fontParser.duplicates = append(fontParser.duplicates, entry.Tag)
 			continue
 		}
 
 		sec := tableSection{
-			offset:  entry.Offset,
-			length:  entry.CompLength,
-			zLength: entry.OrigLength,
+			offset:   entry.Offset,
+			length:   entry.CompLength,
+			zLength:  entry.OrigLength,
+			checksum: entry.OrigChecksum,
 		}
 		// adapt the relative offsets
 		if relativeOffset {
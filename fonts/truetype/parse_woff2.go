@@ -0,0 +1,976 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// WOFF2 files store their tables as one Brotli compressed stream,
+// with optional transformations applied to the 'glyf', 'loca' and 'hmtx' tables.
+// Since tables can't be accessed independently, the whole font (or collection)
+// is decompressed and rebuilt in memory as a regular SFNT file, which is
+// then parsed as usual.
+// See https://www.w3.org/TR/WOFF2/
+
+type woff2Header struct {
+	Signature           Tag
+	Flavor              Tag
+	Length              uint32
+	NumTables           uint16
+	Reserved            uint16
+	TotalSfntSize       uint32
+	TotalCompressedSize uint32
+	MajorVersion        uint16
+	MinorVersion        uint16
+	MetaOffset          uint32
+	MetaLength          uint32
+	MetaOrigLength      uint32
+	PrivOffset          uint32
+	PrivLength          uint32
+}
+
+const (
+	woff2HeaderSize = 48
+
+	// security implementation limit, since the
+	// decompressed size is controlled by the font file
+	woff2MaxSfntSize = 1 << 30
+)
+
+// woff2KnownTags is the list of the tags which may be referenced
+// with a 6-bit index in the table directory.
+var woff2KnownTags = [63]Tag{
+	tagCmap, tagHead, tagHhea, tagHmtx, tagMaxp, tagName, tagOS2, tagPost,
+	MustNewTag("cvt "), MustNewTag("fpgm"), tagGlyf, tagLoca, TagPrep, tagCFF, tagVorg, tagEBDT,
+	tagEBLC, MustNewTag("gasp"), MustNewTag("hdmx"), tagKern, MustNewTag("LTSH"), MustNewTag("PCLT"), MustNewTag("VDMX"), tagVhea,
+	tagVmtx, MustNewTag("BASE"), TagGdef, TagGpos, TagGsub, MustNewTag("EBSC"), MustNewTag("JSTF"), MustNewTag("MATH"),
+	tagCBDT, tagCBLC, tagCOLR, tagCPAL, tagSVG, tagSbix, MustNewTag("acnt"), tagAvar,
+	tagBdat, tagBloc, MustNewTag("bsln"), MustNewTag("cvar"), MustNewTag("fdsc"), tagFeat, MustNewTag("fmtx"), tagFvar,
+	tagGvar, MustNewTag("hsty"), MustNewTag("just"), tagLcar, tagMort, tagMorx, tagOpbd, tagProp,
+	tagTrak, MustNewTag("Zapf"), tagSilf, tagGlat, tagGloc, tagGraphiteFeat, tagSill,
+}
+
+type woff2Entry struct {
+	Tag             Tag
+	transformed     bool
+	OrigLength      uint32
+	TransformLength uint32 // only valid if transformed is true
+
+	data []byte // content of the (transformed) table in the decompressed stream
+}
+
+// length of the entry in the decompressed stream
+func (entry woff2Entry) streamLength() uint32 {
+	if entry.transformed {
+		return entry.TransformLength
+	}
+	return entry.OrigLength
+}
+
+func readWOFF2Header(r io.Reader) (woff2Header, error) {
+	var (
+		buf    [woff2HeaderSize]byte
+		header woff2Header
+	)
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return header, fmt.Errorf("invalid WOFF2 header: %s", err)
+	}
+
+	header.Signature = newTag(buf[0:4])
+	header.Flavor = newTag(buf[4:8])
+	header.Length = binary.BigEndian.Uint32(buf[8:12])
+	header.NumTables = binary.BigEndian.Uint16(buf[12:14])
+	header.Reserved = binary.BigEndian.Uint16(buf[14:16])
+	header.TotalSfntSize = binary.BigEndian.Uint32(buf[16:20])
+	header.TotalCompressedSize = binary.BigEndian.Uint32(buf[20:24])
+	header.MajorVersion = binary.BigEndian.Uint16(buf[24:26])
+	header.MinorVersion = binary.BigEndian.Uint16(buf[26:28])
+	header.MetaOffset = binary.BigEndian.Uint32(buf[28:32])
+	header.MetaLength = binary.BigEndian.Uint32(buf[32:36])
+	header.MetaOrigLength = binary.BigEndian.Uint32(buf[36:40])
+	header.PrivOffset = binary.BigEndian.Uint32(buf[40:44])
+	header.PrivLength = binary.BigEndian.Uint32(buf[44:48])
+	return header, nil
+}
+
+// readUintBase128 reads a variable-length encoding of a 32-bit unsigned integer.
+func readUintBase128(r io.ByteReader) (uint32, error) {
+	var accum uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errors.New("invalid UIntBase128 (EOF)")
+		}
+		// leading zeros are invalid
+		if i == 0 && b == 0x80 {
+			return 0, errors.New("invalid UIntBase128 (leading zeros)")
+		}
+		// if any of the top seven bits are set then we're about to overflow
+		if accum&0xFE000000 != 0 {
+			return 0, errors.New("invalid UIntBase128 (overflow)")
+		}
+		accum = accum<<7 | uint32(b&0x7F)
+		// spin until the most significant bit of data byte is false
+		if b&0x80 == 0 {
+			return accum, nil
+		}
+	}
+	return 0, errors.New("invalid UIntBase128 (exceeds 5 bytes)")
+}
+
+// read255Uint16 reads a variable-length encoding of a 16-bit unsigned integer.
+func read255Uint16(r io.ByteReader) (uint16, error) {
+	const (
+		oneMoreByteCode1 = 255
+		oneMoreByteCode2 = 254
+		wordCode         = 253
+		lowestUCode      = 253
+	)
+	code, err := r.ReadByte()
+	if err != nil {
+		return 0, errors.New("invalid 255UInt16 (EOF)")
+	}
+	switch code {
+	case wordCode:
+		b1, err1 := r.ReadByte()
+		b2, err2 := r.ReadByte()
+		if err1 != nil || err2 != nil {
+			return 0, errors.New("invalid 255UInt16 (EOF)")
+		}
+		return uint16(b1)<<8 | uint16(b2), nil
+	case oneMoreByteCode1:
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errors.New("invalid 255UInt16 (EOF)")
+		}
+		return uint16(b) + lowestUCode, nil
+	case oneMoreByteCode2:
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errors.New("invalid 255UInt16 (EOF)")
+		}
+		return uint16(b) + lowestUCode*2, nil
+	default:
+		return uint16(code), nil
+	}
+}
+
+func readWOFF2Entry(r *bytes.Reader) (woff2Entry, error) {
+	var entry woff2Entry
+	flags, err := r.ReadByte()
+	if err != nil {
+		return entry, errors.New("invalid WOFF2 table directory (EOF)")
+	}
+	if tagIndex := flags & 0x3F; tagIndex == 0x3F {
+		var buf [4]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return entry, errors.New("invalid WOFF2 table directory (EOF)")
+		}
+		entry.Tag = newTag(buf[:])
+	} else {
+		entry.Tag = woff2KnownTags[tagIndex]
+	}
+
+	entry.OrigLength, err = readUintBase128(r)
+	if err != nil {
+		return entry, err
+	}
+
+	// for glyf and loca, the null transform is 3 (and 0 is the actual transform),
+	// whereas for the other tables, it is 0.
+	transformVersion := flags >> 6
+	if entry.Tag == tagGlyf || entry.Tag == tagLoca {
+		entry.transformed = transformVersion != 3
+	} else {
+		entry.transformed = transformVersion != 0
+	}
+
+	if entry.transformed {
+		entry.TransformLength, err = readUintBase128(r)
+		if err != nil {
+			return entry, err
+		}
+		if entry.Tag == tagLoca && entry.TransformLength != 0 {
+			return entry, errors.New("invalid WOFF2 transformed 'loca' table length")
+		}
+	}
+
+	return entry, nil
+}
+
+// woff2Font lists the indices into the global table directory
+// of the tables of one font
+type woff2Font struct {
+	flavor Tag
+	tables []uint16
+}
+
+// the returned slice has length 1 for simple fonts
+func readWOFF2CollectionDirectory(r *bytes.Reader, header woff2Header) ([]woff2Font, error) {
+	if header.Flavor != ttcTag { // simple font using all the tables
+		font := woff2Font{flavor: header.Flavor, tables: make([]uint16, header.NumTables)}
+		for i := range font.tables {
+			font.tables[i] = uint16(i)
+		}
+		return []woff2Font{font}, nil
+	}
+
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil { // version is ignored
+		return nil, errors.New("invalid WOFF2 collection directory (EOF)")
+	}
+	numFonts, err := read255Uint16(r)
+	if err != nil {
+		return nil, err
+	}
+	if numFonts == 0 {
+		return nil, errors.New("empty font collection")
+	}
+	if numFonts > maxNumFonts {
+		return nil, fmt.Errorf("number of fonts (%d) in collection exceed implementation limit (%d)",
+			numFonts, maxNumFonts)
+	}
+
+	out := make([]woff2Font, numFonts)
+	for i := range out {
+		numTables, err := read255Uint16(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, errors.New("invalid WOFF2 collection directory (EOF)")
+		}
+		out[i].flavor = newTag(buf[:])
+		out[i].tables = make([]uint16, numTables)
+		for j := range out[i].tables {
+			index, err := read255Uint16(r)
+			if err != nil {
+				return nil, err
+			}
+			if index >= header.NumTables {
+				return nil, fmt.Errorf("invalid WOFF2 collection table index: %d", index)
+			}
+			out[i].tables[j] = index
+		}
+	}
+	return out, nil
+}
+
+// parseWOFF2 decompresses and rebuilds the font(s) stored in a WOFF2 file,
+// returning one parser for each font.
+// `offset` is the beginning of the ressource in the file.
+func parseWOFF2(file fonts.Resource, offset uint32) ([]*FontParser, error) {
+	_, err := file.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid offset: %s", err)
+	}
+
+	header, err := readWOFF2Header(file)
+	if err != nil {
+		return nil, err
+	}
+	if header.NumTables == 0 {
+		return nil, errors.New("invalid WOFF2 file: no tables")
+	}
+	if header.TotalSfntSize > woff2MaxSfntSize {
+		return nil, fmt.Errorf("invalid WOFF2 file: total size %d exceeds implementation limit", header.TotalSfntSize)
+	}
+
+	// the table directories have variable length, so we load
+	// them and the compressed data at once, after checking the declared length
+	fileSize, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 file: %s", err)
+	}
+	if header.Length < woff2HeaderSize || int64(header.Length) > fileSize-int64(offset) {
+		return nil, fmt.Errorf("invalid WOFF2 file length %d", header.Length)
+	}
+	if header.Length > woff2MaxSfntSize {
+		return nil, fmt.Errorf("invalid WOFF2 file: length %d exceeds implementation limit", header.Length)
+	}
+	rest := make([]byte, header.Length-woff2HeaderSize)
+	if _, err := file.ReadAt(rest, int64(offset)+woff2HeaderSize); err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 file: %s", err)
+	}
+	r := bytes.NewReader(rest)
+
+	entries := make([]woff2Entry, header.NumTables)
+	var totalLength uint64
+	for i := range entries {
+		entries[i], err = readWOFF2Entry(r)
+		if err != nil {
+			return nil, err
+		}
+		totalLength += uint64(entries[i].streamLength())
+	}
+	if totalLength > woff2MaxSfntSize {
+		return nil, fmt.Errorf("invalid WOFF2 file: uncompressed size %d exceeds implementation limit", totalLength)
+	}
+
+	fontsDir, err := readWOFF2CollectionDirectory(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	// the compressed stream starts right after the directories
+	start := len(rest) - r.Len()
+	if len(rest) < start+int(header.TotalCompressedSize) {
+		return nil, errors.New("invalid WOFF2 compressed data (EOF)")
+	}
+	stream := brotli.NewReader(bytes.NewReader(rest[start : start+int(header.TotalCompressedSize)]))
+	decompressed := make([]byte, totalLength)
+	if _, err := io.ReadFull(stream, decompressed); err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 compressed data: %s", err)
+	}
+
+	for i := range entries {
+		L := entries[i].streamLength()
+		entries[i].data, decompressed = decompressed[:L], decompressed[L:]
+	}
+
+	sfnt, err := rebuildWOFF2(entries, fontsDir, header.Flavor == ttcTag)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Flavor != ttcTag {
+		pr, err := parseOTF(sfnt, 0, false)
+		if err != nil {
+			return nil, err
+		}
+		return []*FontParser{pr}, nil
+	}
+
+	offsets, err := parseTTCHeader(sfnt)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*FontParser, len(offsets))
+	for i, o := range offsets {
+		out[i], err = parseOTF(sfnt, o, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// rebuildWOFF2 reverses the table transformations and assembles
+// a regular SFNT file (or a TTC collection if `isCollection` is true).
+func rebuildWOFF2(entries []woff2Entry, fontsDir []woff2Font, isCollection bool) (*bytes.Reader, error) {
+	// the reconstructed content for each entry
+	tables := make([][]byte, len(entries))
+	for i, entry := range entries {
+		if !entry.transformed {
+			tables[i] = entry.data
+		}
+	}
+
+	// transformed tables depend on other tables of the same font
+	for _, font := range fontsDir {
+		if err := reverseWOFF2Transforms(entries, tables, font); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isCollection {
+		byTag := make(map[Tag][]byte, len(entries))
+		for i, entry := range entries {
+			byTag[entry.Tag] = tables[i]
+		}
+		return bytes.NewReader(writeOTF(fontsDir[0].flavor, byTag)), nil
+	}
+
+	// in collections, tables may be shared between fonts, so that
+	// the checkSumAdjustment of the 'head' tables is not updated
+	const ttcHeaderLength = 12 + 12                         // version 2.0, without DSIG
+	sfntOffset := ttcHeaderLength + 4*uint32(len(fontsDir)) // start of the first offset table
+
+	// compute the offsets of the tables data, after all the directories
+	tableDataOffset := sfntOffset
+	for _, font := range fontsDir {
+		tableDataOffset += otfHeaderLength + directoryEntryLength*uint32(len(font.tables))
+	}
+	tableOffsets := make([]uint32, len(entries))
+	for i, table := range tables {
+		tableOffsets[i] = tableDataOffset
+		tableDataOffset += (uint32(len(table)) + 3) &^ 3 // padding
+	}
+
+	out := make([]byte, tableDataOffset)
+	checksums := make([]uint32, len(entries))
+	for i, table := range tables {
+		copy(out[tableOffsets[i]:], table)
+		checksums[i] = tableChecksum(table)
+	}
+
+	binary.BigEndian.PutUint32(out, uint32(ttcTag))
+	binary.BigEndian.PutUint32(out[4:], 0x00020000)
+	binary.BigEndian.PutUint32(out[8:], uint32(len(fontsDir)))
+
+	currentOffset := sfntOffset
+	for i, font := range fontsDir {
+		binary.BigEndian.PutUint32(out[12+4*i:], currentOffset)
+
+		directory := make([]directoryEntry, len(font.tables))
+		for j, tableIndex := range font.tables {
+			directory[j] = directoryEntry{
+				Tag:      entries[tableIndex].Tag,
+				CheckSum: checksums[tableIndex],
+				Offset:   tableOffsets[tableIndex],
+				Length:   uint32(len(tables[tableIndex])),
+			}
+		}
+		writeTableDirectory(out[currentOffset:], font.flavor, directory)
+
+		currentOffset += otfHeaderLength + directoryEntryLength*uint32(len(directory))
+	}
+
+	return bytes.NewReader(out), nil
+}
+
+// reverseWOFF2Transforms fills `tables` with the reconstructed 'glyf', 'loca' and 'hmtx' tables of `font`,
+// if not already done.
+func reverseWOFF2Transforms(entries []woff2Entry, tables [][]byte, font woff2Font) error {
+	var (
+		glyfIndex, locaIndex, hmtxIndex, headIndex, hheaIndex, maxpIndex = -1, -1, -1, -1, -1, -1
+	)
+	for _, tableIndex := range font.tables {
+		switch entries[tableIndex].Tag {
+		case tagGlyf:
+			glyfIndex = int(tableIndex)
+		case tagLoca:
+			locaIndex = int(tableIndex)
+		case tagHmtx:
+			hmtxIndex = int(tableIndex)
+		case tagHead:
+			headIndex = int(tableIndex)
+		case tagHhea:
+			hheaIndex = int(tableIndex)
+		case tagMaxp:
+			maxpIndex = int(tableIndex)
+		}
+	}
+
+	if glyfIndex != -1 && entries[glyfIndex].transformed && tables[glyfIndex] == nil {
+		if locaIndex == -1 || !entries[locaIndex].transformed {
+			return errors.New("invalid WOFF2 file: transformed 'glyf' table without transformed 'loca' table")
+		}
+		glyf, loca, indexFormat, err := reconstructGlyfLoca(entries[glyfIndex].data)
+		if err != nil {
+			return err
+		}
+		if uint32(len(loca)) != entries[locaIndex].OrigLength {
+			return errors.New("invalid WOFF2 reconstructed 'loca' table length")
+		}
+		tables[glyfIndex], tables[locaIndex] = glyf, loca
+
+		// the head table must be consistent with the loca format
+		if headIndex != -1 && len(tables[headIndex]) >= 52 {
+			binary.BigEndian.PutUint16(tables[headIndex][50:], indexFormat)
+		}
+	} else if locaIndex != -1 && entries[locaIndex].transformed && tables[locaIndex] == nil {
+		return errors.New("invalid WOFF2 file: transformed 'loca' table without transformed 'glyf' table")
+	}
+
+	if hmtxIndex != -1 && entries[hmtxIndex].transformed && tables[hmtxIndex] == nil {
+		if glyfIndex == -1 || locaIndex == -1 || hheaIndex == -1 || maxpIndex == -1 || headIndex == -1 {
+			return errors.New("invalid WOFF2 file: missing tables for transformed 'hmtx' table")
+		}
+		maxp, err := parseTableMaxp(tables[maxpIndex])
+		if err != nil {
+			return err
+		}
+		numGlyphs := int(maxp.NumGlyphs)
+		hhea, err := parseTableHVhea(tables[hheaIndex])
+		if err != nil {
+			return err
+		}
+		head, err := parseTableHead(tables[headIndex])
+		if err != nil {
+			return err
+		}
+		loca, err := parseTableLoca(tables[locaIndex], numGlyphs, head.indexToLocFormat == 1)
+		if err != nil {
+			return err
+		}
+		tables[hmtxIndex], err = reconstructHmtx(entries[hmtxIndex].data, tables[glyfIndex], loca,
+			int(hhea.numOfLongMetrics), numGlyphs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tableIndex := range font.tables {
+		if entries[tableIndex].transformed && tables[tableIndex] == nil {
+			return fmt.Errorf("unsupported WOFF2 transformation for table %s", entries[tableIndex].Tag)
+		}
+	}
+	return nil
+}
+
+// the transformed glyf table is made of several streams,
+// whose sizes are given in its header
+type woff2GlyfStreams struct {
+	nContour, nPoints, flag, glyph, composite, bbox, instruction *bytes.Reader
+
+	bboxBitmap          []byte
+	overlapSimpleBitmap []byte // optional
+	numGlyphs           int
+	indexFormat         uint16
+}
+
+func parseWOFF2GlyfStreams(data []byte) (out woff2GlyfStreams, err error) {
+	const headerSize = 36
+	if len(data) < headerSize {
+		return out, errors.New("invalid WOFF2 transformed 'glyf' table (EOF)")
+	}
+	optionFlags := binary.BigEndian.Uint16(data[2:])
+	out.numGlyphs = int(binary.BigEndian.Uint16(data[4:]))
+	out.indexFormat = binary.BigEndian.Uint16(data[6:])
+
+	var sizes [7]uint32
+	for i := range sizes {
+		sizes[i] = binary.BigEndian.Uint32(data[8+4*i:])
+	}
+	data = data[headerSize:]
+
+	var streams [7][]byte
+	for i, size := range sizes {
+		if uint32(len(data)) < size {
+			return out, errors.New("invalid WOFF2 transformed 'glyf' table (EOF)")
+		}
+		streams[i], data = data[:size], data[size:]
+	}
+
+	const hasOverlapSimpleBitmap = 1
+	if optionFlags&hasOverlapSimpleBitmap != 0 {
+		L := (out.numGlyphs + 7) >> 3
+		if len(data) < L {
+			return out, errors.New("invalid WOFF2 transformed 'glyf' table (EOF)")
+		}
+		out.overlapSimpleBitmap = data[:L]
+	}
+
+	bboxStream := streams[5]
+	bitmapLength := ((out.numGlyphs + 31) >> 5) << 2
+	if len(bboxStream) < bitmapLength {
+		return out, errors.New("invalid WOFF2 transformed 'glyf' table (EOF)")
+	}
+	out.bboxBitmap = bboxStream[:bitmapLength]
+
+	out.nContour = bytes.NewReader(streams[0])
+	out.nPoints = bytes.NewReader(streams[1])
+	out.flag = bytes.NewReader(streams[2])
+	out.glyph = bytes.NewReader(streams[3])
+	out.composite = bytes.NewReader(streams[4])
+	out.bbox = bytes.NewReader(bboxStream[bitmapLength:])
+	out.instruction = bytes.NewReader(streams[6])
+	return out, nil
+}
+
+func readInt16(r io.Reader) (int16, error) {
+	var buf [2]byte
+	_, err := io.ReadFull(r, buf[:])
+	return int16(binary.BigEndian.Uint16(buf[:])), err
+}
+
+func hasBit(bitmap []byte, index int) bool {
+	return bitmap[index>>3]&(0x80>>(index&7)) != 0
+}
+
+// reconstructGlyfLoca reverses the 'glyf' transform, returning the
+// 'glyf' and 'loca' tables, and the index format of the 'loca' table.
+func reconstructGlyfLoca(data []byte) (glyf, loca []byte, indexFormat uint16, err error) {
+	streams, err := parseWOFF2GlyfStreams(data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	offsets := make([]uint32, streams.numGlyphs+1)
+	for i := 0; i < streams.numGlyphs; i++ {
+		offsets[i] = uint32(len(glyf))
+
+		glyf, err = streams.appendGlyph(glyf, i)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("invalid WOFF2 transformed glyph %d: %s", i, err)
+		}
+		// the glyphs data are 4-byte aligned
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+	offsets[streams.numGlyphs] = uint32(len(glyf))
+
+	if streams.indexFormat == 0 {
+		if len(glyf) >= 1<<17 {
+			return nil, nil, 0, errors.New("invalid WOFF2 transformed 'glyf' table: too large for short 'loca' format")
+		}
+		loca = make([]byte, 2*len(offsets))
+		for i, o := range offsets {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(o>>1))
+		}
+	} else {
+		loca = make([]byte, 4*len(offsets))
+		for i, o := range offsets {
+			binary.BigEndian.PutUint32(loca[4*i:], o)
+		}
+	}
+
+	return glyf, loca, streams.indexFormat, nil
+}
+
+// decode the glyph `index` and append it in standard format to `glyf`
+func (streams *woff2GlyfStreams) appendGlyph(glyf []byte, index int) ([]byte, error) {
+	nContours, err := readInt16(streams.nContour)
+	if err != nil {
+		return nil, errors.New("EOF in contours stream")
+	}
+	hasBbox := hasBit(streams.bboxBitmap, index)
+
+	var bbox [4]int16
+	if hasBbox {
+		for i := range bbox {
+			bbox[i], err = readInt16(streams.bbox)
+			if err != nil {
+				return nil, errors.New("EOF in bbox stream")
+			}
+		}
+	}
+
+	switch {
+	case nContours == 0: // empty glyph
+		if hasBbox {
+			return nil, errors.New("empty glyph with explicit bounding box")
+		}
+		return glyf, nil
+	case nContours == -1: // composite glyph
+		if !hasBbox {
+			return nil, errors.New("composite glyph without explicit bounding box")
+		}
+		glyf = appendGlyphHeader(glyf, nContours, bbox)
+		haveInstructions, err := streams.appendCompositeData(&glyf)
+		if err != nil {
+			return nil, err
+		}
+		if haveInstructions {
+			return streams.appendInstructions(glyf)
+		}
+		return glyf, nil
+	case nContours < 0:
+		return nil, fmt.Errorf("invalid number of contours %d", nContours)
+	default: // simple glyph
+		return streams.appendSimpleGlyph(glyf, index, nContours, hasBbox, bbox)
+	}
+}
+
+func appendGlyphHeader(glyf []byte, nContours int16, bbox [4]int16) []byte {
+	var buf [10]byte
+	binary.BigEndian.PutUint16(buf[:], uint16(nContours))
+	for i, v := range bbox {
+		binary.BigEndian.PutUint16(buf[2+2*i:], uint16(v))
+	}
+	return append(glyf, buf[:]...)
+}
+
+// read the instruction length from the glyph stream and the
+// instructions from the instruction stream
+func (streams *woff2GlyfStreams) appendInstructions(glyf []byte) ([]byte, error) {
+	instructionLength, err := read255Uint16(streams.glyph)
+	if err != nil {
+		return nil, err
+	}
+	if streams.instruction.Len() < int(instructionLength) {
+		return nil, errors.New("EOF in instructions stream")
+	}
+	glyf = append(glyf, byte(instructionLength>>8), byte(instructionLength))
+	L := len(glyf)
+	glyf = append(glyf, make([]byte, instructionLength)...)
+	streams.instruction.Read(glyf[L:]) // length checked above
+	return glyf, nil
+}
+
+// copy the components, returning true if the glyph has instructions
+func (streams *woff2GlyfStreams) appendCompositeData(glyf *[]byte) (bool, error) {
+	const (
+		arg1And2AreWords   = 1 << 0
+		weHaveAScale       = 1 << 3
+		moreComponents     = 1 << 5
+		weHaveAnXAndYScale = 1 << 6
+		weHaveATwoByTwo    = 1 << 7
+		weHaveInstructions = 1 << 8
+	)
+	haveInstructions := false
+	for do := true; do; {
+		flagsI, err := readInt16(streams.composite)
+		if err != nil {
+			return false, errors.New("EOF in composite stream")
+		}
+		flags := uint16(flagsI)
+		haveInstructions = haveInstructions || flags&weHaveInstructions != 0
+
+		argSize := 2 // glyph index
+		if flags&arg1And2AreWords != 0 {
+			argSize += 4
+		} else {
+			argSize += 2
+		}
+		if flags&weHaveAScale != 0 {
+			argSize += 2
+		} else if flags&weHaveAnXAndYScale != 0 {
+			argSize += 4
+		} else if flags&weHaveATwoByTwo != 0 {
+			argSize += 8
+		}
+
+		if streams.composite.Len() < argSize {
+			return false, errors.New("EOF in composite stream")
+		}
+		*glyf = append(*glyf, byte(flags>>8), byte(flags))
+		L := len(*glyf)
+		*glyf = append(*glyf, make([]byte, argSize)...)
+		streams.composite.Read((*glyf)[L:]) // length checked above
+
+		do = flags&moreComponents != 0
+	}
+	return haveInstructions, nil
+}
+
+// decode one triplet, returning the coordinates deltas
+func decodeTriplet(flag byte, r *bytes.Reader) (dx, dy int16, err error) {
+	withSign := func(flag byte, baseval int) int16 {
+		if flag&1 != 0 {
+			return int16(baseval)
+		}
+		return int16(-baseval)
+	}
+
+	var nbBytes int
+	switch {
+	case flag < 84:
+		nbBytes = 1
+	case flag < 120:
+		nbBytes = 2
+	case flag < 124:
+		nbBytes = 3
+	default:
+		nbBytes = 4
+	}
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:nbBytes]); err != nil {
+		return 0, 0, errors.New("EOF in glyph stream")
+	}
+	b0, b1, b2, b3 := int(buf[0]), int(buf[1]), int(buf[2]), int(buf[3])
+
+	switch fl := int(flag); {
+	case flag < 10:
+		dx = 0
+		dy = withSign(flag, (fl&14)<<7+b0)
+	case flag < 20:
+		dx = withSign(flag, ((fl-10)&14)<<7+b0)
+		dy = 0
+	case flag < 84:
+		c := fl - 20
+		dx = withSign(flag, 1+(c&0x30)+(b0>>4))
+		dy = withSign(flag>>1, 1+(c&0x0c)<<2+(b0&0x0f))
+	case flag < 120:
+		c := fl - 84
+		dx = withSign(flag, 1+(c/12)<<8+b0)
+		dy = withSign(flag>>1, 1+((c%12)>>2)<<8+b1)
+	case flag < 124:
+		dx = withSign(flag, b0<<4+b1>>4)
+		dy = withSign(flag>>1, (b1&0x0f)<<8+b2)
+	default:
+		dx = withSign(flag, b0<<8+b1)
+		dy = withSign(flag>>1, b2<<8+b3)
+	}
+	return dx, dy, nil
+}
+
+func (streams *woff2GlyfStreams) appendSimpleGlyph(glyf []byte, index int, nContours int16, hasBbox bool, bbox [4]int16) ([]byte, error) {
+	endPoints := make([]uint16, nContours)
+	var numPoints int
+	for i := range endPoints {
+		n, err := read255Uint16(streams.nPoints)
+		if err != nil {
+			return nil, err
+		}
+		numPoints += int(n)
+		if numPoints > 0xFFFF {
+			return nil, errors.New("too many points")
+		}
+		endPoints[i] = uint16(numPoints - 1)
+	}
+
+	if streams.flag.Len() < numPoints {
+		return nil, errors.New("EOF in flag stream")
+	}
+
+	points := make([]glyphContourPoint, numPoints)
+	var x, y int16
+	for i := range points {
+		flag, _ := streams.flag.ReadByte() // length checked above
+		dx, dy, err := decodeTriplet(flag&0x7F, streams.glyph)
+		if err != nil {
+			return nil, err
+		}
+		x += dx
+		y += dy
+		points[i].x, points[i].y = x, y
+		if flag&0x80 == 0 {
+			points[i].flag = flagOnCurve
+		}
+	}
+
+	if streams.overlapSimpleBitmap != nil && hasBit(streams.overlapSimpleBitmap, index) && numPoints != 0 {
+		points[0].flag |= overlapSimple
+	}
+
+	if !hasBbox && numPoints != 0 {
+		bbox = [4]int16{points[0].x, points[0].y, points[0].x, points[0].y}
+		for _, p := range points {
+			bbox[0], bbox[1] = min16(bbox[0], p.x), min16(bbox[1], p.y)
+			bbox[2], bbox[3] = max16(bbox[2], p.x), max16(bbox[3], p.y)
+		}
+	}
+
+	glyf = appendGlyphHeader(glyf, nContours, bbox)
+	for _, end := range endPoints {
+		glyf = append(glyf, byte(end>>8), byte(end))
+	}
+
+	glyf, err := streams.appendInstructions(glyf)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendSimpleGlyphPoints(glyf, points), nil
+}
+
+// encode the flags and coordinates of the points, using
+// the compact representations when possible
+func appendSimpleGlyphPoints(glyf []byte, points []glyphContourPoint) []byte {
+	const repeatFlag = 0x08
+
+	flags := make([]byte, len(points))
+	var xs, ys []byte
+	var lastX, lastY int16
+	for i, p := range points {
+		flag := p.flag
+		dx, dy := p.x-lastX, p.y-lastY
+		lastX, lastY = p.x, p.y
+
+		switch {
+		case dx == 0:
+			flag |= xIsSameOrPositiveXShortVector
+		case -256 < dx && dx < 256:
+			flag |= xShortVector
+			if dx > 0 {
+				flag |= xIsSameOrPositiveXShortVector
+			} else {
+				dx = -dx
+			}
+			xs = append(xs, byte(dx))
+		default:
+			xs = append(xs, byte(uint16(dx)>>8), byte(dx))
+		}
+
+		switch {
+		case dy == 0:
+			flag |= yIsSameOrPositiveYShortVector
+		case -256 < dy && dy < 256:
+			flag |= yShortVector
+			if dy > 0 {
+				flag |= yIsSameOrPositiveYShortVector
+			} else {
+				dy = -dy
+			}
+			ys = append(ys, byte(dy))
+		default:
+			ys = append(ys, byte(uint16(dy)>>8), byte(dy))
+		}
+		flags[i] = flag
+	}
+
+	// compress the flags with the repeat flag
+	for i := 0; i < len(flags); {
+		flag := flags[i]
+		repeat := 0
+		for i+repeat+1 < len(flags) && flags[i+repeat+1] == flag && repeat < 255 {
+			repeat++
+		}
+		if repeat > 0 {
+			glyf = append(glyf, flag|repeatFlag, byte(repeat))
+		} else {
+			glyf = append(glyf, flag)
+		}
+		i += repeat + 1
+	}
+
+	glyf = append(glyf, xs...)
+	glyf = append(glyf, ys...)
+	return glyf
+}
+
+// reconstructHmtx reverses the 'hmtx' transform, using the
+// 'glyf' table to fill the omitted left side bearings.
+func reconstructHmtx(data, glyf []byte, loca []uint32, numHMetrics, numGlyphs int) ([]byte, error) {
+	const (
+		lsbAbsent             = 1 << 0
+		leftSideBearingAbsent = 1 << 1
+	)
+	if len(data) < 1 {
+		return nil, errors.New("invalid WOFF2 transformed 'hmtx' table (EOF)")
+	}
+	flags := data[0]
+	data = data[1:]
+	if numHMetrics > numGlyphs || numHMetrics == 0 {
+		return nil, errors.New("invalid WOFF2 transformed 'hmtx' table: invalid number of metrics")
+	}
+
+	expected := 2 * numHMetrics
+	if flags&lsbAbsent == 0 {
+		expected += 2 * numHMetrics
+	}
+	if flags&leftSideBearingAbsent == 0 {
+		expected += 2 * (numGlyphs - numHMetrics)
+	}
+	if len(data) < expected {
+		return nil, errors.New("invalid WOFF2 transformed 'hmtx' table (EOF)")
+	}
+
+	// the xMin of the glyph is used as default value
+	xMin := func(gid int) int16 {
+		start, end := loca[gid], loca[gid+1]
+		if start == end || int(end) > len(glyf) || end-start < 10 {
+			return 0
+		}
+		return int16(binary.BigEndian.Uint16(glyf[start+2:]))
+	}
+
+	advances, data := data[:2*numHMetrics], data[2*numHMetrics:]
+	out := make([]byte, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i := 0; i < numGlyphs; i++ {
+		var lsb int16
+		if i < numHMetrics && flags&lsbAbsent == 0 || i >= numHMetrics && flags&leftSideBearingAbsent == 0 {
+			lsb = int16(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		} else {
+			lsb = xMin(i)
+		}
+
+		if i < numHMetrics {
+			copy(out[4*i:], advances[2*i:2*i+2])
+			binary.BigEndian.PutUint16(out[4*i+2:], uint16(lsb))
+		} else {
+			binary.BigEndian.PutUint16(out[4*numHMetrics+2*(i-numHMetrics):], uint16(lsb))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,344 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestParseWOFF2(t *testing.T) {
+	ref := loadFont(t, "testdata/fontawesome-webfont.ttf")
+	font := loadFont(t, "testdata/fontawesome-webfont.woff2")
+
+	if font.NumGlyphs != ref.NumGlyphs {
+		t.Fatalf("expected %d glyphs, got %d", ref.NumGlyphs, font.NumGlyphs)
+	}
+	if !reflect.DeepEqual(font.Hmtx, ref.Hmtx) {
+		t.Error("invalid reconstructed 'hmtx' table")
+	}
+	if font.Head.indexToLocFormat != ref.Head.indexToLocFormat {
+		t.Error("invalid 'loca' format")
+	}
+	for gid := range ref.Glyf {
+		exp, got := ref.GlyphData(GID(gid), 0, 0), font.GlyphData(GID(gid), 0, 0)
+		if !reflect.DeepEqual(exp, got) {
+			t.Fatalf("glyph %d: expected %v, got %v", gid, exp, got)
+		}
+		if ref.Glyf[gid].Xmin != font.Glyf[gid].Xmin || ref.Glyf[gid].Ymax != font.Glyf[gid].Ymax {
+			t.Fatalf("glyph %d: invalid bounding box", gid)
+		}
+	}
+	for _, r := range "" {
+		g1, _ := ref.NominalGlyph(r)
+		g2, _ := font.NominalGlyph(r)
+		if g1 != g2 {
+			t.Errorf("invalid cmap for %x: expected %d, got %d", r, g1, g2)
+		}
+	}
+}
+
+func TestWOFF2Checksum(t *testing.T) {
+	file, err := os.Open("testdata/fontawesome-webfont.woff2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	prs, err := parseWOFF2(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfnt := prs[0].file.(*bytes.Reader)
+	data := make([]byte, sfnt.Size())
+	sfnt.ReadAt(data, 0)
+
+	if sum := tableChecksum(data); sum != 0xB1B0AFBA {
+		t.Errorf("invalid font checksum %x", sum)
+	}
+	for tag, table := range prs[0].tables {
+		if tag == tagHead {
+			continue
+		}
+		var entryChecksum uint32
+		for i := 0; i < len(prs[0].tables); i++ {
+			entry := data[otfHeaderLength+directoryEntryLength*i:]
+			if newTag(entry) == tag {
+				entryChecksum = binary.BigEndian.Uint32(entry[4:])
+			}
+		}
+		if sum := tableChecksum(data[table.offset : table.offset+table.length]); sum != entryChecksum {
+			t.Errorf("invalid checksum for table %s", tag)
+		}
+	}
+}
+
+func appendUintBase128(data []byte, v uint32) []byte {
+	var buf [5]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7F)
+	for v >>= 7; v != 0; v >>= 7 {
+		i--
+		buf[i] = 0x80 | byte(v&0x7F)
+	}
+	return append(data, buf[i:]...)
+}
+
+// encodeWOFF2 builds a WOFF2 file with null transforms,
+// sharing the identical tables between the fonts.
+func encodeWOFF2(t *testing.T, fonts []*FontParser) []byte {
+	type table struct {
+		tag  Tag
+		data string
+	}
+	var (
+		tables  []table
+		indices = make([][]int, len(fonts))
+	)
+	for i, pr := range fonts {
+		var tags []Tag
+		for tag := range pr.tables {
+			tags = append(tags, tag)
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+		for _, tag := range tags {
+			data, err := pr.GetRawTable(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := -1
+			for j, ta := range tables {
+				if ta.tag == tag && ta.data == string(data) {
+					index = j
+				}
+			}
+			if index == -1 {
+				index = len(tables)
+				tables = append(tables, table{tag, string(data)})
+			}
+			indices[i] = append(indices[i], index)
+		}
+	}
+
+	var directory, stream bytes.Buffer
+	for _, ta := range tables {
+		flags := byte(0x3F) // explicit tag
+		if ta.tag == tagGlyf || ta.tag == tagLoca {
+			flags |= 3 << 6 // null transform
+		}
+		directory.WriteByte(flags)
+		binary.Write(&directory, binary.BigEndian, ta.tag)
+		directory.Write(appendUintBase128(nil, uint32(len(ta.data))))
+		stream.WriteString(ta.data)
+	}
+
+	flavor := fonts[0].Type
+	if len(fonts) > 1 {
+		flavor = ttcTag
+		binary.Write(&directory, binary.BigEndian, uint32(0x00020000))
+		directory.WriteByte(byte(len(fonts)))
+		for i, pr := range fonts {
+			directory.WriteByte(byte(len(indices[i])))
+			binary.Write(&directory, binary.BigEndian, pr.Type)
+			for _, index := range indices[i] {
+				directory.WriteByte(byte(index))
+			}
+		}
+	}
+
+	var compressed bytes.Buffer
+	w := brotli.NewWriter(&compressed)
+	w.Write(stream.Bytes())
+	w.Close()
+
+	header := woff2Header{
+		Signature:           SignatureWOFF2,
+		Flavor:              flavor,
+		Length:              uint32(woff2HeaderSize + directory.Len() + compressed.Len()),
+		NumTables:           uint16(len(tables)),
+		TotalSfntSize:       uint32(stream.Len()),
+		TotalCompressedSize: uint32(compressed.Len()),
+	}
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, header)
+	out.Write(directory.Bytes())
+	out.Write(compressed.Bytes())
+	return out.Bytes()
+}
+
+func TestWOFF2Collection(t *testing.T) {
+	for _, filename := range []string{
+		"testdata/ToyTTC.ttc",
+		"testdata/Roboto-BoldItalic.ttf",
+	} {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refs, err := NewFontParsers(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		woff2 := encodeWOFF2(t, refs)
+		prs, err := NewFontParsers(bytes.NewReader(woff2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prs) != len(refs) {
+			t.Fatalf("expected %d fonts, got %d", len(refs), len(prs))
+		}
+		for i, pr := range prs {
+			if pr.Type != refs[i].Type {
+				t.Errorf("expected %s, got %s", refs[i].Type, pr.Type)
+			}
+			for tag := range refs[i].tables {
+				exp, _ := refs[i].GetRawTable(tag)
+				got, err := pr.GetRawTable(tag)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag == tagHead { // checkSumAdjustment may differ
+					exp, got = exp[12:], got[12:]
+				}
+				if !bytes.Equal(exp, got) {
+					t.Errorf("invalid table %s", tag)
+				}
+			}
+		}
+
+		fonts, err := Load(bytes.NewReader(woff2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fonts) != len(refs) {
+			t.Fatalf("expected %d fonts, got %d", len(refs), len(fonts))
+		}
+
+		file.Close()
+	}
+}
+
+func TestParseWOFF2Crashers(t *testing.T) {
+	data, err := os.ReadFile("testdata/fontawesome-webfont.woff2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, L := range []int{0, 4, 20, woff2HeaderSize, 100, len(data) / 2, len(data) - 1} {
+		_, err := Parse(bytes.NewReader(data[:L]))
+		if err == nil {
+			t.Errorf("expected error for truncated input (%d bytes)", L)
+		}
+	}
+
+	// the declared length exceeds the actual size
+	header := append([]byte(nil), data[:woff2HeaderSize]...)
+	binary.BigEndian.PutUint32(header[8:], 0xFFFFFFFF)
+	if _, err := Parse(bytes.NewReader(header)); err == nil {
+		t.Error("expected error for invalid length")
+	}
+}
+
+func TestVarLengthIntegers(t *testing.T) {
+	for _, v := range []uint32{0, 63, 128, 1 << 20, 0xFFFFFFFF} {
+		got, err := readUintBase128(bytes.NewReader(appendUintBase128(nil, v)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("expected %d, got %d", v, got)
+		}
+	}
+	for _, invalid := range [][]byte{{0x80, 0x01}, {0xFF, 0xFF, 0xFF, 0xFF, 0x7F}, {0x81}} {
+		if _, err := readUintBase128(bytes.NewReader(invalid)); err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+
+	for _, test := range []struct {
+		data     []byte
+		expected uint16
+	}{
+		{[]byte{252}, 252},
+		{[]byte{255, 0}, 253},
+		{[]byte{254, 0}, 506},
+		{[]byte{253, 0x01, 0x02}, 258},
+	} {
+		got, err := read255Uint16(bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("expected %d, got %d", test.expected, got)
+		}
+	}
+}
+
+func TestReconstructHmtx(t *testing.T) {
+	file, err := os.Open("testdata/fontawesome-webfont.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	pr, err := NewFontParser(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := pr.loadTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawHmtx, _ := pr.GetRawTable(tagHmtx)
+	glyf, _ := pr.GetRawTable(tagGlyf)
+	rawLoca, _ := pr.GetRawTable(tagLoca)
+	loca, err := parseTableLoca(rawLoca, font.NumGlyphs, font.Head.indexToLocFormat == 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numHMetrics := int(font.hhea.numOfLongMetrics)
+
+	// explicit side bearings
+	transformed := []byte{0}
+	for i := 0; i < numHMetrics; i++ {
+		transformed = append(transformed, rawHmtx[4*i:4*i+2]...)
+	}
+	for i := 0; i < numHMetrics; i++ {
+		transformed = append(transformed, rawHmtx[4*i+2:4*i+4]...)
+	}
+	transformed = append(transformed, rawHmtx[4*numHMetrics:]...)
+	got, err := reconstructHmtx(transformed, glyf, loca, numHMetrics, font.NumGlyphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, rawHmtx[:len(got)]) {
+		t.Error("invalid reconstructed 'hmtx' table")
+	}
+
+	// side bearings deduced from the glyphs
+	transformed = []byte{3}
+	for i := 0; i < numHMetrics; i++ {
+		transformed = append(transformed, rawHmtx[4*i:4*i+2]...)
+	}
+	got, err = reconstructHmtx(transformed, glyf, loca, numHMetrics, font.NumGlyphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmtx, err := parseHVmtxTable(got, uint16(numHMetrics), uint16(font.NumGlyphs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for gid, metric := range hmtx {
+		if metric.Advance != font.Hmtx[gid].Advance {
+			t.Errorf("glyph %d: invalid advance", gid)
+		}
+		if metric.SideBearing != font.Glyf[gid].Xmin {
+			t.Errorf("glyph %d: expected side bearing %d, got %d", gid, font.Glyf[gid].Xmin, metric.SideBearing)
+		}
+	}
+}
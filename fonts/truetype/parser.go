@@ -24,6 +24,14 @@ type FontParser struct {
 	// of a 'head' table. Apple uses it as a flag that a font doesn't have
 	// any glyph outlines but only embedded bitmaps
 	isBinary bool
+
+	// the header and table directory, and the tables
+	// ignored since duplicated, used by Validate
+	directory  tableSection
+	duplicates []Tag
+	// true when the 'head' checkSumAdjustment applies to
+	// the file, that is for fonts outside collections and WOFF files
+	standalone bool
 }
 
 // NewFontParser reads the `file` header and returns
@@ -57,6 +65,9 @@ func NewFontParsers(file fonts.Resource) ([]*FontParser, error) {
 	switch magic {
 	case SignatureWOFF, TypeTrueType, TypeOpenType, TypePostScript1, TypeAppleTrueType:
 		pr, err = parseOneFont(file, 0, false)
+	case SignatureWOFF2:
+		// WOFF2 files may contain collections
+		return parseWOFF2(file, 0)
 	case ttcTag:
 		offsets, err = parseTTCHeader(file)
 	case dfontResourceDataOffset:
@@ -87,9 +98,10 @@ func NewFontParsers(file fonts.Resource) ([]*FontParser, error) {
 
 // tableSection represents a table within the font file.
 type tableSection struct {
-	offset  uint32 // Offset into the file this table starts.
-	length  uint32 // Length of this table within the file.
-	zLength uint32 // Uncompressed length of this table.
+	offset   uint32 // Offset into the file this table starts.
+	length   uint32 // Length of this table within the file.
+	zLength  uint32 // Uncompressed length of this table.
+	checksum uint32 // Checksum of the (uncompressed) table, as stored in the directory.
 }
 
 func (pr *FontParser) findTableBuffer(s tableSection) ([]byte, error) {
@@ -213,6 +225,15 @@ func (pr *FontParser) cffTable(numGlyphs int) (*type1c.Font, error) {
 	return out, nil
 }
 
+func (pr *FontParser) cff2Table(numGlyphs int, fvar TableFvar) (tableCFF2, error) {
+	buf, err := pr.GetRawTable(tagCFF2)
+	if err != nil {
+		return tableCFF2{}, err
+	}
+
+	return parseTableCFF2(buf, numGlyphs, len(fvar.Axis))
+}
+
 func (pr *FontParser) sbixTable(numGlyphs int) (tableSbix, error) {
 	buf, err := pr.GetRawTable(tagSbix)
 	if err != nil {
@@ -324,6 +345,46 @@ func (pr *FontParser) GDEFTable(nbAxis int) (TableGDEF, error) {
 	return parseTableGdef(buf, nbAxis)
 }
 
+// BASETable returns the Baseline table identified with the 'BASE' tag.
+func (pr *FontParser) BASETable(nbAxis int) (TableBase, error) {
+	buf, err := pr.GetRawTable(tagBase)
+	if err != nil {
+		return TableBase{}, err
+	}
+
+	return parseTableBase(buf, nbAxis)
+}
+
+// StatTable returns the Style Attributes table identified with the 'STAT' tag.
+func (pr *FontParser) StatTable() (TableStat, error) {
+	buf, err := pr.GetRawTable(tagStat)
+	if err != nil {
+		return TableStat{}, err
+	}
+
+	return parseTableStat(buf)
+}
+
+// JSTFTable returns the Justification table identified with the 'JSTF' tag.
+func (pr *FontParser) JSTFTable() (TableJstf, error) {
+	buf, err := pr.GetRawTable(tagJstf)
+	if err != nil {
+		return TableJstf{}, err
+	}
+
+	return parseTableJstf(buf)
+}
+
+// MATHTable returns the Mathematical Typesetting table identified with the 'MATH' tag.
+func (pr *FontParser) MATHTable() (TableMath, error) {
+	buf, err := pr.GetRawTable(tagMath)
+	if err != nil {
+		return TableMath{}, err
+	}
+
+	return parseTableMath(buf)
+}
+
 func (pr *FontParser) CmapTable() (TableCmap, error) {
 	s, found := pr.tables[tagCmap]
 	if !found {
@@ -358,13 +419,75 @@ func (pr *FontParser) svgTable() (tableSVG, error) {
 	return parseTableSVG(buf)
 }
 
+func (pr *FontParser) colrTable(fvar TableFvar) (tableCOLR, error) {
+	buf, err := pr.GetRawTable(tagCOLR)
+	if err != nil {
+		return tableCOLR{}, err
+	}
+
+	return parseTableCOLR(buf, len(fvar.Axis))
+}
+
+// CPALTable parses the 'CPAL' table, storing the color palettes.
+func (pr *FontParser) CPALTable() (TableCPAL, error) {
+	buf, err := pr.GetRawTable(tagCPAL)
+	if err != nil {
+		return TableCPAL{}, err
+	}
+
+	return parseTableCPAL(buf)
+}
+
+// hintingTables loads the tables used by the TrueType instructions.
+// The 'fpgm', 'prep' and 'cvt ' tables are optional, but the 'maxp'
+// table must have version 1.0.
+func (pr *FontParser) hintingTables() (out tableHinting, err error) {
+	buf, err := pr.GetRawTable(tagMaxp)
+	if err != nil {
+		return out, err
+	}
+	out.maxp, err = parseMaxpProfile(buf)
+	if err != nil {
+		return out, err
+	}
+
+	out.fpgm, _ = pr.GetRawTable(tagFpgm)
+	out.prep, _ = pr.GetRawTable(TagPrep)
+	if buf, err := pr.GetRawTable(tagCvt); err == nil {
+		out.cvt, err = parseTableCvt(buf)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func (pr *FontParser) cvarTable(fvar TableFvar, cvtCount int) (tableCvar, error) {
+	buf, err := pr.GetRawTable(tagCvar)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTableCvar(buf, len(fvar.Axis), cvtCount)
+}
+
 // NumGlyphs parses the 'maxp' table to find the number of glyphs in the font.
 func (pr *FontParser) NumGlyphs() (int, error) {
-	buf, err := pr.GetRawTable(tagMaxp)
+	maxp, err := pr.MaxpTable()
 	if err != nil {
 		return -1, err
 	}
 
+	return int(maxp.NumGlyphs), nil
+}
+
+// MaxpTable parses the 'maxp' table.
+func (pr *FontParser) MaxpTable() (TableMaxp, error) {
+	buf, err := pr.GetRawTable(tagMaxp)
+	if err != nil {
+		return TableMaxp{}, err
+	}
+
 	return parseTableMaxp(buf)
 }
 
@@ -420,6 +543,17 @@ func (pr *FontParser) MorxTable(numGlyphs int) (TableMorx, error) {
 	return parseTableMorx(buf, numGlyphs)
 }
 
+// MortTable parse the deprecated AAT 'mort' table,
+// converting it to the 'morx' structures.
+func (pr *FontParser) MortTable(numGlyphs int) (TableMorx, error) {
+	buf, err := pr.GetRawTable(tagMort)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTableMorx(buf, numGlyphs)
+}
+
 // KerxTable parse the AAT 'kerx' table.
 func (pr *FontParser) KerxTable(numGlyphs int) (TableKernx, error) {
 	buf, err := pr.GetRawTable(tagKerx)
@@ -440,6 +574,36 @@ func (pr *FontParser) AnkrTable(numGlyphs int) (TableAnkr, error) {
 	return parseTableAnkr(buf, numGlyphs)
 }
 
+// OpbdTable parse the AAT 'opbd' table.
+func (pr *FontParser) OpbdTable(numGlyphs int) (TableOpbd, error) {
+	buf, err := pr.GetRawTable(tagOpbd)
+	if err != nil {
+		return TableOpbd{}, err
+	}
+
+	return parseTableOpbd(buf, numGlyphs)
+}
+
+// PropTable parse the AAT 'prop' table.
+func (pr *FontParser) PropTable(numGlyphs int) (TableProp, error) {
+	buf, err := pr.GetRawTable(tagProp)
+	if err != nil {
+		return TableProp{}, err
+	}
+
+	return parseTableProp(buf, numGlyphs)
+}
+
+// LcarTable parse the AAT 'lcar' table.
+func (pr *FontParser) LcarTable(numGlyphs int) (TableLcar, error) {
+	buf, err := pr.GetRawTable(tagLcar)
+	if err != nil {
+		return TableLcar{}, err
+	}
+
+	return parseTableLcar(buf, numGlyphs)
+}
+
 // TrakTable parse the AAT 'trak' table.
 func (pr *FontParser) TrakTable() (TableTrak, error) {
 	buf, err := pr.GetRawTable(tagTrak)
@@ -535,38 +699,68 @@ func (pr *FontParser) vorgTable() (tableVorg, error) {
 	return parseTableVorg(buf)
 }
 
-// best effort to load all valid tables
-func (pr *FontParser) loadLayoutTables(numGlyphs int, fvar TableFvar) (out LayoutTables) {
-	if tb, err := pr.GDEFTable(len(fvar.Axis)); err == nil {
+// loadLayoutTables loads the layout tables, ignoring the invalid ones,
+// whose errors are returned as issues.
+func (pr *FontParser) loadLayoutTables(numGlyphs int, fvar TableFvar) (out LayoutTables, issues []Issue) {
+	// record the error of a table which is present
+	check := func(tag Tag, err error) bool {
+		if err != nil && pr.HasTable(tag) {
+			issues = append(issues, Issue{Tag: tag, Err: err})
+		}
+		return err == nil
+	}
+
+	if tb, err := pr.GDEFTable(len(fvar.Axis)); check(TagGdef, err) {
 		out.GDEF = tb
 	}
-	if tb, err := pr.GSUBTable(); err == nil {
+	if tb, err := pr.GSUBTable(); check(TagGsub, err) {
 		out.GSUB = tb
 	}
-	if tb, err := pr.GPOSTable(); err == nil {
+	if tb, err := pr.GPOSTable(); check(TagGpos, err) {
 		out.GPOS = tb
 	}
+	if tb, err := pr.MATHTable(); check(tagMath, err) {
+		out.MATH = tb
+	}
+	if tb, err := pr.BASETable(len(fvar.Axis)); check(tagBase, err) {
+		out.BASE = tb
+	}
+	if tb, err := pr.JSTFTable(); check(tagJstf, err) {
+		out.JSTF = tb
+	}
 
-	if tb, err := pr.MorxTable(numGlyphs); err == nil {
+	if tb, err := pr.MorxTable(numGlyphs); check(tagMorx, err) {
+		out.Morx = tb
+	} else if tb, err := pr.MortTable(numGlyphs); check(tagMort, err) {
+		// fallback to the deprecated table
 		out.Morx = tb
 	}
-	if tb, err := pr.KernTable(numGlyphs); err == nil {
+	if tb, err := pr.KernTable(numGlyphs); check(tagKern, err) {
 		out.Kern = tb
 	}
-	if tb, err := pr.KerxTable(numGlyphs); err == nil {
+	if tb, err := pr.KerxTable(numGlyphs); check(tagKerx, err) {
 		out.Kerx = tb
 	}
-	if tb, err := pr.AnkrTable(numGlyphs); err == nil {
+	if tb, err := pr.AnkrTable(numGlyphs); check(tagAnkr, err) {
 		out.Ankr = tb
 	}
-	if tb, err := pr.TrakTable(); err == nil {
+	if tb, err := pr.TrakTable(); check(tagTrak, err) {
 		out.Trak = tb
 	}
-	if tb, err := pr.FeatTable(); err == nil {
+	if tb, err := pr.OpbdTable(numGlyphs); check(tagOpbd, err) {
+		out.Opbd = tb
+	}
+	if tb, err := pr.PropTable(numGlyphs); check(tagProp, err) {
+		out.Prop = tb
+	}
+	if tb, err := pr.LcarTable(numGlyphs); check(tagLcar, err) {
+		out.Lcar = tb
+	}
+	if tb, err := pr.FeatTable(); check(tagFeat, err) {
 		out.Feat = tb
 	}
 
-	return out
+	return out, issues
 }
 
 // graphite support
@@ -631,6 +825,16 @@ func parseOneFont(file fonts.Resource, offset uint32, relativeOffset bool) (pars
 	switch magic {
 	case SignatureWOFF:
 		parser, err = parseWOFF(file, offset, relativeOffset)
+	case SignatureWOFF2:
+		var parsers []*FontParser
+		parsers, err = parseWOFF2(file, offset)
+		if err == nil {
+			if len(parsers) != 1 {
+				// no collections allowed here
+				return nil, errUnsupportedFormat
+			}
+			parser = parsers[0]
+		}
 	case TypeTrueType, TypeOpenType, TypePostScript1, TypeAppleTrueType:
 		parser, err = parseOTF(file, offset, relativeOffset)
 	default:
@@ -683,6 +887,9 @@ func (pr *FontParser) loadTables() (*Font, error) {
 	out.upem = out.Head.Upem()
 
 	out.OS2, _ = pr.OS2Table()
+	if stat, err := pr.StatTable(); err == nil {
+		out.STAT = &stat
+	}
 
 	out.Glyf, _ = pr.GlyfTable(out.NumGlyphs, out.Head.indexToLocFormat)
 
@@ -690,8 +897,13 @@ func (pr *FontParser) loadTables() (*Font, error) {
 
 	out.sbix, _ = pr.sbixTable(out.NumGlyphs)
 	out.cff, _ = pr.cffTable(out.NumGlyphs)
+	if cff2, err := pr.cff2Table(out.NumGlyphs, out.fvar); err == nil {
+		out.cff2 = &cff2
+	}
 	out.post, _ = pr.PostTable(out.NumGlyphs)
 	out.svg, _ = pr.svgTable()
+	out.colr, _ = pr.colrTable(out.fvar)
+	out.cpal, _ = pr.CPALTable()
 
 	out.hhea, _ = pr.HheaTable()
 	out.vhea, _ = pr.VheaTable()
@@ -716,7 +928,7 @@ func (pr *FontParser) loadTables() (*Font, error) {
 		out.vorg = &vorg
 	}
 
-	out.layoutTables = pr.loadLayoutTables(out.NumGlyphs, out.fvar)
+	out.layoutTables, _ = pr.loadLayoutTables(out.NumGlyphs, out.fvar)
 
 	if pr.HasTable(TagSilf) {
 		var gr GraphiteTables
@@ -730,6 +942,12 @@ func (pr *FontParser) loadTables() (*Font, error) {
 	if pr.HasTable(TagPrep) {
 		out.HasHint = true
 	}
+	if len(out.Glyf) != 0 {
+		out.hinting, _ = pr.hintingTables()
+		if len(out.fvar.Axis) != 0 {
+			out.cvar, _ = pr.cvarTable(out.fvar, len(out.hinting.cvt))
+		}
+	}
 
 	err = pr.loadSummary(&out)
 	if err != nil {
@@ -9,7 +9,7 @@ import (
 
 var _ fonts.FaceRenderer = (*Font)(nil)
 
-func (sbix tableSbix) glyphData(gid GID, xPpem, yPpem uint16) (fonts.GlyphBitmap, error) {
+func (sbix tableSbix) glyphData(gid GID, xPpem, yPpem uint16, rendering fonts.BitmapRendering) (fonts.GlyphBitmap, error) {
 	st := sbix.chooseStrike(xPpem, yPpem)
 	if st == nil {
 		return fonts.GlyphBitmap{}, errors.New("empty 'sbix' table")
@@ -23,50 +23,60 @@ func (sbix tableSbix) glyphData(gid GID, xPpem, yPpem uint16) (fonts.GlyphBitmap
 	out := fonts.GlyphBitmap{Data: glyph.data}
 	var err error
 	out.Width, out.Height, out.Format, err = glyph.decodeConfig()
+	if err != nil {
+		return out, err
+	}
 
-	return out, err
+	xScale, yScale := rendering.ScaleFactors(st.ppem, st.ppem, xPpem, yPpem)
+	return rendering.Render(out, xScale, yScale)
 }
 
-func (colorBitmap bitmapTable) glyphData(gid GID, xPpem, yPpem uint16) (fonts.GlyphBitmap, error) {
+func (colorBitmap bitmapTable) glyphData(gid GID, xPpem, yPpem uint16, rendering fonts.BitmapRendering) (fonts.GlyphBitmap, error) {
 	st := colorBitmap.chooseStrike(xPpem, yPpem)
 	if st == nil || st.ppemX == 0 || st.ppemY == 0 {
 		return fonts.GlyphBitmap{}, errors.New("empty bitmap table")
 	}
 
-	subtable := st.findTable(gid)
-	if subtable == nil {
-		return fonts.GlyphBitmap{}, fmt.Errorf("no glyph %d in bitmap table for resolution (%d, %d)", gid, xPpem, yPpem)
-	}
-
-	glyph := subtable.getImage(gid)
-	if glyph == nil {
-		return fonts.GlyphBitmap{}, fmt.Errorf("no glyph %d in bitmap table for resolution (%d, %d)", gid, xPpem, yPpem)
+	image, metrics, format, err := st.decodeGlyph(gid)
+	if err != nil {
+		return fonts.GlyphBitmap{}, fmt.Errorf("glyph %d in bitmap table for resolution (%d, %d): %s", gid, xPpem, yPpem, err)
 	}
 
 	out := fonts.GlyphBitmap{
-		Data:   glyph.image,
-		Width:  int(glyph.metrics.width),
-		Height: int(glyph.metrics.height),
+		Data:     image,
+		Width:    int(metrics.width),
+		Height:   int(metrics.height),
+		XBearing: int(metrics.horiBearingX),
+		YBearing: int(metrics.horiBearingY),
+		Advance:  int(metrics.horiAdvance),
 	}
-	switch subtable.imageFormat() {
+	switch format {
 	case 17, 18, 19: // PNG
 		out.Format = fonts.PNG
-	case 2, 5:
+	case 4: // always decoded with 1 bit per pixel
 		out.Format = fonts.BlackAndWhite
+	case 1, 2, 5, 6, 7, 8, 9:
+		out.Format = st.bitmapFormat()
 	default:
-		return fonts.GlyphBitmap{}, fmt.Errorf("unsupported format %d in bitmap table", subtable.imageFormat())
+		return fonts.GlyphBitmap{}, fmt.Errorf("unsupported format %d in bitmap table", format)
 	}
 
-	return out, nil
+	xScale, yScale := rendering.ScaleFactors(st.ppemX, st.ppemY, xPpem, yPpem)
+	return rendering.Render(out, xScale, yScale)
 }
 
-// look for data in 'glyf' and 'cff' tables
+// look for data in 'glyf', 'CFF ' and 'CFF2' tables
 func (f *Font) outlineGlyphData(gid GID) (fonts.GlyphOutline, bool) {
 	out, err := f.glyphDataFromCFF1(gid)
 	if err == nil {
 		return out, true
 	}
 
+	out, err = f.glyphDataFromCFF2(gid)
+	if err == nil {
+		return out, true
+	}
+
 	out, err = f.glyphDataFromGlyf(gid)
 	if err == nil {
 		return out, true
@@ -79,16 +89,28 @@ func (f *Font) GlyphData(gid GID, xPpem, yPpem uint16) fonts.GlyphData {
 	var out fonts.GlyphData
 
 	// try every table
-	out, err := f.sbix.glyphData(gid, xPpem, yPpem)
+	out, err := f.sbix.glyphData(gid, xPpem, yPpem, f.bitmapRendering)
 	if err == nil {
 		return out
 	}
 
-	out, err = f.bitmap.glyphData(gid, xPpem, yPpem)
+	out, err = f.bitmap.glyphData(gid, xPpem, yPpem, f.bitmapRendering)
 	if err == nil {
 		return out
 	}
 
+	var coords []float32
+	if f.isVar() {
+		coords = f.varCoords
+	}
+	if paint, err := f.colr.paintGlyphData(gid, coords); err == nil {
+		return paint
+	}
+
+	if layers, ok := f.colr.glyphData(gid); ok {
+		return layers
+	}
+
 	out_, ok := f.svg.glyphData(gid)
 	if ok {
 		// Spec :
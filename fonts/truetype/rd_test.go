@@ -47,7 +47,7 @@ func TestSbixGlyph(t *testing.T) {
 				continue
 			}
 
-			data, err := gs.glyphData(gid, 100, 100)
+			data, err := gs.glyphData(gid, 100, 100, fonts.BitmapRendering{})
 			if err != nil {
 				continue
 			}
@@ -93,7 +93,7 @@ func TestCblcGlyph(t *testing.T) {
 		iter := cmap.Iter()
 		for iter.Next() {
 			_, gid := iter.Char()
-			data, err := gs.glyphData(gid, 94, 94)
+			data, err := gs.glyphData(gid, 94, 94, fonts.BitmapRendering{})
 			if err != nil {
 				// t.Logf("unsupported rune %d", gid)
 				continue
@@ -144,7 +144,7 @@ func TestEblcGlyph(t *testing.T) {
 			if !ok {
 				t.Fatalf("unsupported rune %d", r)
 			}
-			data, err := gs.glyphData(gid, 94, 94)
+			data, err := gs.glyphData(gid, 94, 94, fonts.BitmapRendering{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -189,7 +189,7 @@ func TestAppleBitmapGlyph(t *testing.T) {
 		if !ok {
 			t.Fatalf("unsupported rune %d", r)
 		}
-		data, err := gs.glyphData(gid, 94, 94)
+		data, err := gs.glyphData(gid, 94, 94, fonts.BitmapRendering{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -216,3 +216,31 @@ func TestMixedGlyphs(t *testing.T) {
 		}
 	}
 }
+
+func TestGlyphBitmapImage(t *testing.T) {
+	for _, filename := range []string{
+		"testdata/ToyCBLC1.ttf",       // PNG
+		"testdata/IBM3161-bitmap.otb", // black and white
+		"testdata/mry_KacstQurn.ttf",  // black and white
+	} {
+		font := loadFont(t, filename)
+		nbImages := 0
+		for gid := GID(0); gid < GID(font.NumGlyphs); gid++ {
+			data, ok := font.GlyphData(gid, 94, 94).(fonts.GlyphBitmap)
+			if !ok {
+				continue
+			}
+			img, err := data.Image()
+			if err != nil {
+				t.Fatalf("%s: glyph %d: %s", filename, gid, err)
+			}
+			if b := img.Bounds(); b.Dx() != data.Width || b.Dy() != data.Height {
+				t.Fatalf("%s: glyph %d: unexpected image size %v", filename, gid, b)
+			}
+			nbImages++
+		}
+		if nbImages == 0 {
+			t.Fatalf("%s: no bitmap glyphs", filename)
+		}
+	}
+}
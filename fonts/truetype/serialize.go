@@ -0,0 +1,141 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errOffsetOverflow = errors.New("offset overflow while writing table")
+
+// serialTable is a table being written: its own data, with
+// offsets to sub-tables, which are resolved by `serialize`.
+// Offsets are relative to the start of the table storing them.
+type serialTable struct {
+	data  []byte
+	links []serialLink
+}
+
+type serialLink struct {
+	child *serialTable
+	pos   int  // position of the offset in `data`
+	long  bool // 32-bit offset
+}
+
+func (t *serialTable) u16(v uint16) { t.data = append(t.data, byte(v>>8), byte(v)) }
+
+func (t *serialTable) u32(v uint32) {
+	t.data = append(t.data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (t *serialTable) tag(tag Tag) { t.u32(uint32(tag)) }
+
+// offset16 adds a 16-bit offset to `child`, which may be nil
+// for a null offset.
+func (t *serialTable) offset16(child *serialTable) {
+	if child != nil {
+		t.links = append(t.links, serialLink{child: child, pos: len(t.data)})
+	}
+	t.u16(0)
+}
+
+// offset32 adds a 32-bit offset to `child`, which may be nil
+// for a null offset.
+func (t *serialTable) offset32(child *serialTable) {
+	if child != nil {
+		t.links = append(t.links, serialLink{child: child, pos: len(t.data), long: true})
+	}
+	t.u32(0)
+}
+
+// serialize writes the table, followed by its sub-tables.
+// Identical sub-tables without offsets are shared when possible.
+// The sub-tables are first laid out depth first, and if
+// an offset overflows, breadth first.
+func (t *serialTable) serialize() ([]byte, error) {
+	s := serializer{leaves: make(map[string]int)}
+	s.out = append(s.out, t.data...)
+	err := s.writeDepthFirst(t, 0)
+	if err != errOffsetOverflow {
+		return s.out, err
+	}
+
+	s = serializer{leaves: make(map[string]int)}
+	s.out = append(s.out, t.data...)
+	err = s.writeBreadthFirst(t)
+	return s.out, err
+}
+
+type serializer struct {
+	leaves map[string]int // position of the tables without offsets
+	out    []byte
+}
+
+// writeChild returns the position of `child`, which is appended to the output
+// if needed, and `true` if its own sub-tables must be written.
+func (s *serializer) writeChild(child *serialTable, parentStart int) (int, bool) {
+	isLeaf := len(child.links) == 0
+	if isLeaf {
+		if pos, ok := s.leaves[string(child.data)]; ok && pos > parentStart { // offsets are unsigned
+			return pos, false
+		}
+	}
+	pos := len(s.out)
+	s.out = append(s.out, child.data...)
+	if isLeaf {
+		s.leaves[string(child.data)] = pos
+	}
+	return pos, !isLeaf
+}
+
+// setOffset writes the offset of `link`, for a table starting at `start`
+func (s *serializer) setOffset(link serialLink, start, pos int) error {
+	offset := pos - start
+	if link.long {
+		binary.BigEndian.PutUint32(s.out[start+link.pos:], uint32(offset))
+	} else {
+		if offset > 0xFFFF {
+			return errOffsetOverflow
+		}
+		binary.BigEndian.PutUint16(s.out[start+link.pos:], uint16(offset))
+	}
+	return nil
+}
+
+// writeDepthFirst writes the sub-tables of `t`, already written at `start`
+func (s *serializer) writeDepthFirst(t *serialTable, start int) error {
+	for _, link := range t.links {
+		pos, hasChildren := s.writeChild(link.child, start)
+		if err := s.setOffset(link, start, pos); err != nil {
+			return err
+		}
+		if hasChildren {
+			if err := s.writeDepthFirst(link.child, pos); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeBreadthFirst writes the sub-tables of `t`, already written at 0
+func (s *serializer) writeBreadthFirst(t *serialTable) error {
+	type item struct {
+		table *serialTable
+		start int
+	}
+	queue := []item{{t, 0}}
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, link := range current.table.links {
+			pos, hasChildren := s.writeChild(link.child, current.start)
+			if err := s.setOffset(link, current.start, pos); err != nil {
+				return err
+			}
+			if hasChildren {
+				queue = append(queue, item{link.child, pos})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,903 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This file implements the serialization of the 'GSUB', 'GPOS' and 'GDEF' tables.
+// The feature parameters and the 'GDEF' attachment list, which are not parsed, are not written.
+
+// writeTo serializes the table.
+func (t TableGSUB) writeTo() ([]byte, error) {
+	lookups := make([]serialLookup, len(t.Lookups))
+	for i, lookup := range t.Lookups {
+		lookups[i] = serialLookup{LookupOptions: lookup.LookupOptions, kind: uint16(lookup.Type)}
+		if len(lookup.Subtables) != 0 { // resolve extensions
+			lookups[i].kind = uint16(lookup.Subtables[0].Data.Type())
+		}
+		for _, subtable := range lookup.Subtables {
+			data, err := subtable.serialTable().serialize()
+			if err != nil {
+				return nil, fmt.Errorf("writing GSUB lookup %d: %s", i, err)
+			}
+			lookups[i].subtables = append(lookups[i].subtables, data)
+		}
+	}
+	return writeLayout(t.TableLayout, lookups, uint16(gsubExtension))
+}
+
+// writeTo serializes the table.
+func (t TableGPOS) writeTo() ([]byte, error) {
+	lookups := make([]serialLookup, len(t.Lookups))
+	for i, lookup := range t.Lookups {
+		lookups[i] = serialLookup{LookupOptions: lookup.LookupOptions, kind: uint16(lookup.Type)}
+		if len(lookup.Subtables) != 0 { // resolve extensions
+			lookups[i].kind = uint16(lookup.Subtables[0].Data.Type())
+		}
+		for _, subtable := range lookup.Subtables {
+			data, err := subtable.serialTable().serialize()
+			if err != nil {
+				return nil, fmt.Errorf("writing GPOS lookup %d: %s", i, err)
+			}
+			lookups[i].subtables = append(lookups[i].subtables, data)
+		}
+	}
+	return writeLayout(t.TableLayout, lookups, uint16(gposExtension))
+}
+
+// serialLookup is a lookup with its subtables already serialized
+type serialLookup struct {
+	subtables [][]byte
+	LookupOptions
+	kind uint16
+}
+
+// writeLayout writes the common layout structure of GSUB and GPOS tables.
+// `extensionType` is used if the subtables can't be reached with 16-bit offsets.
+func writeLayout(layout TableLayout, lookups []serialLookup, extensionType uint16) ([]byte, error) {
+	scriptList, err := writeScriptList(layout.Scripts).serialize()
+	if err != nil {
+		return nil, err
+	}
+	featureList, err := writeFeatureList(layout.Features).serialize()
+	if err != nil {
+		return nil, err
+	}
+	lookupList, err := writeLookupList(lookups, 0)
+	if err == errOffsetOverflow {
+		lookupList, err = writeLookupList(lookups, extensionType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := 10
+	if len(layout.FeatureVariations) != 0 {
+		headerSize = 14
+	}
+	out := make([]byte, headerSize, headerSize+len(scriptList)+len(featureList)+len(lookupList))
+	binary.BigEndian.PutUint16(out, 1)
+	binary.BigEndian.PutUint16(out[2:], uint16(headerSize-10)/4) // minor version
+
+	out = append(out, scriptList...)
+	featureListOffset := len(out)
+	out = append(out, featureList...)
+	lookupListOffset := len(out)
+	if lookupListOffset > 0xFFFF {
+		return nil, errOffsetOverflow
+	}
+	binary.BigEndian.PutUint16(out[4:], uint16(headerSize))
+	binary.BigEndian.PutUint16(out[6:], uint16(featureListOffset))
+	binary.BigEndian.PutUint16(out[8:], uint16(lookupListOffset))
+	out = append(out, lookupList...)
+
+	if len(layout.FeatureVariations) != 0 {
+		featureVariations, err := writeFeatureVariations(layout.FeatureVariations).serialize()
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint32(out[10:], uint32(len(out)))
+		out = append(out, featureVariations...)
+	}
+	return out, nil
+}
+
+// writeLookupList writes the lookup tables, followed by the subtables.
+// If `extensionType` is not zero, the subtables are wrapped in extension subtables.
+func writeLookupList(lookups []serialLookup, extensionType uint16) ([]byte, error) {
+	size := 2 + 2*len(lookups)
+	lookupOffsets := make([]int, len(lookups))
+	for i, lookup := range lookups {
+		lookupOffsets[i] = size
+		size += 6 + 2*len(lookup.subtables)
+		if lookup.Flag&UseMarkFilteringSet != 0 {
+			size += 2
+		}
+	}
+	extensionOffset := size
+	if extensionType != 0 {
+		for _, lookup := range lookups {
+			size += 8 * len(lookup.subtables)
+		}
+	}
+
+	out := make([]byte, size)
+	binary.BigEndian.PutUint16(out, uint16(len(lookups)))
+	for i, lookup := range lookups {
+		lookupOffset := lookupOffsets[i]
+		if lookupOffset > 0xFFFF {
+			return nil, errOffsetOverflow
+		}
+		binary.BigEndian.PutUint16(out[2+2*i:], uint16(lookupOffset))
+
+		kind := lookup.kind
+		if extensionType != 0 {
+			kind = extensionType
+		}
+		binary.BigEndian.PutUint16(out[lookupOffset:], kind)
+		binary.BigEndian.PutUint16(out[lookupOffset+2:], lookup.Flag)
+		binary.BigEndian.PutUint16(out[lookupOffset+4:], uint16(len(lookup.subtables)))
+		for j, subtable := range lookup.subtables {
+			subtableOffset := len(out)
+			out = append(out, subtable...)
+			if extensionType != 0 {
+				binary.BigEndian.PutUint16(out[extensionOffset:], 1) // format
+				binary.BigEndian.PutUint16(out[extensionOffset+2:], lookup.kind)
+				binary.BigEndian.PutUint32(out[extensionOffset+4:], uint32(subtableOffset-extensionOffset))
+				subtableOffset = extensionOffset
+				extensionOffset += 8
+			}
+			if subtableOffset-lookupOffset > 0xFFFF {
+				return nil, errOffsetOverflow
+			}
+			binary.BigEndian.PutUint16(out[lookupOffset+6+2*j:], uint16(subtableOffset-lookupOffset))
+		}
+		if lookup.Flag&UseMarkFilteringSet != 0 {
+			binary.BigEndian.PutUint16(out[lookupOffset+6+2*len(lookup.subtables):], lookup.MarkFilteringSet)
+		}
+	}
+	return out, nil
+}
+
+func writeScriptList(scripts []Script) *serialTable {
+	t := &serialTable{}
+	t.u16(uint16(len(scripts)))
+	for _, script := range scripts {
+		t.tag(script.Tag)
+		t.offset16(writeScript(script))
+	}
+	return t
+}
+
+func writeScript(script Script) *serialTable {
+	t := &serialTable{}
+	if script.DefaultLanguage != nil {
+		t.offset16(writeLangSys(*script.DefaultLanguage))
+	} else {
+		t.u16(0)
+	}
+	t.u16(uint16(len(script.Languages)))
+	for _, lang := range script.Languages {
+		t.tag(lang.Tag)
+		t.offset16(writeLangSys(lang))
+	}
+	return t
+}
+
+func writeLangSys(lang LangSys) *serialTable {
+	t := &serialTable{}
+	t.u16(0) // lookupOrder
+	t.u16(lang.RequiredFeatureIndex)
+	t.u16(uint16(len(lang.Features)))
+	for _, index := range lang.Features {
+		t.u16(index)
+	}
+	return t
+}
+
+func writeFeatureList(features []FeatureRecord) *serialTable {
+	t := &serialTable{}
+	t.u16(uint16(len(features)))
+	for _, feature := range features {
+		t.tag(feature.Tag)
+		t.offset16(writeFeature(feature.Feature))
+	}
+	return t
+}
+
+func writeFeature(feature Feature) *serialTable {
+	t := &serialTable{}
+	t.u16(0) // featureParams
+	t.u16(uint16(len(feature.LookupIndices)))
+	for _, index := range feature.LookupIndices {
+		t.u16(index)
+	}
+	return t
+}
+
+func writeFeatureVariations(variations []FeatureVariation) *serialTable {
+	t := &serialTable{}
+	t.u16(1) // major version
+	t.u16(0) // minor version
+	t.u32(uint32(len(variations)))
+	for _, variation := range variations {
+		conditions := &serialTable{}
+		conditions.u16(uint16(len(variation.ConditionSet)))
+		for _, condition := range variation.ConditionSet {
+			c := &serialTable{}
+			c.u16(1) // format
+			c.u16(condition.Axis)
+			c.u16(floatToFixed214(condition.Min))
+			c.u16(floatToFixed214(condition.Max))
+			conditions.offset32(c)
+		}
+
+		substitutions := &serialTable{}
+		substitutions.u16(1) // major version
+		substitutions.u16(0) // minor version
+		substitutions.u16(uint16(len(variation.FeatureSubstitutions)))
+		for _, subs := range variation.FeatureSubstitutions {
+			substitutions.u16(subs.FeatureIndex)
+			substitutions.offset32(writeFeature(subs.AlternateFeature))
+		}
+
+		t.offset32(conditions)
+		t.offset32(substitutions)
+	}
+	return t
+}
+
+// writeCoverage uses the most compact format.
+func writeCoverage(cov Coverage) *serialTable {
+	glyphs := coverageGlyphs(cov)
+	var ranges CoverageRanges
+	for index, g := range glyphs {
+		if L := len(ranges); L != 0 && ranges[L-1].End+1 == g {
+			ranges[L-1].End = g
+			continue
+		}
+		ranges = append(ranges, CoverageRange{Start: g, End: g, StartCoverage: index})
+	}
+
+	t := &serialTable{}
+	if 6*len(ranges) < 2*len(glyphs) {
+		t.u16(2)
+		t.u16(uint16(len(ranges)))
+		for _, rang := range ranges {
+			t.u16(uint16(rang.Start))
+			t.u16(uint16(rang.End))
+			t.u16(uint16(rang.StartCoverage))
+		}
+	} else {
+		t.u16(1)
+		t.u16(uint16(len(glyphs)))
+		for _, g := range glyphs {
+			t.u16(uint16(g))
+		}
+	}
+	return t
+}
+
+// writeClass uses the most compact format.
+// A nil class is written as an empty class definition.
+func writeClass(class Class) *serialTable {
+	var ranges classFormat2 // with non zero classes
+	addRange := func(rang classRangeRecord) {
+		if L := len(ranges); L != 0 && ranges[L-1].end+1 == rang.start && ranges[L-1].targetClassID == rang.targetClassID {
+			ranges[L-1].end = rang.end
+		} else {
+			ranges = append(ranges, rang)
+		}
+	}
+	switch class := class.(type) {
+	case classFormat1:
+		for i, classID := range class.classIDs {
+			if classID != 0 {
+				g := gid(class.startGlyph) + gid(i)
+				addRange(classRangeRecord{start: g, end: g, targetClassID: classID})
+			}
+		}
+	case classFormat2:
+		for _, rang := range class {
+			if rang.targetClassID != 0 {
+				addRange(rang)
+			}
+		}
+	}
+
+	t := &serialTable{}
+	if L := len(ranges); L != 0 && 2*int(ranges[L-1].end-ranges[0].start+1)+2 < 6*L {
+		t.u16(1)
+		start := ranges[0].start
+		t.u16(start)
+		t.u16(ranges[L-1].end - start + 1)
+		classIDs := make([]uint16, ranges[L-1].end-start+1)
+		for _, rang := range ranges {
+			for g := rang.start; g <= rang.end; g++ {
+				classIDs[g-start] = uint16(rang.targetClassID)
+			}
+		}
+		for _, classID := range classIDs {
+			t.u16(classID)
+		}
+	} else {
+		t.u16(2)
+		t.u16(uint16(L))
+		for _, rang := range ranges {
+			t.u16(rang.start)
+			t.u16(rang.end)
+			t.u16(uint16(rang.targetClassID))
+		}
+	}
+	return t
+}
+
+// writeDevice returns nil for a nil device
+func writeDevice(device DeviceTable) *serialTable {
+	t := &serialTable{}
+	switch device := device.(type) {
+	case DeviceHinting:
+		format := uint16(1)
+		for _, v := range device.Values {
+			if v < -8 || v > 7 {
+				format = 3
+			} else if (v < -2 || v > 1) && format == 1 {
+				format = 2
+			}
+		}
+		t.u16(device.StartSize)
+		t.u16(device.EndSize)
+		t.u16(format)
+		bitSize := 1 << format // 2, 4 or 8
+		perWord := 16 / bitSize
+		mask := uint16(1)<<bitSize - 1
+		var word uint16
+		for i, v := range device.Values {
+			word |= (uint16(v) & mask) << (16 - bitSize*(i%perWord+1))
+			if i%perWord == perWord-1 || i == len(device.Values)-1 {
+				t.u16(word)
+				word = 0
+			}
+		}
+	case DeviceVariation:
+		t.u16(device.DeltaSetOuter)
+		t.u16(device.DeltaSetInner)
+		t.u16(0x8000)
+	default:
+		return nil
+	}
+	return t
+}
+
+// writeValueRecord appends the record to `t`, which is the parent
+// table of the device tables.
+func writeValueRecord(t *serialTable, format GPOSValueFormat, record GPOSValueRecord) {
+	if format&XPlacement != 0 {
+		t.u16(uint16(record.XPlacement))
+	}
+	if format&YPlacement != 0 {
+		t.u16(uint16(record.YPlacement))
+	}
+	if format&XAdvance != 0 {
+		t.u16(uint16(record.XAdvance))
+	}
+	if format&YAdvance != 0 {
+		t.u16(uint16(record.YAdvance))
+	}
+	if format&XPlaDevice != 0 {
+		t.offset16(writeDevice(record.XPlaDevice))
+	}
+	if format&YPlaDevice != 0 {
+		t.offset16(writeDevice(record.YPlaDevice))
+	}
+	if format&XAdvDevice != 0 {
+		t.offset16(writeDevice(record.XAdvDevice))
+	}
+	if format&YAdvDevice != 0 {
+		t.offset16(writeDevice(record.YAdvDevice))
+	}
+}
+
+// writeAnchor returns nil for a nil anchor
+func writeAnchor(anchor GPOSAnchor) *serialTable {
+	t := &serialTable{}
+	switch anchor := anchor.(type) {
+	case GPOSAnchorFormat1:
+		t.u16(1)
+		t.u16(uint16(anchor.X))
+		t.u16(uint16(anchor.Y))
+	case GPOSAnchorFormat2:
+		t.u16(2)
+		t.u16(uint16(anchor.X))
+		t.u16(uint16(anchor.Y))
+		t.u16(anchor.AnchorPoint)
+	case GPOSAnchorFormat3:
+		t.u16(3)
+		t.u16(uint16(anchor.X))
+		t.u16(uint16(anchor.Y))
+		t.offset16(writeDevice(anchor.XDevice))
+		t.offset16(writeDevice(anchor.YDevice))
+	default:
+		return nil
+	}
+	return t
+}
+
+func writeGlyphArray(glyphs []GID) *serialTable {
+	t := &serialTable{}
+	t.u16(uint16(len(glyphs)))
+	for _, g := range glyphs {
+		t.u16(uint16(g))
+	}
+	return t
+}
+
+func (t *serialTable) uint16s(values []uint16) {
+	for _, v := range values {
+		t.u16(v)
+	}
+}
+
+func (t *serialTable) sequenceLookups(lookups []SequenceLookup) {
+	for _, lookup := range lookups {
+		t.u16(lookup.InputIndex)
+		t.u16(lookup.LookupIndex)
+	}
+}
+
+func writeSequenceRuleSet(rules []SequenceRule) *serialTable {
+	if len(rules) == 0 {
+		return nil
+	}
+	t := &serialTable{}
+	t.u16(uint16(len(rules)))
+	for _, rule := range rules {
+		r := &serialTable{}
+		r.u16(uint16(len(rule.Input) + 1))
+		r.u16(uint16(len(rule.Lookups)))
+		r.uint16s(rule.Input)
+		r.sequenceLookups(rule.Lookups)
+		t.offset16(r)
+	}
+	return t
+}
+
+func writeChainedSequenceRuleSet(rules []ChainedSequenceRule) *serialTable {
+	if len(rules) == 0 {
+		return nil
+	}
+	t := &serialTable{}
+	t.u16(uint16(len(rules)))
+	for _, rule := range rules {
+		r := &serialTable{}
+		r.u16(uint16(len(rule.Backtrack)))
+		r.uint16s(rule.Backtrack)
+		r.u16(uint16(len(rule.Input) + 1))
+		r.uint16s(rule.Input)
+		r.u16(uint16(len(rule.Lookahead)))
+		r.uint16s(rule.Lookahead)
+		r.u16(uint16(len(rule.Lookups)))
+		r.sequenceLookups(rule.Lookups)
+		t.offset16(r)
+	}
+	return t
+}
+
+func (t *serialTable) coverages(covs []Coverage) {
+	t.u16(uint16(len(covs)))
+	for _, cov := range covs {
+		t.offset16(writeCoverage(cov))
+	}
+}
+
+func (lc LookupContext1) writeTo(t *serialTable, cov Coverage) {
+	t.u16(1)
+	t.offset16(writeCoverage(cov))
+	t.u16(uint16(len(lc)))
+	for _, rules := range lc {
+		t.offset16(writeSequenceRuleSet(rules))
+	}
+}
+
+func (lc LookupContext2) writeTo(t *serialTable, cov Coverage) {
+	t.u16(2)
+	t.offset16(writeCoverage(cov))
+	t.offset16(writeClass(lc.Class))
+	t.u16(uint16(len(lc.SequenceSets)))
+	for _, rules := range lc.SequenceSets {
+		t.offset16(writeSequenceRuleSet(rules))
+	}
+}
+
+func (lc LookupContext3) writeTo(t *serialTable) {
+	t.u16(3)
+	t.u16(uint16(len(lc.Coverages)))
+	t.u16(uint16(len(lc.SequenceLookups)))
+	for _, cov := range lc.Coverages {
+		t.offset16(writeCoverage(cov))
+	}
+	t.sequenceLookups(lc.SequenceLookups)
+}
+
+func (lc LookupChainedContext1) writeTo(t *serialTable, cov Coverage) {
+	t.u16(1)
+	t.offset16(writeCoverage(cov))
+	t.u16(uint16(len(lc)))
+	for _, rules := range lc {
+		t.offset16(writeChainedSequenceRuleSet(rules))
+	}
+}
+
+func (lc LookupChainedContext2) writeTo(t *serialTable, cov Coverage) {
+	t.u16(2)
+	t.offset16(writeCoverage(cov))
+	t.offset16(writeClass(lc.BacktrackClass))
+	t.offset16(writeClass(lc.InputClass))
+	t.offset16(writeClass(lc.LookaheadClass))
+	t.u16(uint16(len(lc.SequenceSets)))
+	for _, rules := range lc.SequenceSets {
+		t.offset16(writeChainedSequenceRuleSet(rules))
+	}
+}
+
+func (lc LookupChainedContext3) writeTo(t *serialTable) {
+	t.u16(3)
+	t.coverages(lc.Backtrack)
+	t.coverages(lc.Input)
+	t.coverages(lc.Lookahead)
+	t.u16(uint16(len(lc.SequenceLookups)))
+	t.sequenceLookups(lc.SequenceLookups)
+}
+
+func (s GSUBSubtable) serialTable() *serialTable {
+	t := &serialTable{}
+	switch data := s.Data.(type) {
+	case GSUBSingle1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(data))
+	case GSUBSingle2:
+		t.u16(2)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(len(data)))
+		for _, g := range data {
+			t.u16(uint16(g))
+		}
+	case GSUBMultiple1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(len(data)))
+		for _, sequence := range data {
+			t.offset16(writeGlyphArray(sequence))
+		}
+	case GSUBAlternate1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(len(data)))
+		for _, set := range data {
+			t.offset16(writeGlyphArray(set))
+		}
+	case GSUBLigature1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(len(data)))
+		for _, ligatures := range data {
+			set := &serialTable{}
+			set.u16(uint16(len(ligatures)))
+			for _, ligature := range ligatures {
+				l := &serialTable{}
+				l.u16(uint16(ligature.Glyph))
+				l.u16(uint16(len(ligature.Components) + 1))
+				l.uint16s(ligature.Components)
+				set.offset16(l)
+			}
+			t.offset16(set)
+		}
+	case GSUBContext1:
+		LookupContext1(data).writeTo(t, s.Coverage)
+	case GSUBContext2:
+		LookupContext2(data).writeTo(t, s.Coverage)
+	case GSUBContext3:
+		LookupContext3(data).writeTo(t)
+	case GSUBChainedContext1:
+		LookupChainedContext1(data).writeTo(t, s.Coverage)
+	case GSUBChainedContext2:
+		LookupChainedContext2(data).writeTo(t, s.Coverage)
+	case GSUBChainedContext3:
+		LookupChainedContext3(data).writeTo(t)
+	case GSUBReverseChainedContext1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.coverages(data.Backtrack)
+		t.coverages(data.Lookahead)
+		t.u16(uint16(len(data.Substitutes)))
+		for _, g := range data.Substitutes {
+			t.u16(uint16(g))
+		}
+	}
+	return t
+}
+
+func (s GPOSSubtable) serialTable() *serialTable {
+	t := &serialTable{}
+	switch data := s.Data.(type) {
+	case GPOSSingle1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(data.Format))
+		writeValueRecord(t, data.Format, data.Value)
+	case GPOSSingle2:
+		t.u16(2)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(data.Format))
+		t.u16(uint16(len(data.Values)))
+		for _, value := range data.Values {
+			writeValueRecord(t, data.Format, value)
+		}
+	case GPOSPair1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(data.Formats[0]))
+		t.u16(uint16(data.Formats[1]))
+		t.u16(uint16(len(data.Values)))
+		for _, pairs := range data.Values {
+			set := &serialTable{}
+			set.u16(uint16(len(pairs)))
+			for _, pair := range pairs {
+				set.u16(uint16(pair.SecondGlyph))
+				writeValueRecord(set, data.Formats[0], pair.Pos[0])
+				writeValueRecord(set, data.Formats[1], pair.Pos[1])
+			}
+			t.offset16(set)
+		}
+	case GPOSPair2:
+		t.u16(2)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(data.Formats[0]))
+		t.u16(uint16(data.Formats[1]))
+		t.offset16(writeClass(data.First))
+		t.offset16(writeClass(data.Second))
+		class2Count := data.Second.Extent()
+		if len(data.Values) != 0 {
+			class2Count = len(data.Values[0])
+		}
+		t.u16(uint16(len(data.Values)))
+		t.u16(uint16(class2Count))
+		for _, row := range data.Values {
+			for _, values := range row {
+				writeValueRecord(t, data.Formats[0], values[0])
+				writeValueRecord(t, data.Formats[1], values[1])
+			}
+		}
+	case GPOSCursive1:
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.u16(uint16(len(data)))
+		for _, anchors := range data {
+			t.offset16(writeAnchor(anchors[0]))
+			t.offset16(writeAnchor(anchors[1]))
+		}
+	case GPOSMarkToBase1:
+		writeMarkAttachment(t, s.Coverage, data.BaseCoverage, data.Marks, data.Bases)
+	case GPOSMarkToMark1:
+		writeMarkAttachment(t, s.Coverage, data.Mark2Coverage, data.Marks1, data.Marks2)
+	case GPOSMarkToLigature1:
+		classCount := markClassCount(data.Marks)
+		for _, ligature := range data.Ligatures {
+			for _, component := range ligature {
+				if len(component) > classCount {
+					classCount = len(component)
+				}
+			}
+		}
+		t.u16(1)
+		t.offset16(writeCoverage(s.Coverage))
+		t.offset16(writeCoverage(data.LigatureCoverage))
+		t.u16(uint16(classCount))
+		t.offset16(writeMarkArray(data.Marks))
+		ligatures := &serialTable{}
+		ligatures.u16(uint16(len(data.Ligatures)))
+		for _, ligature := range data.Ligatures {
+			ligatures.offset16(writeAnchorMatrix(ligature, classCount))
+		}
+		t.offset16(ligatures)
+	case GPOSContext1:
+		LookupContext1(data).writeTo(t, s.Coverage)
+	case GPOSContext2:
+		LookupContext2(data).writeTo(t, s.Coverage)
+	case GPOSContext3:
+		LookupContext3(data).writeTo(t)
+	case GPOSChainedContext1:
+		LookupChainedContext1(data).writeTo(t, s.Coverage)
+	case GPOSChainedContext2:
+		LookupChainedContext2(data).writeTo(t, s.Coverage)
+	case GPOSChainedContext3:
+		LookupChainedContext3(data).writeTo(t)
+	}
+	return t
+}
+
+func markClassCount(marks []GPOSMark) int {
+	count := 0
+	for _, mark := range marks {
+		if int(mark.ClassValue) >= count {
+			count = int(mark.ClassValue) + 1
+		}
+	}
+	return count
+}
+
+// writeMarkAttachment writes a mark-to-base or mark-to-mark subtable.
+func writeMarkAttachment(t *serialTable, markCov, baseCov Coverage, marks []GPOSMark, bases [][]GPOSAnchor) {
+	classCount := markClassCount(marks)
+	for _, base := range bases {
+		if len(base) > classCount {
+			classCount = len(base)
+		}
+	}
+	t.u16(1)
+	t.offset16(writeCoverage(markCov))
+	t.offset16(writeCoverage(baseCov))
+	t.u16(uint16(classCount))
+	t.offset16(writeMarkArray(marks))
+	t.offset16(writeAnchorMatrix(bases, classCount))
+}
+
+func writeMarkArray(marks []GPOSMark) *serialTable {
+	t := &serialTable{}
+	t.u16(uint16(len(marks)))
+	for _, mark := range marks {
+		t.u16(mark.ClassValue)
+		t.offset16(writeAnchor(mark.Anchor))
+	}
+	return t
+}
+
+// writeAnchorMatrix writes a BaseArray, a Mark2Array or a LigatureAttach table
+func writeAnchorMatrix(anchors [][]GPOSAnchor, classCount int) *serialTable {
+	t := &serialTable{}
+	t.u16(uint16(len(anchors)))
+	for _, row := range anchors {
+		for class := 0; class < classCount; class++ {
+			var anchor GPOSAnchor
+			if class < len(row) {
+				anchor = row[class]
+			}
+			t.offset16(writeAnchor(anchor))
+		}
+	}
+	return t
+}
+
+// writeTo serializes the table. The attachment list is not written.
+func (t TableGDEF) writeTo() ([]byte, error) {
+	hasStore := len(t.VariationStore.Regions) != 0 || len(t.VariationStore.Datas) != 0
+	minor := uint16(0)
+	if t.MarkGlyphSet != nil {
+		minor = 2
+	}
+	if hasStore {
+		minor = 3
+	}
+
+	root := &serialTable{}
+	root.u16(1)
+	root.u16(minor)
+	if t.Class != nil {
+		root.offset16(writeClass(t.Class))
+	} else {
+		root.u16(0)
+	}
+	root.u16(0) // attachment list
+	if carets := t.LigatureCaretList; carets.Coverage != nil {
+		list := &serialTable{}
+		list.offset16(writeCoverage(carets.Coverage))
+		list.u16(uint16(len(carets.LigCarets)))
+		for _, ligature := range carets.LigCarets {
+			lig := &serialTable{}
+			lig.u16(uint16(len(ligature)))
+			for _, caret := range ligature {
+				lig.offset16(writeCaretValue(caret))
+			}
+			list.offset16(lig)
+		}
+		root.offset16(list)
+	} else {
+		root.u16(0)
+	}
+	if t.MarkAttach != nil {
+		root.offset16(writeClass(t.MarkAttach))
+	} else {
+		root.u16(0)
+	}
+	if minor >= 2 {
+		if t.MarkGlyphSet != nil {
+			sets := &serialTable{}
+			sets.u16(1) // format
+			sets.u16(uint16(len(t.MarkGlyphSet)))
+			for _, set := range t.MarkGlyphSet {
+				sets.offset32(writeCoverage(set))
+			}
+			root.offset16(sets)
+		} else {
+			root.u16(0)
+		}
+	}
+	if hasStore {
+		root.offset32(t.VariationStore.serialTable())
+	}
+	return root.serialize()
+}
+
+func writeCaretValue(caret CaretValue) *serialTable {
+	t := &serialTable{}
+	switch caret := caret.(type) {
+	case CaretValueFormat1:
+		t.u16(1)
+		t.u16(uint16(caret))
+	case CaretValueFormat2:
+		t.u16(2)
+		t.u16(uint16(caret))
+	case CaretValueFormat3:
+		t.u16(3)
+		t.u16(uint16(caret.Coordinate))
+		t.offset16(writeDevice(caret.Device))
+	default:
+		return nil
+	}
+	return t
+}
+
+func (store VariationStore) serialTable() *serialTable {
+	axisCount := 0
+	if len(store.Regions) != 0 {
+		axisCount = len(store.Regions[0])
+	}
+	regions := &serialTable{}
+	regions.u16(uint16(axisCount))
+	regions.u16(uint16(len(store.Regions)))
+	for _, region := range store.Regions {
+		for _, axis := range region {
+			regions.u16(floatToFixed214(axis[0]))
+			regions.u16(floatToFixed214(axis[1]))
+			regions.u16(floatToFixed214(axis[2]))
+		}
+	}
+
+	t := &serialTable{}
+	t.u16(1) // format
+	t.offset32(regions)
+	t.u16(uint16(len(store.Datas)))
+	for _, data := range store.Datas {
+		t.offset32(data.serialTable())
+	}
+	return t
+}
+
+func (data ItemVariationData) serialTable() *serialTable {
+	// the columns before the last one requiring 16 bits are written with 16 bits
+	shortCount := 0
+	for _, row := range data.Deltas {
+		for j, delta := range row {
+			if (delta < -128 || delta > 127) && j >= shortCount {
+				shortCount = j + 1
+			}
+		}
+	}
+	t := &serialTable{}
+	t.u16(uint16(len(data.Deltas)))
+	t.u16(uint16(shortCount))
+	t.u16(uint16(len(data.RegionIndexes)))
+	t.uint16s(data.RegionIndexes)
+	for _, row := range data.Deltas {
+		for j, delta := range row {
+			if j < shortCount {
+				t.u16(uint16(delta))
+			} else {
+				t.data = append(t.data, byte(int8(delta)))
+			}
+		}
+	}
+	return t
+}
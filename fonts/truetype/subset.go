@@ -0,0 +1,290 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/benoitkugler/textlayout/fonts"
+	type1c "github.com/benoitkugler/textlayout/fonts/type1C"
+)
+
+// tables which do not depend on the glyphs, and are copied as they are
+var subsetPassThroughTables = []Tag{
+	tagName, MustNewTag("cvt "), MustNewTag("fpgm"), TagPrep, MustNewTag("gasp"),
+	tagFvar, tagAvar, MustNewTag("cvar"), tagMvar, MustNewTag("STAT"), MustNewTag("meta"),
+}
+
+// SubsetInput describes the content of a font subset.
+type SubsetInput struct {
+	// Runes are the characters to keep, mapped to glyphs with the 'cmap' table.
+	Runes []rune
+	// Glyphs are additional glyphs to keep.
+	Glyphs []GID
+	// If RetainGIDs is true, the glyphs keep their index in the subset,
+	// and the removed glyphs are replaced by empty glyphs.
+	// Otherwise, the glyphs are renumbered, preserving their order.
+	RetainGIDs bool
+}
+
+// Subset returns a font file containing only the glyphs required to
+// display `input`.
+//
+// The glyph set is first closed over the substitutions of the 'GSUB' table
+// and the components of composite glyphs. Then, the 'glyf', 'loca', 'CFF ',
+// 'cmap', 'hmtx', 'vmtx', 'post', 'gvar', 'GSUB', 'GPOS' and 'GDEF' tables
+// are subsetted, and the tables which don't depend on the glyphs, such as 'name',
+// are copied. The other tables (for instance bitmaps, colors or AAT tables) are dropped.
+// Only Unicode characters in `input.Runes` are kept in the 'cmap' table.
+//
+// CFF2 outlines are not supported.
+func (pr *FontParser) Subset(input SubsetInput) ([]byte, error) {
+	numGlyphs, err := pr.NumGlyphs()
+	if err != nil {
+		return nil, err
+	}
+	head, err := pr.loadHeadTable()
+	if err != nil {
+		return nil, err
+	}
+	cmaps, err := pr.CmapTable()
+	if err != nil {
+		return nil, err
+	}
+	names, err := pr.tryAndLoadNameTable()
+	if err != nil {
+		return nil, err
+	}
+	fvar, err := pr.tryAndLoadFvarTable(names)
+	if err != nil {
+		return nil, err
+	}
+
+	isCFF := pr.HasTable(tagCFF)
+	if !isCFF && !pr.HasTable(tagGlyf) {
+		return nil, errors.New("subsetting requires 'glyf' or 'CFF ' outlines")
+	}
+
+	// select the initial glyphs
+	glyphs := glyphSet{0: true} // .notdef is always included
+	cmap, encoding := cmaps.BestEncoding()
+	runes := map[rune]GID{}
+	if cmap != nil {
+		for _, r := range input.Runes {
+			if g, ok := cmap.Lookup(r); ok && int(g) < numGlyphs {
+				runes[r] = g
+				glyphs[g] = true
+			}
+		}
+	}
+	for _, g := range input.Glyphs {
+		if int(g) < numGlyphs {
+			glyphs[g] = true
+		}
+	}
+
+	// close the glyph set
+	var layout LayoutTables
+	if pr.HasTable(TagGsub) {
+		layout.GSUB, err = pr.GSUBTable()
+		if err != nil {
+			return nil, fmt.Errorf("invalid GSUB table: %s", err)
+		}
+		layout.GSUB.closeGlyphs(glyphs)
+	}
+	var glyf TableGlyf
+	if !isCFF {
+		glyf, err = pr.GlyfTable(numGlyphs, head.indexToLocFormat)
+		if err != nil {
+			return nil, err
+		}
+		glyf.closeGlyphs(glyphs)
+	}
+	for g := range glyphs { // invalid fonts may reference invalid glyphs
+		if int(g) >= numGlyphs {
+			delete(glyphs, g)
+		}
+	}
+
+	mapping := newGlyphMapping(glyphs, input.RetainGIDs)
+
+	tables := make(map[Tag][]byte)
+	for _, tag := range subsetPassThroughTables {
+		if table, err := pr.GetRawTable(tag); err == nil {
+			tables[tag] = table
+		}
+	}
+
+	// outlines
+	var locaFormat int16
+	if isCFF {
+		cff, err := pr.GetRawTable(tagCFF)
+		if err != nil {
+			return nil, err
+		}
+		tables[tagCFF], err = type1c.Subset(cff, mapping.glyphs, input.RetainGIDs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CFF table: %s", err)
+		}
+	} else {
+		tables[tagGlyf], tables[tagLoca], locaFormat, err = pr.subsetGlyf(numGlyphs, head.indexToLocFormat, mapping)
+		if err != nil {
+			return nil, err
+		}
+		if len(fvar.Axis) != 0 && pr.HasTable(tagGvar) {
+			tables[tagGvar], err = pr.subsetGvar(mapping)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if tables[tagHead], err = pr.GetRawTable(tagHead); err != nil {
+		return nil, err
+	}
+	if len(tables[tagHead]) < 54 {
+		return nil, errors.New("invalid 'head' table (EOF)")
+	}
+	binary.BigEndian.PutUint16(tables[tagHead][50:], uint16(locaFormat))
+
+	if tables[tagMaxp], err = pr.GetRawTable(tagMaxp); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(tables[tagMaxp][4:], uint16(mapping.numGlyphs)) // length checked in NumGlyphs
+
+	// metrics
+	hmtx, err := pr.HtmxTable(numGlyphs)
+	if err != nil {
+		return nil, err
+	}
+	tables[tagHhea], tables[tagHmtx], err = pr.subsetMetrics(tagHhea, hmtx, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if vmtx, err := pr.VtmxTable(numGlyphs); err == nil {
+		tables[tagVhea], tables[tagVmtx], err = pr.subsetMetrics(tagVhea, vmtx, mapping)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// character mapping
+	newRunes := make(map[rune]GID, len(runes))
+	for r, g := range runes {
+		newRunes[r], _ = mapping.get(g)
+	}
+	tables[tagCmap] = writeCmap(newRunes, encoding == fonts.EncSymbol)
+	if os2, err := pr.GetRawTable(tagOS2); err == nil {
+		tables[tagOS2] = updateOS2CharRange(os2, newRunes)
+	}
+
+	if post, err := pr.PostTable(numGlyphs); err == nil {
+		raw, _ := pr.GetRawTable(tagPost) // error checked in PostTable
+		tables[tagPost] = subsetPost(raw, post, mapping, isCFF)
+	}
+
+	// layout tables
+	if pr.HasTable(TagGdef) {
+		layout.GDEF, err = pr.GDEFTable(len(fvar.Axis))
+		if err != nil {
+			return nil, fmt.Errorf("invalid GDEF table: %s", err)
+		}
+		tables[TagGdef], err = layout.GDEF.subset(mapping).writeTo()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pr.HasTable(TagGsub) {
+		tables[TagGsub], err = layout.GSUB.subset(mapping).writeTo()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pr.HasTable(TagGpos) {
+		layout.GPOS, err = pr.GPOSTable()
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPOS table: %s", err)
+		}
+		tables[TagGpos], err = layout.GPOS.subset(mapping).writeTo()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return writeOTF(pr.Type, tables), nil
+}
+
+// glyphSet is a set of glyph indices
+type glyphSet map[GID]bool
+
+// sorted returns the glyphs of the set, in increasing order
+func (s glyphSet) sorted() []GID {
+	out := make([]GID, 0, len(s))
+	for g := range s {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// glyphMapping maps the glyphs of a font to the glyphs of its subset.
+// The order of the glyphs is preserved.
+type glyphMapping struct {
+	newGIDs   map[GID]GID
+	glyphs    []GID // the glyphs kept, sorted, in the original font
+	numGlyphs int   // number of glyphs in the subset
+}
+
+func newGlyphMapping(glyphs glyphSet, retainGIDs bool) glyphMapping {
+	out := glyphMapping{glyphs: glyphs.sorted(), newGIDs: make(map[GID]GID, len(glyphs))}
+	for i, g := range out.glyphs {
+		if retainGIDs {
+			out.newGIDs[g] = g
+		} else {
+			out.newGIDs[g] = GID(i)
+		}
+	}
+	out.numGlyphs = len(out.glyphs)
+	if retainGIDs {
+		out.numGlyphs = int(out.glyphs[len(out.glyphs)-1]) + 1
+	}
+	return out
+}
+
+// get returns the glyph in the subset, or false if `g` is removed.
+func (m glyphMapping) get(g GID) (GID, bool) {
+	newG, ok := m.newGIDs[g]
+	return newG, ok
+}
+
+// oldGlyph returns the glyph of the original font stored at `newG`,
+// or false if `newG` is an empty glyph.
+func (m glyphMapping) oldGlyph(newG GID) (GID, bool) {
+	if m.numGlyphs == len(m.glyphs) { // renumbered or no glyph removed
+		return m.glyphs[newG], true
+	}
+	_, ok := m.newGIDs[newG]
+	return newG, ok
+}
+
+// closeGlyphs adds to `glyphs` the components of the composite glyphs.
+func (t TableGlyf) closeGlyphs(glyphs glyphSet) {
+	var addComponents func(g GID, depth int)
+	addComponents = func(g GID, depth int) {
+		if depth > maxCompositeNesting || int(g) >= len(t) {
+			return
+		}
+		composite, ok := t[g].data.(compositeGlyphData)
+		if !ok {
+			return
+		}
+		for _, part := range composite.glyphs {
+			glyphs[part.glyphIndex] = true
+			addComponents(part.glyphIndex, depth+1)
+		}
+	}
+	for _, g := range glyphs.sorted() {
+		addComponents(g, 0)
+	}
+}
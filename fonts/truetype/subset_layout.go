@@ -0,0 +1,628 @@
+package truetype
+
+// This file implements the closure of the glyph set over the 'GSUB' table,
+// and the subsetting of the parsed layout tables.
+// The subsetted tables use the glyph indices of the subset.
+
+// coverageGlyphs returns the glyphs covered by `cov`, in coverage index order.
+func coverageGlyphs(cov Coverage) []GID {
+	switch cov := cov.(type) {
+	case CoverageList:
+		return cov
+	case CoverageRanges:
+		out := make([]GID, cov.Size())
+		for _, rang := range cov {
+			for g := rang.Start; g <= rang.End; g++ {
+				if index := rang.StartCoverage + int(g-rang.Start); index < len(out) {
+					out[index] = g
+				}
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// subsetCovered calls `keep` for each glyph of `cov` kept in the subset,
+// with its coverage index, and returns the new coverage made of the glyphs
+// for which `keep` returned true.
+func subsetCovered(cov Coverage, m glyphMapping, keep func(index int, glyph GID) bool) CoverageList {
+	var out CoverageList
+	for index, g := range coverageGlyphs(cov) {
+		if newG, ok := m.get(g); ok && keep(index, g) {
+			out = append(out, newG)
+		}
+	}
+	return out
+}
+
+// subsetCoverage returns the glyphs of `cov` kept in the subset.
+func subsetCoverage(cov Coverage, m glyphMapping) CoverageList {
+	return subsetCovered(cov, m, func(int, GID) bool { return true })
+}
+
+// subsetCoverages returns false if one of the coverages is empty in the subset.
+func subsetCoverages(covs []Coverage, m glyphMapping) ([]Coverage, bool) {
+	out := make([]Coverage, len(covs))
+	for i, cov := range covs {
+		newCov := subsetCoverage(cov, m)
+		if len(newCov) == 0 {
+			return nil, false
+		}
+		out[i] = newCov
+	}
+	return out, true
+}
+
+// subsetClass returns the classes of the glyphs kept in the subset.
+// The class values are not modified.
+func subsetClass(class Class, m glyphMapping) Class {
+	if class == nil {
+		return nil
+	}
+	out := classFormat2{}
+	for _, g := range m.glyphs {
+		classID, ok := class.ClassID(g)
+		if !ok || classID == 0 {
+			continue
+		}
+		newG, _ := m.get(g)
+		if L := len(out); L != 0 && GID(out[L-1].end)+1 == newG && out[L-1].targetClassID == classID {
+			out[L-1].end++
+			continue
+		}
+		out = append(out, classRangeRecord{start: gid(newG), end: gid(newG), targetClassID: classID})
+	}
+	return out
+}
+
+// mapGlyphs returns false if one of the glyphs is not in the subset.
+func mapGlyphs(glyphs []GID, m glyphMapping) ([]GID, bool) {
+	out := make([]GID, len(glyphs))
+	for i, g := range glyphs {
+		newG, ok := m.get(g)
+		if !ok {
+			return nil, false
+		}
+		out[i] = newG
+	}
+	return out, true
+}
+
+// mapGlyphs16 is the same as mapGlyphs, for glyphs stored as uint16
+func mapGlyphs16(glyphs []uint16, m glyphMapping) ([]uint16, bool) {
+	out := make([]uint16, len(glyphs))
+	for i, g := range glyphs {
+		newG, ok := m.get(GID(g))
+		if !ok {
+			return nil, false
+		}
+		out[i] = uint16(newG)
+	}
+	return out, true
+}
+
+// applies the delta of a single substitution
+func (data GSUBSingle1) substitute(g GID) GID { return GID(uint16(int(g) + int(data))) }
+
+// closeGlyphs adds to `glyphs` the glyphs which may be produced by
+// the substitutions, until a fixed point is reached.
+// This is an over-approximation: all the lookups are considered,
+// regardless of the features and of the contexts.
+func (t TableGSUB) closeGlyphs(glyphs glyphSet) {
+	for {
+		size := len(glyphs)
+		for _, lookup := range t.Lookups {
+			for _, subtable := range lookup.Subtables {
+				subtable.closeGlyphs(glyphs)
+			}
+		}
+		if len(glyphs) == size {
+			return
+		}
+	}
+}
+
+func (s GSUBSubtable) closeGlyphs(glyphs glyphSet) {
+	// the contextual lookups don't produce glyphs by themselves
+	// and are handled through their nested lookups
+	covered := coverageGlyphs(s.Coverage)
+	switch data := s.Data.(type) {
+	case GSUBSingle1:
+		for _, g := range covered {
+			if glyphs[g] {
+				glyphs[data.substitute(g)] = true
+			}
+		}
+	case GSUBSingle2:
+		closeSingle(covered, data, glyphs)
+	case GSUBReverseChainedContext1:
+		closeSingle(covered, data.Substitutes, glyphs)
+	case GSUBMultiple1:
+		closeSequences(covered, data, glyphs)
+	case GSUBAlternate1:
+		closeSequences(covered, data, glyphs)
+	case GSUBLigature1:
+		for index, g := range covered {
+			if !glyphs[g] || index >= len(data) {
+				continue
+			}
+			for _, ligature := range data[index] {
+				if hasComponents(ligature.Components, glyphs) {
+					glyphs[ligature.Glyph] = true
+				}
+			}
+		}
+	}
+}
+
+func closeSingle(covered, substitutes []GID, glyphs glyphSet) {
+	for index, g := range covered {
+		if glyphs[g] && index < len(substitutes) {
+			glyphs[substitutes[index]] = true
+		}
+	}
+}
+
+func closeSequences(covered []GID, sequences [][]GID, glyphs glyphSet) {
+	for index, g := range covered {
+		if !glyphs[g] || index >= len(sequences) {
+			continue
+		}
+		for _, substitute := range sequences[index] {
+			glyphs[substitute] = true
+		}
+	}
+}
+
+func hasComponents(components []uint16, glyphs glyphSet) bool {
+	for _, c := range components {
+		if !glyphs[GID(c)] {
+			return false
+		}
+	}
+	return true
+}
+
+// subset returns the table restricted to the glyphs of `m`.
+// The subtables left empty are removed, but the lookups are kept,
+// so that the lookup indices stay valid.
+func (t TableGSUB) subset(m glyphMapping) TableGSUB {
+	out := TableGSUB{TableLayout: t.TableLayout, Lookups: make([]LookupGSUB, len(t.Lookups))}
+	for i, lookup := range t.Lookups {
+		newLookup := LookupGSUB{LookupOptions: lookup.LookupOptions, Type: lookup.Type}
+		if len(lookup.Subtables) != 0 { // resolve extensions
+			newLookup.Type = lookup.Subtables[0].Data.Type()
+		}
+		for _, subtable := range lookup.Subtables {
+			if newSubtable, ok := subtable.subset(m); ok {
+				newLookup.Subtables = append(newLookup.Subtables, newSubtable)
+			}
+		}
+		out.Lookups[i] = newLookup
+	}
+	return out
+}
+
+// subset returns false if the subtable is empty in the subset
+func (s GSUBSubtable) subset(m glyphMapping) (GSUBSubtable, bool) {
+	var out GSUBSubtable
+	switch data := s.Data.(type) {
+	case GSUBSingle1: // the delta is not preserved by renumbering
+		var substitutes GSUBSingle2
+		out.Coverage = subsetCovered(s.Coverage, m, func(_ int, g GID) bool {
+			substitute, ok := m.get(data.substitute(g))
+			if ok {
+				substitutes = append(substitutes, substitute)
+			}
+			return ok
+		})
+		out.Data = substitutes
+	case GSUBSingle2:
+		var substitutes GSUBSingle2
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data) {
+				return false
+			}
+			substitute, ok := m.get(data[index])
+			if ok {
+				substitutes = append(substitutes, substitute)
+			}
+			return ok
+		})
+		out.Data = substitutes
+	case GSUBMultiple1:
+		var sequences GSUBMultiple1
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data) {
+				return false
+			}
+			sequence, ok := mapGlyphs(data[index], m)
+			if ok {
+				sequences = append(sequences, sequence)
+			}
+			return ok
+		})
+		out.Data = sequences
+	case GSUBAlternate1:
+		var alternates GSUBAlternate1
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data) {
+				return false
+			}
+			var set []GID
+			for _, g := range data[index] {
+				if newG, ok := m.get(g); ok {
+					set = append(set, newG)
+				}
+			}
+			if len(set) == 0 {
+				return false
+			}
+			alternates = append(alternates, set)
+			return true
+		})
+		out.Data = alternates
+	case GSUBLigature1:
+		var ligatures GSUBLigature1
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data) {
+				return false
+			}
+			var set []LigatureGlyph
+			for _, ligature := range data[index] {
+				glyph, ok := m.get(ligature.Glyph)
+				if !ok {
+					continue
+				}
+				components, ok := mapGlyphs16(ligature.Components, m)
+				if !ok {
+					continue
+				}
+				set = append(set, LigatureGlyph{Components: components, Glyph: glyph})
+			}
+			if len(set) == 0 {
+				return false
+			}
+			ligatures = append(ligatures, set)
+			return true
+		})
+		out.Data = ligatures
+	case GSUBContext1:
+		var newData LookupContext1
+		newData, out.Coverage = LookupContext1(data).subset(s.Coverage, m)
+		out.Data = GSUBContext1(newData)
+	case GSUBContext2:
+		var newData LookupContext2
+		newData, out.Coverage = LookupContext2(data).subset(s.Coverage, m)
+		out.Data = GSUBContext2(newData)
+	case GSUBContext3:
+		newData, ok := LookupContext3(data).subset(m)
+		if !ok {
+			return out, false
+		}
+		return GSUBSubtable{Coverage: newData.Coverages[0], Data: GSUBContext3(newData)}, true
+	case GSUBChainedContext1:
+		var newData LookupChainedContext1
+		newData, out.Coverage = LookupChainedContext1(data).subset(s.Coverage, m)
+		out.Data = GSUBChainedContext1(newData)
+	case GSUBChainedContext2:
+		var newData LookupChainedContext2
+		newData, out.Coverage = LookupChainedContext2(data).subset(s.Coverage, m)
+		out.Data = GSUBChainedContext2(newData)
+	case GSUBChainedContext3:
+		newData, ok := LookupChainedContext3(data).subset(m)
+		if !ok {
+			return out, false
+		}
+		return GSUBSubtable{Coverage: newData.Input[0], Data: GSUBChainedContext3(newData)}, true
+	case GSUBReverseChainedContext1:
+		var (
+			newData GSUBReverseChainedContext1
+			ok1     bool
+			ok2     bool
+		)
+		newData.Backtrack, ok1 = subsetCoverages(data.Backtrack, m)
+		newData.Lookahead, ok2 = subsetCoverages(data.Lookahead, m)
+		if !ok1 || !ok2 {
+			return out, false
+		}
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data.Substitutes) {
+				return false
+			}
+			substitute, ok := m.get(data.Substitutes[index])
+			if ok {
+				newData.Substitutes = append(newData.Substitutes, substitute)
+			}
+			return ok
+		})
+		out.Data = newData
+	default:
+		return out, false
+	}
+	return out, out.Coverage.Size() != 0
+}
+
+func (lc LookupContext1) subset(cov Coverage, m glyphMapping) (LookupContext1, CoverageList) {
+	var out LookupContext1
+	newCov := subsetCovered(cov, m, func(index int, _ GID) bool {
+		if index >= len(lc) {
+			return false
+		}
+		var rules []SequenceRule
+		for _, rule := range lc[index] {
+			if input, ok := mapGlyphs16(rule.Input, m); ok {
+				rules = append(rules, SequenceRule{Input: input, Lookups: rule.Lookups})
+			}
+		}
+		if len(rules) == 0 {
+			return false
+		}
+		out = append(out, rules)
+		return true
+	})
+	return out, newCov
+}
+
+func (lc LookupContext2) subset(cov Coverage, m glyphMapping) (LookupContext2, CoverageList) {
+	return LookupContext2{Class: subsetClass(lc.Class, m), SequenceSets: lc.SequenceSets}, subsetCoverage(cov, m)
+}
+
+func (lc LookupContext3) subset(m glyphMapping) (LookupContext3, bool) {
+	coverages, ok := subsetCoverages(lc.Coverages, m)
+	if !ok || len(coverages) == 0 {
+		return LookupContext3{}, false
+	}
+	return LookupContext3{Coverages: coverages, SequenceLookups: lc.SequenceLookups}, true
+}
+
+func (lc LookupChainedContext1) subset(cov Coverage, m glyphMapping) (LookupChainedContext1, CoverageList) {
+	var out LookupChainedContext1
+	newCov := subsetCovered(cov, m, func(index int, _ GID) bool {
+		if index >= len(lc) {
+			return false
+		}
+		var rules []ChainedSequenceRule
+		for _, rule := range lc[index] {
+			input, ok1 := mapGlyphs16(rule.Input, m)
+			backtrack, ok2 := mapGlyphs16(rule.Backtrack, m)
+			lookahead, ok3 := mapGlyphs16(rule.Lookahead, m)
+			if ok1 && ok2 && ok3 {
+				rules = append(rules, ChainedSequenceRule{
+					SequenceRule: SequenceRule{Input: input, Lookups: rule.Lookups},
+					Backtrack:    backtrack,
+					Lookahead:    lookahead,
+				})
+			}
+		}
+		if len(rules) == 0 {
+			return false
+		}
+		out = append(out, rules)
+		return true
+	})
+	return out, newCov
+}
+
+func (lc LookupChainedContext2) subset(cov Coverage, m glyphMapping) (LookupChainedContext2, CoverageList) {
+	return LookupChainedContext2{
+		BacktrackClass: subsetClass(lc.BacktrackClass, m),
+		InputClass:     subsetClass(lc.InputClass, m),
+		LookaheadClass: subsetClass(lc.LookaheadClass, m),
+		SequenceSets:   lc.SequenceSets,
+	}, subsetCoverage(cov, m)
+}
+
+func (lc LookupChainedContext3) subset(m glyphMapping) (LookupChainedContext3, bool) {
+	var out LookupChainedContext3
+	backtrack, ok1 := subsetCoverages(lc.Backtrack, m)
+	input, ok2 := subsetCoverages(lc.Input, m)
+	lookahead, ok3 := subsetCoverages(lc.Lookahead, m)
+	if !ok1 || !ok2 || !ok3 || len(input) == 0 {
+		return out, false
+	}
+	return LookupChainedContext3{Backtrack: backtrack, Input: input, Lookahead: lookahead, SequenceLookups: lc.SequenceLookups}, true
+}
+
+// subset returns the table restricted to the glyphs of `m`.
+// The subtables left empty are removed, but the lookups are kept,
+// so that the lookup indices stay valid.
+func (t TableGPOS) subset(m glyphMapping) TableGPOS {
+	out := TableGPOS{TableLayout: t.TableLayout, Lookups: make([]LookupGPOS, len(t.Lookups))}
+	for i, lookup := range t.Lookups {
+		newLookup := LookupGPOS{LookupOptions: lookup.LookupOptions, Type: lookup.Type}
+		if len(lookup.Subtables) != 0 { // resolve extensions
+			newLookup.Type = lookup.Subtables[0].Data.Type()
+		}
+		for _, subtable := range lookup.Subtables {
+			if newSubtable, ok := subtable.subset(m); ok {
+				newLookup.Subtables = append(newLookup.Subtables, newSubtable)
+			}
+		}
+		out.Lookups[i] = newLookup
+	}
+	return out
+}
+
+// subset returns false if the subtable is empty in the subset
+func (s GPOSSubtable) subset(m glyphMapping) (GPOSSubtable, bool) {
+	var out GPOSSubtable
+	switch data := s.Data.(type) {
+	case GPOSSingle1:
+		out.Coverage, out.Data = subsetCoverage(s.Coverage, m), data
+	case GPOSSingle2:
+		newData := GPOSSingle2{Format: data.Format}
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data.Values) {
+				return false
+			}
+			newData.Values = append(newData.Values, data.Values[index])
+			return true
+		})
+		out.Data = newData
+	case GPOSPair1:
+		newData := GPOSPair1{Formats: data.Formats}
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data.Values) {
+				return false
+			}
+			var set GPOSPairSet
+			for _, record := range data.Values[index] {
+				if second, ok := m.get(record.SecondGlyph); ok {
+					set = append(set, GPOSPairValueRecord{Pos: record.Pos, SecondGlyph: second})
+				}
+			}
+			if len(set) == 0 {
+				return false
+			}
+			newData.Values = append(newData.Values, set)
+			return true
+		})
+		out.Data = newData
+	case GPOSPair2:
+		newData := GPOSPair2{
+			First:   subsetClass(data.First, m),
+			Second:  subsetClass(data.Second, m),
+			Formats: data.Formats,
+		}
+		// the classes not used anymore are removed
+		newData.Values = data.Values
+		if extent := newData.First.Extent(); extent < len(newData.Values) {
+			newData.Values = newData.Values[:extent]
+		}
+		if extent := newData.Second.Extent(); len(newData.Values) != 0 && extent < len(newData.Values[0]) {
+			rows := make([][][2]GPOSValueRecord, len(newData.Values))
+			for i, row := range newData.Values {
+				rows[i] = row[:extent]
+			}
+			newData.Values = rows
+		}
+		out.Coverage, out.Data = subsetCoverage(s.Coverage, m), newData
+	case GPOSCursive1:
+		var newData GPOSCursive1
+		out.Coverage = subsetCovered(s.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(data) {
+				return false
+			}
+			newData = append(newData, data[index])
+			return true
+		})
+		out.Data = newData
+	case GPOSMarkToBase1:
+		var newData GPOSMarkToBase1
+		out.Coverage = subsetMarks(s.Coverage, data.Marks, m, &newData.Marks)
+		bases := subsetAnchors(data.BaseCoverage, data.Bases, m, &newData.Bases)
+		if len(bases) == 0 {
+			return out, false
+		}
+		newData.BaseCoverage = bases
+		out.Data = newData
+	case GPOSMarkToLigature1:
+		var newData GPOSMarkToLigature1
+		out.Coverage = subsetMarks(s.Coverage, data.Marks, m, &newData.Marks)
+		ligatures := subsetCovered(data.LigatureCoverage, m, func(index int, _ GID) bool {
+			if index >= len(data.Ligatures) {
+				return false
+			}
+			newData.Ligatures = append(newData.Ligatures, data.Ligatures[index])
+			return true
+		})
+		if len(ligatures) == 0 {
+			return out, false
+		}
+		newData.LigatureCoverage = ligatures
+		out.Data = newData
+	case GPOSMarkToMark1:
+		var newData GPOSMarkToMark1
+		out.Coverage = subsetMarks(s.Coverage, data.Marks1, m, &newData.Marks1)
+		marks2 := subsetAnchors(data.Mark2Coverage, data.Marks2, m, &newData.Marks2)
+		if len(marks2) == 0 {
+			return out, false
+		}
+		newData.Mark2Coverage = marks2
+		out.Data = newData
+	case GPOSContext1:
+		var newData LookupContext1
+		newData, out.Coverage = LookupContext1(data).subset(s.Coverage, m)
+		out.Data = GPOSContext1(newData)
+	case GPOSContext2:
+		var newData LookupContext2
+		newData, out.Coverage = LookupContext2(data).subset(s.Coverage, m)
+		out.Data = GPOSContext2(newData)
+	case GPOSContext3:
+		newData, ok := LookupContext3(data).subset(m)
+		if !ok {
+			return out, false
+		}
+		return GPOSSubtable{Coverage: newData.Coverages[0], Data: GPOSContext3(newData)}, true
+	case GPOSChainedContext1:
+		var newData LookupChainedContext1
+		newData, out.Coverage = LookupChainedContext1(data).subset(s.Coverage, m)
+		out.Data = GPOSChainedContext1(newData)
+	case GPOSChainedContext2:
+		var newData LookupChainedContext2
+		newData, out.Coverage = LookupChainedContext2(data).subset(s.Coverage, m)
+		out.Data = GPOSChainedContext2(newData)
+	case GPOSChainedContext3:
+		newData, ok := LookupChainedContext3(data).subset(m)
+		if !ok {
+			return out, false
+		}
+		return GPOSSubtable{Coverage: newData.Input[0], Data: GPOSChainedContext3(newData)}, true
+	default:
+		return out, false
+	}
+	return out, out.Coverage.Size() != 0
+}
+
+func subsetMarks(cov Coverage, marks []GPOSMark, m glyphMapping, out *[]GPOSMark) CoverageList {
+	return subsetCovered(cov, m, func(index int, _ GID) bool {
+		if index >= len(marks) {
+			return false
+		}
+		*out = append(*out, marks[index])
+		return true
+	})
+}
+
+func subsetAnchors(cov Coverage, anchors [][]GPOSAnchor, m glyphMapping, out *[][]GPOSAnchor) CoverageList {
+	return subsetCovered(cov, m, func(index int, _ GID) bool {
+		if index >= len(anchors) {
+			return false
+		}
+		*out = append(*out, anchors[index])
+		return true
+	})
+}
+
+// subset returns the table restricted to the glyphs of `m`.
+// The mark glyph sets are kept, even if empty, so that their indices stay valid.
+func (t TableGDEF) subset(m glyphMapping) TableGDEF {
+	out := TableGDEF{
+		Class:          subsetClass(t.Class, m),
+		MarkAttach:     subsetClass(t.MarkAttach, m),
+		VariationStore: t.VariationStore,
+	}
+	if t.MarkGlyphSet != nil {
+		out.MarkGlyphSet = make([]Coverage, len(t.MarkGlyphSet))
+		for i, set := range t.MarkGlyphSet {
+			out.MarkGlyphSet[i] = subsetCoverage(set, m)
+		}
+	}
+	if carets := t.LigatureCaretList; carets.Coverage != nil {
+		var newCarets [][]CaretValue
+		cov := subsetCovered(carets.Coverage, m, func(index int, _ GID) bool {
+			if index >= len(carets.LigCarets) {
+				return false
+			}
+			newCarets = append(newCarets, carets.LigCarets[index])
+			return true
+		})
+		if len(cov) != 0 {
+			out.LigatureCaretList = LigatureCaretList{Coverage: cov, LigCarets: newCarets}
+		}
+	}
+	return out
+}
@@ -0,0 +1,264 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// subsetGlyf copies the glyphs kept from the 'glyf' table, updating the
+// components of composite glyphs, and returns the new 'glyf' and 'loca' tables,
+// with the format of the 'loca' table.
+func (pr *FontParser) subsetGlyf(numGlyphs int, locationIndexFormat int16, mapping glyphMapping) (glyf, loca []byte, format int16, err error) {
+	rawLoca, err := pr.GetRawTable(tagLoca)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	offsets, err := parseTableLoca(rawLoca, numGlyphs, locationIndexFormat == 1)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	rawGlyf, err := pr.GetRawTable(tagGlyf)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	newOffsets := make([]uint32, mapping.numGlyphs+1)
+	for newG := range newOffsets[:mapping.numGlyphs] {
+		newOffsets[newG] = uint32(len(glyf))
+		oldG, ok := mapping.oldGlyph(GID(newG))
+		if !ok {
+			continue
+		}
+		start, end := offsets[oldG], offsets[oldG+1]
+		if start > end || int(end) > len(rawGlyf) {
+			return nil, nil, 0, errors.New("invalid 'glyf' table (EOF)")
+		}
+		glyf = append(glyf, rawGlyf[start:end]...)
+		data := glyf[len(glyf)-int(end-start):]
+		if len(data) >= 10 && int16(binary.BigEndian.Uint16(data)) < 0 {
+			if err = remapComponents(data[10:], mapping); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+		for len(glyf)%4 != 0 { // padding
+			glyf = append(glyf, 0)
+		}
+	}
+	newOffsets[mapping.numGlyphs] = uint32(len(glyf))
+
+	if len(glyf)/2 <= 0xFFFF { // short offsets
+		loca = make([]byte, 2*len(newOffsets))
+		for i, offset := range newOffsets {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(offset/2))
+		}
+		return glyf, loca, 0, nil
+	}
+	loca = make([]byte, 4*len(newOffsets))
+	for i, offset := range newOffsets {
+		binary.BigEndian.PutUint32(loca[4*i:], offset)
+	}
+	return glyf, loca, 1, nil
+}
+
+// remapComponents updates in place the glyph indices of the composite glyph `data`,
+// which starts after the glyph header.
+func remapComponents(data []byte, mapping glyphMapping) error {
+	const (
+		weHaveAScale       = 1 << 3
+		moreComponents     = 1 << 5
+		weHaveAnXAndYScale = 1 << 6
+		weHaveATwoByTwo    = 1 << 7
+	)
+	for {
+		if len(data) < 4 {
+			return errors.New("invalid composite glyph data (EOF)")
+		}
+		flags := binary.BigEndian.Uint16(data)
+		component, ok := mapping.get(GID(binary.BigEndian.Uint16(data[2:])))
+		if !ok {
+			return errors.New("invalid composite glyph data (missing component)")
+		}
+		binary.BigEndian.PutUint16(data[2:], uint16(component))
+
+		size := 4 + 2
+		if flags&arg1And2AreWords != 0 {
+			size = 4 + 4
+		}
+		switch {
+		case flags&weHaveAScale != 0:
+			size += 2
+		case flags&weHaveAnXAndYScale != 0:
+			size += 4
+		case flags&weHaveATwoByTwo != 0:
+			size += 8
+		}
+		if flags&moreComponents == 0 {
+			return nil
+		}
+		if len(data) < size {
+			return errors.New("invalid composite glyph data (EOF)")
+		}
+		data = data[size:]
+	}
+}
+
+// subsetGvar copies the variations of the glyphs kept.
+func (pr *FontParser) subsetGvar(mapping glyphMapping) ([]byte, error) {
+	data, err := pr.GetRawTable(tagGvar)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 20 {
+		return nil, errors.New("invalid 'gvar' table (EOF)")
+	}
+	axisCount := int(binary.BigEndian.Uint16(data[4:]))
+	sharedTupleCount := int(binary.BigEndian.Uint16(data[6:]))
+	sharedTupleOffset := int(binary.BigEndian.Uint32(data[8:]))
+	glyphCount := int(binary.BigEndian.Uint16(data[12:]))
+	flags := binary.BigEndian.Uint16(data[14:])
+	glyphVariationDataArrayOffset := int(binary.BigEndian.Uint32(data[16:]))
+
+	offsets, err := parseTableLoca(data[20:], glyphCount, flags&1 != 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'gvar' table: %s", err)
+	}
+	sharedTuplesEnd := sharedTupleOffset + 2*axisCount*sharedTupleCount
+	if len(data) < sharedTuplesEnd || len(data) < glyphVariationDataArrayOffset+int(offsets[glyphCount]) {
+		return nil, errors.New("invalid 'gvar' table (EOF)")
+	}
+	variations := data[glyphVariationDataArrayOffset:]
+
+	// header, long offsets, shared tuples and variation data
+	newSharedTupleOffset := 20 + 4*(mapping.numGlyphs+1)
+	newArrayOffset := newSharedTupleOffset + sharedTuplesEnd - sharedTupleOffset
+	out := make([]byte, newArrayOffset)
+	copy(out, data[:20])
+	binary.BigEndian.PutUint32(out[8:], uint32(newSharedTupleOffset))
+	binary.BigEndian.PutUint16(out[12:], uint16(mapping.numGlyphs))
+	binary.BigEndian.PutUint16(out[14:], flags|1)
+	binary.BigEndian.PutUint32(out[16:], uint32(newArrayOffset))
+	copy(out[newSharedTupleOffset:], data[sharedTupleOffset:sharedTuplesEnd])
+
+	for newG := 0; newG < mapping.numGlyphs; newG++ {
+		binary.BigEndian.PutUint32(out[20+4*newG:], uint32(len(out)-newArrayOffset))
+		oldG, ok := mapping.oldGlyph(GID(newG))
+		if !ok || int(oldG) >= glyphCount {
+			continue
+		}
+		start, end := offsets[oldG], offsets[oldG+1]
+		if start > end {
+			return nil, errors.New("invalid 'gvar' table")
+		}
+		out = append(out, variations[start:end]...)
+	}
+	binary.BigEndian.PutUint32(out[20+4*mapping.numGlyphs:], uint32(len(out)-newArrayOffset))
+	return out, nil
+}
+
+// subsetMetrics returns the new 'hhea' and 'hmtx' tables (or 'vhea' and 'vmtx' tables).
+func (pr *FontParser) subsetMetrics(headerTag Tag, metrics TableHVmtx, mapping glyphMapping) (header, mtx []byte, err error) {
+	header, err = pr.GetRawTable(headerTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(header) < 36 {
+		return nil, nil, fmt.Errorf("invalid '%s' table (EOF)", headerTag)
+	}
+
+	newMetrics := make(TableHVmtx, mapping.numGlyphs)
+	for newG := range newMetrics {
+		if oldG, ok := mapping.oldGlyph(GID(newG)); ok && int(oldG) < len(metrics) {
+			newMetrics[newG] = metrics[oldG]
+		}
+	}
+	mtx, numLongMetrics := newMetrics.Serialize()
+	binary.BigEndian.PutUint16(header[34:], numLongMetrics)
+	return header, mtx, nil
+}
+
+// writeCmap returns a 'cmap' table for `runes`, with format 4 subtables,
+// and format 12 subtables if needed.
+// If `symbolic` is true, a single (3, 0) subtable is written.
+func writeCmap(runes map[rune]GID, symbolic bool) []byte {
+	sorted := make([]rune, 0, len(runes))
+	for r := range runes {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	bmp := sorted
+	for i, r := range sorted {
+		if r >= 0xFFFF { // 0xFFFF is used by the last segment
+			bmp = sorted[:i]
+			break
+		}
+	}
+	format4 := newCmap4(bmp, runes)
+
+	var table TableCmap
+	if symbolic {
+		table.Cmaps = []CmapSubtable{{format4, CmapID{PlatformMicrosoft, PEMicrosoftSymbolCs}}}
+	} else if len(bmp) == len(sorted) {
+		table.Cmaps = []CmapSubtable{
+			{format4, CmapID{PlatformUnicode, PEUnicodeBMP}},
+			{format4, CmapID{PlatformMicrosoft, PEMicrosoftUnicodeCs}},
+		}
+	} else {
+		format12 := newCmap12(sorted, runes)
+		table.Cmaps = []CmapSubtable{
+			{format4, CmapID{PlatformUnicode, PEUnicodeBMP}},
+			{format12, CmapID{PlatformUnicode, PEUnicodeFull}},
+			{format4, CmapID{PlatformMicrosoft, PEMicrosoftUnicodeCs}},
+			{format12, CmapID{PlatformMicrosoft, PEMicrosoftUcs4}},
+		}
+	}
+	return table.Serialize()
+}
+
+// updateOS2CharRange updates the first and last characters
+// of the 'OS/2' table `os2`
+func updateOS2CharRange(os2 []byte, runes map[rune]GID) []byte {
+	if len(os2) < 68 {
+		return os2
+	}
+	first, last := rune(0xFFFF), rune(0)
+	for r := range runes {
+		if r < first {
+			first = r
+		}
+		if r > last {
+			last = r
+		}
+	}
+	if last > 0xFFFF {
+		last = 0xFFFF
+	}
+	if len(runes) == 0 {
+		first = 0
+	}
+	binary.BigEndian.PutUint16(os2[64:], uint16(first))
+	binary.BigEndian.PutUint16(os2[66:], uint16(last))
+	return os2
+}
+
+// subsetPost returns a version 2 'post' table storing the names of the
+// glyphs kept, or a version 3 table (without names) for CFF fonts or if
+// the font has no glyph names.
+func subsetPost(raw []byte, post TablePost, mapping glyphMapping, isCFF bool) []byte {
+	out := append([]byte(nil), raw[:32]...) // length checked in parseTablePost
+	if isCFF || post.Names == nil {
+		binary.BigEndian.PutUint32(out, 0x30000)
+		return out
+	}
+
+	binary.BigEndian.PutUint32(out, 0x20000)
+	return appendPostNames(out, mapping.numGlyphs, func(newG GID) (string, bool) {
+		oldG, ok := mapping.oldGlyph(newG)
+		if !ok { // .notdef for removed glyphs
+			return "", false
+		}
+		return post.Names.GlyphName(oldG), true
+	})
+}
@@ -0,0 +1,238 @@
+package truetype
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func subsetFont(t *testing.T, filename string, input SubsetInput) (original, subset *Font, subsetParser *FontParser) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := NewFontParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := pr.Subset(input)
+	if err != nil {
+		t.Fatalf("subsetting %s: %s", filename, err)
+	}
+	if len(out) >= len(data) {
+		t.Errorf("subsetting %s: no size reduction (%d >= %d)", filename, len(out), len(data))
+	}
+
+	original, err = Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset, err = Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("parsing subset of %s: %s", filename, err)
+	}
+	subsetParser, err = NewFontParser(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return original, subset, subsetParser
+}
+
+func TestSubset(t *testing.T) {
+	runes := []rune("Hello, world! fi ffi 0123 é ﬁ Ωπ السلام")
+	for _, filename := range []string{
+		"testdata/DejaVuSerif.ttf",
+		"testdata/FreeSerif.ttf", // composite glyphs
+		"testdata/Roboto-BoldItalic.ttf",
+		"testdata/SelawikVar.ttf", // gvar
+		"testdata/NotoSansArabic.ttf",
+		"testdata/LateefGR-Regular.ttf",
+		"testdata/CFFTest.otf",
+		"testdata/AccanthisADFStdNo2-Regular.otf",
+		"testdata/STIX-BoldItalic.otf",
+	} {
+		for _, retainGIDs := range []bool{false, true} {
+			original, subset, pr := subsetFont(t, filename, SubsetInput{Runes: runes, RetainGIDs: retainGIDs})
+
+			var lastGlyph GID
+			for _, r := range runes {
+				gid, ok := original.NominalGlyph(r)
+				if !ok {
+					continue
+				}
+				if gid > lastGlyph {
+					lastGlyph = gid
+				}
+				newGid, ok := subset.NominalGlyph(r)
+				if !ok {
+					t.Fatalf("%s: missing rune %q in subset", filename, r)
+				}
+				if retainGIDs && gid != newGid {
+					t.Fatalf("%s: expected retained glyph %d, got %d", filename, gid, newGid)
+				}
+				expected := original.GlyphData(gid, 0, 0).(fonts.GlyphOutline)
+				got := subset.GlyphData(newGid, 0, 0).(fonts.GlyphOutline)
+				if !reflect.DeepEqual(expected, got) {
+					t.Fatalf("%s: different outlines for rune %q", filename, r)
+				}
+				if a, b := original.HorizontalAdvance(gid), subset.HorizontalAdvance(newGid); a != b {
+					t.Fatalf("%s: different advances for rune %q: %f != %f", filename, r, a, b)
+				}
+				if a, b := original.GlyphName(gid), subset.GlyphName(newGid); a != b {
+					t.Fatalf("%s: different names for rune %q: %s != %s", filename, r, a, b)
+				}
+			}
+
+			numGlyphs, err := pr.NumGlyphs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if retainGIDs {
+				if numGlyphs <= int(lastGlyph) {
+					t.Fatalf("%s: retained glyphs not preserved", filename)
+				}
+			}
+
+			if original.LayoutTables().GSUB.Lookups != nil {
+				gsub, err := pr.GSUBTable()
+				if err != nil {
+					t.Fatalf("%s: invalid subsetted GSUB: %s", filename, err)
+				}
+				if len(gsub.Lookups) != len(original.LayoutTables().GSUB.Lookups) {
+					t.Fatalf("%s: lookups not preserved", filename)
+				}
+			}
+			if original.LayoutTables().GPOS.Lookups != nil {
+				gpos, err := pr.GPOSTable()
+				if err != nil {
+					t.Fatalf("%s: invalid subsetted GPOS: %s", filename, err)
+				}
+				if len(gpos.Lookups) != len(original.LayoutTables().GPOS.Lookups) {
+					t.Fatalf("%s: lookups not preserved", filename)
+				}
+			}
+			if pr.HasTable(TagGdef) {
+				if _, err := pr.GDEFTable(len(subset.fvar.Axis)); err != nil {
+					t.Fatalf("%s: invalid subsetted GDEF: %s", filename, err)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteLayoutTables(t *testing.T) {
+	// compare the tables before and after serialization, normalized
+	// by an identity subset
+	for _, filename := range []string{
+		"testdata/DejaVuSerif.ttf",
+		"testdata/FreeSerif.ttf",
+		"testdata/Roboto-BoldItalic.ttf",
+		"testdata/SelawikVar.ttf",
+		"testdata/NotoSansArabic.ttf",
+		"testdata/Commissioner-VF.ttf", // feature variations
+		"testdata/Estedad-VF.ttf",
+		"testdata/Mada-VF.ttf",
+		"testdata/GDEFCaretList3.ttf",
+		"testdata/ToyGPOSCursive.ttf",
+		"testdata/ToyGSUBLigature.ttf",
+		"testdata/ToyIndicGSUB.ttf",
+	} {
+		font := loadFont(t, filename)
+		glyphs := glyphSet{}
+		for g := 0; g < font.NumGlyphs; g++ {
+			glyphs[GID(g)] = true
+		}
+		identity := newGlyphMapping(glyphs, false)
+		layout := font.LayoutTables()
+
+		if layout.GSUB.Lookups != nil {
+			expectedGSUB := layout.GSUB.subset(identity)
+			data, err := expectedGSUB.writeTo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gsub, err := parseTableGSUB(data)
+			if err != nil {
+				t.Fatalf("%s: invalid GSUB: %s", filename, err)
+			}
+			if got := gsub.subset(identity); !reflect.DeepEqual(normalizeLayout(expectedGSUB.TableLayout), normalizeLayout(got.TableLayout)) ||
+				!reflect.DeepEqual(expectedGSUB.Lookups, got.Lookups) {
+				t.Errorf("%s: GSUB not preserved", filename)
+			}
+		}
+
+		if layout.GPOS.Lookups != nil {
+			expectedGPOS := layout.GPOS.subset(identity)
+			data, err := expectedGPOS.writeTo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gpos, err := parseTableGPOS(data)
+			if err != nil {
+				t.Fatalf("%s: invalid GPOS: %s", filename, err)
+			}
+			if got := gpos.subset(identity); !reflect.DeepEqual(normalizeLayout(expectedGPOS.TableLayout), normalizeLayout(got.TableLayout)) ||
+				!reflect.DeepEqual(expectedGPOS.Lookups, got.Lookups) {
+				t.Errorf("%s: GPOS not preserved", filename)
+			}
+		}
+
+		expectedGDEF := layout.GDEF.subset(identity)
+		data, err := expectedGDEF.writeTo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gdef, err := parseTableGdef(data, len(font.fvar.Axis))
+		if err != nil {
+			t.Fatalf("%s: invalid GDEF: %s", filename, err)
+		}
+		if got := gdef.subset(identity); !reflect.DeepEqual(expectedGDEF, got) {
+			t.Errorf("%s: GDEF not preserved", filename)
+		}
+	}
+}
+
+// normalizeLayout removes the fields not preserved by serialization
+func normalizeLayout(layout TableLayout) TableLayout {
+	layout.header = layoutHeader11{}
+	features := make([]FeatureRecord, len(layout.Features))
+	for i, feature := range layout.Features {
+		feature.paramsOffet = 0
+		features[i] = feature
+	}
+	layout.Features = features
+	return layout
+}
+
+func TestSubsetLigatures(t *testing.T) {
+	original, subset, _ := subsetFont(t, "testdata/DejaVuSerif.ttf", SubsetInput{Runes: []rune("fi")})
+
+	f, _ := subset.NominalGlyph('f')
+	i, _ := subset.NominalGlyph('i')
+	var found bool
+	for _, lookup := range subset.LayoutTables().GSUB.Lookups {
+		for _, table := range lookup.Subtables {
+			ligatures, ok := table.Data.(GSUBLigature1)
+			if !ok {
+				continue
+			}
+			index, ok := table.Coverage.Index(f)
+			if !ok {
+				continue
+			}
+			for _, lig := range ligatures[index] {
+				if len(lig.Components) == 1 && GID(lig.Components[0]) == i {
+					found = subset.GlyphName(lig.Glyph) == original.GlyphName(3315)
+				}
+			}
+		}
+	}
+	if !found {
+		t.Fatal("missing 'fi' ligature in subset")
+	}
+}
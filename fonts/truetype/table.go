@@ -43,6 +43,7 @@ var (
 	tagBloc = MustNewTag("bloc")
 	tagBdat = MustNewTag("bdat")
 	tagCOLR = MustNewTag("COLR")
+	tagCPAL = MustNewTag("CPAL")
 	tagFvar = MustNewTag("fvar")
 	tagAvar = MustNewTag("avar")
 	tagGvar = MustNewTag("gvar")
@@ -56,6 +57,9 @@ var (
 	tagKerx = MustNewTag("kerx")
 	tagAnkr = MustNewTag("ankr")
 	tagTrak = MustNewTag("trak")
+	tagOpbd = MustNewTag("opbd")
+	tagProp = MustNewTag("prop")
+	tagLcar = MustNewTag("lcar")
 
 	// TypeTrueType is the first four bytes of an OpenType file containing a TrueType font
 	TypeTrueType = Tag(0x00010000)
@@ -71,10 +75,10 @@ var (
 	// SignatureWOFF is the magic number at the start of a WOFF file.
 	SignatureWOFF = MustNewTag("wOFF")
 
-	ttcTag = MustNewTag("ttcf")
+	// SignatureWOFF2 is the magic number at the start of a WOFF2 file.
+	SignatureWOFF2 = MustNewTag("wOF2")
 
-	// // SignatureWOFF2 is the magic number at the start of a WOFF2 file.
-	// SignatureWOFF2 = MustNewTag("wOF2")
+	ttcTag = MustNewTag("ttcf")
 )
 
 // dfontResourceDataOffset is the assumed value of a dfont file's resource data
@@ -0,0 +1,377 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	tagBase          = MustNewTag("BASE")
+	tagDefaultScript = MustNewTag("DFLT")
+)
+
+// TableBase is the Baseline table, providing the position of the
+// baselines used to align glyphs of different scripts, and
+// min/max extents for scripts and languages.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/base
+type TableBase struct {
+	Horizontal, Vertical BaseAxis // may be empty
+	// VariationStore is used by the BaseCoordFormat3
+	// variation device tables (may be empty).
+	VariationStore VariationStore
+}
+
+// BaseAxis stores the baselines of one layout direction.
+type BaseAxis struct {
+	// BaselineTags identifies the baselines, such as 'romn' or 'ideo',
+	// sorted in alphabetical order
+	BaselineTags []Tag
+	Scripts      []BaseScript // sorted by tag
+}
+
+// FindScript returns the script record for `script`, falling back to
+// the 'DFLT' script. It returns false if none are found.
+func (ba BaseAxis) FindScript(script Tag) (BaseScript, bool) {
+	if out, ok := ba.findScript(script); ok {
+		return out, true
+	}
+	return ba.findScript(tagDefaultScript)
+}
+
+func (ba BaseAxis) findScript(script Tag) (BaseScript, bool) {
+	i := sort.Search(len(ba.Scripts), func(i int) bool { return ba.Scripts[i].Tag >= script })
+	if i < len(ba.Scripts) && ba.Scripts[i].Tag == script {
+		return ba.Scripts[i], true
+	}
+	return BaseScript{}, false
+}
+
+// BaselineIndex returns the index of `baseline` in BaselineTags,
+// or false if not found.
+func (ba BaseAxis) BaselineIndex(baseline Tag) (int, bool) {
+	i := sort.Search(len(ba.BaselineTags), func(i int) bool { return ba.BaselineTags[i] >= baseline })
+	if i < len(ba.BaselineTags) && ba.BaselineTags[i] == baseline {
+		return i, true
+	}
+	return 0, false
+}
+
+// Baseline returns the position of `baseline` for the given `script`, or
+// false if not found.
+func (ba BaseAxis) Baseline(baseline, script Tag) (BaseCoord, bool) {
+	index, ok := ba.BaselineIndex(baseline)
+	if !ok {
+		return nil, false
+	}
+	record, ok := ba.FindScript(script)
+	if !ok || index >= len(record.BaseCoords) {
+		return nil, false
+	}
+	return record.BaseCoords[index], true
+}
+
+// MinMax returns the min/max extents of the given `script` and `language`,
+// falling back to the default extents of the script.
+// If `feature` matches one of the feature records, its extents are used instead.
+// Both Min and Max may be nil when not defined.
+func (ba BaseAxis) MinMax(script, language, feature Tag) (min, max BaseCoord) {
+	record, ok := ba.FindScript(script)
+	if !ok {
+		return nil, nil
+	}
+	minMax := record.DefaultMinMax
+	for _, langSys := range record.LangSys {
+		if langSys.Tag == language {
+			minMax = langSys.MinMax
+			break
+		}
+	}
+	for _, feat := range minMax.Features {
+		if feat.Tag == feature {
+			return feat.Min, feat.Max
+		}
+	}
+	return minMax.Min, minMax.Max
+}
+
+// BaseScript provides the baselines and the extents for a script.
+type BaseScript struct {
+	// BaseCoords gives the position of each baseline of the axis, and is
+	// either empty or with same length as BaseAxis.BaselineTags.
+	BaseCoords []BaseCoord
+	// Default min/max extents, used when no language specific values
+	// are found
+	DefaultMinMax BaseMinMax
+	LangSys       []BaseLangSys
+	Tag           Tag
+	// DefaultBaseline is the index, in BaseAxis.BaselineTags,
+	// of the baseline used by the script.
+	DefaultBaseline uint16
+}
+
+// BaseLangSys provides the extents for one language.
+type BaseLangSys struct {
+	MinMax BaseMinMax
+	Tag    Tag
+}
+
+// BaseMinMax stores the minimum and maximum extents,
+// in the direction of the line height (Min and Max may be nil).
+type BaseMinMax struct {
+	Min, Max BaseCoord
+	Features []BaseFeatureMinMax
+}
+
+// BaseFeatureMinMax stores the extents used when
+// a feature is applied.
+type BaseFeatureMinMax struct {
+	Min, Max BaseCoord
+	Tag      Tag
+}
+
+// BaseCoord is either BaseCoordFormat1, BaseCoordFormat2 or BaseCoordFormat3.
+type BaseCoord interface {
+	isBaseCoord()
+}
+
+func (BaseCoordFormat1) isBaseCoord() {}
+func (BaseCoordFormat2) isBaseCoord() {}
+func (BaseCoordFormat3) isBaseCoord() {}
+
+// BaseCoordFormat1 is a coordinate in design units.
+type BaseCoordFormat1 int16
+
+// BaseCoordFormat2 is a coordinate in design units, which should be
+// adjusted to the position of a contour point of a glyph, after hinting.
+type BaseCoordFormat2 struct {
+	Coordinate     int16
+	ReferenceGlyph GID
+	BaseCoordPoint uint16
+}
+
+// BaseCoordFormat3 is a coordinate in design units,
+// adjusted by a device table.
+type BaseCoordFormat3 struct {
+	Device     DeviceTable
+	Coordinate int16
+}
+
+func parseTableBase(data []byte, axisCount int) (out TableBase, err error) {
+	if len(data) < 8 {
+		return out, errors.New("invalid 'BASE' table (EOF)")
+	}
+	major, minor := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+	if major != 1 {
+		return out, fmt.Errorf("unsupported 'BASE' table version: %d", major)
+	}
+	horizOffset := binary.BigEndian.Uint16(data[4:])
+	vertOffset := binary.BigEndian.Uint16(data[6:])
+
+	if horizOffset != 0 {
+		out.Horizontal, err = parseBaseAxis(data, horizOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if vertOffset != 0 {
+		out.Vertical, err = parseBaseAxis(data, vertOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	if minor >= 1 {
+		if len(data) < 12 {
+			return out, errors.New("invalid 'BASE' table (EOF)")
+		}
+		if offset := binary.BigEndian.Uint32(data[8:]); offset != 0 {
+			out.VariationStore, err = parseVariationStore(data, offset, axisCount)
+			if err != nil {
+				return out, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func parseBaseAxis(data []byte, offset uint16) (out BaseAxis, err error) {
+	if len(data) < int(offset)+4 {
+		return out, errors.New("invalid 'BASE' axis table (EOF)")
+	}
+	data = data[offset:]
+	tagListOffset := binary.BigEndian.Uint16(data)
+	scriptListOffset := binary.BigEndian.Uint16(data[2:])
+
+	if tagListOffset != 0 {
+		if len(data) < int(tagListOffset)+2 {
+			return out, errors.New("invalid 'BASE' tag list (EOF)")
+		}
+		tagList := data[tagListOffset:]
+		count := int(binary.BigEndian.Uint16(tagList))
+		if len(tagList) < 2+4*count {
+			return out, errors.New("invalid 'BASE' tag list (EOF)")
+		}
+		out.BaselineTags = make([]Tag, count)
+		for i := range out.BaselineTags {
+			out.BaselineTags[i] = Tag(binary.BigEndian.Uint32(tagList[2+4*i:]))
+			// BaselineIndex expects sorted tags, and the baseline values
+			// refer to the tag indices, so unsorted tags can't be fixed up
+			if i > 0 && out.BaselineTags[i] <= out.BaselineTags[i-1] {
+				return out, fmt.Errorf("invalid 'BASE' tag list (unsorted tag %s)", out.BaselineTags[i])
+			}
+		}
+	}
+
+	if len(data) < int(scriptListOffset)+2 {
+		return out, errors.New("invalid 'BASE' script list (EOF)")
+	}
+	scriptList := data[scriptListOffset:]
+	count := int(binary.BigEndian.Uint16(scriptList))
+	if len(scriptList) < 2+6*count {
+		return out, errors.New("invalid 'BASE' script list (EOF)")
+	}
+	out.Scripts = make([]BaseScript, count)
+	for i := range out.Scripts {
+		record := scriptList[2+6*i:]
+		out.Scripts[i], err = parseBaseScript(scriptList, binary.BigEndian.Uint16(record[4:]))
+		if err != nil {
+			return out, err
+		}
+		out.Scripts[i].Tag = Tag(binary.BigEndian.Uint32(record))
+	}
+	// the script records should already be sorted, but FindScript relies on it
+	sort.SliceStable(out.Scripts, func(i, j int) bool { return out.Scripts[i].Tag < out.Scripts[j].Tag })
+
+	return out, nil
+}
+
+func parseBaseScript(data []byte, offset uint16) (out BaseScript, err error) {
+	if len(data) < int(offset)+6 {
+		return out, errors.New("invalid 'BASE' script table (EOF)")
+	}
+	data = data[offset:]
+	valuesOffset := binary.BigEndian.Uint16(data)
+	minMaxOffset := binary.BigEndian.Uint16(data[2:])
+	count := int(binary.BigEndian.Uint16(data[4:]))
+
+	if valuesOffset != 0 {
+		if len(data) < int(valuesOffset)+4 {
+			return out, errors.New("invalid 'BASE' values table (EOF)")
+		}
+		values := data[valuesOffset:]
+		out.DefaultBaseline = binary.BigEndian.Uint16(values)
+		coordOffsets, err := parseUint16s(values[4:], int(binary.BigEndian.Uint16(values[2:])))
+		if err != nil {
+			return out, errors.New("invalid 'BASE' values table (EOF)")
+		}
+		out.BaseCoords = make([]BaseCoord, len(coordOffsets))
+		for i, coordOffset := range coordOffsets {
+			out.BaseCoords[i], err = parseBaseCoord(values, coordOffset)
+			if err != nil {
+				return out, err
+			}
+		}
+	}
+
+	if minMaxOffset != 0 {
+		out.DefaultMinMax, err = parseBaseMinMax(data, minMaxOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	if len(data) < 6+6*count {
+		return out, errors.New("invalid 'BASE' script table (EOF)")
+	}
+	out.LangSys = make([]BaseLangSys, count)
+	for i := range out.LangSys {
+		record := data[6+6*i:]
+		out.LangSys[i].Tag = Tag(binary.BigEndian.Uint32(record))
+		out.LangSys[i].MinMax, err = parseBaseMinMax(data, binary.BigEndian.Uint16(record[4:]))
+		if err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+func parseBaseMinMax(data []byte, offset uint16) (out BaseMinMax, err error) {
+	if len(data) < int(offset)+6 {
+		return out, errors.New("invalid 'BASE' min/max table (EOF)")
+	}
+	data = data[offset:]
+	out.Min, err = parseOptionalBaseCoord(data, binary.BigEndian.Uint16(data))
+	if err != nil {
+		return out, err
+	}
+	out.Max, err = parseOptionalBaseCoord(data, binary.BigEndian.Uint16(data[2:]))
+	if err != nil {
+		return out, err
+	}
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 6+8*count {
+		return out, errors.New("invalid 'BASE' min/max table (EOF)")
+	}
+	out.Features = make([]BaseFeatureMinMax, count)
+	for i := range out.Features {
+		record := data[6+8*i:]
+		out.Features[i].Tag = Tag(binary.BigEndian.Uint32(record))
+		out.Features[i].Min, err = parseOptionalBaseCoord(data, binary.BigEndian.Uint16(record[4:]))
+		if err != nil {
+			return out, err
+		}
+		out.Features[i].Max, err = parseOptionalBaseCoord(data, binary.BigEndian.Uint16(record[6:]))
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// returns nil for a zero offset
+func parseOptionalBaseCoord(data []byte, offset uint16) (BaseCoord, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	return parseBaseCoord(data, offset)
+}
+
+func parseBaseCoord(data []byte, offset uint16) (BaseCoord, error) {
+	if len(data) < int(offset)+4 {
+		return nil, errors.New("invalid 'BASE' coordinate (EOF)")
+	}
+	data = data[offset:]
+	coordinate := int16(binary.BigEndian.Uint16(data[2:]))
+	switch format := binary.BigEndian.Uint16(data); format {
+	case 1:
+		return BaseCoordFormat1(coordinate), nil
+	case 2:
+		if len(data) < 8 {
+			return nil, errors.New("invalid 'BASE' coordinate format 2 (EOF)")
+		}
+		return BaseCoordFormat2{
+			Coordinate:     coordinate,
+			ReferenceGlyph: GID(binary.BigEndian.Uint16(data[4:])),
+			BaseCoordPoint: binary.BigEndian.Uint16(data[6:]),
+		}, nil
+	case 3:
+		if len(data) < 6 {
+			return nil, errors.New("invalid 'BASE' coordinate format 3 (EOF)")
+		}
+		out := BaseCoordFormat3{Coordinate: coordinate}
+		if deviceOffset := binary.BigEndian.Uint16(data[4:]); deviceOffset != 0 {
+			var err error
+			out.Device, err = parseDeviceTable(data, deviceOffset)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("invalid 'BASE' coordinate format: %d", format)
+	}
+}
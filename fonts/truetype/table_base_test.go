@@ -0,0 +1,136 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestParseBase(t *testing.T) {
+	font := loadFont(t, "../../harfbuzz/testdata/harfbuzz_reference/in-house/fonts/6991b13ce889466be6de3f66e891de2bc0f117ee.ttf")
+	base := font.LayoutTables().BASE
+
+	tags := []Tag{MustNewTag("icfb"), MustNewTag("icft"), MustNewTag("ideo"), MustNewTag("romn")}
+	if !reflect.DeepEqual(base.Horizontal.BaselineTags, tags) || !reflect.DeepEqual(base.Vertical.BaselineTags, tags) {
+		t.Fatalf("unexpected baseline tags %v", base.Horizontal.BaselineTags)
+	}
+	if len(base.Horizontal.Scripts) != 4 {
+		t.Fatalf("unexpected scripts %v", base.Horizontal.Scripts)
+	}
+
+	for _, test := range []struct {
+		axis             BaseAxis
+		baseline, script Tag
+		expected         BaseCoord
+	}{
+		{base.Horizontal, MustNewTag("ideo"), MustNewTag("hani"), BaseCoordFormat1(-120)},
+		{base.Horizontal, MustNewTag("icft"), MustNewTag("latn"), BaseCoordFormat1(834)},
+		{base.Horizontal, MustNewTag("ideo"), MustNewTag("cyrl"), BaseCoordFormat1(-120)}, // DFLT
+		{base.Vertical, MustNewTag("romn"), MustNewTag("latn"), BaseCoordFormat1(120)},
+	} {
+		got, ok := test.axis.Baseline(test.baseline, test.script)
+		if !ok || got != test.expected {
+			t.Fatalf("baseline %s for %s: expected %v, got %v", test.baseline, test.script, test.expected, got)
+		}
+	}
+	if _, ok := base.Horizontal.Baseline(MustNewTag("hang"), MustNewTag("latn")); ok {
+		t.Fatal("unexpected hanging baseline")
+	}
+	if script, _ := base.Horizontal.FindScript(MustNewTag("hani")); script.DefaultBaseline != 2 {
+		t.Fatalf("unexpected default baseline %d", script.DefaultBaseline)
+	}
+}
+
+type baseWriter []byte
+
+func (w *baseWriter) u16(values ...uint16) {
+	for _, v := range values {
+		*w = append(*w, byte(v>>8), byte(v))
+	}
+}
+
+func (w *baseWriter) tag(tag string) { *w = append(*w, tag...) }
+
+func TestParseBaseMinMax(t *testing.T) {
+	// a 'BASE' table with min/max extents, format 2 and 3 coordinates,
+	// and a variation store, built by hand
+	var w baseWriter
+	w.u16(1, 1, 12, 0, 0, 0) // header, with variation store offset set below
+	// axis (at 12)
+	w.u16(4, 14)
+	w.u16(2)
+	w.tag("ideo")
+	w.tag("romn")
+	// script list (at 26)
+	w.u16(1)
+	w.tag("latn")
+	w.u16(8)
+	// script (at 34)
+	w.u16(12, 40, 1)
+	w.tag("TRK ")
+	w.u16(66)
+	// values (at 46)
+	w.u16(1, 2, 8, 20)
+	w.u16(3, 0xFFC0, 6, 0, 0, 0x8000) // format 3, with variation device
+	w.u16(2, 0, 5, 3)                 // format 2
+	// default min/max (at 74), with one feature
+	w.u16(14, 18, 1)
+	w.tag("vert")
+	w.u16(22, 0)
+	w.u16(1, 0xFF00) // -256
+	w.u16(1, 800)
+	w.u16(1, 0xFE00) // -512
+	// language min/max (at 100)
+	w.u16(6, 0, 0)
+	w.u16(1, 0xFF9C) // -100
+	// variation store (at 110)
+	binary.BigEndian.PutUint32(w[8:], uint32(len(w)))
+	w.u16(1, 0, 12, 1, 0, 22)
+	// region list
+	w.u16(1, 1, 0, 1<<14, 1<<14)
+	// item variation data : one item, one region, delta of 50
+	w.u16(1, 0, 1, 0)
+	w = append(w, 50)
+
+	base, err := parseTableBase(w, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	axis := base.Horizontal
+	if coord, _ := axis.Baseline(MustNewTag("ideo"), MustNewTag("latn")); coord != (BaseCoordFormat3{Coordinate: -64, Device: DeviceVariation{}}) {
+		t.Fatalf("unexpected ideographic baseline %v", coord)
+	}
+	if coord, _ := axis.Baseline(MustNewTag("romn"), MustNewTag("latn")); coord != (BaseCoordFormat2{ReferenceGlyph: 5, BaseCoordPoint: 3}) {
+		t.Fatalf("unexpected roman baseline %v", coord)
+	}
+	if delta := base.VariationStore.GetDelta(VariationStoreIndex{}, []float32{1}); delta != 50 {
+		t.Fatalf("unexpected delta %f", delta)
+	}
+
+	for _, test := range []struct {
+		language, feature Tag
+		min, max          BaseCoord
+	}{
+		{MustNewTag("dflt"), 0, BaseCoordFormat1(-256), BaseCoordFormat1(800)},
+		{MustNewTag("dflt"), MustNewTag("vert"), BaseCoordFormat1(-512), nil},
+		{MustNewTag("TRK "), MustNewTag("vert"), BaseCoordFormat1(-100), nil},
+	} {
+		min, max := axis.MinMax(MustNewTag("latn"), test.language, test.feature)
+		if min != test.min || max != test.max {
+			t.Fatalf("unexpected min/max for %s, %s: %v %v", test.language, test.feature, min, max)
+		}
+	}
+	if min, max := base.Vertical.MinMax(MustNewTag("latn"), 0, 0); min != nil || max != nil {
+		t.Fatal("unexpected vertical min/max")
+	}
+
+	if _, err := parseTableBase(w[:60], 1); err == nil {
+		t.Fatal("expected error for truncated table")
+	}
+
+	unsorted := append(baseWriter(nil), w...)
+	copy(unsorted[18:], "romnideo")
+	if _, err := parseTableBase(unsorted, 1); err == nil {
+		t.Fatal("expected error for unsorted baseline tags")
+	}
+}
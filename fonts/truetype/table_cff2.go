@@ -0,0 +1,47 @@
+package truetype
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/benoitkugler/textlayout/fonts"
+	ps "github.com/benoitkugler/textlayout/fonts/psinterpreter"
+	type1c "github.com/benoitkugler/textlayout/fonts/type1C"
+)
+
+// tableCFF2 stores a CFF2 font, with its parsed
+// variation store.
+type tableCFF2 struct {
+	*type1c.Font
+	store VariationStore // may be empty for non variable fonts
+}
+
+func parseTableCFF2(buf []byte, numGlyphs int, axisCount int) (tableCFF2, error) {
+	cff, err := type1c.Parse(bytes.NewReader(buf))
+	if err != nil {
+		return tableCFF2{}, err
+	}
+
+	if N := cff.NumGlyphs(); N != numGlyphs {
+		return tableCFF2{}, fmt.Errorf("invalid number of glyphs in CFF2 table (%d != %d)", N, numGlyphs)
+	}
+
+	out := tableCFF2{Font: cff}
+	if len(cff.VarStore) != 0 {
+		out.store, err = parseVariationStore(cff.VarStore, 0, axisCount)
+		if err != nil {
+			return tableCFF2{}, err
+		}
+	}
+	return out, nil
+}
+
+// loadGlyph returns the glyph outline, for the variable instance
+// defined by `coords` (which may be nil)
+func (cff tableCFF2) loadGlyph(glyph GID, coords []float32) ([]fonts.Segment, ps.PathBounds, error) {
+	var scalars type1c.BlendScalars
+	if len(coords) != 0 {
+		scalars = func(vsIndex uint16) []float32 { return cff.store.getScalars(vsIndex, coords) }
+	}
+	return cff.LoadGlyphVar(glyph, scalars)
+}
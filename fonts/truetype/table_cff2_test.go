@@ -0,0 +1,55 @@
+package truetype
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func TestCFF2(t *testing.T) {
+	font := loadFont(t, "testdata/TestCFF2VF.otf")
+	if font.cff2 == nil {
+		t.Fatal("missing CFF2 table")
+	}
+	if len(font.cff2.store.Datas) != 1 {
+		t.Fatalf("unexpected variation store %v", font.cff2.store)
+	}
+
+	for gid := 0; gid < font.NumGlyphs; gid++ {
+		if _, ok := font.GlyphData(GID(gid), 0, 0).(fonts.GlyphOutline); !ok {
+			t.Fatalf("missing outline for glyph %d", gid)
+		}
+	}
+
+	defaultOutline := font.GlyphData(1, 0, 0)
+	defaultExtents, _ := font.GlyphExtents(1, 0, 0)
+
+	// the default instance does not change the outlines
+	font.SetVarCoordinates(font.NormalizeVariations([]float32{400}))
+	if out := font.GlyphData(1, 0, 0); !reflect.DeepEqual(out, defaultOutline) {
+		t.Fatalf("expected %v, got %v", defaultOutline, out)
+	}
+
+	for _, test := range []struct {
+		wght    float32
+		extents fonts.GlyphExtents
+	}{
+		{400, fonts.GlyphExtents{XBearing: 31, YBearing: 656, Width: 538, Height: -656}},
+		{200, fonts.GlyphExtents{XBearing: 50, YBearing: 660, Width: 500, Height: -660}},
+		{900, fonts.GlyphExtents{XBearing: 0, YBearing: 650, Width: 600, Height: -650}},
+	} {
+		font.SetVarCoordinates(font.NormalizeVariations([]float32{test.wght}))
+		extents, ok := font.GlyphExtents(1, 0, 0)
+		if !ok {
+			t.Fatal("missing extents")
+		}
+		if extents != test.extents {
+			t.Fatalf("for wght=%f, expected %v, got %v", test.wght, test.extents, extents)
+		}
+	}
+
+	if extents, _ := font.GlyphExtents(1, 0, 0); extents == defaultExtents {
+		t.Fatal("variations not applied")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/benoitkugler/textlayout/fonts"
 	"golang.org/x/text/encoding/charmap"
@@ -77,6 +78,13 @@ func (t TableCmap) BestEncoding() (Cmap, fonts.CmapEncoding) {
 		return cmap, fonts.EncUnicode
 	}
 
+	/* Legacy CJK encodings, transcoded to Unicode. */
+	for _, subtable := range t.Cmaps {
+		if cmap, ok := subtable.UnicodeCmap(); ok && len(cmap) != 0 {
+			return cmap, fonts.EncUnicode
+		}
+	}
+
 	if len(t.Cmaps) != 0 {
 		return t.Cmaps[0].Cmap, fonts.EncOther
 	}
@@ -356,8 +364,6 @@ func parseTableCmap(input []byte) (out TableCmap, err error) {
 			if err != nil {
 				return out, err
 			}
-		} else if format == 2 { // for now, we just ignore these formats
-			continue
 		} else {
 			cmap.Cmap, err = parseCmapSubtable(format, input, uint32(offset))
 			if err != nil {
@@ -380,8 +386,7 @@ func parseCmapSubtable(format uint16, input []byte, offset uint32) (Cmap, error)
 	case 0:
 		return parseCmapFormat0(input, offset)
 	case 2:
-		// parseCmapFormat2(input, offset)
-		return nil, fmt.Errorf("unsupported cmap subtable format: %d", format)
+		return parseCmapFormat2(input, offset)
 	case 4:
 		return parseCmapFormat4(input, offset)
 	case 6:
@@ -413,6 +418,9 @@ func parseCmapFormat0(input []byte, offset uint32) (cmap0, error) {
 	return chars, nil
 }
 
+// cmap2 maps mixed 8/16-bit encodings, such as Shift-JIS :
+// the runes are character codes in this encoding, whose
+// first byte is the high byte for 16-bit codes.
 type cmap2 struct {
 	subHeaders      []cmapFormat2SubHeader
 	glyphIndexArray []uint16 // may safely by slice by [header.rangeIndex:header.rangeIndex+header.entryCount]
@@ -478,6 +486,82 @@ type cmapFormat2SubHeader struct {
 	rangeIndex int
 }
 
+// returns 0 if not found
+func (s cmap2) lookup(header cmapFormat2SubHeader, low uint16) GID {
+	if low < header.firstCode || low-header.firstCode >= header.entryCount {
+		return 0
+	}
+	index := header.rangeIndex + int(low-header.firstCode)
+	if index >= len(s.glyphIndexArray) {
+		return 0
+	}
+	glyph := s.glyphIndexArray[index]
+	if glyph == 0 {
+		return 0
+	}
+	return GID(uint16(int(glyph) + int(header.idDelta)))
+}
+
+func (s cmap2) Lookup(r rune) (GID, bool) {
+	if r < 0 || r > 0xFFFF {
+		return 0, false
+	}
+	high, low := uint16(r)>>8, uint16(r)&0xFF
+	var glyph GID
+	if high == 0 { // one byte code
+		if s.subHeaderKeys[low] != 0 {
+			return 0, false // first byte of a two bytes code
+		}
+		glyph = s.lookup(s.subHeaders[0], low)
+	} else {
+		key := s.subHeaderKeys[high]
+		if key == 0 {
+			return 0, false // invalid first byte
+		}
+		glyph = s.lookup(s.subHeaders[key], low)
+	}
+	return glyph, glyph != 0
+}
+
+type cmap2Iter struct {
+	data  cmap2
+	code  int // current code
+	glyph GID // glyph for code, valid if found is true
+	found bool
+}
+
+// Next looks for the next code mapped to a non zero glyph
+func (it *cmap2Iter) Next() bool {
+	for !it.found && it.code <= 0xFFFF {
+		high, low := it.code>>8, uint16(it.code&0xFF)
+		if high != 0 && it.data.subHeaderKeys[high] == 0 {
+			// skip the whole range of invalid high bytes
+			it.code = (high + 1) << 8
+			continue
+		}
+		if high == 0 {
+			if it.data.subHeaderKeys[low] == 0 {
+				it.glyph = it.data.lookup(it.data.subHeaders[0], low)
+			}
+		} else {
+			it.glyph = it.data.lookup(it.data.subHeaders[it.data.subHeaderKeys[high]], low)
+		}
+		if it.found = it.glyph != 0; !it.found {
+			it.code++
+		}
+	}
+	return it.found
+}
+
+func (it *cmap2Iter) Char() (rune, GID) {
+	r, glyph := rune(it.code), it.glyph
+	it.code++
+	it.found, it.glyph = false, 0
+	return r, glyph
+}
+
+func (s cmap2) Iter() CmapIter { return &cmap2Iter{data: s} }
+
 func parseCmapFormat4(input []byte, offset uint32) (cmap4, error) {
 	const headerSize = 14
 	if len(input) < int(offset)+headerSize {
@@ -724,3 +808,220 @@ func parseUint24(b []byte) rune {
 	_ = b[2] // BCE
 	return rune(b[0])<<16 | rune(b[1])<<8 | rune(b[2])
 }
+
+// Serialize encodes the table. Identical subtables are shared, and
+// the Unicode variation sequences, if any, are stored in a (0, 5) subtable.
+// Subtables of unknown type are written using format 4 or 12.
+func (t TableCmap) Serialize() []byte {
+	type record struct {
+		id   CmapID
+		data []byte
+	}
+	records := make([]record, 0, len(t.Cmaps)+1)
+	for _, subtable := range t.Cmaps {
+		records = append(records, record{subtable.ID, serializeCmapSubtable(subtable.Cmap)})
+	}
+	if t.unicodeVariation != nil {
+		records = append(records, record{CmapID{PlatformUnicode, 5}, t.unicodeVariation.serialize()})
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].id.key() < records[j].id.key() })
+
+	out := make([]byte, 4+8*len(records))
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)))
+	offsets := map[string]uint32{}
+	for i, rec := range records {
+		offset, ok := offsets[string(rec.data)]
+		if !ok {
+			offset = uint32(len(out))
+			offsets[string(rec.data)] = offset
+			out = append(out, rec.data...)
+		}
+		binary.BigEndian.PutUint16(out[4+8*i:], uint16(rec.id.Platform))
+		binary.BigEndian.PutUint16(out[4+8*i+2:], uint16(rec.id.Encoding))
+		binary.BigEndian.PutUint32(out[4+8*i+4:], offset)
+	}
+	return out
+}
+
+func serializeCmapSubtable(cmap Cmap) []byte {
+	switch cmap := cmap.(type) {
+	case cmap4:
+		return cmap.serialize()
+	case cmap6or10:
+		return cmap.serialize()
+	case cmap12:
+		return serializeCmapFormat12or13(12, cmap)
+	case cmap13:
+		return serializeCmapFormat12or13(13, cmap)
+	case cmap0:
+		if out, ok := serializeCmapFormat0(cmap); ok {
+			return out
+		}
+	}
+
+	runes := make(map[rune]GID)
+	for iter := cmap.Iter(); iter.Next(); {
+		r, g := iter.Char()
+		runes[r] = g
+	}
+	sorted := make([]rune, 0, len(runes))
+	for r := range runes {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) == 0 || sorted[len(sorted)-1] < 0xFFFF {
+		return newCmap4(sorted, runes).serialize()
+	}
+	return serializeCmapFormat12or13(12, newCmap12(sorted, runes))
+}
+
+// serializeCmapFormat0 returns false if `cmap` can't be represented
+// using the Macintosh encoding.
+func serializeCmapFormat0(cmap cmap0) ([]byte, bool) {
+	out := make([]byte, 6+256)
+	binary.BigEndian.PutUint16(out[2:], uint16(len(out)))
+	for r, g := range cmap {
+		b, ok := charmap.Macintosh.EncodeRune(r)
+		if !ok || g > 0xFF {
+			return nil, false
+		}
+		out[6+int(b)] = byte(g)
+	}
+	return out, true
+}
+
+// newCmap4 returns the segments for `sorted`, which
+// must only contain BMP runes, using only deltas.
+func newCmap4(sorted []rune, runes map[rune]GID) cmap4 {
+	var segments cmap4
+	for i, r := range sorted {
+		delta := uint16(runes[r]) - uint16(r)
+		if L := len(segments); i != 0 && rune(segments[L-1].end)+1 == r && segments[L-1].delta == delta {
+			segments[L-1].end = uint16(r)
+			continue
+		}
+		segments = append(segments, cmapEntry16{start: uint16(r), end: uint16(r), delta: delta})
+	}
+	return segments
+}
+
+// serialize adds the final 0xFFFF segment if needed
+func (s cmap4) serialize() []byte {
+	if len(s) == 0 || s[len(s)-1].start != 0xFFFF {
+		s = append(s[:len(s):len(s)], cmapEntry16{start: 0xFFFF, end: 0xFFFF, delta: 1})
+	}
+
+	segCount := len(s)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	searchRange := 2 * (1 << entrySelector)
+
+	out := make([]byte, 16+8*segCount)
+	binary.BigEndian.PutUint16(out, 4)
+	binary.BigEndian.PutUint16(out[6:], uint16(2*segCount))
+	binary.BigEndian.PutUint16(out[8:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[10:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[12:], uint16(2*segCount-searchRange))
+	var glyphIDCount int
+	for i, seg := range s {
+		binary.BigEndian.PutUint16(out[14+2*i:], seg.end)
+		binary.BigEndian.PutUint16(out[16+2*segCount+2*i:], seg.start)
+		binary.BigEndian.PutUint16(out[16+4*segCount+2*i:], seg.delta)
+		if seg.indexes != nil {
+			idRangeOffset := 2 * (glyphIDCount + segCount - i)
+			binary.BigEndian.PutUint16(out[16+6*segCount+2*i:], uint16(idRangeOffset))
+			for _, g := range seg.indexes {
+				out = append(out, byte(g>>8), byte(g))
+			}
+			glyphIDCount += len(seg.indexes)
+		}
+	}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(out)))
+	return out
+}
+
+func (s cmap6or10) serialize() []byte {
+	var out []byte
+	if int(s.firstCode)+len(s.entries) <= 0x10000 {
+		out = make([]byte, 10, 10+2*len(s.entries))
+		binary.BigEndian.PutUint16(out, 6)
+		binary.BigEndian.PutUint16(out[2:], uint16(10+2*len(s.entries)))
+		binary.BigEndian.PutUint16(out[6:], uint16(s.firstCode))
+		binary.BigEndian.PutUint16(out[8:], uint16(len(s.entries)))
+	} else {
+		out = make([]byte, 20, 20+2*len(s.entries))
+		binary.BigEndian.PutUint16(out, 10)
+		binary.BigEndian.PutUint32(out[4:], uint32(20+2*len(s.entries)))
+		binary.BigEndian.PutUint32(out[12:], uint32(s.firstCode))
+		binary.BigEndian.PutUint32(out[16:], uint32(len(s.entries)))
+	}
+	for _, g := range s.entries {
+		out = append(out, byte(g>>8), byte(g))
+	}
+	return out
+}
+
+// newCmap12 returns the groups for `sorted`
+func newCmap12(sorted []rune, runes map[rune]GID) cmap12 {
+	var groups cmap12
+	for i, r := range sorted {
+		g := runes[r]
+		if L := len(groups); i != 0 && groups[L-1].end+1 == uint32(r) &&
+			groups[L-1].value+uint32(r)-groups[L-1].start == uint32(g) {
+			groups[L-1].end = uint32(r)
+			continue
+		}
+		groups = append(groups, cmapEntry32{start: uint32(r), end: uint32(r), value: uint32(g)})
+	}
+	return groups
+}
+
+func serializeCmapFormat12or13(format uint16, groups []cmapEntry32) []byte {
+	out := make([]byte, 16+12*len(groups))
+	binary.BigEndian.PutUint16(out, format)
+	binary.BigEndian.PutUint32(out[4:], uint32(len(out)))
+	binary.BigEndian.PutUint32(out[12:], uint32(len(groups)))
+	for i, group := range groups {
+		binary.BigEndian.PutUint32(out[16+12*i:], group.start)
+		binary.BigEndian.PutUint32(out[16+12*i+4:], group.end)
+		binary.BigEndian.PutUint32(out[16+12*i+8:], group.value)
+	}
+	return out
+}
+
+func appendUint24(out []byte, r rune) []byte {
+	return append(out, byte(r>>16), byte(r>>8), byte(r))
+}
+
+func (t unicodeVariations) serialize() []byte {
+	out := make([]byte, 10+11*len(t))
+	binary.BigEndian.PutUint16(out, 14)
+	binary.BigEndian.PutUint32(out[6:], uint32(len(t)))
+	for i, selector := range t {
+		record := out[10+11*i:]
+		copy(record, appendUint24(nil, selector.varSelector))
+		if selector.defaultUVS != nil {
+			binary.BigEndian.PutUint32(record[3:], uint32(len(out)))
+			out = append(out, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(out[len(out)-4:], uint32(len(selector.defaultUVS)))
+			for _, rang := range selector.defaultUVS {
+				out = appendUint24(out, rang.start)
+				out = append(out, rang.additionalCount)
+			}
+		}
+		record = out[10+11*i:] // out may have been reallocated
+		if selector.nonDefaultUVS != nil {
+			binary.BigEndian.PutUint32(record[7:], uint32(len(out)))
+			out = append(out, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(out[len(out)-4:], uint32(len(selector.nonDefaultUVS)))
+			for _, mapping := range selector.nonDefaultUVS {
+				out = appendUint24(out, mapping.unicode)
+				out = append(out, byte(mapping.glyphID>>8), byte(mapping.glyphID))
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(out[2:], uint32(len(out)))
+	return out
+}
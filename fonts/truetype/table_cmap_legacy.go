@@ -0,0 +1,92 @@
+package truetype
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/benoitkugler/textlayout/fonts"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// returns the legacy CJK encoding used by the subtable, or nil
+func (c CmapID) legacyEncoding() encoding.Encoding {
+	switch c.Platform {
+	case PlatformMac:
+		switch c.Encoding {
+		case 1: // Japanese
+			return japanese.ShiftJIS
+		case 2: // Chinese (Traditional)
+			return traditionalchinese.Big5
+		case 3: // Korean
+			return korean.EUCKR
+		case 25: // Chinese (Simplified)
+			return simplifiedchinese.GBK
+		}
+	case PlatformMicrosoft:
+		switch c.Encoding {
+		case 2: // ShiftJIS
+			return japanese.ShiftJIS
+		case 3: // PRC
+			return simplifiedchinese.GBK
+		case 4: // Big5
+			return traditionalchinese.Big5
+		case 5: // Wansung
+			return korean.EUCKR
+		}
+	}
+	return nil
+}
+
+// UnicodeCmap transcodes a subtable using a legacy CJK encoding
+// (Shift-JIS, Big5, GBK or EUC-KR, as found in older fonts, often with
+// a format 2 subtable), returning a cmap whose keys are Unicode code points.
+// It returns false if the encoding of the subtable is not supported.
+// Character codes from 0x100 are interpreted as two bytes codes, starting with
+// the high byte. When several codes map to the same rune, the lowest is used.
+func (cs CmapSubtable) UnicodeCmap() (fonts.CmapSimple, bool) {
+	enc := cs.ID.legacyEncoding()
+	if enc == nil || cs.Cmap == nil {
+		return nil, false
+	}
+	glyphs := make(map[rune]GID)
+	codes := make([]rune, 0)
+	for iter := cs.Cmap.Iter(); iter.Next(); {
+		code, glyph := iter.Char()
+		glyphs[code] = glyph
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	decoder := enc.NewDecoder()
+	out := make(fonts.CmapSimple, len(codes))
+	var buffer [2]byte
+	for _, code := range codes {
+		if code < 0 || code > 0xFFFF {
+			continue
+		}
+		src := buffer[:1]
+		if code < 0x100 {
+			buffer[0] = byte(code)
+		} else {
+			buffer[0], buffer[1] = byte(code>>8), byte(code)
+			src = buffer[:]
+		}
+		decoded, err := decoder.Bytes(src)
+		if err != nil {
+			continue
+		}
+		r, size := utf8.DecodeRune(decoded)
+		if r == utf8.RuneError || size != len(decoded) {
+			continue // invalid code, or not mapped to a single rune
+		}
+		if _, has := out[r]; has {
+			continue
+		}
+		out[r] = glyphs[code]
+	}
+	return out, true
+}
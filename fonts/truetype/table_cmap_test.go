@@ -208,12 +208,52 @@ func TestCmap2(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	for _, header := range cmap.subHeaders {
-		_ = cmap.glyphIndexArray[header.rangeIndex : header.rangeIndex+int(header.entryCount)]
+
+	expected := readExpectedCmap2()
+	got := make(map[rune]GID)
+	for iter := cmap.Iter(); iter.Next(); {
+		r, gid := iter.Char()
+		got[r] = gid
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %d mappings, got %d", len(expected), len(got))
+	}
+	for r, gid := range expected {
+		if g, ok := cmap.Lookup(r); !ok || g != gid {
+			t.Fatalf("code %x: expected %d, got %d", r, gid, g)
+		}
+	}
+	for _, r := range []rune{0x81, 0x8100, 0x10000, -1} { // lead byte, invalid codes
+		if _, ok := cmap.Lookup(r); ok {
+			t.Fatalf("unexpected glyph for code %x", r)
+		}
+	}
+
+	if _, err = parseCmapFormat2(data[:600], 0); err == nil {
+		t.Fatal("expected error on truncated subtable")
 	}
 
-	// expected := readExpectedCmap2()
-	// fmt.Println(len(expected))
+	// the subtable uses the Mac Japanese (Shift-JIS) encoding
+	subtable := CmapSubtable{ID: CmapID{PlatformMac, 1}, Cmap: cmap}
+	unicode, ok := subtable.UnicodeCmap()
+	if !ok {
+		t.Fatal("expected Unicode cmap")
+	}
+	for r, code := range map[rune]rune{'A': 0x41, 'あ': 0x82A0, '漢': 0x8ABF, 'ｱ': 0xB1} {
+		if gid, ok := unicode.Lookup(r); !ok || gid != expected[code] {
+			t.Fatalf("rune %c: expected %d, got %d", r, expected[code], gid)
+		}
+	}
+
+	table := TableCmap{Cmaps: []CmapSubtable{subtable}}
+	best, enc := table.BestEncoding()
+	if gid, _ := best.Lookup('あ'); enc != fonts.EncUnicode || gid != expected[0x82A0] {
+		t.Fatalf("unexpected best encoding %d", enc)
+	}
+
+	if _, ok = (CmapSubtable{ID: CmapID{PlatformMicrosoft, PEMicrosoftUnicodeCs}, Cmap: cmap}).UnicodeCmap(); ok {
+		t.Fatal("unexpected Unicode cmap")
+	}
 }
 
 func TestBestEncoding(t *testing.T) {
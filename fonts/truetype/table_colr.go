@@ -0,0 +1,185 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// tableCOLR stores the color glyphs, defined as
+// layers of simple glyphs (version 0) or as
+// paint graphs (version 1).
+type tableCOLR struct {
+	baseGlyphs []baseGlyphRecord // sorted by glyph
+	layers     []fonts.GlyphLayer
+
+	// version 1
+
+	paintGlyphs []paintGlyphRecord // sorted by glyph
+	paintLayers []uint32           // offsets of the layer paints, into data
+	clips       []clipRecord       // sorted by glyph ranges
+	varIndexMap deltaSetMapping    // optional
+	store       VariationStore     // optional
+
+	// the paints are lazily parsed, so that
+	// variations may be applied
+	data []byte
+}
+
+type paintGlyphRecord struct {
+	glyph       GID
+	paintOffset uint32 // into the table data
+}
+
+type clipRecord struct {
+	start, end GID
+	boxOffset  uint32 // into the table data
+}
+
+type baseGlyphRecord struct {
+	glyph      GID
+	firstLayer uint16 // index into layers
+	numLayers  uint16
+}
+
+// glyphData returns the layers for the base glyph `gid`,
+// or false if the glyph is not a color glyph.
+func (t tableCOLR) glyphData(gid GID) (fonts.GlyphLayers, bool) {
+	// binary search
+	for i, j := 0, len(t.baseGlyphs); i < j; {
+		h := i + (j-i)/2
+		entry := t.baseGlyphs[h]
+		if gid < entry.glyph {
+			j = h
+		} else if entry.glyph < gid {
+			i = h + 1
+		} else {
+			end := int(entry.firstLayer) + int(entry.numLayers) // checked when parsing
+			return fonts.GlyphLayers{Layers: t.layers[entry.firstLayer:end]}, true
+		}
+	}
+	return fonts.GlyphLayers{}, false
+}
+
+func parseTableCOLR(data []byte, axisCount int) (out tableCOLR, err error) {
+	if len(data) < 14 {
+		return out, errors.New("invalid 'COLR' table (EOF)")
+	}
+	// version is ignored : version 1 is a superset of version 0
+	numBaseGlyphRecords := int(binary.BigEndian.Uint16(data[2:]))
+	baseGlyphRecordsOffset := int(binary.BigEndian.Uint32(data[4:]))
+	layerRecordsOffset := int(binary.BigEndian.Uint32(data[8:]))
+	numLayerRecords := int(binary.BigEndian.Uint16(data[12:]))
+
+	if len(data) < baseGlyphRecordsOffset+6*numBaseGlyphRecords {
+		return out, errors.New("invalid 'COLR' table (EOF)")
+	}
+	out.baseGlyphs = make([]baseGlyphRecord, numBaseGlyphRecords)
+	for i := range out.baseGlyphs {
+		record := data[baseGlyphRecordsOffset+6*i:]
+		out.baseGlyphs[i].glyph = GID(binary.BigEndian.Uint16(record))
+		out.baseGlyphs[i].firstLayer = binary.BigEndian.Uint16(record[2:])
+		out.baseGlyphs[i].numLayers = binary.BigEndian.Uint16(record[4:])
+		if end := int(out.baseGlyphs[i].firstLayer) + int(out.baseGlyphs[i].numLayers); end > numLayerRecords {
+			return out, fmt.Errorf("invalid 'COLR' table (layer index %d for length %d)", end, numLayerRecords)
+		}
+	}
+
+	if len(data) < layerRecordsOffset+4*numLayerRecords {
+		return out, errors.New("invalid 'COLR' table (EOF)")
+	}
+	out.layers = make([]fonts.GlyphLayer, numLayerRecords)
+	for i := range out.layers {
+		record := data[layerRecordsOffset+4*i:]
+		out.layers[i].GID = GID(binary.BigEndian.Uint16(record))
+		out.layers[i].PaletteIndex = binary.BigEndian.Uint16(record[2:])
+	}
+
+	if version := binary.BigEndian.Uint16(data); version == 0 {
+		return out, nil
+	}
+
+	err = out.parseVersion1(data, axisCount)
+	return out, err
+}
+
+func (out *tableCOLR) parseVersion1(data []byte, axisCount int) (err error) {
+	if len(data) < 34 {
+		return errors.New("invalid 'COLR' table (EOF)")
+	}
+	out.data = data
+	baseGlyphListOffset := binary.BigEndian.Uint32(data[14:])
+	layerListOffset := binary.BigEndian.Uint32(data[18:])
+	clipListOffset := binary.BigEndian.Uint32(data[22:])
+	varIndexMapOffset := binary.BigEndian.Uint32(data[26:])
+	itemVariationStoreOffset := binary.BigEndian.Uint32(data[30:])
+
+	if baseGlyphListOffset != 0 {
+		if len(data) < int(baseGlyphListOffset)+4 {
+			return errors.New("invalid 'COLR' base glyph list (EOF)")
+		}
+		list := data[baseGlyphListOffset:]
+		count := int(binary.BigEndian.Uint32(list))
+		if len(list) < 4+6*count {
+			return errors.New("invalid 'COLR' base glyph list (EOF)")
+		}
+		out.paintGlyphs = make([]paintGlyphRecord, count)
+		for i := range out.paintGlyphs {
+			record := list[4+6*i:]
+			out.paintGlyphs[i].glyph = GID(binary.BigEndian.Uint16(record))
+			out.paintGlyphs[i].paintOffset = baseGlyphListOffset + binary.BigEndian.Uint32(record[2:])
+		}
+	}
+
+	if layerListOffset != 0 {
+		if len(data) < int(layerListOffset)+4 {
+			return errors.New("invalid 'COLR' layer list (EOF)")
+		}
+		list := data[layerListOffset:]
+		count := int(binary.BigEndian.Uint32(list))
+		if len(list) < 4+4*count {
+			return errors.New("invalid 'COLR' layer list (EOF)")
+		}
+		out.paintLayers = parseUint32s(list[4:], count)
+		for i := range out.paintLayers {
+			out.paintLayers[i] += layerListOffset
+		}
+	}
+
+	if clipListOffset != 0 {
+		if len(data) < int(clipListOffset)+5 {
+			return errors.New("invalid 'COLR' clip list (EOF)")
+		}
+		list := data[clipListOffset:]
+		// format is ignored
+		count := int(binary.BigEndian.Uint32(list[1:]))
+		if len(list) < 5+7*count {
+			return errors.New("invalid 'COLR' clip list (EOF)")
+		}
+		out.clips = make([]clipRecord, count)
+		for i := range out.clips {
+			record := list[5+7*i:]
+			out.clips[i].start = GID(binary.BigEndian.Uint16(record))
+			out.clips[i].end = GID(binary.BigEndian.Uint16(record[2:]))
+			out.clips[i].boxOffset = clipListOffset + (uint32(record[4])<<16 | uint32(binary.BigEndian.Uint16(record[5:])))
+		}
+	}
+
+	if varIndexMapOffset != 0 {
+		out.varIndexMap, err = parseDeltaSetMapping(data, varIndexMapOffset)
+		if err != nil {
+			return err
+		}
+	}
+
+	if itemVariationStoreOffset != 0 {
+		out.store, err = parseVariationStore(data, itemVariationStoreOffset, axisCount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
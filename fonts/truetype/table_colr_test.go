@@ -0,0 +1,215 @@
+package truetype
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func TestCOLR(t *testing.T) {
+	font := loadFont(t, "testdata/EmojiFlagCOLR.ttf")
+
+	data, ok := font.GlyphData(8, 0, 0).(fonts.GlyphLayers)
+	if !ok {
+		t.Fatal("expected color layers")
+	}
+	exp := []fonts.GlyphLayer{{GID: 9, PaletteIndex: 0}, {GID: 10, PaletteIndex: 7}, {GID: 11, PaletteIndex: 14}}
+	if !reflect.DeepEqual(data.Layers, exp) {
+		t.Fatalf("expected %v, got %v", exp, data.Layers)
+	}
+
+	// layers are simple glyphs
+	if _, ok := font.GlyphData(9, 0, 0).(fonts.GlyphOutline); !ok {
+		t.Fatal("expected outline")
+	}
+
+	palettes := font.Palettes()
+	if len(palettes) != 2 {
+		t.Fatalf("expected 2 palettes, got %d", len(palettes))
+	}
+	for _, palette := range palettes {
+		if len(palette) != 69 {
+			t.Fatalf("expected 69 entries, got %d", len(palette))
+		}
+	}
+	if c := palettes[0][0]; c != (color.NRGBA{A: 0xff}) {
+		t.Fatalf("unexpected color %v", c)
+	}
+	if c := palettes[0][2]; c != (color.NRGBA{R: 0xc6, G: 0x0b, B: 0x1e, A: 0xff}) {
+		t.Fatalf("unexpected color %v", c)
+	}
+	if font.PaletteType(0) != 0 || font.PaletteName(0) != "" || font.PaletteEntryName(0) != "" {
+		t.Fatal("unexpected palette metadata for version 0")
+	}
+}
+
+func TestCPALVersion1(t *testing.T) {
+	data := deHexStr("0001 0002 0002 0004 0000001C " + // version=1 numPaletteEntries=2 numPalettes=2 numColorRecords=4 colorRecordsArrayOffset=28
+		"0000 0002 " + // colorRecordIndices
+		"0000002C 00000034 00000038 " + // paletteTypesArrayOffset=44 paletteLabelsArrayOffset=52 paletteEntryLabelsArrayOffset=56
+		"000000FF 00FF0080 FF000000 FFFFFFFF " + // color records, as BGRA
+		"00000001 00000002 " + // palette types
+		"0100 FFFF " + // palette labels
+		"FFFF 0101") // palette entry labels
+
+	cpal, err := parseTableCPAL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expPalettes := [][]color.NRGBA{
+		{{A: 0xff}, {G: 0xff, A: 0x80}},
+		{{B: 0xff}, {R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+	}
+	if !reflect.DeepEqual(cpal.Palettes, expPalettes) {
+		t.Fatalf("expected %v, got %v", expPalettes, cpal.Palettes)
+	}
+	if exp := []PaletteType{PaletteUsableWithLightBackground, PaletteUsableWithDarkBackground}; !reflect.DeepEqual(cpal.Types, exp) {
+		t.Fatalf("expected %v, got %v", exp, cpal.Types)
+	}
+	if exp := []NameID{256, noNameID}; !reflect.DeepEqual(cpal.Labels, exp) {
+		t.Fatalf("expected %v, got %v", exp, cpal.Labels)
+	}
+	if exp := []NameID{noNameID, 257}; !reflect.DeepEqual(cpal.EntryLabels, exp) {
+		t.Fatalf("expected %v, got %v", exp, cpal.EntryLabels)
+	}
+
+	font := Font{cpal: cpal, Names: TableName{
+		{PlatformID: PlatformUnicode, NameID: 256, Value: []byte{0, 'D', 0, 'a', 0, 'y'}},
+		{PlatformID: PlatformUnicode, NameID: 257, Value: []byte{0, 'S', 0, 'k', 0, 'y'}},
+	}}
+	if font.PaletteName(0) != "Day" || font.PaletteName(1) != "" {
+		t.Fatalf("unexpected palette names %s %s", font.PaletteName(0), font.PaletteName(1))
+	}
+	if font.PaletteEntryName(0) != "" || font.PaletteEntryName(1) != "Sky" {
+		t.Fatalf("unexpected entry names %s %s", font.PaletteEntryName(0), font.PaletteEntryName(1))
+	}
+	if font.PaletteType(1) != PaletteUsableWithDarkBackground || font.PaletteType(2) != 0 {
+		t.Fatal("unexpected palette types")
+	}
+
+	for i := range data {
+		parseTableCPAL(data[:i]) // check for crashes
+	}
+}
+
+func TestCOLRVersion1(t *testing.T) {
+	data := deHexStr("0001 0000 00000000 00000000 0000 " + // version 1, no version 0 records
+		"00000022 00000032 0000003E 00000000 000000B9 " + // baseGlyphList=34 layerList=50 clipList=62 varIndexMap=0 varStore=185
+		"00000002 0005 00000035 0006 00000055 " + // 34: base glyph list: 5 -> 87, 6 -> 119
+		"00000002 0000002B 00000036 " + // 50: layer list: 93, 104
+		"01 00000001 0005 0006 00000C " + // 62: clip list: 5 to 6 -> 74
+		"02 0000 0000 0064 00C8 00000000 " + // 74: variable clip box (0, 0, 100, 200), varIndexBase=0
+		"01 02 00000000 " + // 87: PaintColrLayers
+		"0A 000006 0001 " + // 93: PaintGlyph
+		"02 0000 4000 " + // 99: PaintSolid
+		"0A 000006 0002 " + // 104: PaintGlyph
+		"03 0001 2000 00000000 " + // 110: PaintVarSolid, varIndexBase=0
+		"0F 00000C 000A FFEC 00000000 " + // 119: PaintVarTranslate (10, -20), varIndexBase=0
+		"20 000008 03 00001C " + // 131: PaintComposite
+		"05 000017 0000 0000 0064 0000 0000 0064 00000002 " + // 139: PaintVarLinearGradient, varIndexBase=2
+		"0B 0005 " + // 159: PaintColrGlyph
+		"01 0002 0000 0000 4000 00000000 4000 0001 4000 FFFFFFFF " + // 162: VarColorLine
+		"0001 0000000C 0001 00000016 " + // 185: item variation store
+		"0001 0001 0000 4000 4000 " + // region list
+		"0008 0001 0001 0000 000A 0014 001E 0028 0032 003C 0046 0050") // item variation data
+
+	colr, err := parseTableCOLR(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layers := fonts.PaintColrLayers{Layers: []fonts.Paint{
+		fonts.PaintGlyph{GID: 1, Paint: fonts.PaintSolid{PaletteIndex: 0, Alpha: 1}},
+		fonts.PaintGlyph{GID: 2, Paint: fonts.PaintSolid{PaletteIndex: 1, Alpha: 0.5}},
+	}}
+	composite := fonts.PaintComposite{
+		Mode: fonts.CompositeSrcOver,
+		Source: fonts.PaintLinearGradient{
+			ColorLine: fonts.ColorLine{Extend: fonts.ExtendRepeat, Stops: []fonts.ColorStop{
+				{Offset: 0, PaletteIndex: 0, Alpha: 1},
+				{Offset: 1, PaletteIndex: 1, Alpha: 1},
+			}},
+			X0: 0, Y0: 0, X1: 100, Y1: 0, X2: 0, Y2: 100,
+		},
+		Backdrop: fonts.PaintColrGlyph{GID: 5},
+	}
+	clip := &fonts.ClipBox{XMin: 0, YMin: 0, XMax: 100, YMax: 200}
+
+	// default instance
+	glyph, err := colr.paintGlyphData(5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (fonts.GlyphColor{Paint: layers, Clip: clip}); !reflect.DeepEqual(glyph, exp) {
+		t.Fatalf("expected %v, got %v", exp, glyph)
+	}
+	glyph, err = colr.paintGlyphData(6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (fonts.GlyphColor{Paint: fonts.PaintTranslate{Paint: composite, DX: 10, DY: -20}, Clip: clip}); !reflect.DeepEqual(glyph, exp) {
+		t.Fatalf("expected %v, got %v", exp, glyph)
+	}
+
+	// variable instance, with deltas 10, 20, 30, ...
+	coords := []float32{1}
+	layers.Layers[1] = fonts.PaintGlyph{GID: 2, Paint: fonts.PaintSolid{PaletteIndex: 1, Alpha: float32(0x2000+10) / (1 << 14)}}
+	composite.Source = fonts.PaintLinearGradient{
+		ColorLine: fonts.ColorLine{Extend: fonts.ExtendRepeat, Stops: []fonts.ColorStop{
+			{Offset: float32(10) / (1 << 14), PaletteIndex: 0, Alpha: float32(0x4000+20) / (1 << 14)},
+			{Offset: 1, PaletteIndex: 1, Alpha: 1},
+		}},
+		X0: 30, Y0: 40, X1: 150, Y1: 60, X2: 70, Y2: 180,
+	}
+	clip = &fonts.ClipBox{XMin: 10, YMin: 20, XMax: 130, YMax: 240}
+
+	glyph, err = colr.paintGlyphData(5, coords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (fonts.GlyphColor{Paint: layers, Clip: clip}); !reflect.DeepEqual(glyph, exp) {
+		t.Fatalf("expected %v, got %v", exp, glyph)
+	}
+	glyph, err = colr.paintGlyphData(6, coords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (fonts.GlyphColor{Paint: fonts.PaintTranslate{Paint: composite, DX: 20, DY: 0}, Clip: clip}); !reflect.DeepEqual(glyph, exp) {
+		t.Fatalf("expected %v, got %v", exp, glyph)
+	}
+
+	if _, err = colr.paintGlyphData(7, coords); err == nil {
+		t.Fatal("expected error for missing glyph")
+	}
+
+	// the table still provides version 0 data
+	if _, ok := colr.glyphData(5); ok {
+		t.Fatal("unexpected layers")
+	}
+
+	for i := range data {
+		colr, err := parseTableCOLR(data[:i], 1) // check for crashes
+		if err == nil {
+			colr.paintGlyphData(5, coords)
+			colr.paintGlyphData(6, coords)
+		}
+	}
+}
+
+func TestCOLRCycle(t *testing.T) {
+	data := deHexStr("0001 0000 00000000 00000000 0000 " + // version 1, no version 0 records
+		"00000022 0000002C 00000000 00000000 00000000 " + // baseGlyphList=34 layerList=44
+		"00000001 0005 00000012 " + // 34: base glyph list: 5 -> 52
+		"00000001 00000008 " + // 44: layer list: 52
+		"01 01 00000000") // 52: PaintColrLayers, referencing itself
+
+	colr, err := parseTableCOLR(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = colr.paintGlyphData(5, nil); err == nil {
+		t.Fatal("expected error for cyclic paint graph")
+	}
+}
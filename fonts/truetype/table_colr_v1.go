@@ -0,0 +1,376 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// This file implements the paint graph of COLR version 1.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/colr
+
+const (
+	// limits used to protect against malicious fonts (as in harfbuzz)
+	maxPaintNesting = 64
+	maxPaintCount   = 1 << 16
+
+	noVarIndex = 0xFFFFFFFF
+)
+
+// paintGlyphData returns the paint graph for the base glyph `gid`,
+// with variations applied for `coords`, which may be nil.
+func (t *tableCOLR) paintGlyphData(gid GID, coords []float32) (fonts.GlyphColor, error) {
+	// binary search
+	for i, j := 0, len(t.paintGlyphs); i < j; {
+		h := i + (j-i)/2
+		entry := t.paintGlyphs[h]
+		if gid < entry.glyph {
+			j = h
+		} else if entry.glyph < gid {
+			i = h + 1
+		} else {
+			r := paintResolver{colr: t, coords: coords, budget: maxPaintCount}
+			paint, err := r.parsePaint(entry.paintOffset)
+			if err != nil {
+				return fonts.GlyphColor{}, err
+			}
+			out := fonts.GlyphColor{Paint: paint}
+			out.Clip, err = r.clipBox(gid)
+			return out, err
+		}
+	}
+	return fonts.GlyphColor{}, fmt.Errorf("no paint for glyph %d", gid)
+}
+
+// paintResolver decodes the paint graph, resolving
+// the variable values
+type paintResolver struct {
+	colr   *tableCOLR
+	coords []float32 // may be nil
+	depth  int
+	budget int // remaining number of paints
+}
+
+// delta returns the variation for the value at `varIndexBase + i`
+func (r *paintResolver) delta(varIndexBase uint32, i int) float32 {
+	if varIndexBase == noVarIndex || len(r.coords) == 0 {
+		return 0
+	}
+	varIndex := varIndexBase + uint32(i)
+	var index VariationStoreIndex
+	if m := r.colr.varIndexMap; len(m) != 0 {
+		// If a given index is greater than mapCount - 1, then the last entry is used.
+		if int(varIndex) >= len(m) {
+			varIndex = uint32(len(m) - 1)
+		}
+		index = m[varIndex]
+	} else { // implicit mapping
+		index = VariationStoreIndex{DeltaSetOuter: uint16(varIndex >> 16), DeltaSetInner: uint16(varIndex)}
+	}
+	return r.colr.store.GetDelta(index, r.coords)
+}
+
+// fieldKind is the encoding of a (possibly variable) value
+type fieldKind uint8
+
+const (
+	fWord   fieldKind = iota // int16, in font units
+	ufWord                   // uint16, in font units
+	f2Dot14                  // fixed 2.14
+	angle                    // fixed 2.14, where 1 is 180 degrees
+	fixed32                  // fixed 16.16
+)
+
+// size returns the size in bytes of the field
+func (k fieldKind) size() int {
+	if k == fixed32 {
+		return 4
+	}
+	return 2
+}
+
+// values reads the fields described by `kinds`, starting at `data[0]`,
+// applying the variations if `isVar` is true, in which case
+// the variation index base is expected right after the fields.
+// The length of `data` must have been checked.
+func (r *paintResolver) values(data []byte, isVar bool, kinds ...fieldKind) []float32 {
+	out := make([]float32, len(kinds))
+	var raw []int32
+	for _, kind := range kinds {
+		switch kind {
+		case fWord, f2Dot14, angle:
+			raw = append(raw, int32(int16(binary.BigEndian.Uint16(data))))
+		case ufWord:
+			raw = append(raw, int32(binary.BigEndian.Uint16(data)))
+		case fixed32:
+			raw = append(raw, int32(binary.BigEndian.Uint32(data)))
+		}
+		data = data[kind.size():]
+	}
+	varIndexBase := uint32(noVarIndex)
+	if isVar {
+		varIndexBase = binary.BigEndian.Uint32(data)
+	}
+	for i, kind := range kinds {
+		// deltas are expressed in the same units as the values
+		v := float32(raw[i]) + r.delta(varIndexBase, i)
+		switch kind {
+		case f2Dot14:
+			v /= 1 << 14
+		case angle:
+			v = v / (1 << 14) * 180
+		case fixed32:
+			v /= 1 << 16
+		}
+		out[i] = v
+	}
+	return out
+}
+
+func offset24(data []byte) uint32 {
+	return uint32(data[0])<<16 | uint32(binary.BigEndian.Uint16(data[1:]))
+}
+
+// paintSizes stores the minimum size for each paint format,
+// including the format byte.
+var paintSizes = [...]int{
+	1: 6, 2: 5, 3: 9, 4: 16, 5: 20, 6: 16, 7: 20, 8: 12, 9: 16,
+	10: 6, 11: 3, 12: 7, 13: 7, 14: 8, 15: 12, 16: 8, 17: 12, 18: 12, 19: 16,
+	20: 6, 21: 10, 22: 10, 23: 14, 24: 6, 25: 10, 26: 10, 27: 14,
+	28: 8, 29: 12, 30: 12, 31: 16, 32: 8,
+}
+
+// parsePaint parses the paint at `offset` in the table data
+func (r *paintResolver) parsePaint(offset uint32) (fonts.Paint, error) {
+	if r.depth >= maxPaintNesting {
+		return nil, errors.New("invalid 'COLR' table (maximum nesting level reached)")
+	}
+	if r.budget <= 0 {
+		return nil, errors.New("invalid 'COLR' table (too many paints)")
+	}
+	r.depth++
+	r.budget--
+	defer func() { r.depth-- }()
+
+	if len(r.colr.data) < int(offset)+1 {
+		return nil, errors.New("invalid 'COLR' paint offset (EOF)")
+	}
+	data := r.colr.data[offset:]
+	format := data[0]
+	if format == 0 || int(format) >= len(paintSizes) {
+		return nil, fmt.Errorf("invalid 'COLR' paint format %d", format)
+	}
+	if len(data) < paintSizes[format] {
+		return nil, fmt.Errorf("invalid 'COLR' paint format %d (EOF)", format)
+	}
+	isVar := format%2 == 1 // only meaningful for format >= 3
+
+	// most paints wrap a child paint
+	child := func() (fonts.Paint, error) { return r.parsePaint(offset + offset24(data[1:])) }
+
+	switch format {
+	case 1: // PaintColrLayers
+		numLayers := int(data[1])
+		firstLayer := int(binary.BigEndian.Uint32(data[2:]))
+		if firstLayer+numLayers > len(r.colr.paintLayers) {
+			return nil, fmt.Errorf("invalid 'COLR' layer index %d", firstLayer+numLayers)
+		}
+		out := fonts.PaintColrLayers{Layers: make([]fonts.Paint, numLayers)}
+		for i, layerOffset := range r.colr.paintLayers[firstLayer : firstLayer+numLayers] {
+			var err error
+			out.Layers[i], err = r.parsePaint(layerOffset)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case 2, 3: // PaintSolid, PaintVarSolid
+		v := r.values(data[3:], format == 3, f2Dot14)
+		return fonts.PaintSolid{PaletteIndex: binary.BigEndian.Uint16(data[1:]), Alpha: v[0]}, nil
+	case 4, 5: // PaintLinearGradient, PaintVarLinearGradient
+		cl, err := r.parseColorLine(offset+offset24(data[1:]), format == 5)
+		if err != nil {
+			return nil, err
+		}
+		v := r.values(data[4:], format == 5, fWord, fWord, fWord, fWord, fWord, fWord)
+		return fonts.PaintLinearGradient{ColorLine: cl, X0: v[0], Y0: v[1], X1: v[2], Y1: v[3], X2: v[4], Y2: v[5]}, nil
+	case 6, 7: // PaintRadialGradient, PaintVarRadialGradient
+		cl, err := r.parseColorLine(offset+offset24(data[1:]), format == 7)
+		if err != nil {
+			return nil, err
+		}
+		v := r.values(data[4:], format == 7, fWord, fWord, ufWord, fWord, fWord, ufWord)
+		return fonts.PaintRadialGradient{
+			ColorLine: cl,
+			X0:        v[0], Y0: v[1], Radius0: v[2],
+			X1: v[3], Y1: v[4], Radius1: v[5],
+		}, nil
+	case 8, 9: // PaintSweepGradient, PaintVarSweepGradient
+		cl, err := r.parseColorLine(offset+offset24(data[1:]), format == 9)
+		if err != nil {
+			return nil, err
+		}
+		v := r.values(data[4:], format == 9, fWord, fWord, angle, angle)
+		return fonts.PaintSweepGradient{ColorLine: cl, CenterX: v[0], CenterY: v[1], StartAngle: v[2], EndAngle: v[3]}, nil
+	case 10: // PaintGlyph
+		paint, err := child()
+		if err != nil {
+			return nil, err
+		}
+		return fonts.PaintGlyph{Paint: paint, GID: GID(binary.BigEndian.Uint16(data[4:]))}, nil
+	case 11: // PaintColrGlyph
+		return fonts.PaintColrGlyph{GID: GID(binary.BigEndian.Uint16(data[1:]))}, nil
+	case 12, 13: // PaintTransform, PaintVarTransform
+		paint, err := child()
+		if err != nil {
+			return nil, err
+		}
+		transformOffset := int(offset + offset24(data[4:]))
+		size := 24
+		if format == 13 {
+			size += 4
+		}
+		if len(r.colr.data) < transformOffset+size {
+			return nil, errors.New("invalid 'COLR' transform (EOF)")
+		}
+		v := r.values(r.colr.data[transformOffset:], format == 13, fixed32, fixed32, fixed32, fixed32, fixed32, fixed32)
+		return fonts.PaintTransform{Paint: paint, Transform: fonts.Affine2x3{
+			XX: v[0], YX: v[1], XY: v[2], YY: v[3], DX: v[4], DY: v[5],
+		}}, nil
+	case 14, 15: // PaintTranslate, PaintVarTranslate
+		paint, err := child()
+		if err != nil {
+			return nil, err
+		}
+		v := r.values(data[4:], isVar, fWord, fWord)
+		return fonts.PaintTranslate{Paint: paint, DX: v[0], DY: v[1]}, nil
+	case 16, 17, 18, 19, 20, 21, 22, 23: // PaintScale and variants
+		paint, err := child()
+		if err != nil {
+			return nil, err
+		}
+		var kinds []fieldKind
+		uniform, aroundCenter := format >= 20, format == 18 || format == 19 || format >= 22
+		if uniform {
+			kinds = append(kinds, f2Dot14)
+		} else {
+			kinds = append(kinds, f2Dot14, f2Dot14)
+		}
+		if aroundCenter {
+			kinds = append(kinds, fWord, fWord)
+		}
+		v := r.values(data[4:], isVar, kinds...)
+		out := fonts.PaintScale{Paint: paint, ScaleX: v[0], ScaleY: v[0]}
+		if !uniform {
+			out.ScaleY, v = v[1], v[1:]
+		}
+		if aroundCenter {
+			out.CenterX, out.CenterY = v[1], v[2]
+		}
+		return out, nil
+	case 24, 25, 26, 27: // PaintRotate and variants
+		paint, err := child()
+		if err != nil {
+			return nil, err
+		}
+		kinds := []fieldKind{angle}
+		if format >= 26 {
+			kinds = append(kinds, fWord, fWord)
+		}
+		v := r.values(data[4:], isVar, kinds...)
+		out := fonts.PaintRotate{Paint: paint, Angle: v[0]}
+		if format >= 26 {
+			out.CenterX, out.CenterY = v[1], v[2]
+		}
+		return out, nil
+	case 28, 29, 30, 31: // PaintSkew and variants
+		paint, err := child()
+		if err != nil {
+			return nil, err
+		}
+		kinds := []fieldKind{angle, angle}
+		if format >= 30 {
+			kinds = append(kinds, fWord, fWord)
+		}
+		v := r.values(data[4:], isVar, kinds...)
+		out := fonts.PaintSkew{Paint: paint, XSkewAngle: v[0], YSkewAngle: v[1]}
+		if format >= 30 {
+			out.CenterX, out.CenterY = v[2], v[3]
+		}
+		return out, nil
+	default: // 32 : PaintComposite
+		source, err := child()
+		if err != nil {
+			return nil, err
+		}
+		backdrop, err := r.parsePaint(offset + offset24(data[5:]))
+		if err != nil {
+			return nil, err
+		}
+		return fonts.PaintComposite{Source: source, Backdrop: backdrop, Mode: fonts.CompositeMode(data[4])}, nil
+	}
+}
+
+// parseColorLine parses the (possibly variable) color line at `offset`
+func (r *paintResolver) parseColorLine(offset uint32, isVar bool) (fonts.ColorLine, error) {
+	if len(r.colr.data) < int(offset)+3 {
+		return fonts.ColorLine{}, errors.New("invalid 'COLR' color line (EOF)")
+	}
+	data := r.colr.data[offset:]
+	out := fonts.ColorLine{Extend: fonts.Extend(data[0])}
+	numStops := int(binary.BigEndian.Uint16(data[1:]))
+	stopSize := 6
+	if isVar {
+		stopSize += 4
+	}
+	if len(data) < 3+numStops*stopSize {
+		return fonts.ColorLine{}, errors.New("invalid 'COLR' color line (EOF)")
+	}
+	out.Stops = make([]fonts.ColorStop, numStops)
+	for i := range out.Stops {
+		stop := data[3+i*stopSize:]
+		// the palette index is between the two (possibly variable) values
+		// so that we can't use a single 'values' call
+		varIndexBase := uint32(noVarIndex)
+		if isVar {
+			varIndexBase = binary.BigEndian.Uint32(stop[6:])
+		}
+		stopOffset := float32(int16(binary.BigEndian.Uint16(stop))) + r.delta(varIndexBase, 0)
+		alpha := float32(int16(binary.BigEndian.Uint16(stop[4:]))) + r.delta(varIndexBase, 1)
+		out.Stops[i] = fonts.ColorStop{
+			Offset:       stopOffset / (1 << 14),
+			PaletteIndex: binary.BigEndian.Uint16(stop[2:]),
+			Alpha:        alpha / (1 << 14),
+		}
+	}
+	return out, nil
+}
+
+// clipBox returns the clip box for `gid`, or nil if not found
+func (r *paintResolver) clipBox(gid GID) (*fonts.ClipBox, error) {
+	// binary search
+	clips := r.colr.clips
+	for i, j := 0, len(clips); i < j; {
+		h := i + (j-i)/2
+		entry := clips[h]
+		if gid < entry.start {
+			j = h
+		} else if entry.end < gid {
+			i = h + 1
+		} else {
+			if len(r.colr.data) < int(entry.boxOffset)+9 {
+				return nil, errors.New("invalid 'COLR' clip box (EOF)")
+			}
+			data := r.colr.data[entry.boxOffset:]
+			isVar := data[0] == 2
+			if isVar && len(data) < 13 {
+				return nil, errors.New("invalid 'COLR' clip box (EOF)")
+			}
+			v := r.values(data[1:], isVar, fWord, fWord, fWord, fWord)
+			return &fonts.ClipBox{XMin: v[0], YMin: v[1], XMax: v[2], YMax: v[3]}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,160 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"image/color"
+)
+
+// PaletteType is a set of flags describing
+// the intended usage of a color palette.
+type PaletteType uint32
+
+const (
+	// The palette is appropriate to use when displaying
+	// the font on a light background such as white.
+	PaletteUsableWithLightBackground PaletteType = 1 << iota
+	// The palette is appropriate to use when displaying
+	// the font on a dark background such as black.
+	PaletteUsableWithDarkBackground
+)
+
+// noNameID is used in the 'CPAL' table when
+// no name is provided.
+const noNameID NameID = 0xFFFF
+
+// TableCPAL stores the color palettes used by color glyphs.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/cpal
+type TableCPAL struct {
+	// Palettes all have the same number of entries.
+	// The colors are not premultiplied.
+	Palettes [][]color.NRGBA
+
+	// The following fields are only present in version 1,
+	// and are empty for version 0.
+
+	Types       []PaletteType // same length as Palettes
+	Labels      []NameID      // same length as Palettes, 0xFFFF for no label
+	EntryLabels []NameID      // one for each palette entry, 0xFFFF for no label
+}
+
+func parseTableCPAL(data []byte) (out TableCPAL, err error) {
+	if len(data) < 12 {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	version := binary.BigEndian.Uint16(data)
+	numPaletteEntries := int(binary.BigEndian.Uint16(data[2:]))
+	numPalettes := int(binary.BigEndian.Uint16(data[4:]))
+	numColorRecords := int(binary.BigEndian.Uint16(data[6:]))
+	colorRecordsArrayOffset := int(binary.BigEndian.Uint32(data[8:]))
+
+	colorRecordIndices, err := parseUint16s(data[12:], numPalettes)
+	if err != nil {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	if len(data) < colorRecordsArrayOffset+4*numColorRecords {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	colors := make([]color.NRGBA, numColorRecords)
+	for i := range colors {
+		record := data[colorRecordsArrayOffset+4*i:]
+		// stored as BGRA
+		colors[i] = color.NRGBA{B: record[0], G: record[1], R: record[2], A: record[3]}
+	}
+
+	out.Palettes = make([][]color.NRGBA, numPalettes)
+	for i, start := range colorRecordIndices {
+		end := int(start) + numPaletteEntries
+		if end > numColorRecords {
+			return out, errors.New("invalid 'CPAL' table (color index out of range)")
+		}
+		out.Palettes[i] = colors[start:end]
+	}
+
+	if version == 0 {
+		return out, nil
+	}
+
+	header := data[12+2*numPalettes:] // length checked by parseUint16s
+	if len(header) < 12 {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	paletteTypesArrayOffset := int(binary.BigEndian.Uint32(header))
+	paletteLabelsArrayOffset := int(binary.BigEndian.Uint32(header[4:]))
+	paletteEntryLabelsArrayOffset := int(binary.BigEndian.Uint32(header[8:]))
+
+	if paletteTypesArrayOffset != 0 {
+		if len(data) < paletteTypesArrayOffset+4*numPalettes {
+			return out, errors.New("invalid 'CPAL' table (EOF)")
+		}
+		out.Types = make([]PaletteType, numPalettes)
+		for i := range out.Types {
+			out.Types[i] = PaletteType(binary.BigEndian.Uint32(data[paletteTypesArrayOffset+4*i:]))
+		}
+	}
+	if paletteLabelsArrayOffset != 0 {
+		if len(data) < paletteLabelsArrayOffset {
+			return out, errors.New("invalid 'CPAL' table (EOF)")
+		}
+		out.Labels, err = parseNameIDs(data[paletteLabelsArrayOffset:], numPalettes)
+		if err != nil {
+			return out, err
+		}
+	}
+	if paletteEntryLabelsArrayOffset != 0 {
+		if len(data) < paletteEntryLabelsArrayOffset {
+			return out, errors.New("invalid 'CPAL' table (EOF)")
+		}
+		out.EntryLabels, err = parseNameIDs(data[paletteEntryLabelsArrayOffset:], numPaletteEntries)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+func parseNameIDs(data []byte, count int) ([]NameID, error) {
+	ids, err := parseUint16s(data, count)
+	if err != nil {
+		return nil, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	out := make([]NameID, count)
+	for i, id := range ids {
+		out[i] = NameID(id)
+	}
+	return out, nil
+}
+
+// Palettes returns the color palettes defined in the font,
+// or nil if the font has no valid 'CPAL' table.
+// Each palette has the same number of entries, which are referenced
+// by the `PaletteIndex` field of the color layers (see fonts.GlyphLayers).
+func (font *Font) Palettes() [][]color.NRGBA { return font.cpal.Palettes }
+
+// PaletteType returns the flags describing the palette at `index`,
+// or 0 if not specified.
+func (font *Font) PaletteType(index int) PaletteType {
+	if index < 0 || index >= len(font.cpal.Types) {
+		return 0
+	}
+	return font.cpal.Types[index]
+}
+
+// PaletteName returns the user-friendly name of the palette at `index`,
+// or an empty string if not specified.
+func (font *Font) PaletteName(index int) string {
+	if index < 0 || index >= len(font.cpal.Labels) || font.cpal.Labels[index] == noNameID {
+		return ""
+	}
+	return font.Names.getName(font.cpal.Labels[index])
+}
+
+// PaletteEntryName returns the user-friendly name of the palette entry at `entry`,
+// or an empty string if not specified.
+func (font *Font) PaletteEntryName(entry int) string {
+	if entry < 0 || entry >= len(font.cpal.EntryLabels) || font.cpal.EntryLabels[entry] == noNameID {
+		return ""
+	}
+	return font.Names.getName(font.cpal.EntryLabels[entry])
+}
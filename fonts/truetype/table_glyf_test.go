@@ -85,7 +85,7 @@ func TestCoordinatesGlyph(t *testing.T) {
 	locaBin := []byte{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1b}
 	maxpBin := []byte{0x0, 0x1, 0x0, 0x0, 0x0, 0x4, 0x0, 0xb, 0x0, 0x2, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0xa, 0x0, 0x0, 0x2, 0x0, 0x1, 0x61, 0x0, 0x0, 0x0, 0x0}
 
-	num, err := parseTableMaxp(maxpBin)
+	maxp, err := parseTableMaxp(maxpBin)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -93,7 +93,7 @@ func TestCoordinatesGlyph(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	loca, err := parseTableLoca(locaBin, int(num), head.indexToLocFormat == 1)
+	loca, err := parseTableLoca(locaBin, int(maxp.NumGlyphs), head.indexToLocFormat == 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -102,8 +102,8 @@ func TestCoordinatesGlyph(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if num != 4 {
-		t.Errorf("expected 4 glyphs, got %d", num)
+	if maxp.NumGlyphs != 4 {
+		t.Errorf("expected 4 glyphs, got %d", maxp.NumGlyphs)
 	}
 	if glyphs[0].data != nil {
 		t.Errorf("expected no glyph data for glyph 0")
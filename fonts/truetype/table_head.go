@@ -52,6 +52,30 @@ func parseTableHead(data []byte) (out TableHead, err error) {
 	return out, err
 }
 
+// Serialize encodes the table. The checksum adjustment is
+// stored as it is: see FontBuilder to compute it.
+func (head *TableHead) Serialize() []byte {
+	out := make([]byte, 54)
+	binary.BigEndian.PutUint32(out, 0x00010000) // version
+	binary.BigEndian.PutUint32(out[4:], head.FontRevision)
+	binary.BigEndian.PutUint32(out[8:], head.checkSumAdjustment)
+	binary.BigEndian.PutUint32(out[12:], 0x5F0F3CF5) // magic number
+	binary.BigEndian.PutUint16(out[16:], head.Flags)
+	binary.BigEndian.PutUint16(out[18:], head.UnitsPerEm)
+	binary.BigEndian.PutUint64(out[20:], head.Created.SecondsSince1904)
+	binary.BigEndian.PutUint64(out[28:], head.Updated.SecondsSince1904)
+	binary.BigEndian.PutUint16(out[36:], uint16(head.XMin))
+	binary.BigEndian.PutUint16(out[38:], uint16(head.YMin))
+	binary.BigEndian.PutUint16(out[40:], uint16(head.XMax))
+	binary.BigEndian.PutUint16(out[42:], uint16(head.YMax))
+	binary.BigEndian.PutUint16(out[44:], head.MacStyle)
+	binary.BigEndian.PutUint16(out[46:], head.LowestRecPPEM)
+	binary.BigEndian.PutUint16(out[48:], uint16(head.FontDirection))
+	binary.BigEndian.PutUint16(out[50:], uint16(head.indexToLocFormat))
+	// glyphDataFormat is 0
+	return out
+}
+
 // ExpectedChecksum is the checksum that the file should have had.
 func (table *TableHead) ExpectedChecksum() uint32 {
 	return 0xB1B0AFBA - table.checkSumAdjustment
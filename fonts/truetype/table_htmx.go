@@ -7,12 +7,68 @@ import (
 
 var errInvalidMaxpTable = errors.New("invalid maxp table")
 
-func parseTableMaxp(input []byte) (numGlyphs int, err error) {
+// TableMaxp is the 'maxp' table, which stores the memory requirements of the font.
+// For fonts with CFF outlines (version 0.5), only NumGlyphs is defined.
+type TableMaxp struct {
+	Version   uint32 // 0x00005000 or 0x00010000
+	NumGlyphs uint16
+
+	// The following fields are only used in version 1.0
+	MaxPoints             uint16
+	MaxContours           uint16
+	MaxCompositePoints    uint16
+	MaxCompositeContours  uint16
+	MaxZones              uint16
+	MaxTwilightPoints     uint16
+	MaxStorage            uint16
+	MaxFunctionDefs       uint16
+	MaxInstructionDefs    uint16
+	MaxStackElements      uint16
+	MaxSizeOfInstructions uint16
+	MaxComponentElements  uint16
+	MaxComponentDepth     uint16
+}
+
+func parseTableMaxp(input []byte) (out TableMaxp, err error) {
 	if len(input) < 6 {
-		return 0, errInvalidMaxpTable
+		return out, errInvalidMaxpTable
+	}
+	out.Version = binary.BigEndian.Uint32(input)
+	out.NumGlyphs = binary.BigEndian.Uint16(input[4:6])
+	if out.Version == 0x00010000 && len(input) >= 32 {
+		fields := [...]*uint16{
+			&out.MaxPoints, &out.MaxContours, &out.MaxCompositePoints, &out.MaxCompositeContours,
+			&out.MaxZones, &out.MaxTwilightPoints, &out.MaxStorage, &out.MaxFunctionDefs,
+			&out.MaxInstructionDefs, &out.MaxStackElements, &out.MaxSizeOfInstructions,
+			&out.MaxComponentElements, &out.MaxComponentDepth,
+		}
+		for i, field := range fields {
+			*field = binary.BigEndian.Uint16(input[6+2*i:])
+		}
+	}
+	return out, nil
+}
+
+// Serialize encodes the table. The version 1.0 fields are
+// only written if `Version` is 0x00010000.
+func (t TableMaxp) Serialize() []byte {
+	if t.Version != 0x00010000 {
+		out := make([]byte, 6)
+		binary.BigEndian.PutUint32(out, 0x00005000)
+		binary.BigEndian.PutUint16(out[4:], t.NumGlyphs)
+		return out
+	}
+	out := make([]byte, 32)
+	binary.BigEndian.PutUint32(out, t.Version)
+	for i, v := range [...]uint16{
+		t.NumGlyphs, t.MaxPoints, t.MaxContours, t.MaxCompositePoints, t.MaxCompositeContours,
+		t.MaxZones, t.MaxTwilightPoints, t.MaxStorage, t.MaxFunctionDefs,
+		t.MaxInstructionDefs, t.MaxStackElements, t.MaxSizeOfInstructions,
+		t.MaxComponentElements, t.MaxComponentDepth,
+	} {
+		binary.BigEndian.PutUint16(out[4+2*i:], v)
 	}
-	out := binary.BigEndian.Uint16(input[4:6])
-	return int(out), nil
+	return out
 }
 
 type TableHVmtx []Metric // with length numGlyphs
@@ -29,6 +85,25 @@ type Metric struct {
 	Advance, SideBearing int16
 }
 
+// Serialize encodes the metrics, omitting the advances repeated at the end,
+// and returns the number of advances written, which must be stored
+// in the 'hhea' (or 'vhea') table.
+func (t TableHVmtx) Serialize() (out []byte, numOfLongMetrics uint16) {
+	numLong := len(t)
+	for numLong > 1 && t[numLong-1].Advance == t[numLong-2].Advance {
+		numLong--
+	}
+
+	out = make([]byte, 0, 4*numLong+2*(len(t)-numLong))
+	for i, metric := range t {
+		if i < numLong {
+			out = append(out, byte(uint16(metric.Advance)>>8), byte(metric.Advance))
+		}
+		out = append(out, byte(uint16(metric.SideBearing)>>8), byte(metric.SideBearing))
+	}
+	return out, uint16(numLong)
+}
+
 // pad the width if numberOfHMetrics < numGlyphs
 func parseHVmtxTable(input []byte, numberOfHMetrics, numGlyphs uint16) (TableHVmtx, error) {
 	if numberOfHMetrics == 0 {
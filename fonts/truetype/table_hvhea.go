@@ -59,3 +59,20 @@ func parseTableHVhea(buf []byte) (*TableHVhea, error) {
 	}
 	return &out, nil
 }
+
+// Serialize encodes the table (using version 1.0).
+// `numOfLongMetrics` is the number of advances stored in the
+// 'hmtx' or 'vmtx' table, as returned by TableHVmtx.Serialize.
+func (t *TableHVhea) Serialize(numOfLongMetrics uint16) []byte {
+	out := make([]byte, 36)
+	binary.BigEndian.PutUint32(out, 0x00010000)
+	for i, v := range [...]int16{
+		t.Ascent, t.Descent, t.LineGap, int16(t.AdvanceMax), t.MinFirstSideBearing,
+		t.MinSecondSideBearing, t.MaxExtent, t.CaretSlopeRise, t.CaretSlopeRun, t.CaretOffset,
+	} {
+		binary.BigEndian.PutUint16(out[4+2*i:], uint16(v))
+	}
+	// reserved fields and metricDataFormat are 0
+	binary.BigEndian.PutUint16(out[34:], numOfLongMetrics)
+	return out
+}
@@ -0,0 +1,230 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var tagJstf = MustNewTag("JSTF")
+
+// TableJstf is the Justification table, which provides lookups
+// used to shrink or extend lines of text.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/jstf
+type TableJstf struct {
+	Scripts []JstfScript // sorted by tag
+}
+
+// FindScript returns the justification data for `script`,
+// or false if not found.
+func (t TableJstf) FindScript(script Tag) (JstfScript, bool) {
+	i := sort.Search(len(t.Scripts), func(i int) bool { return t.Scripts[i].Tag >= script })
+	if i < len(t.Scripts) && t.Scripts[i].Tag == script {
+		return t.Scripts[i], true
+	}
+	return JstfScript{}, false
+}
+
+// JstfScript stores the justification data of one script.
+type JstfScript struct {
+	// ExtenderGlyphs are the glyphs, such as the Arabic kashida,
+	// which may be inserted to extend a line (sorted).
+	ExtenderGlyphs []GID
+	DefaultLangSys JstfLangSys   // may be empty
+	LangSys        []JstfLangSys // sorted by tag
+	Tag            Tag
+}
+
+// FindLanguage returns the justification data for `language`,
+// falling back to DefaultLangSys.
+func (js JstfScript) FindLanguage(language Tag) JstfLangSys {
+	i := sort.Search(len(js.LangSys), func(i int) bool { return js.LangSys[i].Tag >= language })
+	if i < len(js.LangSys) && js.LangSys[i].Tag == language {
+		return js.LangSys[i]
+	}
+	return js.DefaultLangSys
+}
+
+// JstfLangSys stores the justification suggestions for one language,
+// in order of priority.
+type JstfLangSys struct {
+	Priorities []JstfPriority
+	Tag        Tag
+}
+
+// JstfPriority is one justification suggestion.
+// The lookups are given as indices into the GSUB and GPOS lookup lists
+// (a nil slice means no modification), and are applied
+// in addition to the ones of the previous priorities.
+type JstfPriority struct {
+	ShrinkageEnableGSUB  []uint16
+	ShrinkageDisableGSUB []uint16
+	ShrinkageEnableGPOS  []uint16
+	ShrinkageDisableGPOS []uint16
+	// ShrinkageMax are GPOS lookups defining the maximum shrinkage
+	// allowed for this priority (may be empty)
+	ShrinkageMax []LookupGPOS
+
+	ExtensionEnableGSUB  []uint16
+	ExtensionDisableGSUB []uint16
+	ExtensionEnableGPOS  []uint16
+	ExtensionDisableGPOS []uint16
+	// ExtensionMax are GPOS lookups defining the maximum extension
+	// allowed for this priority (may be empty)
+	ExtensionMax []LookupGPOS
+}
+
+func parseTableJstf(data []byte) (out TableJstf, err error) {
+	if len(data) < 6 {
+		return out, errors.New("invalid 'JSTF' table (EOF)")
+	}
+	if major := binary.BigEndian.Uint16(data); major != 1 {
+		return out, fmt.Errorf("unsupported 'JSTF' table version: %d", major)
+	}
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 6+6*count {
+		return out, errors.New("invalid 'JSTF' table (EOF)")
+	}
+	out.Scripts = make([]JstfScript, count)
+	for i := range out.Scripts {
+		record := data[6+6*i:]
+		out.Scripts[i], err = parseJstfScript(data, binary.BigEndian.Uint16(record[4:]))
+		if err != nil {
+			return out, err
+		}
+		out.Scripts[i].Tag = Tag(binary.BigEndian.Uint32(record))
+	}
+	// the script records should already be sorted
+	sort.SliceStable(out.Scripts, func(i, j int) bool { return out.Scripts[i].Tag < out.Scripts[j].Tag })
+	return out, nil
+}
+
+func parseJstfScript(data []byte, offset uint16) (out JstfScript, err error) {
+	if len(data) < int(offset)+6 {
+		return out, errors.New("invalid 'JSTF' script table (EOF)")
+	}
+	data = data[offset:]
+	extenderOffset := binary.BigEndian.Uint16(data)
+	defaultOffset := binary.BigEndian.Uint16(data[2:])
+	count := int(binary.BigEndian.Uint16(data[4:]))
+
+	if extenderOffset != 0 {
+		if len(data) < int(extenderOffset)+2 {
+			return out, errors.New("invalid 'JSTF' extender glyphs (EOF)")
+		}
+		extenders := data[extenderOffset:]
+		glyphs, err := parseUint16s(extenders[2:], int(binary.BigEndian.Uint16(extenders)))
+		if err != nil {
+			return out, errors.New("invalid 'JSTF' extender glyphs (EOF)")
+		}
+		out.ExtenderGlyphs = make([]GID, len(glyphs))
+		for i, g := range glyphs {
+			out.ExtenderGlyphs[i] = GID(g)
+		}
+	}
+
+	if defaultOffset != 0 {
+		out.DefaultLangSys, err = parseJstfLangSys(data, defaultOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	if len(data) < 6+6*count {
+		return out, errors.New("invalid 'JSTF' script table (EOF)")
+	}
+	out.LangSys = make([]JstfLangSys, count)
+	for i := range out.LangSys {
+		record := data[6+6*i:]
+		out.LangSys[i], err = parseJstfLangSys(data, binary.BigEndian.Uint16(record[4:]))
+		if err != nil {
+			return out, err
+		}
+		out.LangSys[i].Tag = Tag(binary.BigEndian.Uint32(record))
+	}
+	sort.SliceStable(out.LangSys, func(i, j int) bool { return out.LangSys[i].Tag < out.LangSys[j].Tag })
+
+	return out, nil
+}
+
+func parseJstfLangSys(data []byte, offset uint16) (out JstfLangSys, err error) {
+	if len(data) < int(offset)+2 {
+		return out, errors.New("invalid 'JSTF' language table (EOF)")
+	}
+	data = data[offset:]
+	offsets, err := parseUint16s(data[2:], int(binary.BigEndian.Uint16(data)))
+	if err != nil {
+		return out, errors.New("invalid 'JSTF' language table (EOF)")
+	}
+	out.Priorities = make([]JstfPriority, len(offsets))
+	for i, priorityOffset := range offsets {
+		out.Priorities[i], err = parseJstfPriority(data, priorityOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func parseJstfPriority(data []byte, offset uint16) (out JstfPriority, err error) {
+	if len(data) < int(offset)+20 {
+		return out, errors.New("invalid 'JSTF' priority table (EOF)")
+	}
+	data = data[offset:]
+
+	// the offsets are given in this order
+	modLists := [10]*[]uint16{
+		0: &out.ShrinkageEnableGSUB, 1: &out.ShrinkageDisableGSUB,
+		2: &out.ShrinkageEnableGPOS, 3: &out.ShrinkageDisableGPOS,
+		5: &out.ExtensionEnableGSUB, 6: &out.ExtensionDisableGSUB,
+		7: &out.ExtensionEnableGPOS, 8: &out.ExtensionDisableGPOS,
+	}
+	maxLists := [10]*[]LookupGPOS{4: &out.ShrinkageMax, 9: &out.ExtensionMax}
+	for i, list := range modLists {
+		listOffset := binary.BigEndian.Uint16(data[2*i:])
+		if listOffset == 0 {
+			continue
+		}
+		if list == nil {
+			*maxLists[i], err = parseJstfMax(data, listOffset)
+			if err != nil {
+				return out, err
+			}
+			continue
+		}
+		if len(data) < int(listOffset)+2 {
+			return out, errors.New("invalid 'JSTF' modification list (EOF)")
+		}
+		*list, err = parseUint16s(data[listOffset+2:], int(binary.BigEndian.Uint16(data[listOffset:])))
+		if err != nil {
+			return out, errors.New("invalid 'JSTF' modification list (EOF)")
+		}
+	}
+	return out, nil
+}
+
+// JstfMax tables store GPOS lookups, with offsets from the beginning of the JstfMax table
+func parseJstfMax(data []byte, offset uint16) ([]LookupGPOS, error) {
+	if len(data) < int(offset)+2 {
+		return nil, errors.New("invalid 'JSTF' max table (EOF)")
+	}
+	data = data[offset:]
+	offsets, err := parseUint16s(data[2:], int(binary.BigEndian.Uint16(data)))
+	if err != nil {
+		return nil, errors.New("invalid 'JSTF' max table (EOF)")
+	}
+	var tl TableLayout
+	out := make([]LookupGPOS, len(offsets))
+	for i, lookupOffset := range offsets {
+		l, err := tl.parseLookup(data, lookupOffset)
+		if err != nil {
+			return nil, err
+		}
+		out[i], err = l.parseGPOS(uint16(len(offsets)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
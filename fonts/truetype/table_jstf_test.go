@@ -0,0 +1,64 @@
+package truetype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJstf(t *testing.T) {
+	// JstfTest.ttf is Estedad-VF.ttf with a synthetic 'JSTF' table
+	font := loadFont(t, "testdata/JstfTest.ttf")
+	jstf := font.LayoutTables().JSTF
+
+	if len(jstf.Scripts) != 1 {
+		t.Fatalf("unexpected scripts %v", jstf.Scripts)
+	}
+	if _, ok := jstf.FindScript(MustNewTag("latn")); ok {
+		t.Fatal("unexpected script")
+	}
+	script, ok := jstf.FindScript(MustNewTag("arab"))
+	if !ok {
+		t.Fatal("missing script")
+	}
+	if exp := []GID{310, 311}; !reflect.DeepEqual(script.ExtenderGlyphs, exp) {
+		t.Fatalf("unexpected extender glyphs %v", script.ExtenderGlyphs)
+	}
+
+	def := script.FindLanguage(MustNewTag("URD "))
+	if len(def.Priorities) != 2 {
+		t.Fatalf("unexpected priorities %v", def.Priorities)
+	}
+	if p := def.Priorities[0]; !reflect.DeepEqual(p.ExtensionEnableGSUB, []uint16{6}) ||
+		p.ExtensionDisableGSUB != nil || p.ShrinkageEnableGPOS != nil || p.ExtensionMax != nil {
+		t.Fatalf("unexpected priority %v", p)
+	}
+	p := def.Priorities[1]
+	if !reflect.DeepEqual(p.ExtensionEnableGSUB, []uint16{5}) || !reflect.DeepEqual(p.ExtensionDisableGSUB, []uint16{8, 9, 10}) {
+		t.Fatalf("unexpected priority %v", p)
+	}
+	if len(p.ExtensionMax) != 1 || p.ShrinkageMax != nil {
+		t.Fatalf("unexpected max lookups %v", p.ExtensionMax)
+	}
+	lookup := p.ExtensionMax[0]
+	if lookup.Type != GPOSSingle || len(lookup.Subtables) != 1 {
+		t.Fatalf("unexpected max lookup %v", lookup)
+	}
+	if single, ok := lookup.Subtables[0].Data.(GPOSSingle1); !ok || single.Value.XAdvance != 500 {
+		t.Fatalf("unexpected max lookup subtable %v", lookup.Subtables[0].Data)
+	}
+
+	far := script.FindLanguage(MustNewTag("FAR "))
+	if len(far.Priorities) != 1 || !reflect.DeepEqual(far.Priorities[0].ShrinkageEnableGPOS, []uint16{0}) {
+		t.Fatalf("unexpected language %v", far)
+	}
+
+	raw, err := loadParser(t, "testdata/JstfTest.ttf").GetRawTable(tagJstf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, end := range []int{4, 20, 60, len(raw) - 2} {
+		if _, err := parseTableJstf(raw[:end]); err == nil {
+			t.Fatalf("expected error for table truncated at %d", end)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var tagMath = MustNewTag("MATH")
+
+// TableMath is the Mathematical Typesetting table, which
+// provides the font specific information needed to layout math formulas.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/math
+type TableMath struct {
+	// Constants is indexed by MathConstant.
+	// The percent values (see MathConstant.IsPercent) and the
+	// minimum heights have no device table.
+	Constants [mathConstantsCount]MathValue
+	GlyphInfo MathGlyphInfo
+	Variants  MathVariants
+}
+
+// MathValue is a value in font units, with an optional
+// device table (whose variation indices refer to the 'GDEF' variation store).
+type MathValue struct {
+	Device DeviceTable // may be nil
+	Value  int16
+}
+
+// MathConstant identifies one of the constants defined in the 'MATH' table.
+type MathConstant uint8
+
+const (
+	MathScriptPercentScaleDown MathConstant = iota
+	MathScriptScriptPercentScaleDown
+	MathDelimitedSubFormulaMinHeight
+	MathDisplayOperatorMinHeight
+	MathMathLeading
+	MathAxisHeight
+	MathAccentBaseHeight
+	MathFlattenedAccentBaseHeight
+	MathSubscriptShiftDown
+	MathSubscriptTopMax
+	MathSubscriptBaselineDropMin
+	MathSuperscriptShiftUp
+	MathSuperscriptShiftUpCramped
+	MathSuperscriptBottomMin
+	MathSuperscriptBaselineDropMax
+	MathSubSuperscriptGapMin
+	MathSuperscriptBottomMaxWithSubscript
+	MathSpaceAfterScript
+	MathUpperLimitGapMin
+	MathUpperLimitBaselineRiseMin
+	MathLowerLimitGapMin
+	MathLowerLimitBaselineDropMin
+	MathStackTopShiftUp
+	MathStackTopDisplayStyleShiftUp
+	MathStackBottomShiftDown
+	MathStackBottomDisplayStyleShiftDown
+	MathStackGapMin
+	MathStackDisplayStyleGapMin
+	MathStretchStackTopShiftUp
+	MathStretchStackBottomShiftDown
+	MathStretchStackGapAboveMin
+	MathStretchStackGapBelowMin
+	MathFractionNumeratorShiftUp
+	MathFractionNumeratorDisplayStyleShiftUp
+	MathFractionDenominatorShiftDown
+	MathFractionDenominatorDisplayStyleShiftDown
+	MathFractionNumeratorGapMin
+	MathFractionNumDisplayStyleGapMin
+	MathFractionRuleThickness
+	MathFractionDenominatorGapMin
+	MathFractionDenomDisplayStyleGapMin
+	MathSkewedFractionHorizontalGap
+	MathSkewedFractionVerticalGap
+	MathOverbarVerticalGap
+	MathOverbarRuleThickness
+	MathOverbarExtraAscender
+	MathUnderbarVerticalGap
+	MathUnderbarRuleThickness
+	MathUnderbarExtraDescender
+	MathRadicalVerticalGap
+	MathRadicalDisplayStyleVerticalGap
+	MathRadicalRuleThickness
+	MathRadicalExtraAscender
+	MathRadicalKernBeforeDegree
+	MathRadicalKernAfterDegree
+	MathRadicalDegreeBottomRaisePercent
+
+	mathConstantsCount
+)
+
+// IsPercent returns true for the constants expressed as
+// a percentage rather than in font units.
+func (c MathConstant) IsPercent() bool {
+	return c == MathScriptPercentScaleDown || c == MathScriptScriptPercentScaleDown ||
+		c == MathRadicalDegreeBottomRaisePercent
+}
+
+// IsHorizontal returns true for the constants expressing a
+// horizontal distance.
+func (c MathConstant) IsHorizontal() bool {
+	switch c {
+	case MathSpaceAfterScript, MathSkewedFractionHorizontalGap,
+		MathRadicalKernBeforeDegree, MathRadicalKernAfterDegree:
+		return true
+	default:
+		return false
+	}
+}
+
+// MathValueTable maps glyphs to values.
+type MathValueTable struct {
+	Coverage Coverage    // may be nil
+	Values   []MathValue // with same length as Coverage.Size()
+}
+
+// Get returns the value for `glyph`, or false if it is not covered.
+func (mt MathValueTable) Get(glyph GID) (MathValue, bool) {
+	if mt.Coverage == nil {
+		return MathValue{}, false
+	}
+	index, ok := mt.Coverage.Index(glyph)
+	if !ok {
+		return MathValue{}, false
+	}
+	return mt.Values[index], true
+}
+
+// MathGlyphInfo stores per-glyph information.
+type MathGlyphInfo struct {
+	ItalicsCorrection   MathValueTable
+	TopAccentAttachment MathValueTable
+	// ExtendedShapes marks the glyphs which should be
+	// considered extended shapes (may be nil)
+	ExtendedShapes Coverage
+	Kerns          MathKernInfo
+}
+
+// IsExtendedShape returns true if `glyph` is an extended shape.
+func (mg MathGlyphInfo) IsExtendedShape(glyph GID) bool {
+	if mg.ExtendedShapes == nil {
+		return false
+	}
+	_, ok := mg.ExtendedShapes.Index(glyph)
+	return ok
+}
+
+// MathKernCorner is one of the four corners of a glyph
+// on which a math kerning may be defined.
+type MathKernCorner uint8
+
+const (
+	MathKernTopRight MathKernCorner = iota
+	MathKernTopLeft
+	MathKernBottomRight
+	MathKernBottomLeft
+)
+
+// MathKern provides kerning amounts for different heights
+// of a glyph corner : KernValues[i] applies to the heights between
+// CorrectionHeights[i-1] and CorrectionHeights[i].
+// For valid tables, len(KernValues) = len(CorrectionHeights) + 1,
+// unless the kern is empty.
+type MathKern struct {
+	CorrectionHeights []MathValue
+	KernValues        []MathValue
+}
+
+// MathKernInfo provides the kerning of the four corners of glyphs,
+// indexed by MathKernCorner.
+type MathKernInfo struct {
+	Coverage Coverage // may be nil
+	Kerns    [][4]MathKern
+}
+
+// Get returns the kern defined for the `corner` of `glyph`,
+// or false if not found.
+func (mk MathKernInfo) Get(glyph GID, corner MathKernCorner) (MathKern, bool) {
+	if mk.Coverage == nil {
+		return MathKern{}, false
+	}
+	index, ok := mk.Coverage.Index(glyph)
+	if !ok {
+		return MathKern{}, false
+	}
+	kern := mk.Kerns[index][corner]
+	return kern, len(kern.KernValues) != 0
+}
+
+// MathVariants stores the size variants and the
+// assemblies used to build stretchy glyphs.
+type MathVariants struct {
+	// Vertical and Horizontal may have a nil Coverage
+	Vertical, Horizontal MathGlyphConstructions
+	// Minimum overlap of connecting glyphs during glyph construction,
+	// in font units.
+	MinConnectorOverlap uint16
+}
+
+// MathGlyphConstructions maps glyphs to their constructions.
+type MathGlyphConstructions struct {
+	Coverage      Coverage
+	Constructions []MathGlyphConstruction
+}
+
+// Get returns the construction for `glyph`, or false if not found.
+func (mc MathGlyphConstructions) Get(glyph GID) (MathGlyphConstruction, bool) {
+	if mc.Coverage == nil {
+		return MathGlyphConstruction{}, false
+	}
+	index, ok := mc.Coverage.Index(glyph)
+	if !ok {
+		return MathGlyphConstruction{}, false
+	}
+	return mc.Constructions[index], true
+}
+
+// MathGlyphConstruction provides the variants of a glyph,
+// of increasing size, and an optional assembly, used when
+// no variant is big enough.
+type MathGlyphConstruction struct {
+	Assembly MathGlyphAssembly // empty Parts if absent
+	Variants []MathGlyphVariant
+}
+
+// MathGlyphVariant is a glyph alternative, whose size is given in
+// font units, in the direction of the stretching.
+type MathGlyphVariant struct {
+	Glyph              GID
+	AdvanceMeasurement uint16
+}
+
+// MathGlyphAssembly describes how to build a glyph
+// from several parts.
+type MathGlyphAssembly struct {
+	ItalicsCorrection MathValue
+	// Parts are listed from left to right (horizontal direction)
+	// or bottom to top (vertical direction).
+	Parts []MathGlyphPart
+}
+
+// MathGlyphPart is a part of a glyph assembly,
+// with lengths expressed in font units.
+type MathGlyphPart struct {
+	Glyph                GID
+	StartConnectorLength uint16
+	EndConnectorLength   uint16
+	FullAdvance          uint16
+	Flags                uint16
+}
+
+// IsExtender returns true if the part may be repeated.
+func (mp MathGlyphPart) IsExtender() bool { return mp.Flags&0x0001 != 0 }
+
+func parseTableMath(data []byte) (out TableMath, err error) {
+	if len(data) < 10 {
+		return out, errors.New("invalid 'MATH' table (EOF)")
+	}
+	if major := binary.BigEndian.Uint16(data); major != 1 {
+		return out, fmt.Errorf("unsupported 'MATH' table version: %d", major)
+	}
+	constantsOffset := binary.BigEndian.Uint16(data[4:])
+	glyphInfoOffset := binary.BigEndian.Uint16(data[6:])
+	variantsOffset := binary.BigEndian.Uint16(data[8:])
+
+	if constantsOffset != 0 {
+		out.Constants, err = parseMathConstants(data, constantsOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if glyphInfoOffset != 0 {
+		out.GlyphInfo, err = parseMathGlyphInfo(data, glyphInfoOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if variantsOffset != 0 {
+		out.Variants, err = parseMathVariants(data, variantsOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// parseMathValue parses a MathValueRecord, whose device
+// offset is relative to `parent`
+func parseMathValue(parent []byte, offset int) (out MathValue, err error) {
+	if len(parent) < offset+4 {
+		return out, errors.New("invalid math value record (EOF)")
+	}
+	out.Value = int16(binary.BigEndian.Uint16(parent[offset:]))
+	if deviceOffset := binary.BigEndian.Uint16(parent[offset+2:]); deviceOffset != 0 {
+		out.Device, err = parseDeviceTable(parent, deviceOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func parseMathValues(parent []byte, offset, count int) ([]MathValue, error) {
+	out := make([]MathValue, count)
+	var err error
+	for i := range out {
+		out[i], err = parseMathValue(parent, offset+4*i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func parseMathConstants(data []byte, offset uint16) (out [mathConstantsCount]MathValue, err error) {
+	const size = 4*2 + 51*4 + 2 // 4 int16, 51 MathValueRecord, 1 int16
+	if len(data) < int(offset)+size {
+		return out, errors.New("invalid 'MATH' constants (EOF)")
+	}
+	data = data[offset:]
+	for c := MathScriptPercentScaleDown; c <= MathDisplayOperatorMinHeight; c++ {
+		out[c].Value = int16(binary.BigEndian.Uint16(data[2*c:]))
+	}
+	for c := MathMathLeading; c <= MathRadicalKernAfterDegree; c++ {
+		out[c], err = parseMathValue(data, 8+4*int(c-MathMathLeading))
+		if err != nil {
+			return out, err
+		}
+	}
+	out[MathRadicalDegreeBottomRaisePercent].Value = int16(binary.BigEndian.Uint16(data[size-2:]))
+	return out, nil
+}
+
+func parseMathValueTable(data []byte, offset uint16) (out MathValueTable, err error) {
+	if len(data) < int(offset)+4 {
+		return out, errors.New("invalid 'MATH' value table (EOF)")
+	}
+	data = data[offset:]
+	out.Coverage, err = parseCoverage(data, uint32(binary.BigEndian.Uint16(data)))
+	if err != nil {
+		return out, err
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	out.Values, err = parseMathValues(data, 4, count)
+	if err != nil {
+		return out, err
+	}
+	if L1, L2 := len(out.Values), out.Coverage.Size(); L1 != L2 {
+		return out, fmt.Errorf("invalid 'MATH' value table length: %d for %d", L1, L2)
+	}
+	return out, nil
+}
+
+func parseMathGlyphInfo(data []byte, offset uint16) (out MathGlyphInfo, err error) {
+	if len(data) < int(offset)+8 {
+		return out, errors.New("invalid 'MATH' glyph info (EOF)")
+	}
+	data = data[offset:]
+	italicsOffset := binary.BigEndian.Uint16(data)
+	accentOffset := binary.BigEndian.Uint16(data[2:])
+	extendedOffset := binary.BigEndian.Uint16(data[4:])
+	kernOffset := binary.BigEndian.Uint16(data[6:])
+
+	if italicsOffset != 0 {
+		out.ItalicsCorrection, err = parseMathValueTable(data, italicsOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if accentOffset != 0 {
+		out.TopAccentAttachment, err = parseMathValueTable(data, accentOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if extendedOffset != 0 {
+		out.ExtendedShapes, err = parseCoverage(data, uint32(extendedOffset))
+		if err != nil {
+			return out, err
+		}
+	}
+	if kernOffset != 0 {
+		out.Kerns, err = parseMathKernInfo(data, kernOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func parseMathKernInfo(data []byte, offset uint16) (out MathKernInfo, err error) {
+	if len(data) < int(offset)+4 {
+		return out, errors.New("invalid 'MATH' kern info (EOF)")
+	}
+	data = data[offset:]
+	out.Coverage, err = parseCoverage(data, uint32(binary.BigEndian.Uint16(data)))
+	if err != nil {
+		return out, err
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	offsets, err := parseUint16s(data[4:], 4*count)
+	if err != nil {
+		return out, errors.New("invalid 'MATH' kern info (EOF)")
+	}
+	out.Kerns = make([][4]MathKern, count)
+	for i := range out.Kerns {
+		for corner, kernOffset := range offsets[4*i : 4*i+4] {
+			if kernOffset == 0 {
+				continue
+			}
+			out.Kerns[i][corner], err = parseMathKern(data, kernOffset)
+			if err != nil {
+				return out, err
+			}
+		}
+	}
+	if L1, L2 := len(out.Kerns), out.Coverage.Size(); L1 != L2 {
+		return out, fmt.Errorf("invalid 'MATH' kern info length: %d for %d", L1, L2)
+	}
+	return out, nil
+}
+
+func parseMathKern(data []byte, offset uint16) (out MathKern, err error) {
+	if len(data) < int(offset)+2 {
+		return out, errors.New("invalid 'MATH' kern (EOF)")
+	}
+	data = data[offset:]
+	count := int(binary.BigEndian.Uint16(data))
+	out.CorrectionHeights, err = parseMathValues(data, 2, count)
+	if err != nil {
+		return out, err
+	}
+	out.KernValues, err = parseMathValues(data, 2+4*count, count+1)
+	return out, err
+}
+
+func parseMathVariants(data []byte, offset uint16) (out MathVariants, err error) {
+	if len(data) < int(offset)+10 {
+		return out, errors.New("invalid 'MATH' variants (EOF)")
+	}
+	data = data[offset:]
+	out.MinConnectorOverlap = binary.BigEndian.Uint16(data)
+	vertCoverageOffset := binary.BigEndian.Uint16(data[2:])
+	horizCoverageOffset := binary.BigEndian.Uint16(data[4:])
+	vertCount := int(binary.BigEndian.Uint16(data[6:]))
+	horizCount := int(binary.BigEndian.Uint16(data[8:]))
+	offsets, err := parseUint16s(data[10:], vertCount+horizCount)
+	if err != nil {
+		return out, errors.New("invalid 'MATH' variants (EOF)")
+	}
+
+	out.Vertical, err = parseMathGlyphConstructions(data, vertCoverageOffset, offsets[:vertCount])
+	if err != nil {
+		return out, err
+	}
+	out.Horizontal, err = parseMathGlyphConstructions(data, horizCoverageOffset, offsets[vertCount:])
+	return out, err
+}
+
+func parseMathGlyphConstructions(data []byte, coverageOffset uint16, offsets []uint16) (out MathGlyphConstructions, err error) {
+	if coverageOffset == 0 {
+		return out, nil
+	}
+	out.Coverage, err = parseCoverage(data, uint32(coverageOffset))
+	if err != nil {
+		return out, err
+	}
+	if L1, L2 := len(offsets), out.Coverage.Size(); L1 != L2 {
+		return out, fmt.Errorf("invalid 'MATH' variants length: %d for %d", L1, L2)
+	}
+	out.Constructions = make([]MathGlyphConstruction, len(offsets))
+	for i, offset := range offsets {
+		out.Constructions[i], err = parseMathGlyphConstruction(data, offset)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func parseMathGlyphConstruction(data []byte, offset uint16) (out MathGlyphConstruction, err error) {
+	if len(data) < int(offset)+4 {
+		return out, errors.New("invalid 'MATH' glyph construction (EOF)")
+	}
+	data = data[offset:]
+	assemblyOffset := binary.BigEndian.Uint16(data)
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	if len(data) < 4+4*count {
+		return out, errors.New("invalid 'MATH' glyph construction (EOF)")
+	}
+	out.Variants = make([]MathGlyphVariant, count)
+	for i := range out.Variants {
+		out.Variants[i].Glyph = GID(binary.BigEndian.Uint16(data[4+4*i:]))
+		out.Variants[i].AdvanceMeasurement = binary.BigEndian.Uint16(data[4+4*i+2:])
+	}
+	if assemblyOffset != 0 {
+		out.Assembly, err = parseMathGlyphAssembly(data, assemblyOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func parseMathGlyphAssembly(data []byte, offset uint16) (out MathGlyphAssembly, err error) {
+	if len(data) < int(offset)+6 {
+		return out, errors.New("invalid 'MATH' glyph assembly (EOF)")
+	}
+	data = data[offset:]
+	out.ItalicsCorrection, err = parseMathValue(data, 0)
+	if err != nil {
+		return out, err
+	}
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 6+10*count {
+		return out, errors.New("invalid 'MATH' glyph assembly (EOF)")
+	}
+	out.Parts = make([]MathGlyphPart, count)
+	for i := range out.Parts {
+		part := data[6+10*i:]
+		out.Parts[i] = MathGlyphPart{
+			Glyph:                GID(binary.BigEndian.Uint16(part)),
+			StartConnectorLength: binary.BigEndian.Uint16(part[2:]),
+			EndConnectorLength:   binary.BigEndian.Uint16(part[4:]),
+			FullAdvance:          binary.BigEndian.Uint16(part[6:]),
+			Flags:                binary.BigEndian.Uint16(part[8:]),
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,85 @@
+package truetype
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMath(t *testing.T) {
+	// MathTest.ttf has a synthetic 'MATH' table, where
+	// the constants from MathMathLeading to MathRadicalKernAfterDegree are 100 + index
+	font := loadFont(t, "testdata/MathTest.ttf")
+	math := font.LayoutTables().MATH
+
+	for c, expected := range map[MathConstant]int16{
+		MathScriptPercentScaleDown:          80,
+		MathScriptScriptPercentScaleDown:    60,
+		MathDelimitedSubFormulaMinHeight:    1300,
+		MathDisplayOperatorMinHeight:        2000,
+		MathMathLeading:                     104,
+		MathAxisHeight:                      105,
+		MathRadicalKernAfterDegree:          154,
+		MathRadicalDegreeBottomRaisePercent: 65,
+	} {
+		if got := math.Constants[c].Value; got != expected {
+			t.Fatalf("constant %d: expected %d, got %d", c, expected, got)
+		}
+	}
+	expectedDevice := DeviceHinting{StartSize: 10, EndSize: 12, Values: []int8{1, 2, 3}}
+	if dev := math.Constants[MathMathLeading].Device; !reflect.DeepEqual(dev, expectedDevice) {
+		t.Fatalf("unexpected device %v", dev)
+	}
+
+	info := math.GlyphInfo
+	if v, ok := info.ItalicsCorrection.Get(5); !ok || v.Value != -20 {
+		t.Fatalf("unexpected italics correction %v", v)
+	}
+	if _, ok := info.ItalicsCorrection.Get(3); ok {
+		t.Fatal("unexpected italics correction")
+	}
+	if v, ok := info.TopAccentAttachment.Get(3); !ok || v.Value != 250 {
+		t.Fatalf("unexpected top accent attachment %v", v)
+	}
+	if !info.IsExtendedShape(11) || info.IsExtendedShape(12) {
+		t.Fatal("invalid extended shapes")
+	}
+	kern, ok := info.Kerns.Get(4, MathKernTopRight)
+	if !ok || len(kern.CorrectionHeights) != 2 || kern.CorrectionHeights[1].Value != 300 || kern.KernValues[2].Value != 30 {
+		t.Fatalf("unexpected kern %v", kern)
+	}
+	if _, ok := info.Kerns.Get(4, MathKernTopLeft); ok {
+		t.Fatal("unexpected top left kern")
+	}
+	if kern, ok := info.Kerns.Get(4, MathKernBottomLeft); !ok || kern.KernValues[0].Value != -5 {
+		t.Fatalf("unexpected kern %v", kern)
+	}
+
+	variants := math.Variants
+	if variants.MinConnectorOverlap != 50 {
+		t.Fatalf("unexpected min connector overlap %d", variants.MinConnectorOverlap)
+	}
+	construction, ok := variants.Vertical.Get(10)
+	if !ok {
+		t.Fatal("missing vertical construction")
+	}
+	if exp := []MathGlyphVariant{{10, 500}, {11, 800}}; !reflect.DeepEqual(construction.Variants, exp) {
+		t.Fatalf("unexpected variants %v", construction.Variants)
+	}
+	assembly := construction.Assembly
+	if exp := []MathGlyphPart{
+		{Glyph: 12, EndConnectorLength: 100, FullAdvance: 400},
+		{Glyph: 13, StartConnectorLength: 100, EndConnectorLength: 100, FullAdvance: 300, Flags: 1},
+		{Glyph: 14, StartConnectorLength: 100, FullAdvance: 400},
+	}; assembly.ItalicsCorrection.Value != 7 || !reflect.DeepEqual(assembly.Parts, exp) {
+		t.Fatalf("unexpected assembly %v", assembly)
+	}
+	if !assembly.Parts[1].IsExtender() || assembly.Parts[0].IsExtender() {
+		t.Fatal("invalid extender flag")
+	}
+	if construction, ok := variants.Horizontal.Get(20); !ok || len(construction.Assembly.Parts) != 0 || len(construction.Variants) != 1 {
+		t.Fatalf("unexpected horizontal construction %v", construction)
+	}
+	if _, ok := variants.Horizontal.Get(10); ok {
+		t.Fatal("unexpected horizontal construction")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"io"
+	"sort"
 	"strconv"
 
+	"github.com/benoitkugler/textlayout/language"
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
@@ -41,6 +43,83 @@ func (names TableName) getName(name NameID) string {
 	return ""
 }
 
+// SelectEntryLanguage returns the entry for `name` best matching `lang`,
+// or nil if not found.
+// An exact match is preferred, then an entry with a parent
+// language (such as "fr" for "fr-ca"), then an entry with the same primary language.
+// If no entry matches, the default entry returned by SelectEntry is used.
+func (names TableName) SelectEntryLanguage(name NameID, lang language.Language) *NameEntry {
+	var (
+		best      = -1
+		bestScore = 0
+	)
+	inheritance := lang.SimpleInheritance()
+	for n, rec := range names {
+		if rec.NameID != name || len(rec.Value) == 0 || !rec.isDecodable() {
+			continue
+		}
+		recLang := rec.Language()
+		if recLang == "" {
+			continue
+		}
+		// score by language, then by platform
+		score := 0
+		if recLang == lang {
+			score = 3
+		} else if recLang.Compare(lang) == language.LanguagePrimaryMatch {
+			score = 1
+			for _, parent := range inheritance {
+				if parent == recLang {
+					score = 2
+					break
+				}
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		score *= 2
+		if rec.PlatformID == PlatformMicrosoft {
+			// names encoded in Windows formats are more reliable
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == -1 {
+		return names.SelectEntry(name)
+	}
+	return &names[best]
+}
+
+// Localizations returns one entry for each language available for `name`,
+// sorted by language. Entries on the Windows platform are preferred, and
+// entries whose language is unknown are ignored.
+func (names TableName) Localizations(name NameID) []NameEntry {
+	byLanguage := make(map[language.Language]int)
+	var out []NameEntry
+	for _, rec := range names {
+		if rec.NameID != name || len(rec.Value) == 0 || !rec.isDecodable() {
+			continue
+		}
+		lang := rec.Language()
+		if lang == "" {
+			continue
+		}
+		if index, has := byLanguage[lang]; has {
+			if out[index].PlatformID != PlatformMicrosoft && rec.PlatformID == PlatformMicrosoft {
+				out[index] = rec
+			}
+			continue
+		}
+		byLanguage[lang] = len(out)
+		out = append(out, rec)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Language() < out[j].Language() })
+	return out
+}
+
 // SelectEntry return the entry for `name` or nil if not found.
 func (names TableName) SelectEntry(name NameID) *NameEntry {
 	var (
@@ -114,6 +193,10 @@ type NameEntry struct {
 	EncodingID PlatformEncodingID
 	LanguageID PlatformLanguageID
 	NameID     NameID
+
+	// LanguageTag is the BCP 47 tag referenced by language IDs
+	// starting at 0x8000, in version 1 tables. It is empty otherwise.
+	LanguageTag string
 }
 
 func (n NameEntry) isWindows() bool {
@@ -124,6 +207,12 @@ func (n NameEntry) isMac() bool {
 	return n.PlatformID == PlatformMac && n.EncodingID == PEMacRoman
 }
 
+// returns true if String correctly decodes the entry
+func (n NameEntry) isDecodable() bool {
+	return n.PlatformID == PlatformUnicode || n.isMac() ||
+		(n.PlatformID == PlatformMicrosoft && n.EncodingID == PEMicrosoftUnicodeCs)
+}
+
 // String is a best-effort attempt to get an UTF-8 encoded version of
 // Value. Only MicrosoftUnicode (3,1 ,X), MacRomain (1,0,X) and Unicode platform
 // strings are supported.
@@ -349,5 +438,125 @@ func parseTableName(buf []byte) (TableName, error) {
 		})
 	}
 
+	if header.Format == 1 {
+		var storage []byte
+		if int(header.StringOffset) <= len(buf) {
+			storage = buf[header.StringOffset:]
+		}
+		tags, err := parseNameLanguageTags(r, storage)
+		if err != nil {
+			return nil, err
+		}
+		for i, entry := range table {
+			if index := int(entry.LanguageID) - 0x8000; index >= 0 && index < len(tags) {
+				table[i].LanguageTag = tags[index]
+			}
+		}
+	}
+
 	return table, nil
 }
+
+// parseNameLanguageTags reads the language-tag records
+// of version 1 tables, with the strings stored in `storage`
+func parseNameLanguageTags(r io.Reader, storage []byte) ([]string, error) {
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	records := make([]struct{ Length, Offset uint16 }, count)
+	if err := binary.Read(r, binary.BigEndian, records); err != nil {
+		return nil, err
+	}
+	tags := make([]string, count)
+	decoder := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+	for i, record := range records {
+		end := int(record.Offset) + int(record.Length)
+		if end > len(storage) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		tag, _, err := transform.String(decoder, string(storage[record.Offset:end]))
+		if err != nil {
+			return nil, err
+		}
+		tags[i] = tag
+	}
+	return tags, nil
+}
+
+// Serialize encodes the table, using format 0, or format 1
+// if some entries have a language tag. The records are sorted
+// as required by the specification, and identical strings are shared.
+func (names TableName) Serialize() []byte {
+	records := append(TableName(nil), names...)
+
+	// language tags are referenced by language IDs starting at 0x8000
+	var tags []string
+	tagIDs := make(map[string]PlatformLanguageID)
+	for i, record := range records {
+		if record.LanguageTag == "" {
+			continue
+		}
+		id, ok := tagIDs[record.LanguageTag]
+		if !ok {
+			id = PlatformLanguageID(0x8000 + len(tags))
+			tagIDs[record.LanguageTag] = id
+			tags = append(tags, record.LanguageTag)
+		}
+		records[i].LanguageID = id
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		ri, rj := records[i], records[j]
+		if ri.PlatformID != rj.PlatformID {
+			return ri.PlatformID < rj.PlatformID
+		}
+		if ri.EncodingID != rj.EncodingID {
+			return ri.EncodingID < rj.EncodingID
+		}
+		if ri.LanguageID != rj.LanguageID {
+			return ri.LanguageID < rj.LanguageID
+		}
+		return ri.NameID < rj.NameID
+	})
+
+	headerSize := 6 + 12*len(records)
+	if tags != nil {
+		headerSize += 2 + 4*len(tags)
+	}
+	out := make([]byte, headerSize)
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)))
+	binary.BigEndian.PutUint16(out[4:], uint16(headerSize))
+	offsets := make(map[string]int)
+	addString := func(value []byte) int {
+		offset, ok := offsets[string(value)]
+		if !ok {
+			offset = len(out) - headerSize
+			offsets[string(value)] = offset
+			out = append(out, value...)
+		}
+		return offset
+	}
+	for i, record := range records {
+		offset := addString(record.Value)
+		binary.BigEndian.PutUint16(out[6+12*i:], uint16(record.PlatformID))
+		binary.BigEndian.PutUint16(out[6+12*i+2:], uint16(record.EncodingID))
+		binary.BigEndian.PutUint16(out[6+12*i+4:], uint16(record.LanguageID))
+		binary.BigEndian.PutUint16(out[6+12*i+6:], uint16(record.NameID))
+		binary.BigEndian.PutUint16(out[6+12*i+8:], uint16(len(record.Value)))
+		binary.BigEndian.PutUint16(out[6+12*i+10:], uint16(offset))
+	}
+
+	if tags != nil {
+		binary.BigEndian.PutUint16(out, 1) // version
+		binary.BigEndian.PutUint16(out[6+12*len(records):], uint16(len(tags)))
+		encoder := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+		for i, tag := range tags {
+			value, _, _ := transform.Bytes(encoder, []byte(tag))
+			offset := addString(value)
+			binary.BigEndian.PutUint16(out[6+12*len(records)+2+4*i:], uint16(len(value)))
+			binary.BigEndian.PutUint16(out[6+12*len(records)+2+4*i+2:], uint16(offset))
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package truetype
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/language"
+)
+
+func TestNameLanguage(t *testing.T) {
+	for _, test := range []struct {
+		entry    NameEntry
+		expected language.Language
+	}{
+		{NameEntry{PlatformID: PlatformMicrosoft, LanguageID: 0x0409}, "en"},
+		{NameEntry{PlatformID: PlatformMicrosoft, LanguageID: 0x0C0C}, "fr-ca"},
+		{NameEntry{PlatformID: PlatformMicrosoft, LanguageID: 0x7C0C}, "fr"}, // unknown sub-language
+		{NameEntry{PlatformID: PlatformMicrosoft, LanguageID: 0x03FF}, ""},
+		{NameEntry{PlatformID: PlatformMac, LanguageID: 19}, "zh-hant"},
+		{NameEntry{PlatformID: PlatformMac, LanguageID: 200}, ""},
+		{NameEntry{PlatformID: PlatformUnicode}, ""},
+		{NameEntry{PlatformID: PlatformMicrosoft, LanguageID: 0x8000, LanguageTag: "sr-Latn-RS"}, "sr-latn-rs"},
+	} {
+		if got := test.entry.Language(); got != test.expected {
+			t.Fatalf("unexpected language for %v: %s", test.entry, got)
+		}
+	}
+}
+
+func TestSelectEntryLanguage(t *testing.T) {
+	font := loadFont(t, "testdata/FreeSerif.ttf")
+
+	for _, test := range []struct {
+		lang     language.Language
+		expected string
+	}{
+		{"de", "Mittel"},
+		{"de-ch", "Mittel"},
+		{"ru-ru", "Обычный"},
+		{"el", "µεσαία"},
+		{"pt-br", "Normal"},
+		{"en", "Medium"},
+		{"ja", "Medium"}, // default entry
+	} {
+		entry := font.Names.SelectEntryLanguage(NameFontSubfamily, test.lang)
+		if entry == nil || entry.String() != test.expected {
+			t.Fatalf("unexpected name for %s: %v", test.lang, entry)
+		}
+	}
+	if entry := font.Names.SelectEntryLanguage(NameFontFamily, "fr"); entry == nil || entry.String() != "FreeSerif" {
+		t.Fatalf("unexpected family %v", entry)
+	}
+	if entry := font.Names.SelectEntryLanguage(NameSampleText, "fr"); entry != nil {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+
+	locs := font.Names.Localizations(NameFontSubfamily)
+	if len(locs) != 28 {
+		t.Fatalf("unexpected number of localizations %d", len(locs))
+	}
+	for i, loc := range locs {
+		if i > 0 && locs[i-1].Language() >= loc.Language() {
+			t.Fatalf("unsorted localizations %v", locs)
+		}
+	}
+	// the Windows entry is preferred
+	if en := locs[5]; en.Language() != "en" || en.PlatformID != PlatformMicrosoft {
+		t.Fatalf("unexpected entry %v", en)
+	}
+}
+
+func TestNameLanguageTags(t *testing.T) {
+	utf16 := func(s string) []byte {
+		var out []byte
+		for _, r := range s {
+			out = append(out, byte(r>>8), byte(r))
+		}
+		return out
+	}
+	names := TableName{
+		{Value: utf16("Regular"), PlatformID: PlatformMicrosoft, EncodingID: PEMicrosoftUnicodeCs, LanguageID: PLMicrosoftEnglish, NameID: NameFontSubfamily},
+		{Value: utf16("Обычный"), PlatformID: PlatformMicrosoft, EncodingID: PEMicrosoftUnicodeCs, LanguageID: 0x8001, NameID: NameFontSubfamily, LanguageTag: "ru-RU"},
+		{Value: utf16("Normal"), PlatformID: PlatformMicrosoft, EncodingID: PEMicrosoftUnicodeCs, LanguageID: 0x8000, NameID: NameFontSubfamily, LanguageTag: "fr-CA"},
+	}
+	data := names.Serialize()
+	if format := data[1]; format != 1 {
+		t.Fatalf("unexpected format %d", format)
+	}
+	got, err := parseTableName(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// language IDs are re-assigned
+	names[1].LanguageID, names[2].LanguageID = 0x8000, 0x8001
+	if !reflect.DeepEqual(sortedNames(got), sortedNames(names)) {
+		t.Fatalf("unexpected names %v", got)
+	}
+
+	if entry := got.SelectEntryLanguage(NameFontSubfamily, "fr"); entry == nil || entry.String() != "Normal" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if locs := got.Localizations(NameFontSubfamily); len(locs) != 3 || locs[1].Language() != "fr-ca" {
+		t.Fatalf("unexpected localizations %v", locs)
+	}
+
+	if _, err := parseTableName(data[:6+12*3+2+4*2]); err == nil {
+		t.Fatal("expected error on truncated table")
+	}
+}
@@ -75,9 +75,9 @@ func parseTableOS2(buf []byte) (*TableOS2, error) {
 	switch version {
 	case 0:
 		dst = &out.TableOS2Version0
-	case 1, 2, 3:
+	case 1:
 		dst = &out.TableOS2Version1
-	case 4:
+	case 2, 3, 4:
 		dst = &out.TableOS2Version4
 	case 5:
 		dst = &out
@@ -92,6 +92,24 @@ func parseTableOS2(buf []byte) (*TableOS2, error) {
 	return &out, nil
 }
 
+// Serialize encodes the table, using the fields defined by its `Version`.
+func (t *TableOS2) Serialize() []byte {
+	var src interface{}
+	switch t.Version {
+	case 0:
+		src = t.TableOS2Version0
+	case 1:
+		src = t.TableOS2Version1
+	case 2, 3, 4:
+		src = t.TableOS2Version4
+	default:
+		src = t
+	}
+	var out bytes.Buffer
+	_ = binary.Write(&out, binary.BigEndian, src) // the fields have fixed size
+	return out.Bytes()
+}
+
 func (t *TableOS2) useTypoMetrics() bool {
 	const useTypoMetrics = 1 << 7
 	return t.FsSelection&useTypoMetrics != 0
@@ -3,6 +3,7 @@ package truetype
 import (
 	"encoding/binary"
 	"errors"
+	"math"
 )
 
 var (
@@ -147,3 +148,65 @@ func parseNameFormat20(buf []byte, numGlyphs uint16) (postNamesFormat20, error)
 	}
 	return postNamesFormat20{glyphNameIndexes: glyphNameIndexes, names: names}, nil
 }
+
+// Serialize encodes the table for a font with `numGlyphs` glyphs.
+// The version is deduced from `Names`: 3.0 if it is nil, 1.0 for the
+// standard Macintosh names, and 2.0 otherwise.
+// The memory usage fields are set to 0.
+func (t TablePost) Serialize(numGlyphs int) []byte {
+	out := make([]byte, 32)
+	binary.BigEndian.PutUint32(out[4:], uint32(int32(math.Round(t.ItalicAngle*0x10000))))
+	binary.BigEndian.PutUint16(out[8:], uint16(t.UnderlinePosition))
+	binary.BigEndian.PutUint16(out[10:], uint16(t.UnderlineThickness))
+	if t.IsFixedPitch {
+		binary.BigEndian.PutUint32(out[12:], 1)
+	}
+	switch t.Names.(type) {
+	case nil:
+		binary.BigEndian.PutUint32(out, 0x30000)
+	case postNamesFormat10:
+		binary.BigEndian.PutUint32(out, 0x10000)
+	default:
+		binary.BigEndian.PutUint32(out, 0x20000)
+		out = appendPostNames(out, numGlyphs, func(g GID) (string, bool) { return t.Names.GlyphName(g), true })
+	}
+	return out
+}
+
+var builtInPostNamesIndexes = func() map[string]uint16 {
+	out := make(map[string]uint16, numBuiltInPostNames)
+	for i, name := range builtInPostNames {
+		out[name] = uint16(i)
+	}
+	return out
+}()
+
+// appendPostNames writes the glyph names of a version 2.0 table.
+// `glyphName` returns false for glyphs mapped to .notdef
+func appendPostNames(out []byte, numGlyphs int, glyphName func(GID) (string, bool)) []byte {
+	out = append(out, byte(numGlyphs>>8), byte(numGlyphs))
+	var (
+		customNames   []byte
+		customIndexes = map[string]uint16{}
+	)
+	for g := 0; g < numGlyphs; g++ {
+		var index uint16
+		if name, ok := glyphName(GID(g)); ok {
+			if len(name) > 255 {
+				name = name[:255]
+			}
+			var isBuiltIn bool
+			if index, isBuiltIn = builtInPostNamesIndexes[name]; !isBuiltIn {
+				index, ok = customIndexes[name]
+				if !ok {
+					index = uint16(numBuiltInPostNames + len(customIndexes))
+					customIndexes[name] = index
+					customNames = append(customNames, byte(len(name)))
+					customNames = append(customNames, name...)
+				}
+			}
+		}
+		out = append(out, byte(index>>8), byte(index))
+	}
+	return append(out, customNames...)
+}
@@ -0,0 +1,415 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var tagStat = MustNewTag("STAT")
+
+// TableStat is the Style Attributes table, which describes
+// the design axes of a font family, and names the values on these axes.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/stat
+type TableStat struct {
+	DesignAxes []StatAxis
+	AxisValues []StatAxisValue
+	// ElidedFallbackName is the name used when all the axis values
+	// are elided. It is zero for old tables.
+	ElidedFallbackName NameID
+}
+
+// StatAxis is a design axis, which may or may not
+// be a variation axis.
+type StatAxis struct {
+	Tag  Tag
+	Name NameID
+	// Ordering is used to sort the axis values names
+	// when building a style name.
+	Ordering uint16
+}
+
+// StatAxisValueFlag are the flags of an axis value.
+type StatAxisValueFlag uint16
+
+const (
+	// The axis value applies to older fonts of the family, and
+	// should not be used by fonts with variations.
+	StatOlderSiblingFontAttribute StatAxisValueFlag = 1 << iota
+	// The name of the axis value may be omitted when building
+	// a style name, as with "Regular".
+	StatElidableAxisValueName
+)
+
+// StatAxisValue is either StatAxisValueFormat1, StatAxisValueFormat2,
+// StatAxisValueFormat3 or StatAxisValueFormat4
+type StatAxisValue interface {
+	// Flags returns the flags of the value.
+	Flags() StatAxisValueFlag
+	// Name returns the name of the value.
+	Name() NameID
+}
+
+// statAxisValueHeader is common to all formats.
+type statAxisValueHeader struct {
+	flags StatAxisValueFlag
+	name  NameID
+}
+
+func (h statAxisValueHeader) Flags() StatAxisValueFlag { return h.flags }
+func (h statAxisValueHeader) Name() NameID             { return h.name }
+
+// StatAxisValueFormat1 names a single value on an axis.
+type StatAxisValueFormat1 struct {
+	statAxisValueHeader
+	AxisIndex uint16 // index into DesignAxes
+	Value     float32
+}
+
+// StatAxisValueFormat2 names a range of values on an axis.
+type StatAxisValueFormat2 struct {
+	statAxisValueHeader
+	AxisIndex    uint16 // index into DesignAxes
+	NominalValue float32
+	RangeMin     float32
+	RangeMax     float32
+}
+
+// StatAxisValueFormat3 names a single value on an axis,
+// linked to another value, as for a "Bold" value linked to
+// a "Regular" value.
+type StatAxisValueFormat3 struct {
+	statAxisValueHeader
+	AxisIndex   uint16 // index into DesignAxes
+	Value       float32
+	LinkedValue float32
+}
+
+// StatAxisValueFormat4 names a combination of values
+// on several axes.
+type StatAxisValueFormat4 struct {
+	statAxisValueHeader
+	Values []StatAxisValueRecord
+}
+
+// StatAxisValueRecord is a value on one axis.
+type StatAxisValueRecord struct {
+	AxisIndex uint16 // index into DesignAxes
+	Value     float32
+}
+
+func parseTableStat(data []byte) (out TableStat, err error) {
+	if len(data) < 18 {
+		return out, errors.New("invalid 'STAT' table (EOF)")
+	}
+	major, minor := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+	if major != 1 {
+		return out, fmt.Errorf("unsupported 'STAT' table version: %d", major)
+	}
+	axisSize := int(binary.BigEndian.Uint16(data[4:]))
+	axisCount := int(binary.BigEndian.Uint16(data[6:]))
+	axesOffset := int(binary.BigEndian.Uint32(data[8:]))
+	valueCount := int(binary.BigEndian.Uint16(data[12:]))
+	valuesOffset := int(binary.BigEndian.Uint32(data[14:]))
+	if minor >= 1 {
+		if len(data) < 20 {
+			return out, errors.New("invalid 'STAT' table (EOF)")
+		}
+		out.ElidedFallbackName = NameID(binary.BigEndian.Uint16(data[18:]))
+	}
+
+	if axisCount != 0 {
+		if axisSize < 8 || len(data) < axesOffset+axisSize*axisCount {
+			return out, errors.New("invalid 'STAT' design axes (EOF)")
+		}
+		out.DesignAxes = make([]StatAxis, axisCount)
+		for i := range out.DesignAxes {
+			record := data[axesOffset+axisSize*i:]
+			out.DesignAxes[i] = StatAxis{
+				Tag:      Tag(binary.BigEndian.Uint32(record)),
+				Name:     NameID(binary.BigEndian.Uint16(record[4:])),
+				Ordering: binary.BigEndian.Uint16(record[6:]),
+			}
+		}
+	}
+
+	if valueCount != 0 {
+		if len(data) < valuesOffset {
+			return out, errors.New("invalid 'STAT' axis values (EOF)")
+		}
+		values := data[valuesOffset:]
+		offsets, err := parseUint16s(values, valueCount)
+		if err != nil {
+			return out, errors.New("invalid 'STAT' axis values (EOF)")
+		}
+		out.AxisValues = make([]StatAxisValue, valueCount)
+		for i, offset := range offsets {
+			out.AxisValues[i], err = parseStatAxisValue(values, offset, axisCount)
+			if err != nil {
+				return out, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func parseStatAxisValue(data []byte, offset uint16, axisCount int) (StatAxisValue, error) {
+	if len(data) < int(offset)+2 {
+		return nil, errors.New("invalid 'STAT' axis value (EOF)")
+	}
+	data = data[offset:]
+	format := binary.BigEndian.Uint16(data)
+	// size of the value, excluding the (format, axisIndex) or (format, axisCount) fields
+	var size int
+	switch format {
+	case 1:
+		size = 8
+	case 2:
+		size = 16
+	case 3:
+		size = 12
+	case 4:
+		if len(data) < 4 {
+			return nil, errors.New("invalid 'STAT' axis value (EOF)")
+		}
+		size = 4 + 6*int(binary.BigEndian.Uint16(data[2:]))
+	default:
+		return nil, fmt.Errorf("invalid 'STAT' axis value format: %d", format)
+	}
+	if len(data) < 4+size {
+		return nil, errors.New("invalid 'STAT' axis value (EOF)")
+	}
+
+	// for format 4, the header is after the axis count
+	header := statAxisValueHeader{
+		flags: StatAxisValueFlag(binary.BigEndian.Uint16(data[4:])),
+		name:  NameID(binary.BigEndian.Uint16(data[6:])),
+	}
+	axisIndex := binary.BigEndian.Uint16(data[2:])
+	if format != 4 && int(axisIndex) >= axisCount {
+		return nil, fmt.Errorf("invalid 'STAT' axis index: %d", axisIndex)
+	}
+	switch format {
+	case 1:
+		return StatAxisValueFormat1{
+			statAxisValueHeader: header,
+			AxisIndex:           axisIndex,
+			Value:               Float1616FromUint(binary.BigEndian.Uint32(data[8:])),
+		}, nil
+	case 2:
+		return StatAxisValueFormat2{
+			statAxisValueHeader: header,
+			AxisIndex:           axisIndex,
+			NominalValue:        Float1616FromUint(binary.BigEndian.Uint32(data[8:])),
+			RangeMin:            Float1616FromUint(binary.BigEndian.Uint32(data[12:])),
+			RangeMax:            Float1616FromUint(binary.BigEndian.Uint32(data[16:])),
+		}, nil
+	case 3:
+		return StatAxisValueFormat3{
+			statAxisValueHeader: header,
+			AxisIndex:           axisIndex,
+			Value:               Float1616FromUint(binary.BigEndian.Uint32(data[8:])),
+			LinkedValue:         Float1616FromUint(binary.BigEndian.Uint32(data[12:])),
+		}, nil
+	default:
+		out := StatAxisValueFormat4{statAxisValueHeader: header, Values: make([]StatAxisValueRecord, axisIndex)}
+		for i := range out.Values {
+			record := data[8+6*i:]
+			out.Values[i] = StatAxisValueRecord{
+				AxisIndex: binary.BigEndian.Uint16(record),
+				Value:     Float1616FromUint(binary.BigEndian.Uint32(record[2:])),
+			}
+			if int(out.Values[i].AxisIndex) >= axisCount {
+				return nil, fmt.Errorf("invalid 'STAT' axis index: %d", out.Values[i].AxisIndex)
+			}
+		}
+		return out, nil
+	}
+}
+
+// MatchingValues returns the axis values matching the given location, with at most
+// one value per design axis, sorted by the axis ordering.
+// `location` gives the value on each design axis (with same length as DesignAxes) :
+// axis with no value should be marked with a NaN value.
+//
+// Combinations of values (format 4) are preferred. Then, for each axis, a value
+// whose nominal value matches the location is preferred to a range containing it.
+// Values flagged with StatOlderSiblingFontAttribute are ignored.
+func (t TableStat) MatchingValues(location []float32) []StatAxisValue {
+	type match struct {
+		value    StatAxisValue
+		ordering uint16
+	}
+	var (
+		matches []match
+		done    = make([]bool, len(t.DesignAxes))
+	)
+
+	// combinations first, preferring the ones with more axes
+	var combinations []StatAxisValueFormat4
+	for _, value := range t.AxisValues {
+		if value, ok := value.(StatAxisValueFormat4); ok && value.flags&StatOlderSiblingFontAttribute == 0 {
+			combinations = append(combinations, value)
+		}
+	}
+	sort.SliceStable(combinations, func(i, j int) bool { return len(combinations[i].Values) > len(combinations[j].Values) })
+	for _, value := range combinations {
+		matching := len(value.Values) != 0
+		for _, record := range value.Values {
+			if done[record.AxisIndex] || location[record.AxisIndex] != record.Value {
+				matching = false
+				break
+			}
+		}
+		if !matching {
+			continue
+		}
+		ordering := uint16(0xFFFF)
+		for _, record := range value.Values {
+			done[record.AxisIndex] = true
+			if o := t.DesignAxes[record.AxisIndex].Ordering; o < ordering {
+				ordering = o
+			}
+		}
+		matches = append(matches, match{value: value, ordering: ordering})
+	}
+
+	for axisIndex, axis := range t.DesignAxes {
+		if done[axisIndex] {
+			continue
+		}
+		v := location[axisIndex]
+		var best StatAxisValue
+	search:
+		for _, value := range t.AxisValues {
+			if value.Flags()&StatOlderSiblingFontAttribute != 0 {
+				continue
+			}
+			switch value := value.(type) {
+			case StatAxisValueFormat1:
+				if int(value.AxisIndex) == axisIndex && value.Value == v {
+					best = value
+					break search
+				}
+			case StatAxisValueFormat3:
+				if int(value.AxisIndex) == axisIndex && value.Value == v {
+					best = value
+					break search
+				}
+			case StatAxisValueFormat2:
+				if int(value.AxisIndex) == axisIndex && value.RangeMin <= v && v <= value.RangeMax {
+					if value.NominalValue == v {
+						best = value
+						break search
+					}
+					if best == nil {
+						best = value
+					}
+				}
+			}
+		}
+		if best != nil {
+			matches = append(matches, match{value: best, ordering: axis.Ordering})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].ordering < matches[j].ordering })
+	out := make([]StatAxisValue, len(matches))
+	for i, m := range matches {
+		out[i] = m.value
+	}
+	return out
+}
+
+// StyleName builds a style name, such as "SemiBold Condensed Italic",
+// for the given `location` (see MatchingValues), using the `names` table.
+// The elidable names are omitted, unless all the names are elidable,
+// in which case the elided fallback name is returned.
+// An empty string is returned if no axis value matches.
+func (t TableStat) StyleName(location []float32, names TableName) string {
+	values := t.MatchingValues(location)
+	if len(values) == 0 {
+		return ""
+	}
+	var chunks []string
+	for _, value := range values {
+		if value.Flags()&StatElidableAxisValueName != 0 {
+			continue
+		}
+		if name := names.getName(value.Name()); name != "" {
+			chunks = append(chunks, name)
+		}
+	}
+	if len(chunks) == 0 {
+		if name := names.getName(t.ElidedFallbackName); t.ElidedFallbackName != 0 && name != "" {
+			return name
+		}
+		return "Regular"
+	}
+	return strings.Join(chunks, " ")
+}
+
+// location returns the position on the design axes matching the
+// given variation coordinates (see Font.StyleName).
+// Design axes which are not variation axes are positioned at their only value
+// (if any), as they are supposed to describe the font as a whole.
+func (t TableStat) location(fvar TableFvar, coords []float32) []float32 {
+	out := make([]float32, len(t.DesignAxes))
+	for i, axis := range t.DesignAxes {
+		out[i] = float32(math.NaN())
+		found := false
+		for j, varAxis := range fvar.Axis {
+			if varAxis.Tag == axis.Tag {
+				out[i], found = varAxis.Default, true
+				if j < len(coords) {
+					out[i] = coords[j]
+				}
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		count := 0
+		for _, value := range t.AxisValues {
+			if value.Flags()&StatOlderSiblingFontAttribute != 0 {
+				continue
+			}
+			switch value := value.(type) {
+			case StatAxisValueFormat1:
+				if int(value.AxisIndex) == i {
+					out[i] = value.Value
+					count++
+				}
+			case StatAxisValueFormat3:
+				if int(value.AxisIndex) == i {
+					out[i] = value.Value
+					count++
+				}
+			}
+		}
+		if count != 1 {
+			out[i] = float32(math.NaN())
+		}
+	}
+	return out
+}
+
+// StyleName returns a style name, such as "SemiBold Condensed Italic",
+// describing the given variation coordinates, which are expressed in
+// design space units, with the same length as the 'fvar' axes.
+// A nil `coords` selects the default instance.
+// The name is built from the 'STAT' table : an empty string is returned
+// if it is missing.
+func (font *Font) StyleName(coords []float32) string {
+	if font.STAT == nil {
+		return ""
+	}
+	location := font.STAT.location(font.fvar, coords)
+	return font.STAT.StyleName(location, font.Names)
+}
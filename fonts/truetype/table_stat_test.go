@@ -0,0 +1,114 @@
+package truetype
+
+import (
+	"os"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func TestParseStat(t *testing.T) {
+	font := loadFont(t, "testdata/Commissioner-VF.ttf")
+	if font.STAT == nil {
+		t.Fatal("missing 'STAT' table")
+	}
+	stat := *font.STAT
+	if len(stat.DesignAxes) != 4 || stat.DesignAxes[0].Tag != MustNewTag("wght") {
+		t.Fatalf("unexpected design axes %v", stat.DesignAxes)
+	}
+
+	var formats [4]int
+	for _, value := range stat.AxisValues {
+		switch value.(type) {
+		case StatAxisValueFormat1:
+			formats[0]++
+		case StatAxisValueFormat2:
+			formats[1]++
+		case StatAxisValueFormat3:
+			formats[2]++
+		case StatAxisValueFormat4:
+			formats[3]++
+		}
+	}
+	for i, count := range formats {
+		if count == 0 {
+			t.Fatalf("missing axis value of format %d", i+1)
+		}
+	}
+
+	pr := loadParser(t, "testdata/Roboto-BoldItalic.ttf")
+	if _, err := pr.StatTable(); err == nil {
+		t.Fatal("expected missing table error")
+	}
+
+	for _, data := range [][]byte{
+		{0, 1},
+		{0, 2, 0, 0},
+		{0, 1, 0, 2, 0, 8, 0, 1, 0, 0, 0, 20, 0, 0, 0, 0, 0, 0},
+	} {
+		if _, err := parseTableStat(data); err == nil {
+			t.Fatalf("expected error for invalid table %v", data)
+		}
+	}
+}
+
+func TestStyleName(t *testing.T) {
+	for _, file := range []string{
+		"testdata/Commissioner-VF.ttf",
+		"testdata/SelawikVar.ttf",
+		"testdata/SourceSansVariable-Roman.anchor.ttf",
+	} {
+		font := loadFont(t, file)
+		// the named instances should match the STAT names
+		for _, instance := range font.fvar.Instances {
+			name := font.Names.getName(instance.Subfamily)
+			if got := font.StyleName(instance.Coords); got != name {
+				t.Fatalf("%s: expected style %s, got %s", file, name, got)
+			}
+		}
+	}
+
+	font := loadFont(t, "testdata/Commissioner-VF.ttf")
+	for _, test := range []struct {
+		coords   []float32
+		expected string
+	}{
+		{nil, "Thin"},
+		{[]float32{600, -12, 100, 100}, "SemiBold Italic Loud"},
+		{[]float32{620, -5, 100, 0}, "SemiBold Flair"},
+		{[]float32{420, 0, 0, 0}, "Regular"},
+	} {
+		if got := font.StyleName(test.coords); got != test.expected {
+			t.Fatalf("expected style %s, got %s", test.expected, got)
+		}
+	}
+
+	font = loadFont(t, "testdata/Comfortaa-i.ttf")
+	if got := font.StyleName(nil); got != "Regular" {
+		t.Fatalf("unexpected style %s", got)
+	}
+	font = loadFont(t, "testdata/Roboto-BoldItalic.ttf")
+	if got := font.StyleName(nil); got != "" {
+		t.Fatalf("unexpected style %s", got)
+	}
+}
+
+func TestVariableDescriptor(t *testing.T) {
+	f, err := os.Open("testdata/SourceSansVariable-Roman.anchor.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fds, err := ScanFont(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := fds[0]
+	if style := fd.AdditionalStyle(); style != "ExtraLight" {
+		t.Fatalf("unexpected style %s", style)
+	}
+	if _, weight, _ := fd.Aspect(); weight != fonts.WeightExtraLight {
+		t.Fatalf("unexpected weight %v", weight)
+	}
+}
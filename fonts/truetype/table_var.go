@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 )
 
 func fixed1616ToFloat(fi uint32) float32 {
@@ -16,6 +17,10 @@ func fixed214ToFloat(fi uint16) float32 {
 	return float32(int16(fi)) / (1 << 14)
 }
 
+func floatToFixed214(f float32) uint16 {
+	return uint16(int16(math.Round(float64(f) * (1 << 14))))
+}
+
 func parseTableFvar(table []byte, names TableName) (out TableFvar, err error) {
 	hd, err := parseFvarHeader(table)
 	if err != nil {
@@ -187,6 +192,25 @@ func (store VariationStore) GetDelta(index VariationStoreIndex, coords []float32
 	return delta
 }
 
+// getScalars returns the scalars of each region referenced by the
+// item variation data at `vsIndex`, or nil for an invalid index.
+func (store VariationStore) getScalars(vsIndex uint16, coords []float32) []float32 {
+	if int(vsIndex) >= len(store.Datas) {
+		return nil
+	}
+	varData := store.Datas[vsIndex]
+	out := make([]float32, len(varData.RegionIndexes))
+	for i, regionIndex := range varData.RegionIndexes {
+		region := store.Regions[regionIndex]
+		v := float32(1)
+		for axis, coord := range coords {
+			v *= region[axis].evaluate(coord)
+		}
+		out[i] = v
+	}
+	return out
+}
+
 func parseVariationStore(data []byte, offset uint32, axisCount int) (out VariationStore, err error) {
 	if len(data) < int(offset)+8 {
 		return out, errors.New("invalid item variation store (EOF)")
@@ -431,12 +455,21 @@ func parseDeltaSetMapping(data []byte, offset uint32) (deltaSetMapping, error) {
 	if len(data) < int(offset)+4 {
 		return nil, errors.New("invalid delta-set mapping (EOF)")
 	}
-	format := binary.BigEndian.Uint16(data[offset:])
-	count := int(binary.BigEndian.Uint16(data[offset+2:]))
-	data = data[offset+4:]
+	format, entryFormat := data[offset], data[offset+1]
+	var count int
+	if format == 1 { // 32-bit count
+		if len(data) < int(offset)+6 {
+			return nil, errors.New("invalid delta-set mapping (EOF)")
+		}
+		count = int(binary.BigEndian.Uint32(data[offset+2:]))
+		data = data[offset+6:]
+	} else {
+		count = int(binary.BigEndian.Uint16(data[offset+2:]))
+		data = data[offset+4:]
+	}
 
-	entrySize := int((format&0x0030)>>4 + 1)
-	innerBitSize := format&0x0F + 1
+	entrySize := int((entryFormat&0x30)>>4 + 1)
+	innerBitSize := entryFormat&0x0F + 1
 	if entrySize > 4 || len(data) < entrySize*count {
 		return nil, errors.New("invalid delta-set mapping (EOF)")
 	}
@@ -528,6 +561,9 @@ func (t tableGvar) applyDeltasToPoints(glyph GID, coords []float32, points []con
 			if !applyToAll {
 				ptIndex = tuple.pointNumbers[i]
 			}
+			if int(ptIndex) >= len(deltas) { // invalid point number
+				continue
+			}
 			deltas[ptIndex].isExplicit = true
 			deltas[ptIndex].X += float32(xDeltas[i]) * scalar
 			deltas[ptIndex].Y += float32(yDeltas[i]) * scalar
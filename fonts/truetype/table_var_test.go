@@ -524,3 +524,28 @@ func TestNormalize(t *testing.T) {
 		t.Fatalf("expected %v, got %v", exp, coords)
 	}
 }
+
+func TestGlyphPointsVar(t *testing.T) {
+	font := loadFont(t, "testdata/SelawikVar.ttf")
+
+	regular, regularPhantoms, ok := font.GlyphPoints(5)
+	if !ok {
+		t.Fatal("missing glyph")
+	}
+	font.SetVarCoordinates(font.NormalizeVariations([]float32{700}))
+	bold, boldPhantoms, _ := font.GlyphPoints(5)
+
+	if len(regular) != len(bold) || !bold[len(bold)-1].EndOfContour {
+		t.Fatalf("invalid points %v", bold)
+	}
+	if reflect.DeepEqual(regular, bold) || regularPhantoms == boldPhantoms {
+		t.Fatal("variations not applied")
+	}
+	if adv := boldPhantoms[phantomRight].X - boldPhantoms[phantomLeft].X; adv != font.HorizontalAdvance(5) {
+		t.Fatalf("expected advance %g, got %g", font.HorizontalAdvance(5), adv)
+	}
+
+	if _, _, ok = font.GlyphPoints(GID(len(font.Glyf))); ok {
+		t.Fatal("expected invalid glyph")
+	}
+}
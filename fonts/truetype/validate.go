@@ -0,0 +1,201 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Issue is a problem found by Validate.
+type Issue struct {
+	Tag Tag   // the table concerned, or 0 for the font file itself
+	Err error // description of the problem
+}
+
+func (is Issue) String() string {
+	if is.Tag == 0 {
+		return is.Err.Error()
+	}
+	return fmt.Sprintf("table %s: %s", is.Tag, is.Err)
+}
+
+// Validate checks the structure of the font file and the content
+// of its tables, reporting all the problems found.
+// It verifies :
+//   - the table checksums and the 'head' checkSumAdjustment (the later only for
+//     fonts outside collections, and not for WOFF files)
+//   - the alignment and the overlaps of the tables
+//   - the tables which are silently ignored when invalid by Parse, such as the layout tables.
+//
+// Note that WOFF2 files are rebuilt during parsing, so that their checksums
+// are always valid.
+// A nil slice is returned if no problems are found.
+func (pr *FontParser) Validate() []Issue {
+	issues := pr.validateDirectory()
+	return append(issues, pr.validateTables()...)
+}
+
+// validateDirectory checks the checksums, the bounds and the layout
+// of the tables.
+func (pr *FontParser) validateDirectory() (issues []Issue) {
+	fileSize, err := pr.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return []Issue{{Err: fmt.Errorf("invalid file: %s", err)}}
+	}
+
+	for _, tag := range pr.duplicates {
+		issues = append(issues, Issue{Tag: tag, Err: errors.New("duplicate table entry")})
+	}
+
+	// sort the tables for reproducible reports
+	tags := make([]Tag, 0, len(pr.tables))
+	for tag := range pr.tables {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	fontChecksum, adjustment := uint32(0), uint32(0)
+	if directory, err := pr.findTableBuffer(pr.directory); err == nil {
+		fontChecksum = tableChecksum(directory)
+	} else {
+		return append(issues, Issue{Err: fmt.Errorf("invalid table directory: %s", err)})
+	}
+
+	type region struct {
+		tag         Tag
+		start, end  int64
+		isDirectory bool
+	}
+	regions := []region{{start: int64(pr.directory.offset), end: int64(pr.directory.offset) + int64(pr.directory.length), isDirectory: true}}
+	for _, tag := range tags {
+		sec := pr.tables[tag]
+		start, end := int64(sec.offset), int64(sec.offset)+int64(sec.length)
+		if end > fileSize {
+			issues = append(issues, Issue{Tag: tag, Err: fmt.Errorf("table out of file bounds (%d > %d)", end, fileSize)})
+			continue
+		}
+		if sec.offset%4 != 0 {
+			issues = append(issues, Issue{Tag: tag, Err: fmt.Errorf("table offset %d not 4-byte aligned", sec.offset)})
+		}
+		regions = append(regions, region{tag: tag, start: start, end: end})
+
+		table, err := pr.findTableBuffer(sec)
+		if err != nil {
+			issues = append(issues, Issue{Tag: tag, Err: err})
+			continue
+		}
+		if tag == tagHead && len(table) >= 12 {
+			// the checksum of 'head' is computed with a zero checkSumAdjustment
+			adjustment = binary.BigEndian.Uint32(table[8:])
+			binary.BigEndian.PutUint32(table[8:], 0)
+		}
+		checksum := tableChecksum(table)
+		if checksum != sec.checksum {
+			issues = append(issues, Issue{Tag: tag, Err: fmt.Errorf("invalid checksum (expected 0x%08x, got 0x%08x)", sec.checksum, checksum)})
+		}
+		fontChecksum += checksum
+	}
+
+	// tables may only be shared (with the same bounds) between fonts of
+	// a collection, so any overlap inside a font is an error
+	sort.SliceStable(regions, func(i, j int) bool { return regions[i].start < regions[j].start })
+	previous := regions[0] // the region ending last
+	for _, current := range regions[1:] {
+		if current.start < previous.end {
+			if previous.isDirectory {
+				issues = append(issues, Issue{Tag: current.tag, Err: errors.New("table overlaps the table directory")})
+			} else {
+				issues = append(issues, Issue{Tag: current.tag, Err: fmt.Errorf("table overlaps table %s", previous.tag)})
+			}
+		}
+		if current.end > previous.end {
+			previous = current
+		}
+	}
+
+	if _, hasHead := pr.tables[tagHead]; hasHead && pr.standalone {
+		if expected := 0xB1B0AFBA - fontChecksum; adjustment != expected {
+			issues = append(issues, Issue{Tag: tagHead, Err: fmt.Errorf("invalid checkSumAdjustment (expected 0x%08x, got 0x%08x)", expected, adjustment)})
+		}
+	}
+
+	return issues
+}
+
+// validateTables parses the tables, reporting the errors.
+func (pr *FontParser) validateTables() (issues []Issue) {
+	// record the error of a table which is present
+	check := func(tag Tag, err error) {
+		if err != nil && pr.HasTable(tag) {
+			issues = append(issues, Issue{Tag: tag, Err: err})
+		}
+	}
+
+	// the following tables are required to load the other ones
+	numGlyphs, err := pr.NumGlyphs()
+	if err != nil {
+		return append(issues, Issue{Tag: tagMaxp, Err: err})
+	}
+	head, err := pr.loadHeadTable()
+	if err != nil {
+		issues = append(issues, Issue{Tag: tagHead, Err: err})
+	}
+	_, err = pr.CmapTable()
+	if err != nil {
+		issues = append(issues, Issue{Tag: tagCmap, Err: err})
+	}
+	names, err := pr.tryAndLoadNameTable()
+	check(tagName, err)
+	fvar, err := pr.tryAndLoadFvarTable(names)
+	check(tagFvar, err)
+	_, err = pr.tryAndLoadAvarTable(fvar)
+	check(tagAvar, err)
+
+	_, err = pr.OS2Table()
+	check(tagOS2, err)
+	_, err = pr.StatTable()
+	check(tagStat, err)
+	_, err = pr.HheaTable()
+	check(tagHhea, err)
+	_, err = pr.VheaTable()
+	check(tagVhea, err)
+	_, err = pr.HtmxTable(numGlyphs)
+	check(tagHmtx, err)
+	_, err = pr.VtmxTable(numGlyphs)
+	check(tagVmtx, err)
+	_, err = pr.PostTable(numGlyphs)
+	check(tagPost, err)
+
+	glyf, err := pr.GlyfTable(numGlyphs, head.indexToLocFormat)
+	check(tagGlyf, err)
+	_, err = pr.cffTable(numGlyphs)
+	check(tagCFF, err)
+	_, err = pr.cff2Table(numGlyphs, fvar)
+	check(tagCFF2, err)
+	_, err = pr.sbixTable(numGlyphs)
+	check(tagSbix, err)
+	_, err = pr.svgTable()
+	check(tagSVG, err)
+	_, err = pr.colrTable(fvar)
+	check(tagCOLR, err)
+	_, err = pr.CPALTable()
+	check(tagCPAL, err)
+	_, err = pr.vorgTable()
+	check(tagVorg, err)
+
+	if len(fvar.Axis) != 0 {
+		_, err = pr.mvarTable(fvar)
+		check(tagMvar, err)
+		_, err = pr.gvarTable(glyf, fvar)
+		check(tagGvar, err)
+		_, err = pr.hvarTable(fvar)
+		check(tagHvar, err)
+		_, err = pr.vvarTable(fvar)
+		check(tagVvar, err)
+	}
+
+	_, layoutIssues := pr.loadLayoutTables(numGlyphs, fvar)
+	return append(issues, layoutIssues...)
+}
@@ -0,0 +1,116 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, filename := range []string{
+		"testdata/DejaVuSerif.ttf",
+		"testdata/Roboto-BoldItalic.ttf",
+		"testdata/CFFTest.otf",
+		"testdata/SelawikVar.ttf",
+		"testdata/ToyTTC.ttc",
+		"testdata/open-sans-v15-latin-regular.woff",
+		"testdata/fontawesome-webfont.woff2",
+	} {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prs, err := NewFontParsers(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pr := range prs {
+			if issues := pr.Validate(); len(issues) != 0 {
+				t.Fatalf("%s: unexpected issues %v", filename, issues)
+			}
+		}
+		file.Close()
+	}
+
+	// this font stores little endian checksums
+	pr := loadParser(t, "testdata/AnjaliOldLipi-Regular.ttf")
+	if issues := pr.Validate(); len(issues) == 0 {
+		t.Fatal("expected invalid checksums")
+	}
+}
+
+// validateFont returns the issues of the font `data`, keyed by table
+func validateFont(t *testing.T, data []byte) map[Tag]int {
+	t.Helper()
+
+	pr, err := NewFontParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[Tag]int)
+	for _, issue := range pr.Validate() {
+		out[issue.Tag]++
+	}
+	return out
+}
+
+// tableEntry returns the directory entry of `tag`
+func tableEntry(data []byte, tag Tag) []byte {
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		entry := data[otfHeaderLength+directoryEntryLength*i:]
+		if Tag(binary.BigEndian.Uint32(entry)) == tag {
+			return entry[:directoryEntryLength]
+		}
+	}
+	return nil
+}
+
+func TestValidateCorrupted(t *testing.T) {
+	data, err := os.ReadFile("testdata/DejaVuSerif.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// modified content
+	modified := append([]byte(nil), data...)
+	gsubOffset := binary.BigEndian.Uint32(tableEntry(modified, TagGsub)[8:])
+	modified[gsubOffset+20]++
+	if issues := validateFont(t, modified); len(issues) != 2 || issues[TagGsub] != 1 || issues[tagHead] != 1 {
+		t.Fatalf("expected checksum issues, got %v", issues)
+	}
+
+	// invalid layout tables, with valid checksums: the font
+	// is accepted by Parse, but not by Validate
+	builder, err := loadParser(t, "testdata/DejaVuSerif.ttf").Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gsub := append([]byte(nil), builder.Table(TagGsub)...)
+	binary.BigEndian.PutUint16(gsub[8:], 0xFFFF) // lookup list offset
+	builder.AddTable(TagGsub, gsub)
+	builder.AddTable(tagKern, []byte{0, 0, 0, 1, 0, 0})
+	modified = builder.Build()
+	if _, err := Parse(bytes.NewReader(modified)); err != nil {
+		t.Fatal(err)
+	}
+	if issues := validateFont(t, modified); len(issues) != 2 || issues[TagGsub] != 1 || issues[tagKern] != 1 {
+		t.Fatalf("expected layout issues, got %v", issues)
+	}
+
+	// misaligned and overlapping tables
+	modified = append([]byte(nil), data...)
+	entry := tableEntry(modified, tagPost)
+	binary.BigEndian.PutUint32(entry[8:], binary.BigEndian.Uint32(entry[8:])-2)
+	issues := validateFont(t, modified)
+	if issues[tagPost] < 3 { // alignment, overlap, checksum
+		t.Fatalf("expected structural issues, got %v", issues)
+	}
+
+	modified = append([]byte(nil), data...)
+	binary.BigEndian.PutUint32(tableEntry(modified, tagName)[8:], 4)
+	if issues := validateFont(t, modified); issues[tagName] < 2 { // overlap, checksum
+		t.Fatalf("expected structural issues, got %v", issues)
+	}
+}
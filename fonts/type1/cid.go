@@ -0,0 +1,550 @@
+package type1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	tk "github.com/benoitkugler/pstokenizer"
+	"github.com/benoitkugler/textlayout/fonts"
+	"github.com/benoitkugler/textlayout/fonts/cmaps"
+)
+
+const (
+	headerCID = "%!PS-Adobe-3.0 Resource-CIDFont"
+
+	// operator starting the binary section of a CID-keyed font
+	startData = "StartData"
+
+	// upper bound for the number of font dicts in the FDArray
+	maxFontDicts = 1 << 16
+)
+
+// cidFontDict is an entry of the FDArray of a CID-keyed font
+type cidFontDict struct {
+	fontMatrix []Fl     // concatenated with the top-level FontMatrix
+	subrs      [][]byte // decrypted local subroutines
+}
+
+// cidPrivate stores the entries of a Private dict
+// needed to read the binary section
+type cidPrivate struct {
+	subrMapOffset, sdBytes, subrCount int
+	lenIV                             int
+}
+
+// cidHeader stores the entries of the top-level dict
+// needed to read the binary section
+type cidHeader struct {
+	cidMapOffset, fdBytes, gdBytes, cidCount int
+	privates                                 []cidPrivate // one per font dict
+
+	dataFormat string // Binary or Hex
+	dataLength int    // in bytes, after hexadecimal decoding
+}
+
+// isCIDFont checks the header of `file`
+func isCIDFont(file fonts.Resource) bool {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	var buffer [len(headerCID)]byte
+	_, err := io.ReadFull(file, buffer[:])
+	return err == nil && string(buffer[:]) == headerCID
+}
+
+// ParseCID parses a CID-keyed Type 1 font file (CIDFontType 0).
+// The glyphs of the returned font are indexed by CID : the GID of a glyph is its CID,
+// and CIDs with no glyph description have empty charstrings, for which
+// no metrics nor outlines are available.
+// Since such fonts have no builtin Unicode mapping, the cmap of the returned font is built
+// from the predefined CMap of its character collection (see `cmaps.PredefinedToUnicode`),
+// and is empty if it is not available. Use `SetCmap` to supply another one.
+// Since loading the CMap is expensive, this is only done on the first call
+// to `Cmap` or `NominalGlyph`.
+//
+// See "Adobe CMap and CIDFont Files Specification, Adobe Systems (1996)"
+func ParseCID(file fonts.Resource) (*Font, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	font, err := parseCID(data, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID-keyed font file: %s", err)
+	}
+	return &font, nil
+}
+
+// cidCmapLock guards the lazy initialization of the cmap of CID-keyed fonts.
+// It is not stored in Font, which is copied by value.
+var cidCmapLock sync.Mutex
+
+// getCmap returns the cmap of the font, building the cmap
+// of CID-keyed fonts on the first call.
+func (f *Font) getCmap() fonts.Cmap {
+	if f.CIDSystemInfo == nil {
+		return f.cmap
+	}
+	cidCmapLock.Lock()
+	defer cidCmapLock.Unlock()
+	if f.cmap == nil {
+		f.cmap = fonts.CmapSimple{}
+		if cidToUnicode, err := cmaps.PredefinedToUnicode(*f.CIDSystemInfo); err == nil {
+			f.cmap = cmaps.UnicodeCmap(cidToUnicode, nil)
+		}
+	}
+	return f.cmap
+}
+
+// parseCID parses the dict of a CID-keyed font, and
+// if `withGlyphs` is true, the charstrings and subroutines
+// of its binary section.
+func parseCID(data []byte, withGlyphs bool) (Font, error) {
+	index := bytes.Index(data, []byte(startData))
+	if index == -1 {
+		return Font{}, errors.New("missing StartData operator")
+	}
+	var p parser
+	out, header, err := p.parseCIDHeader(data[:index])
+	if err != nil || !withGlyphs {
+		return out, err
+	}
+
+	binary := data[index+len(startData):]
+	if len(binary) != 0 { // single white space
+		binary = binary[1:]
+	}
+	switch header.dataFormat {
+	case "Binary":
+	case "Hex":
+		if end := bytes.IndexByte(binary, '>'); end != -1 {
+			binary = binary[:end]
+		}
+		binary = hexToBinary(binary)
+	default:
+		return out, fmt.Errorf("unsupported data format %s", header.dataFormat)
+	}
+	if header.dataLength < 0 || header.dataLength > len(binary) {
+		return out, fmt.Errorf("invalid data length %d (for %d)", header.dataLength, len(binary))
+	}
+	binary = binary[:header.dataLength]
+
+	err = header.readGlyphs(binary, &out)
+	return out, err
+}
+
+// Parses the font dict of a CID-keyed font, up to the StartData operator.
+func (p *parser) parseCIDHeader(data []byte) (Font, cidHeader, error) {
+	var (
+		out    = Font{CIDSystemInfo: new(cmaps.CIDSystemInfo)}
+		header cidHeader
+	)
+	p.lexer = newLexer(data)
+
+	// skip the resource header, such as "/CIDInit /ProcSet findresource begin"
+	for {
+		token := p.lexer.peekToken()
+		if token.Kind == tk.EOF {
+			return out, header, errors.New("font dict not found")
+		}
+		if next, _ := p.lexer.PeekPeekToken(); token.Kind == tk.Integer && next.IsOther("dict") {
+			break
+		}
+		if _, err := p.lexer.nextToken(); err != nil {
+			return out, header, err
+		}
+	}
+
+	// font dict
+	if _, err := p.read(tk.Integer); err != nil {
+		return out, header, err
+	}
+	if err := p.readWithName(tk.Other, "dict"); err != nil {
+		return out, header, err
+	}
+	if _, err := p.readMaybe(tk.Other, "dup"); err != nil {
+		return out, header, err
+	}
+	if err := p.readWithName(tk.Other, "begin"); err != nil {
+		return out, header, err
+	}
+
+	dict := map[string][]tk.Token{}
+	for p.lexer.peekToken().Kind == tk.Name {
+		keyT, err := p.read(tk.Name)
+		if err != nil {
+			return out, header, err
+		}
+		// "/CIDFontName currentdict /CIDFont defineresource pop" ends the dict
+		if p.lexer.peekToken().IsOther("currentdict") {
+			break
+		}
+		switch key := string(keyT.Value); key {
+		case "FontInfo":
+			info, err := p.readSimpleDict()
+			if err != nil {
+				return out, header, err
+			}
+			out.PSInfo = p.readFontInfo(info)
+		case "CIDSystemInfo":
+			info, err := p.readSimpleDict()
+			if err != nil {
+				return out, header, err
+			}
+			if value := info["Registry"]; len(value) != 0 {
+				out.CIDSystemInfo.Registry = string(value[0].Value)
+			}
+			if value := info["Ordering"]; len(value) != 0 {
+				out.CIDSystemInfo.Ordering = string(value[0].Value)
+			}
+			out.CIDSystemInfo.Supplement, err = intEntry(info, "Supplement", 0)
+			if err != nil {
+				return out, header, err
+			}
+		case "FDArray":
+			out.fontDicts, header.privates, err = p.readFDArray()
+			if err != nil {
+				return out, header, err
+			}
+		default:
+			dict[key], err = p.readCIDValue()
+			if err != nil {
+				return out, header, err
+			}
+		}
+	}
+
+	if err := p.readCIDEntries(dict, &out, &header); err != nil {
+		return out, header, err
+	}
+
+	// the last tokens are the arguments of StartData,
+	// such as "(Binary) 123456"
+	var format, length tk.Token
+	for {
+		token, err := p.lexer.nextToken()
+		if err != nil {
+			return out, header, err
+		}
+		if token.Kind == tk.EOF {
+			break
+		}
+		format, length = length, token
+	}
+	if format.Kind != tk.String || length.Kind != tk.Integer {
+		return out, header, errors.New("invalid arguments for StartData")
+	}
+	header.dataFormat = string(format.Value)
+	header.dataLength, _ = length.Int()
+
+	return out, header, nil
+}
+
+// interprets the simple values of the top-level dict
+func (p *parser) readCIDEntries(dict map[string][]tk.Token, font *Font, header *cidHeader) error {
+	if value := dict["CIDFontName"]; len(value) != 0 {
+		font.FontName = string(value[0].Value)
+	}
+	if cidFontType, err := intEntry(dict, "CIDFontType", 0); err != nil {
+		return err
+	} else if cidFontType != 0 {
+		return fmt.Errorf("unsupported CIDFontType %d", cidFontType)
+	}
+
+	var err error
+	if value, ok := dict["FontMatrix"]; ok {
+		font.FontMatrix, err = p.arrayToNumbers(value)
+		if err != nil {
+			return err
+		}
+	}
+	if value, ok := dict["FontBBox"]; ok {
+		font.FontBBox, err = p.arrayToNumbers(value)
+		if err != nil {
+			return err
+		}
+	}
+	if font.UniqueID, err = intEntry(dict, "UIDBase", 0); err != nil {
+		return err
+	}
+	for i := range font.fontDicts {
+		font.fontDicts[i].fontMatrix = multiplyMatrices(font.fontDicts[i].fontMatrix, font.FontMatrix)
+	}
+
+	if header.cidMapOffset, err = intEntry(dict, "CIDMapOffset", 0); err != nil {
+		return err
+	}
+	if header.fdBytes, err = intEntry(dict, "FDBytes", 1); err != nil {
+		return err
+	}
+	if header.gdBytes, err = intEntry(dict, "GDBytes", 0); err != nil {
+		return err
+	}
+	if header.cidCount, err = intEntry(dict, "CIDCount", 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reads the /FDArray array, returning the font dicts
+// and the associated Private dicts.
+func (p *parser) readFDArray() ([]cidFontDict, []cidPrivate, error) {
+	lengthT, err := p.read(tk.Integer)
+	if err != nil {
+		return nil, nil, err
+	}
+	length, _ := lengthT.Int()
+	if length < 0 || length > maxFontDicts {
+		return nil, nil, fmt.Errorf("invalid FDArray length %d", length)
+	}
+	if err = p.readWithName(tk.Other, "array"); err != nil {
+		return nil, nil, err
+	}
+
+	fontDicts, privates := make([]cidFontDict, length), make([]cidPrivate, length)
+	for i := 0; i < length; i++ {
+		// premature end
+		if !p.lexer.peekToken().IsOther("dup") {
+			break
+		}
+		if err = p.readWithName(tk.Other, "dup"); err != nil {
+			return nil, nil, err
+		}
+		indexT, err := p.read(tk.Integer)
+		if err != nil {
+			return nil, nil, err
+		}
+		index, _ := indexT.Int()
+		if index < 0 || index >= length {
+			return nil, nil, fmt.Errorf("out of range font dict index %d (for %d)", index, length)
+		}
+		fontDicts[index], privates[index], err = p.readFontDict()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = p.readPut(); err != nil {
+			return nil, nil, err
+		}
+	}
+	err = p.readDef()
+	return fontDicts, privates, err
+}
+
+// Reads one font dict of the FDArray, such as
+// "14 dict begin ... /Private 18 dict dup begin ... end def currentdict end"
+func (p *parser) readFontDict() (cidFontDict, cidPrivate, error) {
+	var (
+		out     cidFontDict
+		private = cidPrivate{lenIV: 4}
+	)
+	if _, err := p.read(tk.Integer); err != nil {
+		return out, private, err
+	}
+	if err := p.readWithName(tk.Other, "dict"); err != nil {
+		return out, private, err
+	}
+	if _, err := p.readMaybe(tk.Other, "dup"); err != nil {
+		return out, private, err
+	}
+	if err := p.readWithName(tk.Other, "begin"); err != nil {
+		return out, private, err
+	}
+
+	for p.lexer.peekToken().Kind == tk.Name {
+		keyT, err := p.read(tk.Name)
+		if err != nil {
+			return out, private, err
+		}
+		switch string(keyT.Value) {
+		case "Private":
+			dict, err := p.readSimpleDict()
+			if err != nil {
+				return out, private, err
+			}
+			private, err = readCIDPrivate(dict)
+			if err != nil {
+				return out, private, err
+			}
+		case "FontMatrix":
+			value, err := p.readCIDValue()
+			if err != nil {
+				return out, private, err
+			}
+			out.fontMatrix, err = p.arrayToNumbers(value)
+			if err != nil {
+				return out, private, err
+			}
+		default:
+			if _, err = p.readCIDValue(); err != nil {
+				return out, private, err
+			}
+		}
+	}
+
+	if _, err := p.readMaybe(tk.Other, "currentdict"); err != nil {
+		return out, private, err
+	}
+	err := p.readWithName(tk.Other, "end")
+	return out, private, err
+}
+
+func readCIDPrivate(dict map[string][]tk.Token) (out cidPrivate, err error) {
+	if out.subrMapOffset, err = intEntry(dict, "SubrMapOffset", 0); err != nil {
+		return out, err
+	}
+	if out.sdBytes, err = intEntry(dict, "SDBytes", 0); err != nil {
+		return out, err
+	}
+	if out.subrCount, err = intEntry(dict, "SubrCount", 0); err != nil {
+		return out, err
+	}
+	if out.lenIV, err = intEntry(dict, "lenIV", 4); err != nil {
+		return out, err
+	}
+	if out.lenIV < -1 {
+		return out, fmt.Errorf("invalid lenIV %d", out.lenIV)
+	}
+	return out, nil
+}
+
+// Reads a simple value from a dictionary, also accepting
+// procedures followed by "bind".
+func (p *parser) readCIDValue() ([]tk.Token, error) {
+	value, err := p.readValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.readMaybe(tk.Other, "bind"); err != nil {
+		return nil, err
+	}
+	if _, err = p.readMaybe(tk.Other, "executeonly"); err != nil {
+		return nil, err
+	}
+	err = p.readDef()
+	return value, err
+}
+
+// returns the integer value of `key`, or `defaultValue` if it is missing
+func intEntry(dict map[string][]tk.Token, key string, defaultValue int) (int, error) {
+	value, ok := dict[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	if len(value) == 0 {
+		return 0, fmt.Errorf("missing value for key %s", key)
+	}
+	return value[0].Int()
+}
+
+// readGlyphs reads the CIDMap and the subroutines maps from the binary section,
+// and fills the charstrings and the subroutines of `font`.
+func (header cidHeader) readGlyphs(data []byte, font *Font) error {
+	for i, private := range header.privates {
+		subrs, err := readCIDOffsets(data, private.subrMapOffset, 0, private.sdBytes, private.subrCount)
+		if err != nil {
+			return fmt.Errorf("invalid subroutines map: %s", err)
+		}
+		font.fontDicts[i].subrs = make([][]byte, len(subrs))
+		for j, subr := range subrs {
+			font.fontDicts[i].subrs[j] = decryptCharstring(subr.data, private.lenIV)
+		}
+	}
+
+	glyphs, err := readCIDOffsets(data, header.cidMapOffset, header.fdBytes, header.gdBytes, header.cidCount)
+	if err != nil {
+		return fmt.Errorf("invalid CIDMap: %s", err)
+	}
+	font.charstrings = make([]charstring, len(glyphs))
+	for cid, glyph := range glyphs {
+		if len(glyph.data) == 0 { // no glyph description for this CID
+			continue
+		}
+		if glyph.fd >= len(header.privates) {
+			return fmt.Errorf("invalid font dict index %d for CID %d", glyph.fd, cid)
+		}
+		font.charstrings[cid] = charstring{
+			data: decryptCharstring(glyph.data, header.privates[glyph.fd].lenIV),
+			fd:   glyph.fd,
+		}
+	}
+	return nil
+}
+
+type cidEntry struct {
+	data []byte // still encrypted
+	fd   int
+}
+
+// readCIDOffsets reads the `count`+1 entries of a map starting at `offset`,
+// each entry being made of a font dict index (stored in `fdBytes`) and an offset in `data`
+// (stored in `offsetBytes`).
+// The consecutive offsets delimit `count` slices of `data`.
+func readCIDOffsets(data []byte, offset, fdBytes, offsetBytes, count int) ([]cidEntry, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	if fdBytes < 0 || fdBytes > 4 || offsetBytes < 1 || offsetBytes > 4 {
+		return nil, fmt.Errorf("invalid entry sizes %d and %d", fdBytes, offsetBytes)
+	}
+	entrySize := fdBytes + offsetBytes
+	if offset < 0 || count < 0 || (len(data)-offset)/entrySize < count+1 {
+		return nil, fmt.Errorf("invalid offset %d or count %d (for length %d)", offset, count, len(data))
+	}
+
+	entries := data[offset:]
+	readEntry := func(i int) (fd, start int) {
+		entry := entries[i*entrySize:]
+		return readUint(entry[:fdBytes]), readUint(entry[fdBytes:entrySize])
+	}
+	out := make([]cidEntry, count)
+	fd, start := readEntry(0)
+	for i := range out {
+		nextFd, end := readEntry(i + 1)
+		if end < start || end > len(data) {
+			return nil, fmt.Errorf("invalid offsets %d, %d (for length %d)", start, end, len(data))
+		}
+		out[i] = cidEntry{data: data[start:end], fd: fd}
+		fd, start = nextFd, end
+	}
+	return out, nil
+}
+
+// reads a big-endian unsigned integer
+func readUint(data []byte) int {
+	var out int
+	for _, b := range data {
+		out = out<<8 | int(b)
+	}
+	return out
+}
+
+// decrypts a copy of `cipher`, since charstrings may share their storage
+func decryptCharstring(cipher []byte, lenIV int) []byte {
+	return decrypt(append([]byte(nil), cipher...), CHARSTRING_KEY, lenIV)
+}
+
+// multiplyMatrices returns the matrix applying `m1` then `m2`.
+// A missing (or invalid) matrix is treated as the identity.
+func multiplyMatrices(m1, m2 []Fl) []Fl {
+	if len(m1) != 6 {
+		return m2
+	}
+	if len(m2) != 6 {
+		return m1
+	}
+	return []Fl{
+		m1[0]*m2[0] + m1[1]*m2[2],
+		m1[0]*m2[1] + m1[1]*m2[3],
+		m1[2]*m2[0] + m1[3]*m2[2],
+		m1[2]*m2[1] + m1[3]*m2[3],
+		m1[4]*m2[0] + m1[5]*m2[2] + m2[4],
+		m1[4]*m2[1] + m1[5]*m2[3] + m2[5],
+	}
+}
@@ -0,0 +1,248 @@
+package type1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+	"github.com/benoitkugler/textlayout/fonts/cmaps"
+	ps "github.com/benoitkugler/textlayout/fonts/psinterpreter"
+)
+
+// encrypts a Type 1 charstring, adding `lenIV` random (here zero) bytes
+func encryptCharstring(plain []byte, lenIV int) []byte {
+	r := uint16(CHARSTRING_KEY)
+	out := make([]byte, 0, lenIV+len(plain))
+	for _, p := range append(make([]byte, lenIV), plain...) {
+		c := p ^ byte(r>>8)
+		out = append(out, c)
+		r = (uint16(c)+r)*52845 + 22719
+	}
+	return out
+}
+
+const cidFontHeader = `%%!PS-Adobe-3.0 Resource-CIDFont
+%%%%DocumentNeededResources: ProcSet (CIDInit)
+%%%%BeginResource: CIDFont (Test-Light)
+/CIDInit /ProcSet findresource begin
+20 dict begin
+/CIDFontName /Test-Light def
+/CIDFontVersion 1.0 def
+/CIDFontType 0 def
+/CIDSystemInfo 3 dict dup begin
+  /Registry (Adobe) def
+  /Ordering (Japan1) def
+  /Supplement 6 def
+end def
+/FontBBox [0 -120 1000 880] def
+/UIDBase 1234 def
+/FontInfo 3 dict dup begin
+  /FamilyName (Test) readonly def
+  /Weight (Light) readonly def
+  /isFixedPitch false def
+end readonly def
+/CIDMapOffset %d def
+/FDBytes 1 def
+/GDBytes 2 def
+/CIDCount 4 def
+/FDArray 2 array
+dup 0
+%%ADOBeginFontDict
+7 dict
+  begin
+  /FontName /Test-Light-Kana def
+  /FontType 1 def
+  /FontMatrix [0.001 0 0 0.001 0 0] def
+  /PaintType 0 def
+  /Private 8 dict dup
+  begin
+  /MinFeature {16 16} def
+  /BlueValues [-120 -120 880 880] def
+  /RndStemUp true def
+  /lenIV -1 def
+  /SubrMapOffset 0 def
+  /SDBytes 2 def
+  /SubrCount 1 def
+  end def
+  currentdict
+  end
+%%ADOEndFontDict
+put
+dup 1
+%%ADOBeginFontDict
+7 dict
+  begin
+  /FontName /Test-Light-Kanji def
+  /FontType 1 def
+  /FontMatrix [0.001 0 0 0.001 0 0] def
+  /Private 3 dict dup
+  begin
+  /SubrCount 0 def
+  end def
+  currentdict
+  end
+%%ADOEndFontDict
+put
+def
+/CDevProc {pop 4 index add} bind def
+/CIDFontName currentdict /CIDFont defineresource pop
+end
+end
+%%%%EndResource
+%%%%BeginData: %d %s Bytes
+(%s) %d StartData `
+
+// returns a CID-keyed font with 4 CIDs, the last one being undefined
+func buildCIDFont(hexFormat bool) []byte {
+	var (
+		subr   = []byte{139, 247, 92, 5, 11}                                                    // 0 200 rlineto return
+		notdef = []byte{139, 248, 136, 13, 14}                                                  // 0 500 hsbw endchar
+		glyph1 = []byte{189, 248, 236, 13, 239, 139, 21, 247, 92, 139, 5, 139, 10, 9, 14}       // 50 600 hsbw 100 0 rmoveto 200 0 rlineto 0 callsubr closepath endchar
+		glyph2 = encryptCharstring([]byte{139, 247, 192, 13, 149, 159, 21, 169, 179, 5, 14}, 4) // 0 300 hsbw 10 20 rmoveto 30 40 rlineto endchar
+	)
+
+	const subrMapSize, cidMapSize = 2 * 2, 5 * 3
+	var data []byte
+	offset := subrMapSize + cidMapSize
+	data = append(data, byte(offset>>8), byte(offset))
+	offset += len(subr)
+	data = append(data, byte(offset>>8), byte(offset))
+	for _, glyph := range []struct {
+		fd   byte
+		data []byte
+	}{{0, notdef}, {0, glyph1}, {1, glyph2}, {0, nil}, {0, nil}} {
+		data = append(data, glyph.fd, byte(offset>>8), byte(offset))
+		offset += len(glyph.data)
+	}
+	data = append(data, subr...)
+	data = append(data, notdef...)
+	data = append(data, glyph1...)
+	data = append(data, glyph2...)
+
+	format, section := "Binary", data
+	if hexFormat {
+		format, section = "Hex", []byte(hex.EncodeToString(data)+">")
+	}
+	out := fmt.Sprintf(cidFontHeader, subrMapSize, len(section)+20, format, format, len(data))
+	return append([]byte(out), append(section, "\n%%EndData\n%%EndResource\n"...)...)
+}
+
+func TestParseCID(t *testing.T) {
+	// provide a (fake) predefined CMap for the Adobe-Japan1 collection
+	dir := t.TempDir()
+	cmap := `/CMapName /Adobe-Japan1-UCS2 def
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+1 beginbfchar <0001> <0041> endbfchar`
+	if err := ioutil.WriteFile(filepath.Join(dir, "Adobe-Japan1-UCS2"), []byte(cmap), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	saved := cmaps.ResourceDirs
+	cmaps.ResourceDirs = []string{dir}
+	defer func() { cmaps.ResourceDirs = saved }()
+
+	for _, hexFormat := range []bool{false, true} {
+		file := buildCIDFont(hexFormat)
+
+		fs, err := Load(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		font := fs[0].(*Font)
+
+		if exp := (cmaps.CIDSystemInfo{Registry: "Adobe", Ordering: "Japan1", Supplement: 6}); *font.CIDSystemInfo != exp {
+			t.Fatalf("expected %v, got %v", exp, *font.CIDSystemInfo)
+		}
+		if font.FontName != "Test-Light" || font.FamilyName != "Test" || font.UniqueID != 1234 {
+			t.Fatalf("unexpected font dict %s %s %d", font.FontName, font.FamilyName, font.UniqueID)
+		}
+		if len(font.charstrings) != 4 || len(font.fontDicts) != 2 {
+			t.Fatalf("unexpected number of glyphs or font dicts: %d %d", len(font.charstrings), len(font.fontDicts))
+		}
+		if upem := font.Upem(); upem != 1000 {
+			t.Fatalf("unexpected upem %d", upem)
+		}
+
+		for cid, exp := range []struct {
+			bounds  ps.PathBounds
+			advance int32
+		}{
+			{ps.PathBounds{}, 500},
+			{ps.PathBounds{Min: ps.Point{X: 150, Y: 0}, Max: ps.Point{X: 350, Y: 200}}, 600},
+			{ps.PathBounds{Min: ps.Point{X: 10, Y: 20}, Max: ps.Point{X: 40, Y: 60}}, 300},
+		} {
+			_, bounds, advance, err := font.loadGlyph(fonts.GID(cid), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bounds != exp.bounds || advance != exp.advance {
+				t.Fatalf("CID %d: expected %v %d, got %v %d", cid, exp.bounds, exp.advance, bounds, advance)
+			}
+		}
+		if outlines, ok := font.GlyphData(1, 0, 0).(fonts.GlyphOutline); !ok || len(outlines.Segments) == 0 {
+			t.Fatalf("unexpected outlines %v", outlines)
+		}
+		if font.GlyphData(3, 0, 0) != nil || font.GlyphData(4, 0, 0) != nil {
+			t.Fatal("expected no glyph for undefined CIDs")
+		}
+
+		if font.cmap != nil {
+			t.Fatal("the CMap should only be loaded when needed")
+		}
+		// the lazy loading is safe for concurrent use
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				font.NominalGlyph('A')
+			}()
+		}
+		wg.Wait()
+		if gid, ok := font.NominalGlyph('A'); !ok || gid != 1 {
+			t.Fatalf("unexpected glyph for 'A': %d", gid)
+		}
+		if _, ok := font.NominalGlyph('a'); ok {
+			t.Fatal("unexpected glyph for 'a'")
+		}
+		font.SetCmap(fonts.CmapSimple{'a': 2})
+		if gid, ok := font.NominalGlyph('a'); !ok || gid != 2 {
+			t.Fatalf("unexpected glyph for 'a': %d", gid)
+		}
+
+		descriptors, err := ScanFont(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if family := descriptors[0].Family(); family != "Test" {
+			t.Fatalf("unexpected family %s", family)
+		}
+		if _, err = descriptors[0].LoadCmap(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseCIDInvalid(t *testing.T) {
+	file := string(buildCIDFont(false))
+	for _, invalid := range []string{
+		strings.Replace(file, "/CIDFontType 0", "/CIDFontType 2", 1),
+		strings.Replace(file, "/GDBytes 2", "/GDBytes 5", 1),
+		strings.Replace(file, "/CIDCount 4", "/CIDCount 400", 1),
+		strings.Replace(file, "/SubrCount 1", "/SubrCount 10", 1),
+		strings.Replace(file, "/lenIV -1", "/lenIV -3", 1),
+		strings.Replace(file, "(Binary)", "(Base85)", 1),
+		strings.Replace(file, "StartData", "", 1),
+		file[:len(file)-40], // truncated
+	} {
+		if _, err := ParseCID(strings.NewReader(invalid)); err == nil {
+			t.Fatal("expected error for invalid CID-keyed font")
+		}
+	}
+}
@@ -2,6 +2,8 @@ package type1
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 
 	"github.com/benoitkugler/textlayout/fonts"
@@ -20,6 +22,10 @@ type fontDescriptor struct {
 // ScanFont lazily parse `file` to extract the information about the font.
 // If no error occurs, the returned slice has always length 1.
 func ScanFont(file fonts.Resource) ([]fonts.FontDescriptor, error) {
+	if isCIDFont(file) {
+		return scanCIDFont(file)
+	}
+
 	seg1, _, err := openPfb(file)
 	if err != nil {
 		return nil, fmt.Errorf("invalid .pfb font file: %s", err)
@@ -37,6 +43,23 @@ func ScanFont(file fonts.Resource) ([]fonts.FontDescriptor, error) {
 	return []fonts.FontDescriptor{&fd}, nil
 }
 
+// only parses the font dict, skipping the binary section
+func scanCIDFont(file fonts.Resource) ([]fonts.FontDescriptor, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	font, err := parseCID(data, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID-keyed font file: %s", err)
+	}
+
+	return []fonts.FontDescriptor{&fontDescriptor{info: font.PSInfo}}, nil
+}
+
 func (fd *fontDescriptor) Family() string {
 	return fd.info.FamilyName
 }
@@ -104,8 +127,12 @@ func (fd *fontDescriptor) Aspect() (fonts.Style, fonts.Weight, fonts.Stretch) {
 
 // LoadCmap returns a cmap whose GID are invalid, but
 // whose runes are correct, which is good enough to build coverage information.
+// CID-keyed fonts have no builtin encoding, so that an empty cmap is returned.
 func (fd *fontDescriptor) LoadCmap() (fonts.Cmap, error) {
 	out := fonts.CmapSimple{}
+	if fd.encoding == nil {
+		return out, nil
+	}
 	for r := range fd.encoding.RuneToByte() {
 		out[r] = 1
 	}
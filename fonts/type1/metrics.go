@@ -11,11 +11,16 @@ import (
 var _ fonts.FaceMetrics = (*Font)(nil)
 
 // Upem reads the FontMatrix to extract the scaling factor (the maximum between x and y coordinates)
+// For CID-keyed fonts, the FontMatrix of the first font dict is also taken into account.
 func (f *Font) Upem() uint16 {
-	if len(f.FontMatrix) < 4 {
+	fontMatrix := f.FontMatrix
+	if len(f.fontDicts) != 0 {
+		fontMatrix = f.fontDicts[0].fontMatrix
+	}
+	if len(fontMatrix) < 4 {
 		return 1000 // typical value for Type1 fonts
 	}
-	xx, yy := math.Abs(float64(f.FontMatrix[0])), math.Abs(float64(f.FontMatrix[3]))
+	xx, yy := math.Abs(float64(fontMatrix[0])), math.Abs(float64(fontMatrix[3]))
 	var (
 		upemX uint16 = 1000
 		upemY        = upemX
@@ -74,12 +79,11 @@ func (f *Font) FontVExtents() (fonts.FontExtents, bool) {
 }
 
 func (f *Font) Cmap() (fonts.Cmap, fonts.CmapEncoding) {
-	return f.cmap, fonts.EncUnicode
+	return f.getCmap(), fonts.EncUnicode
 }
 
 func (f *Font) NominalGlyph(ch rune) (fonts.GID, bool) {
-	out, ok := f.cmap[ch]
-	return out, ok
+	return f.getCmap().Lookup(ch)
 }
 
 // HorizontalAdvance returns the advance of the glyph with index `index`
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/benoitkugler/textlayout/fonts"
+	"github.com/benoitkugler/textlayout/fonts/cmaps"
 	"github.com/benoitkugler/textlayout/fonts/glyphsnames"
 	ps "github.com/benoitkugler/textlayout/fonts/psinterpreter"
 	"github.com/benoitkugler/textlayout/fonts/simpleencodings"
@@ -17,8 +18,17 @@ type loader struct{}
 
 // Load implements fonts.FontLoader. When the error is `nil`,
 // one (and only one) font is returned.
+// CID-keyed fonts are detected and parsed with `ParseCID`.
 func Load(file fonts.Resource) (fonts.Faces, error) {
-	f, err := Parse(file)
+	var (
+		f   *Font
+		err error
+	)
+	if isCIDFont(file) {
+		f, err = ParseCID(file)
+	} else {
+		f, err = Parse(file)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +60,7 @@ func Parse(pfb fonts.Resource) (*Font, error) {
 type charstring struct {
 	name string
 	data []byte
+	fd   int // index into the FDArray, for CID-keyed fonts
 }
 
 // Font exposes the content of a .pfb file.
@@ -57,7 +68,7 @@ type charstring struct {
 // entry, which defines the "builtin encoding" of the font.
 type Font struct {
 	Encoding *simpleencodings.Encoding
-	cmap     fonts.CmapSimple // see synthetizeCmap
+	cmap     fonts.Cmap // see synthetizeCmap, SetCmap and getCmap
 
 	FontID      string
 	FontBBox    []Fl
@@ -72,6 +83,11 @@ type Font struct {
 	PaintType int
 	FontType  int
 	UniqueID  int
+
+	// CIDSystemInfo is only set for CID-keyed fonts (see `ParseCID`),
+	// whose glyphs are indexed by CID.
+	CIDSystemInfo *cmaps.CIDSystemInfo
+	fontDicts     []cidFontDict // FDArray, for CID-keyed fonts
 }
 
 func (f *Font) PostscriptInfo() (fonts.PSInfo, bool) { return f.PSInfo, true }
@@ -161,12 +177,22 @@ func (f *Font) checkAndSwapGlyphNotdef() {
 // Type1 fonts have no natural notion of Unicode code points
 // We use a glyph names table to identify the most commonly used runes
 func (f *Font) synthetizeCmap() {
-	f.cmap = make(map[rune]fonts.GID)
+	cmap := make(fonts.CmapSimple)
 	for gid, charstring := range f.charstrings {
 		glyphName := charstring.name
 		r, _ := glyphsnames.GlyphToRune(glyphName)
-		f.cmap[r] = fonts.GID(gid)
+		cmap[r] = fonts.GID(gid)
 	}
+	f.cmap = cmap
+}
+
+// SetCmap replaces the cmap of the font, used by `Cmap` and `NominalGlyph`.
+// It is mostly useful for CID-keyed fonts, which have no builtin Unicode mapping:
+// `cmap` should then map runes to CIDs, and is typically built from an external CMap.
+func (f *Font) SetCmap(cmap fonts.Cmap) {
+	cidCmapLock.Lock()
+	defer cidCmapLock.Unlock()
+	f.cmap = cmap
 }
 
 // loadGlyph returns the advance of the glyph with index `index`
@@ -179,11 +205,22 @@ func (f *Font) loadGlyph(index fonts.GID, inSeac bool) ([]fonts.Segment, ps.Path
 		return nil, ps.PathBounds{}, 0, errors.New("invalid glyph index")
 	}
 
+	subrs := f.subrs
+	if f.CIDSystemInfo != nil {
+		cs := f.charstrings[index]
+		if len(cs.data) == 0 {
+			return nil, ps.PathBounds{}, 0, errors.New("undefined CID")
+		}
+		// the font dicts may use different FontMatrix, which are
+		// currently ignored: only the first one is used (see Upem)
+		subrs = f.fontDicts[cs.fd].subrs
+	}
+
 	var (
 		psi    ps.Machine
 		parser type1CharstringParser
 	)
-	err := psi.Run(f.charstrings[index].data, f.subrs, nil, &parser)
+	err := psi.Run(f.charstrings[index].data, subrs, nil, &parser)
 	if err != nil {
 		return nil, ps.PathBounds{}, 0, err
 	}
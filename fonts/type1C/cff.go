@@ -9,8 +9,10 @@ import (
 	"io"
 	"io/ioutil"
 	"strings"
+	"sync"
 
 	"github.com/benoitkugler/textlayout/fonts"
+	"github.com/benoitkugler/textlayout/fonts/cmaps"
 	"github.com/benoitkugler/textlayout/fonts/glyphsnames"
 	"github.com/benoitkugler/textlayout/fonts/simpleencodings"
 )
@@ -34,7 +36,11 @@ type Font struct {
 	charset     []uint16 // indexed by glyph ID
 	Encoding    *simpleencodings.Encoding
 
-	cmap fonts.CmapSimple // see synthetizeCmap
+	cmap fonts.CmapSimple // see synthetizeCmap and cidCmap (lazily called by Cmap)
+
+	// CIDSystemInfo is only set for CIDFonts, which are
+	// identified by their Registry-Ordering-Supplement (ROS).
+	CIDSystemInfo *cmaps.CIDSystemInfo
 
 	cidFontName string
 	charstrings [][]byte // indexed by glyph ID
@@ -44,9 +50,19 @@ type Font struct {
 	// For CIDFonts, it can be safely indexed by `fdSelect` output
 	localSubrs [][][]byte
 	fonts.PSInfo
+
+	// VarStore is the raw Item Variation Store of CFF2 fonts, used by
+	// the 'blend' operator. It is empty for CFF fonts, and may be empty
+	// for non variable CFF2 fonts.
+	VarStore []byte
+
+	// CFF2 only
+	vsIndexes    []uint16 // default 'vsindex' for each Font DICT
+	regionCounts []uint16 // number of regions for each item variation data
+	isCFF2       bool
 }
 
-// Parse parse a .cff font file.
+// Parse parse a .cff font file, or a CFF2 table.
 // Although CFF enables multiple font or CIDFont programs to be bundled together in a
 // single file, embedded CFF font file in PDF or in TrueType/OpenType fonts
 // shall consist of exactly one font or CIDFont. Thus, this function
@@ -71,7 +87,8 @@ func parse(file fonts.Resource) ([]Font, error) {
 	// read 4 bytes to check if its a supported CFF file
 	var buf [4]byte
 	file.Read(buf[:])
-	if buf[0] != 1 || buf[1] != 0 || buf[2] != 4 {
+	isCFF2 := buf[0] == 2 && buf[1] == 0
+	if !isCFF2 && (buf[0] != 1 || buf[1] != 0 || buf[2] != 4) {
 		return nil, errUnsupportedCFFVersion
 	}
 	file.Seek(0, io.SeekStart)
@@ -83,6 +100,13 @@ func parse(file fonts.Resource) ([]Font, error) {
 		return nil, err
 	}
 	p := cffParser{src: input}
+	if isCFF2 {
+		font, err := p.parseCFF2()
+		if err != nil {
+			return nil, err
+		}
+		return []Font{font}, nil
+	}
 	p.skip(4)
 	return p.parse()
 }
@@ -98,7 +122,39 @@ func (f *Font) synthetizeCmap() {
 	}
 }
 
+// CIDFonts glyphs are identified by CIDs (stored in the charset), which
+// are mapped to Unicode using the predefined CMap of the character collection
+// (such as Adobe-Japan1-UCS2), if available (see cmaps.ResourceDirs).
+// Since loading the CMap is expensive, and not needed for CFF tables embedded
+// in OpenType fonts, this is only done on the first call to Cmap.
+func (f *Font) cidCmap() fonts.CmapSimple {
+	cidToUnicode, err := cmaps.PredefinedToUnicode(*f.CIDSystemInfo)
+	if err != nil { // no Unicode mapping is available
+		return fonts.CmapSimple{}
+	}
+	cidToGID := make(map[cmaps.CID]fonts.GID, len(f.charset))
+	for gid, cid := range f.charset {
+		if _, has := cidToGID[cmaps.CID(cid)]; !has {
+			cidToGID[cmaps.CID(cid)] = fonts.GID(gid)
+		}
+	}
+	return cmaps.UnicodeCmap(cidToUnicode, cidToGID)
+}
+
+// cidCmapLock guards the lazy initialization of the cmap of CIDFonts.
+// It is not stored in Font, which is copied by value.
+var cidCmapLock sync.Mutex
+
+// Cmap returns the Unicode cmap of the font. For CIDFonts,
+// it requires the predefined CMaps (see cmaps.ResourceDirs).
 func (f *Font) Cmap() (fonts.Cmap, fonts.CmapEncoding) {
+	if f.CIDSystemInfo != nil {
+		cidCmapLock.Lock()
+		defer cidCmapLock.Unlock()
+		if f.cmap == nil {
+			f.cmap = f.cidCmap()
+		}
+	}
 	return f.cmap, fonts.EncUnicode
 }
 
@@ -0,0 +1,145 @@
+package type1c
+
+import (
+	"errors"
+	"fmt"
+
+	ps "github.com/benoitkugler/textlayout/fonts/psinterpreter"
+)
+
+// CFF2 is the successor of CFF, used in variable OpenType fonts.
+// The main differences with CFF are :
+//	- the Name INDEX, the String INDEX, the charset and the encoding are removed
+//	- the Top DICT is stored directly after the header (not in an INDEX)
+//	- INDEX counts are stored on 32 bits
+//	- Font DICTs and FDSelect are used even for non CID fonts
+//	- the 'blend' and 'vsindex' operators provide variations, using
+//	an Item Variation Store
+//
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/cff2
+
+// parseCFF2 expects the whole input, header included.
+func (p *cffParser) parseCFF2() (out Font, err error) {
+	p.isCFF2 = true
+	out.isCFF2 = true
+
+	// header
+	buf, err := p.read(5)
+	if err != nil {
+		return out, err
+	}
+	headerSize, topDictLength := int32(buf[2]), int32(be.Uint16(buf[3:]))
+
+	if err = p.seek(headerSize); err != nil {
+		return out, err
+	}
+	buf, err = p.read(int(topDictLength))
+	if err != nil {
+		return out, err
+	}
+	var (
+		psi     ps.Machine
+		topDict topDictData
+	)
+	if err = psi.Run(buf, nil, nil, &topDict); err != nil {
+		return out, err
+	}
+
+	// the Global Subrs INDEX follows the Top DICT
+	out.globalSubrs, err = p.parseIndex()
+	if err != nil {
+		return out, err
+	}
+
+	if err = p.seek(topDict.charStringsOffset); err != nil {
+		return out, err
+	}
+	out.charstrings, err = p.parseIndex()
+	if err != nil {
+		return out, err
+	}
+	if len(out.charstrings) > 0xFFFF {
+		return out, fmt.Errorf("invalid number of glyphs in CFF2 table: %d", len(out.charstrings))
+	}
+	numGlyphs := uint16(len(out.charstrings))
+
+	if topDict.vstoreOffset != 0 {
+		out.VarStore, p.regionCounts, err = p.parseVariationStore(topDict.vstoreOffset)
+		if err != nil {
+			return out, err
+		}
+		out.regionCounts = p.regionCounts
+	}
+
+	// the FDArray is required in CFF2
+	if err = p.seek(topDict.fdArray); err != nil {
+		return out, err
+	}
+	fontDicts, err := p.parseTopDicts()
+	if err != nil {
+		return out, err
+	}
+	if len(fontDicts) == 0 {
+		return out, errors.New("missing Font DICT in CFF2 table")
+	}
+
+	// the FDSelect is only required when there are several Font DICTs
+	if topDict.fdSelect != 0 {
+		out.fdSelect, err = p.parseFDSelect(topDict.fdSelect, numGlyphs)
+		if err != nil {
+			return out, err
+		}
+		if indexExtent := out.fdSelect.extent(); len(fontDicts) < indexExtent {
+			return out, fmt.Errorf("invalid number of font dicts: %d (for %d)",
+				len(fontDicts), indexExtent)
+		}
+	} else if len(fontDicts) != 1 {
+		return out, errors.New("missing FDSelect in CFF2 table")
+	}
+
+	out.localSubrs = make([][][]byte, len(fontDicts))
+	out.vsIndexes = make([]uint16, len(fontDicts))
+	for i, fontDict := range fontDicts {
+		out.localSubrs[i], out.vsIndexes[i], err = p.parsePrivateDICT(fontDict.privateDictOffset, fontDict.privateDictLength)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+// parseVariationStore returns the Item Variation Store (without the
+// CFF2 length prefix) and the number of regions referenced
+// by each of its item variation data.
+func (p *cffParser) parseVariationStore(offset int32) ([]byte, []uint16, error) {
+	if err := p.seek(offset); err != nil {
+		return nil, nil, err
+	}
+	buf, err := p.read(2)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := p.read(int(be.Uint16(buf)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(store) < 8 {
+		return nil, nil, errors.New("invalid CFF2 variation store (EOF)")
+	}
+	// format and region list offset are ignored
+	count := int(be.Uint16(store[6:]))
+	if len(store) < 8+4*count {
+		return nil, nil, errors.New("invalid CFF2 variation store (EOF)")
+	}
+	regionCounts := make([]uint16, count)
+	for i := range regionCounts {
+		dataOffset := be.Uint32(store[8+4*i:])
+		if len(store) < int(dataOffset)+6 {
+			return nil, nil, errors.New("invalid CFF2 item variation data (EOF)")
+		}
+		regionCounts[i] = be.Uint16(store[dataOffset+4:])
+	}
+	return store, regionCounts, nil
+}
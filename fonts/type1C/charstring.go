@@ -1,7 +1,9 @@
 package type1c
 
 import (
+	"errors"
 	"fmt"
+	"math"
 
 	"github.com/benoitkugler/textlayout/fonts"
 	ps "github.com/benoitkugler/textlayout/fonts/psinterpreter"
@@ -9,11 +11,27 @@ import (
 
 // LoadGlyph parses the glyph charstring to compute segments and path bounds.
 // It returns an error if the glyph is invalid or if decoding the charstring fails.
+// For CFF2 fonts, the default instance is used : see LoadGlyphVar to
+// select a variable instance.
 func (f *Font) LoadGlyph(glyph fonts.GID) ([]fonts.Segment, ps.PathBounds, error) {
+	return f.LoadGlyphVar(glyph, nil)
+}
+
+// BlendScalars returns the scalars to apply to each region
+// referenced by the item variation data at index `vsIndex`
+// in the font variation store (see Font.VarStore).
+// Missing scalars are interpreted as zero.
+type BlendScalars func(vsIndex uint16) []float32
+
+// LoadGlyphVar is the same as LoadGlyph, but resolves the 'blend'
+// operators found in CFF2 charstrings using `scalars`, which
+// are usually computed from the variable instance in use.
+// A nil `scalars` selects the default instance.
+func (f *Font) LoadGlyphVar(glyph fonts.GID, scalars BlendScalars) ([]fonts.Segment, ps.PathBounds, error) {
 	var (
 		psi    ps.Machine
 		loader type2CharstringHandler
-		index  byte = 0
+		index  uint16 = 0
 		err    error
 	)
 	if f.fdSelect != nil {
@@ -26,8 +44,18 @@ func (f *Font) LoadGlyph(glyph fonts.GID) ([]fonts.Segment, ps.PathBounds, error
 		return nil, ps.PathBounds{}, fmt.Errorf("invalid glyph index %d", glyph)
 	}
 
+	if f.isCFF2 {
+		loader.isCFF2 = true
+		loader.vsIndex = f.vsIndexes[index]
+		loader.regionCounts = f.regionCounts
+		loader.scalars = scalars
+	}
+
 	subrs := f.localSubrs[index]
 	err = psi.Run(f.charstrings[glyph], subrs, f.globalSubrs, &loader)
+	if f.isCFF2 { // there is no 'endchar' operator in CFF2
+		loader.cs.ClosePath()
+	}
 	return loader.cs.Segments, loader.cs.Bounds, err
 }
 
@@ -40,9 +68,20 @@ type type2CharstringHandler struct {
 	// `width` must be initialized to default width
 	nominalWidthX int32
 	width         int32
+
+	// CFF2 only
+	isCFF2       bool
+	vsIndex      uint16
+	regionCounts []uint16 // number of regions for each item variation data
+	scalars      BlendScalars
 }
 
-func (type2CharstringHandler) Context() ps.PsContext { return ps.Type2Charstring }
+func (met *type2CharstringHandler) Context() ps.PsContext {
+	if met.isCFF2 {
+		return ps.CFF2Charstring
+	}
+	return ps.Type2Charstring
+}
 
 func (met *type2CharstringHandler) Apply(op ps.PsOperator, state *ps.Machine) error {
 	var err error
@@ -60,6 +99,13 @@ func (met *type2CharstringHandler) Apply(op ps.PsOperator, state *ps.Machine) er
 			return ps.LocalSubr(state) // do not clear the arg stack
 		case 29: // callgsubr
 			return ps.GlobalSubr(state) // do not clear the arg stack
+		case 15: // vsindex (CFF2 only)
+			if state.ArgStack.Top < 1 {
+				return errors.New("invalid vsindex operator")
+			}
+			met.vsIndex = uint16(state.ArgStack.Pop())
+		case 16: // blend (CFF2 only)
+			return met.blend(state) // do not clear the arg stack
 		case 21: // rmoveto
 			if state.ArgStack.Top > 2 { // width is optional
 				met.width = met.nominalWidthX + state.ArgStack.Vals[0]
@@ -132,6 +178,41 @@ func (met *type2CharstringHandler) Apply(op ps.PsOperator, state *ps.Machine) er
 	return err
 }
 
+// blend replaces the default values and their deltas
+// by the values for the current variable instance.
+func (met *type2CharstringHandler) blend(state *ps.Machine) error {
+	if state.ArgStack.Top < 1 {
+		return errors.New("invalid blend operator (empty stack)")
+	}
+	if int(met.vsIndex) >= len(met.regionCounts) {
+		return fmt.Errorf("invalid vsindex %d in blend operator", met.vsIndex)
+	}
+	n := state.ArgStack.Pop()
+	k := int32(met.regionCounts[met.vsIndex])
+	if n < 0 || n > state.ArgStack.Top || state.ArgStack.Top < n*(k+1) {
+		return errors.New("invalid blend operator (invalid stack size)")
+	}
+
+	start := state.ArgStack.Top - n*(k+1)
+	if met.scalars != nil {
+		scalars := met.scalars(met.vsIndex)
+		if len(scalars) > int(k) {
+			scalars = scalars[:k]
+		}
+		deltas := state.ArgStack.Vals[start+n : state.ArgStack.Top]
+		for i := int32(0); i < n; i++ {
+			v := float64(state.ArgStack.Vals[start+i])
+			for j, scalar := range scalars {
+				v += float64(scalar) * float64(deltas[i*k+int32(j)])
+			}
+			state.ArgStack.Vals[start+i] = int32(math.Round(v))
+		}
+	}
+	// only keep the blended values
+	state.ArgStack.Top = start + n
+	return nil
+}
+
 // func (met *type2CharstringHandler) hstem(state *ps.Machine) {
 // 	met.hstemCount += state.ArgStack.Top / 2
 // }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"github.com/benoitkugler/textlayout/fonts"
+	"github.com/benoitkugler/textlayout/fonts/cmaps"
 	ps "github.com/benoitkugler/textlayout/fonts/psinterpreter"
 	"github.com/benoitkugler/textlayout/fonts/simpleencodings"
 )
@@ -84,6 +85,12 @@ func (u userStrings) getString(sid uint16) (string, error) {
 type cffParser struct {
 	src    []byte // whole input
 	offset int    // current position
+
+	// CFF2 tables use 32-bit INDEX counts
+	isCFF2 bool
+	// CFF2 only : number of regions of each item variation data,
+	// needed to interpret the 'blend' operator in Private DICTs
+	regionCounts []uint16
 }
 
 func (p *cffParser) parse() ([]Font, error) {
@@ -161,12 +168,17 @@ func (p *cffParser) parse() ([]Font, error) {
 		if !topDict.isCIDFont {
 			// Parse the Private DICT, whose location was found in the Top DICT.
 			var localSubrs [][]byte
-			localSubrs, err = p.parsePrivateDICT(topDict.privateDictOffset, topDict.privateDictLength)
+			localSubrs, _, err = p.parsePrivateDICT(topDict.privateDictOffset, topDict.privateDictLength)
 			if err != nil {
 				return nil, err
 			}
 			out[i].localSubrs = [][][]byte{localSubrs}
 		} else {
+			out[i].CIDSystemInfo, err = topDict.cidSystemInfo(strs)
+			if err != nil {
+				return nil, err
+			}
+
 			// Parse the Font Dict Select data, whose location was found in the Top
 			// DICT.
 			out[i].fdSelect, err = p.parseFDSelect(topDict.fdSelect, numGlyphs)
@@ -189,7 +201,7 @@ func (p *cffParser) parse() ([]Font, error) {
 			}
 			multiSubrs := make([][][]byte, len(topDicts))
 			for i, topDict := range topDicts {
-				multiSubrs[i], err = p.parsePrivateDICT(topDict.privateDictOffset, topDict.privateDictLength)
+				multiSubrs[i], _, err = p.parsePrivateDICT(topDict.privateDictOffset, topDict.privateDictLength)
 				if err != nil {
 					return nil, err
 				}
@@ -208,7 +220,7 @@ func (p *cffParser) parseTopDicts() ([]topDictData, error) {
 		return nil, err
 	}
 
-	out := make([]topDictData, len(instructions)) // guarded by uint16 max size
+	out := make([]topDictData, len(instructions)) // guarded by the INDEX length check
 	var psi ps.Machine
 	for i, buf := range instructions {
 		topDict := &out[i]
@@ -408,7 +420,7 @@ func (p *cffParser) parseEncoding(encodingOffset int32, numGlyphs uint16, charse
 
 // fdSelect holds a CFF font's Font Dict Select data.
 type fdSelect interface {
-	fontDictIndex(glyph fonts.GID) (byte, error)
+	fontDictIndex(glyph fonts.GID) (uint16, error)
 	// return the maximum index + 1 (it's the length of an array
 	// which can be safely indexed by the indexes)
 	extent() int
@@ -416,11 +428,11 @@ type fdSelect interface {
 
 type fdSelect0 []byte
 
-func (fds fdSelect0) fontDictIndex(glyph fonts.GID) (byte, error) {
+func (fds fdSelect0) fontDictIndex(glyph fonts.GID) (uint16, error) {
 	if int(glyph) >= len(fds) {
 		return 0, errors.New("invalid glyph index")
 	}
-	return fds[glyph], nil
+	return uint16(fds[glyph]), nil
 }
 
 func (fds fdSelect0) extent() int {
@@ -435,15 +447,16 @@ func (fds fdSelect0) extent() int {
 
 type range3 struct {
 	first fonts.GID
-	fd    byte
+	fd    uint16 // a byte for format 3, but an uint16 for format 4 (CFF2 only)
 }
 
+// fdSelect3 is used for both format 3 and 4
 type fdSelect3 struct {
 	ranges   []range3
 	sentinel fonts.GID // = numGlyphs
 }
 
-func (fds fdSelect3) fontDictIndex(x fonts.GID) (byte, error) {
+func (fds fdSelect3) fontDictIndex(x fonts.GID) (uint16, error) {
 	lo, hi := 0, len(fds.ranges)
 	for lo < hi {
 		i := (lo + hi) / 2
@@ -508,46 +521,69 @@ func (p *cffParser) parseFDSelect(offset int32, numGlyphs uint16) (fdSelect, err
 		for i := range out.ranges {
 			// 	buf holds the range [xlo, xhi).
 			out.ranges[i].first = fonts.GID(be.Uint16(p.src[p.offset+3*i:]))
-			out.ranges[i].fd = p.src[p.offset+3*i+2]
+			out.ranges[i].fd = uint16(p.src[p.offset+3*i+2])
+		}
+		return out, nil
+	case 4: // CFF2 only
+		if !p.isCFF2 {
+			break
+		}
+		buf, err = p.read(4)
+		if err != nil {
+			return nil, err
+		}
+		numRanges := be.Uint32(buf)
+		if uint64(len(p.src)) < uint64(p.offset)+6*uint64(numRanges)+4 {
+			return nil, errors.New("invalid FDSelect data")
+		}
+		out := fdSelect3{
+			sentinel: fonts.GID(numGlyphs),
+			ranges:   make([]range3, numRanges),
+		}
+		for i := range out.ranges {
+			// glyph indices are stored on 32 bits, but are actually bounded by numGlyphs
+			out.ranges[i].first = fonts.GID(be.Uint32(p.src[p.offset+6*i:]))
+			out.ranges[i].fd = be.Uint16(p.src[p.offset+6*i+4:])
 		}
 		return out, nil
 	}
 	return nil, errUnsupportedCFFFDSelectTable
 }
 
-// Parse Private DICT and the Local Subrs [Subroutines] INDEX
-func (p *cffParser) parsePrivateDICT(offset, length int32) ([][]byte, error) {
+// Parse Private DICT and the Local Subrs [Subroutines] INDEX.
+// For CFF2 fonts, the default 'vsindex' is also returned.
+func (p *cffParser) parsePrivateDICT(offset, length int32) ([][]byte, uint16, error) {
 	if length == 0 {
-		return nil, nil
+		return nil, 0, nil
 	}
 	if err := p.seek(offset); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	buf, err := p.read(int(length))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	var (
 		psi  ps.Machine
-		priv privateDict
+		priv = privateDict{isCFF2: p.isCFF2, regionCounts: p.regionCounts}
 	)
 	if err = psi.Run(buf, nil, nil, &priv); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if priv.subrsOffset == 0 {
-		return nil, nil
+		return nil, priv.vsIndex, nil
 	}
 
 	// "The local subrs offset is relative to the beginning of the Private DICT data"
 	if err = p.seek(offset + priv.subrsOffset); err != nil {
-		return nil, errors.New("invalid local subroutines offset")
+		return nil, 0, errors.New("invalid local subroutines offset")
 	}
 	subrs, err := p.parseIndex()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return subrs, nil
+	return subrs, priv.vsIndex, nil
 }
 
 // read returns the n bytes from p.offset and advances p.offset by n.
@@ -591,19 +627,27 @@ func bigEndian(b []byte) uint32 {
 	panic("unreachable")
 }
 
-func (p *cffParser) parseIndexHeader() (count uint16, offSize int32, err error) {
-	buf, err := p.read(2)
-	if err != nil {
-		return 0, 0, err
+func (p *cffParser) parseIndexHeader() (count uint32, offSize int32, err error) {
+	if p.isCFF2 {
+		buf, err := p.read(4)
+		if err != nil {
+			return 0, 0, err
+		}
+		count = be.Uint32(buf)
+	} else {
+		buf, err := p.read(2)
+		if err != nil {
+			return 0, 0, err
+		}
+		count = uint32(be.Uint16(buf))
 	}
-	count = be.Uint16(buf)
 	// 5176.CFF.pdf section 5 "INDEX Data" says that "An empty INDEX is
 	// represented by a count field with a 0 value and no additional fields.
 	// Thus, the total size of an empty INDEX is 2 bytes".
 	if count == 0 {
 		return count, 0, nil
 	}
-	buf, err = p.read(1)
+	buf, err := p.read(1)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -611,6 +655,10 @@ func (p *cffParser) parseIndexHeader() (count uint16, offSize int32, err error)
 	if offSize < 1 || 4 < offSize {
 		return 0, 0, fmt.Errorf("invalid offset size %d", offSize)
 	}
+	// check the offset array length before allocating it
+	if uint64(len(p.src)-p.offset) < (uint64(count)+1)*uint64(offSize) {
+		return 0, 0, errors.New("invalid CFF font file (EOF)")
+	}
 	return count, offSize, nil
 }
 
@@ -670,9 +718,25 @@ type topDictData struct {
 	fdArray                                            int32
 	fdSelect                                           int32
 	isCIDFont                                          bool
+	registry, ordering                                 uint16 // SIDs of the ROS, for CIDFonts
+	supplement                                         int32
 	cidFontName                                        uint16
 	privateDictOffset                                  int32
 	privateDictLength                                  int32
+	vstoreOffset                                       int32 // CFF2 only
+}
+
+// resolve the strings of the ROS
+func (topDict topDictData) cidSystemInfo(strs userStrings) (*cmaps.CIDSystemInfo, error) {
+	registry, err := strs.getString(topDict.registry)
+	if err != nil {
+		return nil, err
+	}
+	ordering, err := strs.getString(topDict.ordering)
+	if err != nil {
+		return nil, err
+	}
+	return &cmaps.CIDSystemInfo{Registry: registry, Ordering: ordering, Supplement: int(topDict.supplement)}, nil
 }
 
 // resolve the strings
@@ -786,6 +850,11 @@ var topDictOperators = [2][]topDictOperator{
 			t.privateDictOffset = s.ArgStack.Vals[s.ArgStack.Top-1]
 			return nil
 		}, +2 /*Private*/},
+		24: {func(t *topDictData, s *ps.Machine) error {
+			t.vstoreOffset = s.ArgStack.Vals[s.ArgStack.Top-1]
+			return nil
+		}, +1 /*vstore (CFF2 only)*/},
+		25: {topDictNoOp, +1 /*maxstack (CFF2 only)*/},
 	},
 	// 2-byte operators. The first byte is the escape byte.
 	{
@@ -819,8 +888,11 @@ var topDictOperators = [2][]topDictOperator{
 		21: {topDictNoOp, +1 /*PostScript*/},
 		22: {topDictNoOp, +1 /*BaseFontName*/},
 		23: {topDictNoOp, -2 /*BaseFontBlend*/},
-		30: {func(t *topDictData, _ *ps.Machine) error {
+		30: {func(t *topDictData, s *ps.Machine) error {
 			t.isCIDFont = true
+			t.registry = uint16(s.ArgStack.Vals[s.ArgStack.Top-3])
+			t.ordering = uint16(s.ArgStack.Vals[s.ArgStack.Top-2])
+			t.supplement = s.ArgStack.Vals[s.ArgStack.Top-1]
 			return nil
 		}, +3 /*ROS*/},
 		31: {topDictNoOp, +1 /*CIDFontVersion*/},
@@ -847,9 +919,19 @@ var topDictOperators = [2][]topDictOperator{
 type privateDict struct {
 	subrsOffset                  int32
 	defaultWidthX, nominalWidthX int32
+
+	// CFF2 only
+	isCFF2       bool // input
+	vsIndex      uint16
+	regionCounts []uint16 // input
 }
 
-func (privateDict) Context() ps.PsContext { return ps.PrivateDict }
+func (priv *privateDict) Context() ps.PsContext {
+	if priv.isCFF2 {
+		return ps.CFF2PrivateDict
+	}
+	return ps.PrivateDict
+}
 
 // The Private DICT operators are defined by 5176.CFF.pdf Table 23 "Private
 // DICT Operators".
@@ -878,6 +960,14 @@ func (priv *privateDict) Apply(op ps.PsOperator, state *ps.Machine) error {
 			}
 			priv.subrsOffset = state.ArgStack.Vals[state.ArgStack.Top-1]
 			return state.ArgStack.PopN(1)
+		case 22: // "vsindex" (CFF2 only)
+			if state.ArgStack.Top < 1 {
+				return errors.New("invalid stack size for 'vsindex' in private Dict charstring")
+			}
+			priv.vsIndex = uint16(state.ArgStack.Vals[state.ArgStack.Top-1])
+			return state.ArgStack.PopN(1)
+		case 23: // "blend" (CFF2 only)
+			return priv.blend(state)
 		}
 	} else { // 2-byte operators. The first byte is the escape byte.
 		switch op.Operator {
@@ -889,3 +979,21 @@ func (priv *privateDict) Apply(op ps.PsOperator, state *ps.Machine) error {
 	}
 	return errors.New("invalid operand in private Dict charstring")
 }
+
+// blend only keeps the default values, since the
+// hinting values of the Private DICT are ignored anyway.
+func (priv *privateDict) blend(state *ps.Machine) error {
+	if state.ArgStack.Top < 1 {
+		return errors.New("invalid stack size for 'blend' in private Dict charstring")
+	}
+	if int(priv.vsIndex) >= len(priv.regionCounts) {
+		return fmt.Errorf("invalid 'vsindex' %d in private Dict charstring", priv.vsIndex)
+	}
+	n := state.ArgStack.Pop()
+	k := int32(priv.regionCounts[priv.vsIndex])
+	if n < 0 || n > state.ArgStack.Top || state.ArgStack.Top < n*(k+1) {
+		return errors.New("invalid stack size for 'blend' in private Dict charstring")
+	}
+	// remove the deltas
+	return state.ArgStack.PopN(n * k)
+}
@@ -7,9 +7,11 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/benoitkugler/textlayout/fonts"
+	"github.com/benoitkugler/textlayout/fonts/cmaps"
 )
 
 func TestParseCFF(t *testing.T) {
@@ -112,3 +114,52 @@ func TestCIDFont(t *testing.T) {
 	}
 	fmt.Println(len(font.localSubrs))
 }
+
+func TestCIDFontCmap(t *testing.T) {
+	// provide a (fake) predefined CMap for the Adobe-Identity collection
+	dir := t.TempDir()
+	cmap := `/CMapName /Adobe-Identity-UCS2 def
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+1 beginbfrange <0001> <0003> <0041> endbfrange
+1 beginbfchar <0100> <3042> endbfchar`
+	if err := ioutil.WriteFile(filepath.Join(dir, "Adobe-Identity-UCS2"), []byte(cmap), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	saved := cmaps.ResourceDirs
+	cmaps.ResourceDirs = []string{dir}
+	defer func() { cmaps.ResourceDirs = saved }()
+
+	b, err := ioutil.ReadFile("test/FDArrayTest257.cff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (cmaps.CIDSystemInfo{Registry: "Adobe", Ordering: "Identity"}); *font.CIDSystemInfo != exp {
+		t.Fatalf("expected %v, got %v", exp, *font.CIDSystemInfo)
+	}
+	if font.cmap != nil {
+		t.Fatal("the CMap should only be loaded when needed")
+	}
+	// the lazy loading is safe for concurrent use
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			font.Cmap()
+		}()
+	}
+	wg.Wait()
+	cmapUnicode, _ := font.Cmap()
+	for r, exp := range map[rune]fonts.GID{'A': 1, 'C': 3, 'あ': 256} {
+		if gid, ok := cmapUnicode.Lookup(r); !ok || gid != exp {
+			t.Fatalf("rune %c: expected %d, got %d", r, exp, gid)
+		}
+	}
+	if _, ok := cmapUnicode.Lookup('D'); ok {
+		t.Fatal("unexpected glyph for 'D'")
+	}
+}
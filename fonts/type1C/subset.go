@@ -0,0 +1,566 @@
+package type1c
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+// DICT operators whose operands are offsets, which must be
+// updated when the font is subsetted.
+const (
+	opCharset     = 15
+	opEncoding    = 16
+	opCharStrings = 17
+	opPrivate     = 18
+	opSubrs       = 19
+	opFDArray     = 12<<8 | 36
+	opFDSelect    = 12<<8 | 37
+)
+
+// endchar, used for the glyphs removed when glyph indices are retained
+var emptyCharstring = []byte{14}
+
+// Subset returns a CFF font program containing only the glyphs `glyphs`,
+// which must be sorted and start with the .notdef glyph 0.
+// The input `cff` must contain exactly one font, and CFF2 is not supported.
+//
+// If `retainGIDs` is false, the glyphs are renumbered in the order of `glyphs`.
+// Otherwise, the glyphs keep their indices, and the glyphs not in `glyphs`
+// (up to the last one) are replaced by empty glyphs.
+//
+// Subroutines are kept as they are, so that charstrings don't need to
+// be rewritten.
+func Subset(cff []byte, glyphs []fonts.GID, retainGIDs bool) ([]byte, error) {
+	if len(cff) < 4 || cff[0] != 1 {
+		return nil, errUnsupportedCFFVersion
+	}
+	if len(glyphs) == 0 || glyphs[0] != 0 {
+		return nil, errors.New("missing .notdef glyph in CFF subset")
+	}
+
+	p := cffParser{src: cff}
+	if err := p.seek(int32(cff[2])); err != nil { // header size
+		return nil, err
+	}
+	names, err := p.parseRawIndex()
+	if err != nil {
+		return nil, err
+	}
+	topDicts, err := p.parseIndex()
+	if err != nil {
+		return nil, err
+	}
+	if len(topDicts) != 1 {
+		return nil, errors.New("only one CFF font is allowed in embedded files")
+	}
+	strs, err := p.parseRawIndex()
+	if err != nil {
+		return nil, err
+	}
+	globalSubrs, err := p.parseRawIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	// fetch the data referenced by the Top DICT
+	topDict, err := splitDict(topDicts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid Top DICT: %s", err)
+	}
+	top, err := parseDict(topDicts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.seek(top.charStringsOffset); err != nil {
+		return nil, err
+	}
+	charstrings, err := p.parseIndex()
+	if err != nil {
+		return nil, err
+	}
+	numGlyphs := uint16(len(charstrings))
+	for _, g := range glyphs {
+		if int(g) >= len(charstrings) {
+			return nil, fmt.Errorf("invalid glyph index %d", g)
+		}
+	}
+	charset, err := p.parseCharset(top.charsetOffset, numGlyphs)
+	if err != nil {
+		return nil, err
+	}
+
+	// build the new list of glyphs, with their index in the input font
+	var oldGlyphs []fonts.GID // new GID -> old GID
+	if retainGIDs {
+		oldGlyphs = make([]fonts.GID, glyphs[len(glyphs)-1]+1)
+		for i := range oldGlyphs {
+			oldGlyphs[i] = fonts.GID(i)
+		}
+	} else {
+		oldGlyphs = glyphs
+	}
+	kept := make(map[fonts.GID]bool, len(glyphs))
+	for _, g := range glyphs {
+		kept[g] = true
+	}
+
+	newCharstrings := make([][]byte, len(oldGlyphs))
+	newCharset := make([]byte, 1, 1+2*len(oldGlyphs)) // format 0
+	for i, g := range oldGlyphs {
+		if kept[g] {
+			newCharstrings[i] = charstrings[g]
+		} else {
+			newCharstrings[i] = emptyCharstring
+		}
+		if i == 0 { // .notdef is implicit
+			continue
+		}
+		var sid uint16
+		if int(g) < len(charset) {
+			sid = charset[g]
+		}
+		newCharset = append(newCharset, byte(sid>>8), byte(sid))
+	}
+
+	var (
+		privates   []privateData // one for non CID fonts
+		fdSelectFn fdSelect
+		fdArray    [][]dictEntry
+	)
+	if !top.isCIDFont {
+		priv, err := p.parsePrivateData(top.privateDictOffset, top.privateDictLength)
+		if err != nil {
+			return nil, err
+		}
+		privates = []privateData{priv}
+	} else {
+		fdSelectFn, err = p.parseFDSelect(top.fdSelect, numGlyphs)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.seek(top.fdArray); err != nil {
+			return nil, err
+		}
+		fontDicts, err := p.parseIndex()
+		if err != nil {
+			return nil, err
+		}
+		for _, fontDict := range fontDicts {
+			entries, err := splitDict(fontDict)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Font DICT: %s", err)
+			}
+			fd, err := parseDict(fontDict)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := p.parsePrivateData(fd.privateDictOffset, fd.privateDictLength)
+			if err != nil {
+				return nil, err
+			}
+			fdArray = append(fdArray, entries)
+			privates = append(privates, priv)
+		}
+	}
+
+	// the encoding is not used in OpenType fonts, and a custom one
+	// would reference removed glyphs: use the standard encoding instead
+	topDict = removeDictOperator(topDict, opEncoding)
+
+	// layout: header, Name INDEX, Top DICT INDEX, String INDEX, Global Subrs INDEX,
+	// charset, [FDSelect], CharStrings INDEX, [FDArray INDEX], Private DICTs and Subrs
+	var newFDSelect []byte
+	if top.isCIDFont {
+		newFDSelect, err = buildFDSelect3(oldGlyphs, fdSelectFn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	charstringsIndex := appendIndex(nil, newCharstrings)
+
+	// Top DICT entries are written with fixed size integers,
+	// so that the offsets may be computed beforehand
+	topDictSize := len(encodeTopDict(topDict, 0, 0, 0, 0, 0, 0, top.isCIDFont))
+	offset := 4 + len(names) + indexSize([]int{topDictSize}) + len(strs) + len(globalSubrs)
+	charsetOffset := offset
+	offset += len(newCharset)
+	fdSelectOffset := offset
+	offset += len(newFDSelect)
+	charstringsOffset := offset
+	offset += len(charstringsIndex)
+
+	privateBlocks := make([][]byte, len(privates))
+	privateOffsets := make([]int, len(privates))
+	privateSizes := make([]int, len(privates))
+	var fdArrayIndex []byte
+	if top.isCIDFont {
+		// the Font DICTs have a fixed size
+		fdSizes := make([]int, len(fdArray))
+		for i, fd := range fdArray {
+			fdSizes[i] = len(encodePrivateOperator(fd, 0, 0))
+		}
+		fdArrayOffset := offset
+		offset += indexSize(fdSizes)
+		newFontDicts := make([][]byte, len(fdArray))
+		for i, priv := range privates {
+			privateBlocks[i], privateSizes[i] = priv.encode()
+			privateOffsets[i] = offset
+			offset += len(privateBlocks[i])
+			newFontDicts[i] = encodePrivateOperator(fdArray[i], privateSizes[i], privateOffsets[i])
+		}
+		fdArrayIndex = appendIndex(nil, newFontDicts)
+		topDictBytes := encodeTopDict(topDict, charsetOffset, charstringsOffset, 0, 0, fdArrayOffset, fdSelectOffset, true)
+		return assembleCFF(names, topDictBytes, strs, globalSubrs, newCharset, newFDSelect, charstringsIndex, fdArrayIndex, privateBlocks), nil
+	}
+
+	privateBlocks[0], privateSizes[0] = privates[0].encode()
+	privateOffsets[0] = offset
+	topDictBytes := encodeTopDict(topDict, charsetOffset, charstringsOffset, privateSizes[0], privateOffsets[0], 0, 0, false)
+	return assembleCFF(names, topDictBytes, strs, globalSubrs, newCharset, nil, charstringsIndex, nil, privateBlocks), nil
+}
+
+func assembleCFF(names, topDict, strs, globalSubrs, charset, fdSelect, charstrings, fdArray []byte, privates [][]byte) []byte {
+	out := []byte{1, 0, 4, 4} // version 1.0, header size, offSize
+	out = append(out, names...)
+	out = appendIndex(out, [][]byte{topDict})
+	out = append(out, strs...)
+	out = append(out, globalSubrs...)
+	out = append(out, charset...)
+	out = append(out, fdSelect...)
+	out = append(out, charstrings...)
+	out = append(out, fdArray...)
+	for _, priv := range privates {
+		out = append(out, priv...)
+	}
+	return out
+}
+
+// parseDict interprets a Top DICT or a Font DICT.
+func parseDict(dict []byte) (topDictData, error) {
+	// reuse the INDEX based parser on a standalone buffer
+	p := cffParser{src: appendIndex(nil, [][]byte{dict})}
+	out, err := p.parseTopDicts()
+	if err != nil {
+		return topDictData{}, err
+	}
+	return out[0], nil
+}
+
+// parseRawIndex returns the binary form of the INDEX starting at the current position.
+func (p *cffParser) parseRawIndex() ([]byte, error) {
+	start := p.offset
+	count, offSize, err := p.parseIndexHeader()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return p.src[start:p.offset], nil
+	}
+	locations := make([]uint32, int(count)+1)
+	if err = p.parseIndexLocations(locations, offSize); err != nil {
+		return nil, err
+	}
+	end := int(locations[count])
+	if end > len(p.src) {
+		return nil, errors.New("invalid CFF font file (EOF)")
+	}
+	p.offset = end
+	return p.src[start:end], nil
+}
+
+// privateData stores a Private DICT and its local subroutines
+type privateData struct {
+	dict  []dictEntry
+	subrs []byte // binary INDEX, may be empty
+}
+
+func (p *cffParser) parsePrivateData(offset, length int32) (out privateData, err error) {
+	if length == 0 {
+		return out, nil
+	}
+	if err = p.seek(offset); err != nil {
+		return out, err
+	}
+	buf, err := p.read(int(length))
+	if err != nil {
+		return out, err
+	}
+	out.dict, err = splitDict(buf)
+	if err != nil {
+		return out, fmt.Errorf("invalid Private DICT: %s", err)
+	}
+	for _, entry := range out.dict {
+		if entry.op != opSubrs {
+			continue
+		}
+		subrsOffset, err := entry.lastInt()
+		if err != nil {
+			return out, err
+		}
+		// "The local subrs offset is relative to the beginning of the Private DICT data"
+		if err = p.seek(offset + subrsOffset); err != nil {
+			return out, errors.New("invalid local subroutines offset")
+		}
+		out.subrs, err = p.parseRawIndex()
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// encode returns the Private DICT followed by the local subroutines,
+// and the size of the DICT.
+func (priv privateData) encode() ([]byte, int) {
+	dict := removeDictOperator(priv.dict, opSubrs)
+	var out []byte
+	for _, entry := range dict {
+		out = entry.appendTo(out)
+	}
+	if len(priv.subrs) == 0 {
+		return out, len(out)
+	}
+	// the subroutines are stored right after the DICT
+	size := len(out) + 6
+	out = appendDictInt(out, int32(size))
+	out = append(out, opSubrs)
+	return append(out, priv.subrs...), size
+}
+
+// dictEntry is an operator with its operands, in binary form
+type dictEntry struct {
+	operands []byte
+	op       uint16 // escaped operators are stored as 12<<8 | b1
+}
+
+func (entry dictEntry) appendTo(dst []byte) []byte {
+	dst = append(dst, entry.operands...)
+	if entry.op>>8 == 12 {
+		return append(dst, 12, byte(entry.op))
+	}
+	return append(dst, byte(entry.op))
+}
+
+// lastInt returns the last operand of the entry, which must be an integer.
+func (entry dictEntry) lastInt() (int32, error) {
+	var (
+		value int32
+		found bool
+	)
+	for ops := entry.operands; len(ops) != 0; {
+		n, _ := operandLength(ops)
+		switch b0 := ops[0]; {
+		case b0 == 28:
+			value, found = int32(int16(binary.BigEndian.Uint16(ops[1:]))), true
+		case b0 == 29:
+			value, found = int32(binary.BigEndian.Uint32(ops[1:])), true
+		case 32 <= b0 && b0 <= 246:
+			value, found = int32(b0)-139, true
+		case 247 <= b0 && b0 <= 250:
+			value, found = (int32(b0)-247)*256+int32(ops[1])+108, true
+		case 251 <= b0 && b0 <= 254:
+			value, found = -(int32(b0)-251)*256-int32(ops[1])-108, true
+		default:
+			found = false
+		}
+		ops = ops[n:]
+	}
+	if !found {
+		return 0, errors.New("invalid DICT operand")
+	}
+	return value, nil
+}
+
+// operandLength returns the length of the operand starting `ops`
+func operandLength(ops []byte) (int, error) {
+	switch b0 := ops[0]; {
+	case b0 == 28:
+		if len(ops) < 3 {
+			return 0, errors.New("invalid DICT operand (EOF)")
+		}
+		return 3, nil
+	case b0 == 29:
+		if len(ops) < 5 {
+			return 0, errors.New("invalid DICT operand (EOF)")
+		}
+		return 5, nil
+	case b0 == 30: // real number, ended by a 0xf nibble
+		for i := 1; i < len(ops); i++ {
+			if ops[i]&0x0f == 0x0f || ops[i]>>4 == 0x0f {
+				return i + 1, nil
+			}
+		}
+		return 0, errors.New("invalid DICT operand (EOF)")
+	case 32 <= b0 && b0 <= 246:
+		return 1, nil
+	case 247 <= b0 && b0 <= 254:
+		if len(ops) < 2 {
+			return 0, errors.New("invalid DICT operand (EOF)")
+		}
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid DICT operand %d", b0)
+	}
+}
+
+// splitDict returns the entries of a DICT, without interpreting them.
+func splitDict(data []byte) ([]dictEntry, error) {
+	var (
+		out   []dictEntry
+		start int
+	)
+	for i := 0; i < len(data); {
+		b0 := data[i]
+		if b0 <= 21 { // operator
+			entry := dictEntry{operands: data[start:i], op: uint16(b0)}
+			i++
+			if b0 == 12 {
+				if i >= len(data) {
+					return nil, errors.New("invalid DICT operator (EOF)")
+				}
+				entry.op = 12<<8 | uint16(data[i])
+				i++
+			}
+			out = append(out, entry)
+			start = i
+			continue
+		}
+		n, err := operandLength(data[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+	}
+	return out, nil
+}
+
+func removeDictOperator(dict []dictEntry, op uint16) []dictEntry {
+	out := make([]dictEntry, 0, len(dict))
+	for _, entry := range dict {
+		if entry.op != op {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// appendDictInt appends `v` using the 5 bytes integer form.
+func appendDictInt(dst []byte, v int32) []byte {
+	return append(dst, 29, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// encodeTopDict writes the Top DICT, replacing the operators holding offsets
+func encodeTopDict(dict []dictEntry, charset, charstrings, privateSize, privateOffset, fdArray, fdSelect int, isCID bool) []byte {
+	var out []byte
+	for _, entry := range dict {
+		switch entry.op {
+		case opCharset, opCharStrings, opPrivate, opFDArray, opFDSelect:
+			continue // written at the end
+		}
+		out = entry.appendTo(out)
+	}
+	out = appendDictInt(out, int32(charset))
+	out = append(out, opCharset)
+	out = appendDictInt(out, int32(charstrings))
+	out = append(out, opCharStrings)
+	if isCID {
+		out = appendDictInt(out, int32(fdArray))
+		out = dictEntry{op: opFDArray}.appendTo(out)
+		out = appendDictInt(out, int32(fdSelect))
+		out = dictEntry{op: opFDSelect}.appendTo(out)
+	} else {
+		out = appendDictInt(out, int32(privateSize))
+		out = appendDictInt(out, int32(privateOffset))
+		out = append(out, opPrivate)
+	}
+	return out
+}
+
+// encodePrivateOperator writes the Font DICT `dict`, replacing the Private operator
+func encodePrivateOperator(dict []dictEntry, privateSize, privateOffset int) []byte {
+	var out []byte
+	for _, entry := range removeDictOperator(dict, opPrivate) {
+		out = entry.appendTo(out)
+	}
+	out = appendDictInt(out, int32(privateSize))
+	out = appendDictInt(out, int32(privateOffset))
+	return append(out, opPrivate)
+}
+
+// buildFDSelect3 returns a FDSelect table with format 3
+func buildFDSelect3(oldGlyphs []fonts.GID, fds fdSelect) ([]byte, error) {
+	out := []byte{3, 0, 0} // format, numRanges (set below)
+	numRanges, lastFd := 0, -1
+	for i, g := range oldGlyphs {
+		fd, err := fds.fontDictIndex(g)
+		if err != nil {
+			return nil, err
+		}
+		if int(fd) != lastFd {
+			out = append(out, byte(i>>8), byte(i), byte(fd))
+			numRanges++
+			lastFd = int(fd)
+		}
+	}
+	binary.BigEndian.PutUint16(out[1:], uint16(numRanges))
+	sentinel := len(oldGlyphs)
+	return append(out, byte(sentinel>>8), byte(sentinel)), nil
+}
+
+// offSizeFor returns the number of bytes needed to store `maxOffset`
+func offSizeFor(maxOffset int) int {
+	switch {
+	case maxOffset <= math.MaxUint8:
+		return 1
+	case maxOffset <= math.MaxUint16:
+		return 2
+	case maxOffset <= 1<<24-1:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// indexSize returns the size of an INDEX storing items with the given lengths
+func indexSize(lengths []int) int {
+	if len(lengths) == 0 {
+		return 2
+	}
+	total := 0
+	for _, l := range lengths {
+		total += l
+	}
+	return 3 + (len(lengths)+1)*offSizeFor(total+1) + total
+}
+
+// appendIndex appends the INDEX built from `items`
+func appendIndex(dst []byte, items [][]byte) []byte {
+	dst = append(dst, byte(len(items)>>8), byte(len(items)))
+	if len(items) == 0 {
+		return dst
+	}
+	total := 0
+	for _, item := range items {
+		total += len(item)
+	}
+	offSize := offSizeFor(total + 1)
+	dst = append(dst, byte(offSize))
+	offset := uint32(1)
+	var buf [4]byte
+	for i := 0; i <= len(items); i++ {
+		binary.BigEndian.PutUint32(buf[:], offset)
+		dst = append(dst, buf[4-offSize:]...)
+		if i < len(items) {
+			offset += uint32(len(items[i]))
+		}
+	}
+	for _, item := range items {
+		dst = append(dst, item...)
+	}
+	return dst
+}
@@ -0,0 +1,90 @@
+package type1c
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+)
+
+func TestSubset(t *testing.T) {
+	files := []string{
+		"test/AAAPKB+SourceSansPro-Bold.cff",
+		"test/YPTQCA+CMR17.cff",
+	}
+	ttfs, err := ioutil.ReadDir("test/ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range ttfs {
+		files = append(files, filepath.Join("test/ttf", f.Name()))
+	}
+
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		font, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		numGlyphs := fonts.GID(len(font.charstrings))
+		glyphs := []fonts.GID{0}
+		for _, g := range []fonts.GID{2, 5, numGlyphs / 2, numGlyphs - 1} {
+			if g > glyphs[len(glyphs)-1] && g < numGlyphs {
+				glyphs = append(glyphs, g)
+			}
+		}
+
+		for _, retainGIDs := range []bool{false, true} {
+			subset, err := Subset(b, glyphs, retainGIDs)
+			if err != nil {
+				t.Fatalf("%s: %s", file, err)
+			}
+			if len(subset) >= len(b) && len(glyphs) < int(numGlyphs) {
+				t.Errorf("%s: subset is not smaller: %d >= %d", file, len(subset), len(b))
+			}
+			sub, err := Parse(bytes.NewReader(subset))
+			if err != nil {
+				t.Fatalf("%s: invalid subset: %s", file, err)
+			}
+			if sub.PSInfo != font.PSInfo {
+				t.Errorf("%s: expected %v, got %v", file, font.PSInfo, sub.PSInfo)
+			}
+
+			for i, g := range glyphs {
+				newGID := fonts.GID(i)
+				if retainGIDs {
+					newGID = g
+				}
+				if !bytes.Equal(sub.charstrings[newGID], font.charstrings[g]) {
+					t.Fatalf("%s: invalid charstring for glyph %d", file, g)
+				}
+				if sub.GlyphName(newGID) != font.GlyphName(g) {
+					t.Fatalf("%s: expected name %s, got %s", file, font.GlyphName(g), sub.GlyphName(newGID))
+				}
+				exp, _, err := font.LoadGlyph(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _, err := sub.LoadGlyph(newGID)
+				if err != nil {
+					t.Fatalf("%s: glyph %d: %s", file, g, err)
+				}
+				if len(exp) != len(got) {
+					t.Fatalf("%s: glyph %d: expected %d segments, got %d", file, g, len(exp), len(got))
+				}
+			}
+			if retainGIDs && len(sub.charstrings) != int(glyphs[len(glyphs)-1])+1 {
+				t.Fatalf("%s: unexpected number of glyphs %d", file, len(sub.charstrings))
+			}
+		}
+	}
+
+	if _, err = Subset([]byte{1, 0, 4, 4}, []fonts.GID{1}, false); err == nil {
+		t.Fatal("expected error for missing .notdef")
+	}
+}
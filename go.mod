@@ -3,6 +3,7 @@ module github.com/benoitkugler/textlayout
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/benoitkugler/pstokenizer v1.0.0
 	golang.org/x/image v0.0.0-20210504121937-7319ad40d33e
 	golang.org/x/net v0.0.0-20210510120150-4163338589ed
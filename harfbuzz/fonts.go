@@ -403,7 +403,7 @@ func (f *Font) GetOTGlyphClass(glyph fonts.GID) uint32 {
 }
 
 // GetOTLigatureCarets fetches a list of the caret positions defined for a ligature glyph in the GDEF
-// table of the font (or nil if not found).
+// table of the font, or in the AAT 'lcar' table (or nil if not found).
 func (f *Font) GetOTLigatureCarets(direction Direction, glyph fonts.GID) []Position {
 	if f.otTables == nil {
 		return nil
@@ -413,12 +413,12 @@ func (f *Font) GetOTLigatureCarets(direction Direction, glyph fonts.GID) []Posit
 
 	list := f.otTables.GDEF.LigatureCaretList
 	if list.Coverage == nil {
-		return nil
+		return f.getAATLigatureCarets(direction, glyph)
 	}
 
 	index, ok := list.Coverage.Index(glyph)
 	if !ok {
-		return nil
+		return f.getAATLigatureCarets(direction, glyph)
 	}
 
 	glyphCarets := list.LigCarets[index]
@@ -429,6 +429,84 @@ func (f *Font) GetOTLigatureCarets(direction Direction, glyph fonts.GID) []Posit
 	return out
 }
 
+// use the AAT 'lcar' table
+func (f *Font) getAATLigatureCarets(direction Direction, glyph fonts.GID) []Position {
+	lcar := f.otTables.Lcar
+	carets := lcar.GetCarets(glyph)
+	if carets == nil {
+		return nil
+	}
+	out := make([]Position, len(carets))
+	for i, c := range carets {
+		if lcar.IsControlPoints {
+			x, y, _ := f.getGlyphContourPointForOrigin(glyph, uint16(c), direction)
+			if direction.isHorizontal() {
+				out[i] = x
+			} else {
+				out[i] = y
+			}
+		} else if direction.isHorizontal() {
+			out[i] = f.emScaleX(c)
+		} else {
+			out[i] = f.emScaleY(c)
+		}
+	}
+	return out
+}
+
+// OpticalBounds stores the adjustments to apply to the edges
+// of the advance box of a glyph to obtain its optical edges,
+// used for instance to make punctuation hang into the margin.
+type OpticalBounds struct {
+	Left, Top, Right, Bottom Position
+}
+
+// GetOpticalBounds fetches the optical bounds defined for `glyph` in the AAT 'opbd'
+// table of the font, or returns false if not found.
+// Bounds defined by control points are resolved relatively to the edges of the
+// advance box.
+func (f *Font) GetOpticalBounds(glyph fonts.GID) (OpticalBounds, bool) {
+	if f.otTables == nil {
+		return OpticalBounds{}, false
+	}
+	opbd := f.otTables.Opbd
+	bounds, ok := opbd.GetBounds(glyph)
+	if !ok {
+		return OpticalBounds{}, false
+	}
+	if !opbd.IsControlPoints {
+		return OpticalBounds{
+			Left:   f.emScaleX(bounds.Left),
+			Top:    f.emScaleY(bounds.Top),
+			Right:  f.emScaleX(bounds.Right),
+			Bottom: f.emScaleY(bounds.Bottom),
+		}, true
+	}
+
+	// returns the coordinate of the point relative to `edge`,
+	// or 0 for invalid points
+	resolve := func(point int16, direction Direction, edge Position) Position {
+		if point < 0 {
+			return 0
+		}
+		x, y, ok := f.getGlyphContourPointForOrigin(glyph, uint16(point), direction)
+		if !ok {
+			return 0
+		}
+		if direction.isHorizontal() {
+			return x - edge
+		}
+		return y - edge
+	}
+	// the vertical advance is negative, going down from the top edge
+	return OpticalBounds{
+		Left:   resolve(bounds.Left, LeftToRight, 0),
+		Top:    resolve(bounds.Top, TopToBottom, 0),
+		Right:  resolve(bounds.Right, LeftToRight, f.GlyphHAdvance(glyph)),
+		Bottom: resolve(bounds.Bottom, TopToBottom, f.getGlyphVAdvance(glyph)),
+	}, true
+}
+
 // interpreted the CaretValue according to its format
 func (f *Font) getCaretValue(caret truetype.CaretValue, direction Direction, glyph fonts.GID, varStore truetype.VariationStore) Position {
 	switch caret := caret.(type) {
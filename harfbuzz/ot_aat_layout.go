@@ -1286,7 +1286,7 @@ func (dc *driverContextLigature) transition(driver stateTableDriver, entry tt.AA
 			}
 			offset := int32(uoffset)
 			componentIdx := int32(buffer.cur(0).Glyph) + offset
-			if int(componentIdx) >= len(dc.table.Component) {
+			if componentIdx < 0 || int(componentIdx) >= len(dc.table.Component) {
 				break
 			}
 			componentData := dc.table.Component[componentIdx]
@@ -1458,12 +1458,11 @@ func aatLayoutFindFeatureMapping(tag tt.Tag) *aatFeatureMapping {
 }
 
 func (sp *otShapePlan) aatLayoutSubstitute(font *Font, buffer *Buffer) {
-	morx := font.otTables.Morx
+	morx := font.otTables.Morx // possibly converted from a 'mort' table
 	c := newAatApplyContext(sp, font, buffer)
 	for i, chain := range morx {
 		c.applyMorx(chain, c.plan.aatMap.chainFlags[i])
 	}
-	// TODO: we dont support obsolete 'mort' table
 }
 
 func aatLayoutZeroWidthDeletedGlyphs(buffer *Buffer) {
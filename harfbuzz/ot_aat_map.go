@@ -57,17 +57,11 @@ func (mb *aatMapBuilder) hasFeature(info aatFeatureInfo) bool {
 }
 
 func (mb *aatMapBuilder) compileMap(map_ *aatMap) {
+	// a deprecated 'mort' table is also exposed as a 'morx' table
 	morx := mb.tables.Morx
 	for _, chain := range morx {
 		map_.chainFlags = append(map_.chainFlags, mb.compileMorxFlag(chain))
 	}
-
-	// TODO: for now we dont support deprecated mort table
-	// mort := mapper.face.table.mort
-	// if mort.has_data() {
-	// 	mort.compile_flags(mapper, map_)
-	// 	return
-	// }
 }
 
 func (mb *aatMapBuilder) compileMorxFlag(chain tt.MorxChain) GlyphMask {
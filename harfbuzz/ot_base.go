@@ -0,0 +1,221 @@
+package harfbuzz
+
+import (
+	"github.com/benoitkugler/textlayout/fonts"
+	tt "github.com/benoitkugler/textlayout/fonts/truetype"
+	"github.com/benoitkugler/textlayout/language"
+)
+
+// ported from harfbuzz/src/hb-ot-layout.cc, hb-ot-layout-base-table.hh Copyright © 2016 Elie Roux, Ebrahim Byagowi
+
+// Baseline tags, as defined in the OpenType registry.
+var (
+	// The baseline used by alphabetic scripts such as Latin, Cyrillic and Greek.
+	BaselineRoman = tt.NewTag('r', 'o', 'm', 'n')
+	// The hanging baseline, used by Indic scripts such as Devanagari and Bengali.
+	BaselineHanging = tt.NewTag('h', 'a', 'n', 'g')
+	// The bottom (horizontal) or left (vertical) edge of the ideographic character face.
+	BaselineIdeoFaceBottomOrLeft = tt.NewTag('i', 'c', 'f', 'b')
+	// The top (horizontal) or right (vertical) edge of the ideographic character face.
+	BaselineIdeoFaceTopOrRight = tt.NewTag('i', 'c', 'f', 't')
+	// The center of the ideographic character face.
+	BaselineIdeoFaceCentral = tt.NewTag('I', 'c', 'f', 'c')
+	// The bottom (horizontal) or left (vertical) edge of the ideographic em-box.
+	BaselineIdeoEmboxBottomOrLeft = tt.NewTag('i', 'd', 'e', 'o')
+	// The top (horizontal) or right (vertical) edge of the ideographic em-box.
+	BaselineIdeoEmboxTopOrRight = tt.NewTag('i', 'd', 't', 'p')
+	// The center of the ideographic em-box.
+	BaselineIdeoEmboxCentral = tt.NewTag('I', 'd', 'e', 'o')
+	// The baseline about which mathematical characters are centered.
+	BaselineMath = tt.NewTag('m', 'a', 't', 'h')
+)
+
+// returns the tags used to select the BASE records
+func baseTagsFromScriptAndLanguage(script language.Script, lang language.Language) (scriptTag, languageTag tt.Tag) {
+	scriptTags, languageTags := NewOTTagsFromScriptAndLanguage(script, lang)
+	scriptTag, languageTag = tagDefaultScript, tagDefaultLanguage
+	if len(scriptTags) != 0 {
+		scriptTag = scriptTags[len(scriptTags)-1]
+	}
+	if len(languageTags) != 0 {
+		languageTag = languageTags[len(languageTags)-1]
+	}
+	return scriptTag, languageTag
+}
+
+func (f *Font) baseAxis(direction Direction) *tt.BaseAxis {
+	if f.otTables == nil {
+		return nil
+	}
+	if direction.isHorizontal() {
+		return &f.otTables.BASE.Horizontal
+	}
+	return &f.otTables.BASE.Vertical
+}
+
+// baseCoord scales `coord`, which is measured along the line height:
+// the Y axis for horizontal text, the X axis for vertical text.
+func (f *Font) baseCoord(coord tt.BaseCoord, direction Direction) Position {
+	horizontal := direction.isHorizontal()
+	switch coord := coord.(type) {
+	case tt.BaseCoordFormat1:
+		if horizontal {
+			return f.emScaleY(int16(coord))
+		}
+		return f.emScaleX(int16(coord))
+	case tt.BaseCoordFormat2:
+		if x, y, ok := f.getGlyphContourPointForOrigin(coord.ReferenceGlyph, coord.BaseCoordPoint, direction); ok {
+			if horizontal {
+				return y
+			}
+			return x
+		}
+		if horizontal {
+			return f.emScaleY(coord.Coordinate)
+		}
+		return f.emScaleX(coord.Coordinate)
+	case tt.BaseCoordFormat3:
+		varStore := f.otTables.BASE.VariationStore
+		if horizontal {
+			return f.emScaleY(coord.Coordinate) + f.getYDelta(varStore, coord.Device)
+		}
+		return f.emScaleX(coord.Coordinate) + f.getXDelta(varStore, coord.Device)
+	default:
+		return 0
+	}
+}
+
+func (f *Font) getOTBaseline(baseline tt.Tag, direction Direction, scriptTag tt.Tag) (Position, bool) {
+	axis := f.baseAxis(direction)
+	if axis == nil {
+		return 0, false
+	}
+	coord, ok := axis.Baseline(baseline, scriptTag)
+	if !ok || coord == nil {
+		return 0, false
+	}
+	return f.baseCoord(coord, direction), true
+}
+
+// GetOTBaseline fetches the position of the given `baseline` from the 'BASE' table,
+// for the given text `direction`, `script` and `lang`.
+// The position is measured along the Y axis for horizontal directions,
+// and along the X axis for vertical ones.
+// It returns false if the font has no such baseline.
+func (f *Font) GetOTBaseline(baseline tt.Tag, direction Direction, script language.Script, lang language.Language) (Position, bool) {
+	scriptTag, _ := baseTagsFromScriptAndLanguage(script, lang)
+	return f.getOTBaseline(baseline, direction, scriptTag)
+}
+
+// GetOTBaselineWithFallback is the same as GetOTBaseline, but synthesizes
+// the baseline when it is not found in the font, using the font
+// extents and the glyphs extents, as suggested by
+// https://www.w3.org/TR/css-inline-3/#baseline-synthesis-fonts
+func (f *Font) GetOTBaselineWithFallback(baseline tt.Tag, direction Direction, script language.Script, lang language.Language) Position {
+	scriptTag, _ := baseTagsFromScriptAndLanguage(script, lang)
+	return f.getOTBaselineWithFallback(baseline, direction, script, scriptTag)
+}
+
+func (f *Font) getOTBaselineWithFallback(baseline tt.Tag, direction Direction, script language.Script, scriptTag tt.Tag) Position {
+	if coord, ok := f.getOTBaseline(baseline, direction, scriptTag); ok {
+		return coord
+	}
+
+	// the size of the em-box in the line height direction
+	emSize := f.XScale
+	if direction.isHorizontal() {
+		emSize = f.YScale
+	}
+
+	switch baseline {
+	case BaselineMath:
+		if direction.isHorizontal() {
+			glyph, ok := f.face.NominalGlyph(0x2212) // minus sign
+			if !ok {
+				glyph, ok = f.face.NominalGlyph('-')
+			}
+			if ok {
+				if extents, ok := f.GlyphExtents(glyph); ok {
+					return extents.YBearing + extents.Height/2
+				}
+			}
+		}
+		xHeight, ok := f.LineMetric(fonts.XHeight)
+		if !ok {
+			xHeight = f.YScale / 2
+		}
+		return xHeight / 2
+	case BaselineIdeoEmboxTopOrRight:
+		if coord, ok := f.getOTBaseline(BaselineIdeoEmboxBottomOrLeft, direction, scriptTag); ok {
+			return coord + emSize
+		}
+		return Position(f.ExtentsForDirection(direction).Ascender)
+	case BaselineIdeoEmboxBottomOrLeft:
+		if coord, ok := f.getOTBaseline(BaselineIdeoEmboxTopOrRight, direction, scriptTag); ok {
+			return coord - emSize
+		}
+		return Position(f.ExtentsForDirection(direction).Descender)
+	case BaselineIdeoFaceTopOrRight, BaselineIdeoFaceBottomOrLeft:
+		top := f.getOTBaselineWithFallback(BaselineIdeoEmboxTopOrRight, direction, script, scriptTag)
+		bottom := f.getOTBaselineWithFallback(BaselineIdeoEmboxBottomOrLeft, direction, script, scriptTag)
+		if baseline == BaselineIdeoFaceTopOrRight {
+			return top + (bottom-top)/10
+		}
+		return bottom + (top-bottom)/10
+	case BaselineIdeoEmboxCentral:
+		top := f.getOTBaselineWithFallback(BaselineIdeoEmboxTopOrRight, direction, script, scriptTag)
+		bottom := f.getOTBaselineWithFallback(BaselineIdeoEmboxBottomOrLeft, direction, script, scriptTag)
+		return (top + bottom) / 2
+	case BaselineIdeoFaceCentral:
+		top := f.getOTBaselineWithFallback(BaselineIdeoFaceTopOrRight, direction, script, scriptTag)
+		bottom := f.getOTBaselineWithFallback(BaselineIdeoFaceBottomOrLeft, direction, script, scriptTag)
+		return (top + bottom) / 2
+	case BaselineHanging:
+		if direction.isHorizontal() {
+			if ch, ok := hangingBaselineChars[script]; ok {
+				if glyph, ok := f.face.NominalGlyph(ch); ok {
+					if extents, ok := f.GlyphExtents(glyph); ok {
+						return extents.YBearing
+					}
+				}
+			}
+		}
+		return emSize * 6 / 10
+	default: // including BaselineRoman
+		return 0
+	}
+}
+
+// characters whose top is used to synthesize the hanging baseline
+var hangingBaselineChars = map[language.Script]rune{
+	language.Bengali:      0x0995,
+	language.Devanagari:   0x0915,
+	language.Gurmukhi:     0x0A15,
+	language.Tibetan:      0x0F40,
+	language.Limbu:        0x1901,
+	language.Syloti_Nagri: 0xA807,
+	language.Phags_Pa:     0xA840,
+	language.Samaritan:    0x0800,
+	language.Sharada:      0x11191,
+	language.Takri:        0x1168C,
+	language.Tirhuta:      0x1148F,
+	language.Newa:         0x1140C,
+	language.Marchen:      0x11C72,
+}
+
+// GetOTMinMaxExtents fetches the minimum and maximum extents, along the
+// line height, from the 'BASE' table, for the given text `direction`, `script` and `lang`.
+// If `feature` is not zero and has specific extents, they are used instead.
+// It returns false if the font does not provide both extents.
+func (f *Font) GetOTMinMaxExtents(direction Direction, script language.Script, lang language.Language, feature tt.Tag) (min, max Position, ok bool) {
+	axis := f.baseAxis(direction)
+	if axis == nil {
+		return 0, 0, false
+	}
+	scriptTag, languageTag := baseTagsFromScriptAndLanguage(script, lang)
+	minCoord, maxCoord := axis.MinMax(scriptTag, languageTag, feature)
+	if minCoord == nil || maxCoord == nil {
+		return 0, 0, false
+	}
+	return f.baseCoord(minCoord, direction), f.baseCoord(maxCoord, direction), true
+}
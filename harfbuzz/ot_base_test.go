@@ -0,0 +1,58 @@
+package harfbuzz
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/textlayout/language"
+)
+
+func TestOTBaseline(t *testing.T) {
+	font := NewFont(openFontFile("testdata/harfbuzz_reference/in-house/fonts/6991b13ce889466be6de3f66e891de2bc0f117ee.ttf"))
+
+	coord, ok := font.GetOTBaseline(BaselineIdeoEmboxBottomOrLeft, LeftToRight, language.Han, "")
+	assert(t, ok)
+	assertEqualInt32(t, coord, -120)
+	coord, ok = font.GetOTBaseline(BaselineIdeoFaceTopOrRight, LeftToRight, language.Latin, "")
+	assert(t, ok)
+	assertEqualInt32(t, coord, 834)
+	coord, ok = font.GetOTBaseline(BaselineRoman, TopToBottom, language.Latin, "")
+	assert(t, ok)
+	assertEqualInt32(t, coord, 120)
+	_, ok = font.GetOTBaseline(BaselineHanging, LeftToRight, language.Devanagari, "")
+	assert(t, !ok)
+
+	// baselines are derived from the defined ones
+	assertEqualInt32(t, font.GetOTBaselineWithFallback(BaselineIdeoEmboxTopOrRight, LeftToRight, language.Han, ""), 880)
+	assertEqualInt32(t, font.GetOTBaselineWithFallback(BaselineIdeoEmboxCentral, LeftToRight, language.Han, ""), 380)
+	assertEqualInt32(t, font.GetOTBaselineWithFallback(BaselineIdeoFaceCentral, LeftToRight, language.Han, ""), 380)
+
+	_, _, ok = font.GetOTMinMaxExtents(LeftToRight, language.Han, "", 0)
+	assert(t, !ok)
+
+	font.YScale = 2 * font.faceUpem
+	coord, _ = font.GetOTBaseline(BaselineIdeoEmboxBottomOrLeft, LeftToRight, language.Han, "")
+	assertEqualInt32(t, coord, -240)
+}
+
+func TestOTBaselineFallback(t *testing.T) {
+	// no 'BASE' table : ascender 800, descender -200
+	font := NewFont(openFontFile("testdata/fonts/cv01.otf"))
+
+	_, ok := font.GetOTBaseline(BaselineRoman, LeftToRight, language.Latin, "")
+	assert(t, !ok)
+
+	for _, test := range []struct {
+		got, expected Position
+	}{
+		{font.GetOTBaselineWithFallback(BaselineRoman, LeftToRight, language.Latin, ""), 0},
+		{font.GetOTBaselineWithFallback(BaselineIdeoEmboxTopOrRight, LeftToRight, language.Han, ""), 800},
+		{font.GetOTBaselineWithFallback(BaselineIdeoEmboxBottomOrLeft, LeftToRight, language.Han, ""), -200},
+		{font.GetOTBaselineWithFallback(BaselineIdeoFaceTopOrRight, LeftToRight, language.Han, ""), 700},
+		{font.GetOTBaselineWithFallback(BaselineIdeoFaceBottomOrLeft, LeftToRight, language.Han, ""), -100},
+		{font.GetOTBaselineWithFallback(BaselineIdeoEmboxCentral, LeftToRight, language.Han, ""), 300},
+		{font.GetOTBaselineWithFallback(BaselineHanging, LeftToRight, language.Devanagari, ""), 600},
+		{font.GetOTBaselineWithFallback(BaselineIdeoEmboxBottomOrLeft, TopToBottom, language.Han, ""), -500},
+	} {
+		assertEqualInt32(t, test.got, test.expected)
+	}
+}
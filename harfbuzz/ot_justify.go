@@ -0,0 +1,282 @@
+package harfbuzz
+
+import (
+	"sort"
+
+	tt "github.com/benoitkugler/textlayout/fonts/truetype"
+)
+
+// Justification is driven by the 'JSTF' table, which prescribes,
+// in order of priority, GSUB and GPOS lookups to enable or disable
+// to shrink or extend a line, and, for variable fonts, by the 'wdth' axis.
+
+// maximum number of shaping passes when varying the 'wdth' axis
+const maxJustifyIterations = 16
+
+var tagWidth = tt.NewTag('w', 'd', 't', 'h')
+
+// jstfLookups stores the lookups modifications, for GSUB and GPOS.
+type jstfLookups struct {
+	enable, disable [2][]uint16
+}
+
+func (jl jstfLookups) isEmpty() bool {
+	return len(jl.enable[0]) == 0 && len(jl.enable[1]) == 0 &&
+		len(jl.disable[0]) == 0 && len(jl.disable[1]) == 0
+}
+
+func containsLookup(lookups []uint16, index uint16) bool {
+	for _, l := range lookups {
+		if l == index {
+			return true
+		}
+	}
+	return false
+}
+
+func removeLookup(lookups []uint16, index uint16) []uint16 {
+	out := lookups[:0]
+	for _, l := range lookups {
+		if l != index {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func (jl *jstfLookups) add(tableIndex int, enable, disable []uint16) {
+	// the modifications of a priority override the previous ones
+	for _, index := range enable {
+		jl.disable[tableIndex] = removeLookup(jl.disable[tableIndex], index)
+		if !containsLookup(jl.enable[tableIndex], index) {
+			jl.enable[tableIndex] = append(jl.enable[tableIndex], index)
+		}
+	}
+	for _, index := range disable {
+		jl.enable[tableIndex] = removeLookup(jl.enable[tableIndex], index)
+		if !containsLookup(jl.disable[tableIndex], index) {
+			jl.disable[tableIndex] = append(jl.disable[tableIndex], index)
+		}
+	}
+}
+
+// addPriority adds the shrinkage or extension modifications of `priority`,
+// and returns false if there are none.
+func (jl *jstfLookups) addPriority(priority tt.JstfPriority, shrink bool) bool {
+	if shrink {
+		jl.add(0, priority.ShrinkageEnableGSUB, priority.ShrinkageDisableGSUB)
+		jl.add(1, priority.ShrinkageEnableGPOS, priority.ShrinkageDisableGPOS)
+		return len(priority.ShrinkageEnableGSUB) != 0 || len(priority.ShrinkageDisableGSUB) != 0 ||
+			len(priority.ShrinkageEnableGPOS) != 0 || len(priority.ShrinkageDisableGPOS) != 0
+	}
+	jl.add(0, priority.ExtensionEnableGSUB, priority.ExtensionDisableGSUB)
+	jl.add(1, priority.ExtensionEnableGPOS, priority.ExtensionDisableGPOS)
+	return len(priority.ExtensionEnableGSUB) != 0 || len(priority.ExtensionDisableGSUB) != 0 ||
+		len(priority.ExtensionEnableGPOS) != 0 || len(priority.ExtensionDisableGPOS) != 0
+}
+
+// withLookups returns a copy of the map, where the disabled lookups are removed,
+// and the enabled ones are added to the last stage, applying to all glyphs.
+// `lookupCounts` is used to ignore invalid indices.
+func (m *otMap) withLookups(lookups jstfLookups, lookupCounts [2]int) otMap {
+	out := *m
+	for tableIndex := range out.lookups {
+		enable, disable := lookups.enable[tableIndex], lookups.disable[tableIndex]
+		if len(enable) == 0 && len(disable) == 0 {
+			continue
+		}
+
+		var (
+			stages     = m.stages[tableIndex]
+			newLookups []lookupMap
+			newStages  = make([]stageMap, len(stages))
+			start      = 0
+		)
+		for i, stage := range stages {
+			stageStart := len(newLookups)
+			for _, l := range m.lookups[tableIndex][start:stage.lastLookup] {
+				if !containsLookup(disable, l.index) {
+					newLookups = append(newLookups, l)
+				}
+			}
+			start = stage.lastLookup
+
+			if i == len(stages)-1 {
+				for _, index := range enable {
+					if int(index) >= lookupCounts[tableIndex] || containsLookup(disable, index) || hasLookup(newLookups, index) {
+						continue
+					}
+					newLookups = append(newLookups, lookupMap{index: index, mask: globalBitMask, autoZWNJ: true, autoZWJ: true})
+				}
+				view := newLookups[stageStart:]
+				sort.Slice(view, func(i, j int) bool { return view[i].index < view[j].index })
+			}
+
+			newStages[i] = stageMap{lastLookup: len(newLookups), pauseFunc: stage.pauseFunc}
+		}
+		out.lookups[tableIndex], out.stages[tableIndex] = newLookups, newStages
+	}
+	return out
+}
+
+func hasLookup(lookups []lookupMap, index uint16) bool {
+	for _, l := range lookups {
+		if l.index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// shapeWithLookups is the same as Shape, but applies the
+// lookups modifications, if any.
+func (b *Buffer) shapeWithLookups(font *Font, features []Feature, lookups jstfLookups) {
+	plan := newShapePlanCached(font, b.Props, features, font.varCoords())
+	if ot, isOT := plan.shaper.(*shaperOpentype); isOT && !lookups.isEmpty() {
+		modified := *ot
+		modified.plan.map_ = ot.plan.map_.withLookups(lookups, [2]int{len(font.gsubAccels), len(font.gposAccels)})
+		modified.shape(font, b, features)
+		return
+	}
+	plan.execute(font, b, features)
+}
+
+// returns the total advance of the shaped buffer, in the
+// text direction
+func (b *Buffer) advance() Position {
+	var out Position
+	if b.Props.Direction.isHorizontal() {
+		for _, pos := range b.Pos {
+			out += pos.XAdvance
+		}
+	} else {
+		for _, pos := range b.Pos {
+			out -= pos.YAdvance
+		}
+	}
+	return out
+}
+
+// jstfLangSys returns the justification suggestions for the
+// script and language of `props`, or an empty value.
+func (f *Font) jstfLangSys(props SegmentProperties) tt.JstfLangSys {
+	if f.otTables == nil {
+		return tt.JstfLangSys{}
+	}
+	scriptTags, languageTags := NewOTTagsFromScriptAndLanguage(props.Script, props.Language)
+	for _, scriptTag := range scriptTags {
+		script, ok := f.otTables.JSTF.FindScript(scriptTag)
+		if !ok {
+			continue
+		}
+		for _, languageTag := range languageTags {
+			if langSys := script.FindLanguage(languageTag); langSys.Tag == languageTag {
+				return langSys
+			}
+		}
+		return script.DefaultLangSys
+	}
+	return tt.JstfLangSys{}
+}
+
+// ShapeJustify shapes the buffer, as `Shape` does, then tries to bring
+// its total advance (in the direction of the text) between `minWidth` and `maxWidth`
+// (in font units, scaled).
+//
+// To do so, the suggestions of the font 'JSTF' table are applied in order of priority,
+// enabling or disabling GSUB and GPOS lookups, until the line is short (or long)
+// enough. Then, for variable fonts with a 'wdth' axis, the width variation is adjusted.
+// In this case, the variation coordinates of `font` are modified, so that the
+// returned glyphs may be rendered with it.
+//
+// The returned `width` is the total advance of the shaped buffer, and `ok` is false if
+// it is not in the target range (in which case the buffer is shaped with the
+// last attempt).
+// Note that the maximum adjustments provided by the JstfMax lookups are not enforced.
+func (b *Buffer) ShapeJustify(font *Font, features []Feature, minWidth, maxWidth Position) (width Position, ok bool) {
+	// save the input, used for each shaping pass
+	input := append([]GlyphInfo(nil), b.Info...)
+	props := b.Props
+	var lookups jstfLookups
+	shape := func() Position {
+		b.Info = append(b.Info[:0], input...)
+		b.Props = props
+		b.shapeWithLookups(font, features, lookups)
+		return b.advance()
+	}
+	inRange := func(width Position) bool { return minWidth <= width && width <= maxWidth }
+
+	width = shape()
+	if inRange(width) {
+		return width, true
+	}
+
+	// apply the JSTF suggestions
+	shrink := width > maxWidth
+	for _, priority := range font.jstfLangSys(props).Priorities {
+		if !lookups.addPriority(priority, shrink) {
+			continue
+		}
+		width = shape()
+		if inRange(width) {
+			return width, true
+		}
+		if shrink && width < minWidth || !shrink && width > maxWidth {
+			break // too far : use the width axis to adjust
+		}
+	}
+
+	// vary the width axis
+	varFace, isVariable := font.face.(FaceOpentype)
+	if !isVariable {
+		return width, false
+	}
+	axisIndex := -1
+	for i, axis := range varFace.Variations().Axis {
+		if axis.Tag == tagWidth {
+			axisIndex = i
+			break
+		}
+	}
+	if axisIndex == -1 {
+		return width, false
+	}
+	coords := make([]float32, len(varFace.Variations().Axis))
+	copy(coords, varFace.VarCoordinates())
+	setWidth := func(value float32) Position {
+		coords[axisIndex] = value
+		varFace.SetVarCoordinates(append([]float32(nil), coords...))
+		return shape()
+	}
+
+	// the advance is expected to increase with the width axis value
+	shrink = width > maxWidth
+	low, high := coords[axisIndex], float32(1)
+	extreme := high
+	if shrink {
+		low, high = -1, coords[axisIndex]
+		extreme = low
+	}
+	width = setWidth(extreme)
+	if inRange(width) {
+		return width, true
+	}
+	if shrink && width > maxWidth || !shrink && width < minWidth {
+		return width, false // the target can't be reached
+	}
+
+	// the target is now between low and high
+	for i := 0; i < maxJustifyIterations; i++ {
+		mid := (low + high) / 2
+		width = setWidth(mid)
+		if inRange(width) {
+			return width, true
+		}
+		if width > maxWidth {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return width, false
+}
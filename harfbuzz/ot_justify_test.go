@@ -0,0 +1,82 @@
+package harfbuzz
+
+import (
+	"testing"
+
+	"github.com/benoitkugler/textlayout/language"
+)
+
+// JstfTest.ttf is a variable font (with a 'wdth' axis, whose default is the minimum)
+// with a synthetic 'JSTF' table : see fonts/truetype/table_jstf_test.go
+
+func newJustifyBuffer() *Buffer {
+	b := NewBuffer()
+	b.AddRunes([]rune("سلام بر شما"), 0, -1)
+	b.Props = SegmentProperties{Direction: RightToLeft, Script: language.Arabic, Language: language.NewLanguage("ar")}
+	return b
+}
+
+func TestShapeWithLookups(t *testing.T) {
+	font := NewFont(openFontFile("../fonts/truetype/testdata/JstfTest.ttf"))
+
+	b := newJustifyBuffer()
+	b.shapeWithLookups(font, nil, jstfLookups{})
+	assert(t, len(b.Info) == 10)
+	assertEqualInt32(t, b.advance(), 9688)
+
+	// adds a kashida
+	b = newJustifyBuffer()
+	b.shapeWithLookups(font, nil, jstfLookups{enable: [2][]uint16{{5}}})
+	assert(t, len(b.Info) == 11 && b.Info[5].Glyph == 310)
+	assertEqualInt32(t, b.advance(), 9688+120)
+
+	// disables the lam-alef ligature
+	b = newJustifyBuffer()
+	b.shapeWithLookups(font, nil, jstfLookups{disable: [2][]uint16{{3, 4}}})
+	assert(t, len(b.Info) == 11)
+
+	// invalid lookups are ignored
+	b = newJustifyBuffer()
+	b.shapeWithLookups(font, nil, jstfLookups{enable: [2][]uint16{{500}, {500}}})
+	assert(t, len(b.Info) == 10)
+}
+
+func TestShapeJustify(t *testing.T) {
+	font := NewFont(openFontFile("../fonts/truetype/testdata/JstfTest.ttf"))
+	face := font.face.(FaceOpentype)
+
+	for _, test := range []struct {
+		min, max Position
+		ok       bool
+		glyphs   int
+	}{
+		{0, 10000, true, 10},      // natural width
+		{9700, 9900, true, 11},    // with the JSTF lookups
+		{11400, 11500, true, 11},  // with the width axis
+		{30000, 31000, false, 11}, // too long
+		{0, 1000, false, 10},      // too short
+	} {
+		face.SetVarCoordinates(nil)
+		b := newJustifyBuffer()
+		width, ok := b.ShapeJustify(font, nil, test.min, test.max)
+		assert(t, ok == test.ok)
+		assert(t, len(b.Info) == test.glyphs)
+		assertEqualInt32(t, width, b.advance())
+		if ok {
+			assert(t, test.min <= width && width <= test.max)
+		}
+	}
+
+	// the width axis is kept to the justified value
+	face.SetVarCoordinates(nil)
+	_, ok := newJustifyBuffer().ShapeJustify(font, nil, 11400, 11500)
+	assert(t, ok && face.VarCoordinates()[1] > 0)
+
+	// no JSTF table and no variations
+	font = NewFont(openFontFile("testdata/fonts/cv01.otf"))
+	b := NewBuffer()
+	b.AddRunes([]rune("abc"), 0, -1)
+	b.Props = SegmentProperties{Direction: LeftToRight, Script: language.Latin}
+	width, ok := b.ShapeJustify(font, nil, 0, 1)
+	assert(t, !ok && width > 1)
+}
@@ -0,0 +1,349 @@
+package harfbuzz
+
+import (
+	"github.com/benoitkugler/textlayout/fonts"
+	tt "github.com/benoitkugler/textlayout/fonts/truetype"
+)
+
+// ported from harfbuzz/src/hb-ot-math.cc, hb-ot-math-table.hh Copyright © 2016  Igalia S.L.
+
+// MathGlyphVariant is a size variant of a glyph,
+// whose advance is measured in the stretch direction.
+type MathGlyphVariant struct {
+	Glyph   fonts.GID
+	Advance Position
+}
+
+// MathGlyphPart is a part of a glyph assembly, with scaled dimensions.
+type MathGlyphPart struct {
+	Glyph                fonts.GID
+	StartConnectorLength Position
+	EndConnectorLength   Position
+	FullAdvance          Position
+	IsExtender           bool
+}
+
+// MathAssemblyGlyph is a glyph of a stretched glyph, with its offset
+// in the stretch direction.
+type MathAssemblyGlyph struct {
+	Glyph  fonts.GID
+	Offset Position
+}
+
+// MathStretchedGlyph is a glyph stretched in one direction,
+// made either of a size variant or of the parts of a glyph assembly.
+type MathStretchedGlyph struct {
+	// Glyphs to draw, with their offsets measured from the left (horizontal direction)
+	// or the bottom (vertical direction) of the stretched glyph.
+	Glyphs []MathAssemblyGlyph
+	// Size of the stretched glyph, in the stretch direction
+	Advance           Position
+	ItalicsCorrection Position
+}
+
+func (f *Font) mathTable() *tt.TableMath {
+	if f.otTables == nil {
+		return nil
+	}
+	return &f.otTables.MATH
+}
+
+func (f *Font) mathValueX(value tt.MathValue) Position {
+	return f.emScaleX(value.Value) + f.getXDelta(f.otTables.GDEF.VariationStore, value.Device)
+}
+
+func (f *Font) mathValueY(value tt.MathValue) Position {
+	return f.emScaleY(value.Value) + f.getYDelta(f.otTables.GDEF.VariationStore, value.Device)
+}
+
+// HasOTMathData returns true if the font has a 'MATH' table.
+func (f *Font) HasOTMathData() bool {
+	math := f.mathTable()
+	if math == nil {
+		return false
+	}
+	info, variants := math.GlyphInfo, math.Variants
+	if info.ItalicsCorrection.Coverage != nil || info.TopAccentAttachment.Coverage != nil ||
+		info.ExtendedShapes != nil || info.Kerns.Coverage != nil ||
+		variants.Vertical.Coverage != nil || variants.Horizontal.Coverage != nil {
+		return true
+	}
+	for _, constant := range math.Constants {
+		if constant.Value != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOTMathConstant fetches the specified math constant, scaled according
+// to its direction. The percent constants (see truetype.MathConstant.IsPercent)
+// are returned unscaled.
+// Zero is returned if the font has no 'MATH' table.
+func (f *Font) GetOTMathConstant(constant tt.MathConstant) Position {
+	math := f.mathTable()
+	if math == nil || int(constant) >= len(math.Constants) {
+		return 0
+	}
+	value := math.Constants[constant]
+	switch {
+	case constant.IsPercent():
+		return Position(value.Value)
+	case constant == tt.MathDelimitedSubFormulaMinHeight, constant == tt.MathDisplayOperatorMinHeight:
+		return f.emScaleDir(uint16(value.Value), TopToBottom) // unsigned values
+	case constant.IsHorizontal():
+		return f.mathValueX(value)
+	default:
+		return f.mathValueY(value)
+	}
+}
+
+// GetOTMathGlyphItalicsCorrection fetches the italics correction of the glyph,
+// or 0 if not found.
+func (f *Font) GetOTMathGlyphItalicsCorrection(glyph fonts.GID) Position {
+	math := f.mathTable()
+	if math == nil {
+		return 0
+	}
+	value, ok := math.GlyphInfo.ItalicsCorrection.Get(glyph)
+	if !ok {
+		return 0
+	}
+	return f.mathValueX(value)
+}
+
+// GetOTMathGlyphTopAccentAttachment fetches the horizontal position where an accent
+// should be attached to the glyph. If not found, half the advance of the glyph is returned.
+func (f *Font) GetOTMathGlyphTopAccentAttachment(glyph fonts.GID) Position {
+	if math := f.mathTable(); math != nil {
+		if value, ok := math.GlyphInfo.TopAccentAttachment.Get(glyph); ok {
+			return f.mathValueX(value)
+		}
+	}
+	return f.GlyphHAdvance(glyph) / 2
+}
+
+// IsOTMathGlyphExtendedShape returns true if the glyph is an extended shape.
+func (f *Font) IsOTMathGlyphExtendedShape(glyph fonts.GID) bool {
+	math := f.mathTable()
+	return math != nil && math.GlyphInfo.IsExtendedShape(glyph)
+}
+
+// GetOTMathGlyphKerning fetches the kerning for the `corner` of the glyph,
+// at the given height, or 0 if not found.
+func (f *Font) GetOTMathGlyphKerning(glyph fonts.GID, corner tt.MathKernCorner, correctionHeight Position) Position {
+	math := f.mathTable()
+	if math == nil {
+		return 0
+	}
+	kern, ok := math.GlyphInfo.Kerns.Get(glyph, corner)
+	if !ok {
+		return 0
+	}
+
+	// find the first height not lower than `correctionHeight`
+	i, count := 0, len(kern.CorrectionHeights)
+	for count > 0 {
+		half := count / 2
+		if f.mathValueY(kern.CorrectionHeights[i+half]) < correctionHeight {
+			i += half + 1
+			count -= half + 1
+		} else {
+			count = half
+		}
+	}
+	if i >= len(kern.KernValues) { // invalid table
+		return 0
+	}
+	return f.mathValueX(kern.KernValues[i])
+}
+
+func (f *Font) mathConstruction(glyph fonts.GID, direction Direction) (tt.MathGlyphConstruction, bool) {
+	math := f.mathTable()
+	if math == nil {
+		return tt.MathGlyphConstruction{}, false
+	}
+	if direction.isHorizontal() {
+		return math.Variants.Horizontal.Get(glyph)
+	}
+	return math.Variants.Vertical.Get(glyph)
+}
+
+// scale a value in the stretch direction
+func (f *Font) emScaleDir(v uint16, direction Direction) Position {
+	if direction.isHorizontal() {
+		return Position(v) * f.XScale / f.faceUpem
+	}
+	return Position(v) * f.YScale / f.faceUpem
+}
+
+// GetOTMathGlyphVariants fetches the size variants of the glyph,
+// for the given stretch direction, or nil if not found.
+func (f *Font) GetOTMathGlyphVariants(glyph fonts.GID, direction Direction) []MathGlyphVariant {
+	construction, ok := f.mathConstruction(glyph, direction)
+	if !ok || len(construction.Variants) == 0 {
+		return nil
+	}
+	out := make([]MathGlyphVariant, len(construction.Variants))
+	for i, variant := range construction.Variants {
+		out[i] = MathGlyphVariant{Glyph: variant.Glyph, Advance: f.emScaleDir(variant.AdvanceMeasurement, direction)}
+	}
+	return out
+}
+
+// GetOTMathMinConnectorOverlap fetches the minimum overlap between
+// two parts of a glyph assembly, in the given direction.
+func (f *Font) GetOTMathMinConnectorOverlap(direction Direction) Position {
+	math := f.mathTable()
+	if math == nil {
+		return 0
+	}
+	return f.emScaleDir(math.Variants.MinConnectorOverlap, direction)
+}
+
+// GetOTMathGlyphAssembly fetches the parts of the glyph assembly of the glyph,
+// for the given stretch direction, and its italics correction.
+// It returns nil if not found.
+func (f *Font) GetOTMathGlyphAssembly(glyph fonts.GID, direction Direction) (parts []MathGlyphPart, italicsCorrection Position) {
+	construction, ok := f.mathConstruction(glyph, direction)
+	if !ok || len(construction.Assembly.Parts) == 0 {
+		return nil, 0
+	}
+	assembly := construction.Assembly
+	parts = make([]MathGlyphPart, len(assembly.Parts))
+	for i, part := range assembly.Parts {
+		parts[i] = MathGlyphPart{
+			Glyph:                part.Glyph,
+			StartConnectorLength: f.emScaleDir(part.StartConnectorLength, direction),
+			EndConnectorLength:   f.emScaleDir(part.EndConnectorLength, direction),
+			FullAdvance:          f.emScaleDir(part.FullAdvance, direction),
+			IsExtender:           part.IsExtender(),
+		}
+	}
+	return parts, f.mathValueX(assembly.ItalicsCorrection)
+}
+
+// maximum number of repetitions of the extenders
+// of a glyph assembly
+const maxMathExtenderRepeats = 1 << 12
+
+// StretchOTMathGlyph returns the glyph to use to cover at least `size` in the
+// given direction : the first size variant big enough, or, if
+// none is found, the glyph assembly of the glyph, with its extenders repeated
+// and its parts overlapped so that the size is reached.
+// If the font has no suitable assembly, the largest variant (or the glyph itself) is returned.
+func (f *Font) StretchOTMathGlyph(glyph fonts.GID, direction Direction, size Position) MathStretchedGlyph {
+	variants := f.GetOTMathGlyphVariants(glyph, direction)
+	for _, variant := range variants {
+		if variant.Advance >= size {
+			return f.singleMathGlyph(variant)
+		}
+	}
+
+	parts, italicsCorrection := f.GetOTMathGlyphAssembly(glyph, direction)
+	if len(parts) == 0 {
+		if len(variants) != 0 {
+			return f.singleMathGlyph(variants[len(variants)-1])
+		}
+		variant := MathGlyphVariant{Glyph: glyph}
+		if direction.isHorizontal() {
+			variant.Advance = f.GlyphHAdvance(glyph)
+		} else if extents, ok := f.GlyphExtents(glyph); ok {
+			variant.Advance = -extents.Height
+		}
+		return f.singleMathGlyph(variant)
+	}
+
+	out := f.assembleMathGlyph(parts, direction, size)
+	out.ItalicsCorrection = italicsCorrection
+	return out
+}
+
+func (f *Font) singleMathGlyph(variant MathGlyphVariant) MathStretchedGlyph {
+	return MathStretchedGlyph{
+		Glyphs:            []MathAssemblyGlyph{{Glyph: variant.Glyph}},
+		Advance:           variant.Advance,
+		ItalicsCorrection: f.GetOTMathGlyphItalicsCorrection(variant.Glyph),
+	}
+}
+
+// assembleMathGlyph repeats the extenders the same number of times, and
+// use the same overlap between all the parts, as done by MathML Core.
+func (f *Font) assembleMathGlyph(parts []MathGlyphPart, direction Direction, size Position) MathStretchedGlyph {
+	minOverlap := f.GetOTMathMinConnectorOverlap(direction)
+
+	var (
+		nonExtenderCount, extenderCount     Position
+		nonExtenderAdvance, extenderAdvance Position
+	)
+	for _, part := range parts {
+		if part.IsExtender {
+			extenderCount++
+			extenderAdvance += part.FullAdvance
+		} else {
+			nonExtenderCount++
+			nonExtenderAdvance += part.FullAdvance
+		}
+	}
+
+	// the maximum size reached with r repetitions is
+	// nonExtenderAdvance + r * extenderAdvance - minOverlap * (nonExtenderCount + r * extenderCount - 1)
+	var repeats Position
+	if sizeByRepeat := extenderAdvance - minOverlap*extenderCount; sizeByRepeat > 0 {
+		if missing := size - (nonExtenderAdvance - minOverlap*(nonExtenderCount-1)); missing > 0 {
+			repeats = (missing + sizeByRepeat - 1) / sizeByRepeat
+		}
+		if repeats > maxMathExtenderRepeats {
+			repeats = maxMathExtenderRepeats
+		}
+	}
+
+	var assembly []MathGlyphPart
+	for _, part := range parts {
+		if !part.IsExtender {
+			assembly = append(assembly, part)
+			continue
+		}
+		for r := Position(0); r < repeats; r++ {
+			assembly = append(assembly, part)
+		}
+	}
+	if len(assembly) == 0 { // only extenders, with no repetition
+		assembly = parts[:1]
+	}
+
+	// choose the overlap, bounded by the connectors lengths
+	overlap := minOverlap
+	if len(assembly) > 1 {
+		maxOverlap := assembly[0].EndConnectorLength
+		fullAdvance := assembly[0].FullAdvance
+		for i, part := range assembly[1:] {
+			if connector := assembly[i].EndConnectorLength; connector < maxOverlap {
+				maxOverlap = connector
+			}
+			if connector := part.StartConnectorLength; connector < maxOverlap {
+				maxOverlap = connector
+			}
+			fullAdvance += part.FullAdvance
+		}
+		overlap = (fullAdvance - size) / Position(len(assembly)-1)
+		if overlap > maxOverlap {
+			overlap = maxOverlap
+		}
+		if overlap < minOverlap {
+			overlap = minOverlap
+		}
+	}
+
+	out := MathStretchedGlyph{Glyphs: make([]MathAssemblyGlyph, len(assembly))}
+	var offset Position
+	for i, part := range assembly {
+		if i != 0 {
+			offset -= overlap
+		}
+		out.Glyphs[i] = MathAssemblyGlyph{Glyph: part.Glyph, Offset: offset}
+		offset += part.FullAdvance
+	}
+	out.Advance = offset
+	return out
+}
@@ -0,0 +1,125 @@
+package harfbuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benoitkugler/textlayout/fonts"
+	tt "github.com/benoitkugler/textlayout/fonts/truetype"
+)
+
+// MathTest.ttf has a synthetic 'MATH' table : see fonts/truetype/table_math_test.go
+// for its content.
+
+func TestOTMathConstants(t *testing.T) {
+	font := NewFont(openFontFile("../fonts/truetype/testdata/MathTest.ttf"))
+	assert(t, font.HasOTMathData())
+	assert(t, !NewFont(openFontFile("testdata/fonts/cv01.otf")).HasOTMathData())
+
+	upem := font.faceUpem
+	font.XScale, font.YScale = 2*upem, 3*upem
+
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathScriptPercentScaleDown), 80)
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathRadicalDegreeBottomRaisePercent), 65)
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathDisplayOperatorMinHeight), 2000*3)
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathAxisHeight), 105*3)
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathSpaceAfterScript), (100+int32(tt.MathSpaceAfterScript))*2)
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathRadicalKernAfterDegree), 154*2)
+
+	// device table
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathMathLeading), 104*3)
+	font.YPpem = 11
+	assertEqualInt32(t, font.GetOTMathConstant(tt.MathMathLeading), 104*3+2*(font.YScale/11))
+}
+
+func TestOTMathGlyphInfo(t *testing.T) {
+	font := NewFont(openFontFile("../fonts/truetype/testdata/MathTest.ttf"))
+
+	assertEqualInt32(t, font.GetOTMathGlyphItalicsCorrection(2), 30)
+	assertEqualInt32(t, font.GetOTMathGlyphItalicsCorrection(5), -20)
+	assertEqualInt32(t, font.GetOTMathGlyphItalicsCorrection(6), 0)
+
+	assertEqualInt32(t, font.GetOTMathGlyphTopAccentAttachment(3), 250)
+	assertEqualInt32(t, font.GetOTMathGlyphTopAccentAttachment(6), font.GlyphHAdvance(6)/2)
+
+	assert(t, font.IsOTMathGlyphExtendedShape(10))
+	assert(t, !font.IsOTMathGlyphExtendedShape(2))
+
+	for _, test := range []struct {
+		height, kern Position
+	}{
+		{50, 10},
+		{100, 10},
+		{200, 20},
+		{300, 20},
+		{400, 30},
+	} {
+		assertEqualInt32(t, font.GetOTMathGlyphKerning(4, tt.MathKernTopRight, test.height), test.kern)
+	}
+	assertEqualInt32(t, font.GetOTMathGlyphKerning(4, tt.MathKernBottomLeft, 1000), -5)
+	assertEqualInt32(t, font.GetOTMathGlyphKerning(4, tt.MathKernTopLeft, 1000), 0)
+	assertEqualInt32(t, font.GetOTMathGlyphKerning(5, tt.MathKernTopRight, 1000), 0)
+}
+
+func TestOTMathVariants(t *testing.T) {
+	font := NewFont(openFontFile("../fonts/truetype/testdata/MathTest.ttf"))
+
+	variants := font.GetOTMathGlyphVariants(10, BottomToTop)
+	if exp := []MathGlyphVariant{{10, 500}, {11, 800}}; !reflect.DeepEqual(variants, exp) {
+		t.Fatalf("unexpected variants %v", variants)
+	}
+	assert(t, font.GetOTMathGlyphVariants(10, LeftToRight) == nil)
+	assertEqualInt32(t, font.GetOTMathMinConnectorOverlap(BottomToTop), 50)
+
+	parts, italicsCorrection := font.GetOTMathGlyphAssembly(10, BottomToTop)
+	assertEqualInt32(t, italicsCorrection, 7)
+	if exp := []MathGlyphPart{
+		{Glyph: 12, EndConnectorLength: 100, FullAdvance: 400},
+		{Glyph: 13, StartConnectorLength: 100, EndConnectorLength: 100, FullAdvance: 300, IsExtender: true},
+		{Glyph: 14, StartConnectorLength: 100, FullAdvance: 400},
+	}; !reflect.DeepEqual(parts, exp) {
+		t.Fatalf("unexpected parts %v", parts)
+	}
+	parts, _ = font.GetOTMathGlyphAssembly(20, LeftToRight)
+	assert(t, parts == nil)
+}
+
+func TestOTMathStretch(t *testing.T) {
+	font := NewFont(openFontFile("../fonts/truetype/testdata/MathTest.ttf"))
+
+	for _, test := range []struct {
+		glyph     fonts.GID
+		direction Direction
+		size      Position
+		expected  MathStretchedGlyph
+	}{
+		// size variants
+		{10, BottomToTop, 400, MathStretchedGlyph{Glyphs: []MathAssemblyGlyph{{10, 0}}, Advance: 500}},
+		{10, BottomToTop, 700, MathStretchedGlyph{Glyphs: []MathAssemblyGlyph{{11, 0}}, Advance: 800}},
+		// assembly, with the minimum overlap
+		{10, BottomToTop, 1000, MathStretchedGlyph{
+			Glyphs:  []MathAssemblyGlyph{{12, 0}, {13, 350}, {14, 600}},
+			Advance: 1000, ItalicsCorrection: 7,
+		}},
+		{10, BottomToTop, 1500, MathStretchedGlyph{
+			Glyphs:  []MathAssemblyGlyph{{12, 0}, {13, 350}, {13, 600}, {13, 850}, {14, 1100}},
+			Advance: 1500, ItalicsCorrection: 7,
+		}},
+		// assembly, with the overlap bounded by the connectors
+		{10, BottomToTop, 1010, MathStretchedGlyph{
+			Glyphs:  []MathAssemblyGlyph{{12, 0}, {13, 300}, {13, 500}, {14, 700}},
+			Advance: 1100, ItalicsCorrection: 7,
+		}},
+		// no assembly : the biggest variant is used
+		{20, LeftToRight, 1000, MathStretchedGlyph{Glyphs: []MathAssemblyGlyph{{20, 0}}, Advance: 300}},
+	} {
+		got := font.StretchOTMathGlyph(test.glyph, test.direction, test.size)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("stretching glyph %d to %d: expected %v, got %v", test.glyph, test.size, test.expected, got)
+		}
+	}
+
+	// glyph without construction
+	got := font.StretchOTMathGlyph(5, LeftToRight, 1000)
+	assert(t, len(got.Glyphs) == 1 && got.Glyphs[0].Glyph == 5 && got.Advance == font.GlyphHAdvance(5))
+}